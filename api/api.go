@@ -56,7 +56,7 @@ func (s *API) Handler() http.Handler {
 				r.Use(s.validateBucket)
 				r.Post("/cleanCache", webMessageWrapper("Reset bucket cache", func(ctx context.Context, _ struct{}) *kilonova.StatusError {
 					b := util.BucketContext(ctx)
-					if !b.Cache {
+					if !b.IsCache() {
 						return kilonova.Statusf(403, "Refusing to remove non-cache bucket")
 					}
 					if err := b.ResetCache(); err != nil {
@@ -67,7 +67,7 @@ func (s *API) Handler() http.Handler {
 				}))
 				r.Post("/evictObjects", webWrapper(func(ctx context.Context, _ struct{}) (string, *kilonova.StatusError) {
 					b := util.BucketContext(ctx)
-					if b.Persistent {
+					if b.IsPersistent() {
 						return "", kilonova.Statusf(403, "Refusing to remove important bucket")
 					}
 					s.base.LogUserAction(ctx, "Attempted running bucket eviction", slog.Any("bucket", b))
@@ -78,6 +78,42 @@ func (s *API) Handler() http.Handler {
 					}
 					return fmt.Sprintf("Deleted %d objects", numDeleted), nil
 				}))
+				r.Post("/deduplicate", webWrapper(func(ctx context.Context, _ struct{}) (string, *kilonova.StatusError) {
+					b, ok := util.BucketContext(ctx).(datastore.ContentAddressed)
+					if !ok {
+						return "", kilonova.Statusf(400, "Bucket is not content-addressed")
+					}
+					s.base.LogUserAction(ctx, "Deduplicated bucket files", slog.Any("bucket", util.BucketContext(ctx)))
+					numMigrated, err := b.MigrateFiles(context.WithoutCancel(ctx))
+					if err != nil {
+						slog.Warn("Could not deduplicate bucket files", slog.Any("bucket", util.BucketContext(ctx)), slog.Any("reason", err))
+						return "", kilonova.WrapError(err, "Could not deduplicate files")
+					}
+					return fmt.Sprintf("Deduplicated %d files", numMigrated), nil
+				}))
+				r.Post("/scrub", webWrapper(func(ctx context.Context, args struct {
+					DeleteOrphans bool `json:"delete_orphans"`
+				}) (*sudoapi.ScrubReport, *kilonova.StatusError) {
+					b := util.BucketContext(ctx)
+					if args.DeleteOrphans {
+						s.base.LogUserAction(ctx, "Deleting orphaned bucket files", slog.Any("bucket", b))
+					}
+					return s.base.StartScrub(ctx, b, args.DeleteOrphans)
+				}))
+				r.Get("/scrubReport", webWrapper(func(ctx context.Context, _ struct{}) (*sudoapi.ScrubReport, *kilonova.StatusError) {
+					report := s.base.ScrubReport(util.BucketContext(ctx).Name())
+					if report == nil {
+						return nil, kilonova.Statusf(404, "The bucket was never scrubbed")
+					}
+					return report, nil
+				}))
+				r.Post("/recompress", webWrapper(func(ctx context.Context, _ struct{}) (*sudoapi.RecompressionStatus, *kilonova.StatusError) {
+					s.base.LogUserAction(ctx, "Started bucket recompression", slog.Any("bucket", util.BucketContext(ctx)))
+					return s.base.StartRecompression(ctx, util.BucketContext(ctx))
+				}))
+				r.Post("/stopRecompression", webMessageWrapper("Stopped recompression", func(ctx context.Context, _ struct{}) *kilonova.StatusError {
+					return s.base.StopRecompression(util.BucketContext(ctx))
+				}))
 				r.Post("/stats", webWrapper(func(ctx context.Context, args struct {
 					Refresh bool `json:"refresh"`
 				}) (*datastore.BucketStats, *kilonova.StatusError) {
@@ -138,6 +174,8 @@ func (s *API) Handler() http.Handler {
 						r.Use(s.validateTestID)
 						r.Post("/data", s.saveTestData)
 						r.Post("/info", s.updateTestInfo)
+						r.Post("/inputFile", s.saveTestInputFile)
+						r.Post("/deleteInputFile", webMessageWrapper("Removed input file", s.deleteTestInputFile))
 						r.Post("/delete", webMessageWrapper("Removed test", s.deleteTest))
 					})
 
@@ -164,9 +202,27 @@ func (s *API) Handler() http.Handler {
 					r.Post("/bulkDeleteSubTasks", s.bulkDeleteSubTasks)
 				})
 
+				r.Post("/recommendTimeLimit", webWrapper(s.recommendTimeLimit))
+				r.Get("/timeLimitRecommendation", webWrapper(s.timeLimitRecommendation))
+
 				r.Post("/reevaluateSubs", webMessageWrapper("Reevaluating submissions", func(ctx context.Context, _ struct{}) *kilonova.StatusError {
 					return s.base.ResetProblemSubmissions(context.WithoutCancel(ctx), util.ProblemContext(ctx))
 				}))
+				r.Post("/reevaluateChangedTests", webWrapper(func(ctx context.Context, _ struct{}) (int, *kilonova.StatusError) {
+					return s.base.ReevaluateChangedTests(context.WithoutCancel(ctx), util.ProblemContext(ctx))
+				}))
+				r.Post("/reevaluateToolchain", webWrapper(func(ctx context.Context, args struct {
+					LanguageVersion *string `json:"language_version"`
+					SandboxVersion  *string `json:"sandbox_version"`
+				}) (int, *kilonova.StatusError) {
+					return s.base.ReevaluateToolchainSubmissions(context.WithoutCancel(ctx), util.ProblemContext(ctx), args.LanguageVersion, args.SandboxVersion)
+				}))
+				r.Get("/scoreChanges", webWrapper(func(ctx context.Context, _ struct{}) ([]*kilonova.SubmissionScoreChange, *kilonova.StatusError) {
+					return s.base.SubmissionScoreChanges(ctx, util.ProblemContext(ctx))
+				}))
+				r.Post("/cancelEvaluations", webMessageWrapper("Cancelled evaluations", func(ctx context.Context, _ struct{}) *kilonova.StatusError {
+					return s.base.CancelProblemEvaluations(context.WithoutCancel(ctx), util.ProblemContext(ctx))
+				}))
 
 				r.Post("/delete", s.deleteProblem)
 			})
@@ -242,9 +298,19 @@ func (s *API) Handler() http.Handler {
 
 				return s.base.ResetSubmission(context.WithoutCancel(ctx), util.SubmissionContext(ctx).ID)
 			}))
+			r.With(s.MustBeAuthed).Post("/cancel", webMessageWrapper("Cancelled evaluation", func(ctx context.Context, _ struct{}) *kilonova.StatusError {
+				// Check submission permissions
+				if !(util.UserBriefContext(ctx).Admin || util.SubmissionContext(ctx).ProblemEditor) {
+					return kilonova.Statusf(403, "You cannot cancel this submission!")
+				}
+
+				return s.base.CancelSubmission(context.WithoutCancel(ctx), &util.SubmissionContext(ctx).Submission)
+			}))
 		})
 
 		r.With(s.MustBeAuthed).Post("/submit", s.createSubmission)
+		r.With(s.MustBeAuthed).Post("/customRun", s.customRun)
+		r.With(s.MustBeAuthed).Get("/customRun", webWrapper(s.customRunStatus))
 	})
 	r.Route("/paste/{pasteID}", func(r chi.Router) {
 		r.Get("/", s.getPaste)
@@ -359,6 +425,11 @@ func (s *API) Handler() http.Handler {
 			r.With(s.validateContestEditor).Post("/updateAnnouncement", webMessageWrapper("Updated announcement", s.updateContestAnnouncement))
 			r.With(s.validateContestEditor).Post("/deleteAnnouncement", webMessageWrapper("Removed announcement", s.deleteContestAnnouncement))
 
+			r.Get("/hacks", webWrapper(s.contestHacks))
+			r.Get("/lockedProblems", webWrapper(s.contestLockedProblems))
+			r.With(s.validateContestParticipant).Post("/lockProblem", webMessageWrapper("Locked problem", s.lockContestProblem))
+			r.With(s.validateContestParticipant).Post("/hack", s.createHack)
+
 			r.With(s.MustBeAuthed).Post("/register", s.registerForContest)
 			r.With(s.MustBeAuthed).Post("/startRegistration", s.startContestRegistration)
 			r.With(s.validateContestEditor).Post("/runMOSS", webMessageWrapper("MOSS executed successfully", s.runMOSS))
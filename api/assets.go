@@ -12,13 +12,16 @@ import (
 	"math"
 	"net/http"
 	"path"
+	"slices"
 	"strconv"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
 
 	"github.com/KiloProjects/kilonova"
 	"github.com/KiloProjects/kilonova/archive/test"
+	"github.com/KiloProjects/kilonova/datastore"
 	"github.com/KiloProjects/kilonova/internal/util"
 	"github.com/KiloProjects/kilonova/sudoapi"
 	"github.com/Yiling-J/theine-go"
@@ -61,6 +64,7 @@ func (s *Assets) AssetsRouter() http.Handler {
 
 		r.With(s.api.validateVisibleTests, s.api.validateTestID).Get("/test/{tID}/input", s.ServeTestInput)
 		r.With(s.api.validateVisibleTests, s.api.validateTestID).Get("/test/{tID}/output", s.ServeTestOutput)
+		r.With(s.api.validateVisibleTests, s.api.validateTestID).Get("/test/{tID}/inputFile/{name}", s.ServeTestInputFile)
 
 		// Enforce authed user for rate limit
 		r.With(s.api.MustBeAuthed, s.api.validateProblemFullyVisible).Get("/problemArchive", s.ServeProblemArchive())
@@ -88,6 +92,23 @@ func (s *Assets) ServeAttachment(w http.ResponseWriter, r *http.Request) {
 	w.Header().Add("X-Robots-Tag", "noindex, nofollow, noarchive")
 	att := util.Attachment(r)
 
+	w.Header().Set("Cache-Control", `public, max-age=3600`)
+	// Renders get their own entity tags, since they're different representations of the same data
+	dataHash := att.Hash
+	w.Header().Set("ETag", `"`+dataHash+`"`)
+	// The representation only depends on the contents and the query, so revalidations are answered before loading the data
+	etags := []string{`"` + dataHash + `"`}
+	if path.Ext(att.Name) == ".md" && r.FormValue("format") == "html" {
+		etags = []string{`"` + dataHash + `-html"`}
+	} else if r.FormValue("w") != "" || r.FormValue("h") != "" {
+		etags = append(etags, `"`+dataHash+"-"+fmt.Sprintf("img_%dx%d", formInt(r, "w"), formInt(r, "h"))+`"`)
+	}
+	if etag, ok := matchesETag(r, etags); ok {
+		w.Header().Set("ETag", etag)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
 	attData, err := s.base.AttachmentData(r.Context(), att.ID)
 	if err != nil {
 		if !errors.Is(err, context.Canceled) {
@@ -97,8 +118,6 @@ func (s *Assets) ServeAttachment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Cache-Control", `public, max-age=3600`)
-
 	// If markdown file and client asks for HTML format, render the markdown
 	// TODO: Extract from cache if able to
 	if path.Ext(att.Name) == ".md" && r.FormValue("format") == "html" {
@@ -108,6 +127,7 @@ func (s *Assets) ServeAttachment(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Could not render file", 500)
 			return
 		}
+		w.Header().Set("ETag", `"`+dataHash+`-html"`)
 		http.ServeContent(w, r, att.Name+".html", att.LastUpdatedAt, bytes.NewReader(data))
 		return
 	}
@@ -136,6 +156,7 @@ func (s *Assets) ServeAttachment(w http.ResponseWriter, r *http.Request) {
 		}
 
 		renderType := fmt.Sprintf("img_%dx%d", width, height)
+		renderETag := `"` + dataHash + "-" + renderType + `"`
 		data, err := s.base.GetAttachmentRender(att.ID, renderType)
 		if err != nil {
 			if !errors.Is(err, kilonova.ErrNotExist) {
@@ -143,6 +164,7 @@ func (s *Assets) ServeAttachment(w http.ResponseWriter, r *http.Request) {
 			}
 		} else {
 			defer data.Close()
+			w.Header().Set("ETag", renderETag)
 			http.ServeContent(w, r, att.Name, att.LastUpdatedAt, data)
 			return
 		}
@@ -168,6 +190,7 @@ func (s *Assets) ServeAttachment(w http.ResponseWriter, r *http.Request) {
 			if width <= 4000 && height <= 4000 {
 				s.base.SaveAttachmentRender(att.ID, renderType, buf.Bytes())
 			}
+			w.Header().Set("ETag", renderETag)
 			http.ServeContent(w, r, att.Name, att.LastUpdatedAt, bytes.NewReader(buf.Bytes()))
 			return
 		}
@@ -320,35 +343,116 @@ func (s *Assets) ServeSubtest(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Assets) ServeTestInput(w http.ResponseWriter, r *http.Request) {
-	rr, err := s.base.TestInput(util.Test(r).ID)
+	f, err := s.base.StoredTestInput(util.Test(r).ID)
 	if err != nil {
 		zap.S().Warn(err)
 		http.Error(w, "Couldn't get test input", 500)
 		return
 	}
-	defer rr.Close()
 
 	tname := fmt.Sprintf("%d-%s.in", util.Test(r).VisibleID, util.Problem(r).TestName)
+	serveStoredFile(w, r, tname, f)
+}
 
-	w.Header().Add("Content-Disposition", fmt.Sprintf("attachment; filename=%q", tname))
-	w.WriteHeader(200)
-	io.Copy(w, rr)
+func (s *Assets) ServeTestInputFile(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if !slices.Contains(util.Test(r).InputFiles, name) {
+		http.Error(w, "Input file not found", 404)
+		return
+	}
+	f, err := s.base.StoredTestInputFile(util.Test(r).ID, name)
+	if err != nil {
+		zap.S().Warn(err)
+		http.Error(w, "Couldn't get test input file", 500)
+		return
+	}
+
+	serveStoredFile(w, r, name, f)
 }
 
 func (s *Assets) ServeTestOutput(w http.ResponseWriter, r *http.Request) {
-	rr, err := s.base.TestOutput(util.Test(r).ID)
+	f, err := s.base.StoredTestOutput(util.Test(r).ID)
 	if err != nil {
 		zap.S().Warn(err)
 		http.Error(w, "Couldn't get test output", 500)
 		return
 	}
-	defer rr.Close()
 
 	tname := fmt.Sprintf("%d-%s.out", util.Test(r).VisibleID, util.Problem(r).TestName)
+	serveStoredFile(w, r, tname, f)
+}
 
-	w.Header().Add("Content-Disposition", fmt.Sprintf("attachment; filename=%q", tname))
-	w.WriteHeader(200)
-	io.Copy(w, rr)
+// serveStoredFile serves the file as a download, with support for range and conditional requests.
+// If the client accepts the file's codec, the stored stream is sent as is, with the matching Content-Encoding.
+// Ranges always refer to the decompressed contents, so range requests are never answered with the encoded stream.
+// Files without a known content hash are tagged with the version of the stored file.
+func serveStoredFile(w http.ResponseWriter, r *http.Request, name string, f *datastore.StoredFile) {
+	w.Header().Add("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	// Test data is private, but it may be cached as long as it's revalidated
+	w.Header().Set("Cache-Control", "private, no-cache")
+	if f.Codec != datastore.CodecNone {
+		w.Header().Add("Vary", "Accept-Encoding")
+	}
+	etag := f.Hash
+	if etag == "" {
+		etag = f.Version
+	}
+
+	if f.Codec != datastore.CodecNone && r.Header.Get("Range") == "" && acceptsEncoding(r, string(f.Codec)) {
+		defer f.Close()
+		// The encoded stream is a different representation, so it needs its own entity tag
+		w.Header().Set("ETag", `"`+etag+f.Codec.Ext()+`"`)
+		w.Header().Set("Content-Encoding", string(f.Codec))
+		// Otherwise, it would be sniffed from the compressed stream
+		w.Header().Set("Content-Type", "application/octet-stream")
+		http.ServeContent(w, r, name, f.ModTime, f)
+		return
+	}
+
+	rs := f.Decompressed()
+	defer rs.Close()
+	w.Header().Set("ETag", `"`+etag+`"`)
+	http.ServeContent(w, r, name, f.ModTime, rs)
+}
+
+// formInt returns the integer form value, or 0 if it's missing or invalid
+func formInt(r *http.Request, key string) int {
+	val, _ := strconv.Atoi(r.FormValue(key))
+	return val
+}
+
+// matchesETag returns the entity tag from the list that matches the If-None-Match header, using weak comparison
+func matchesETag(r *http.Request, etags []string) (string, bool) {
+	for _, header := range r.Header.Values("If-None-Match") {
+		for _, part := range strings.Split(header, ",") {
+			tag := strings.TrimPrefix(strings.TrimSpace(part), "W/")
+			for _, etag := range etags {
+				if tag == etag || tag == "*" {
+					return etag, true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// acceptsEncoding returns whether the client accepts responses with the given content coding
+func acceptsEncoding(r *http.Request, coding string) bool {
+	for _, header := range r.Header.Values("Accept-Encoding") {
+		for _, part := range strings.Split(header, ",") {
+			name, params, _ := strings.Cut(part, ";")
+			if !strings.EqualFold(strings.TrimSpace(name), coding) {
+				continue
+			}
+			q, ok := strings.CutPrefix(strings.TrimSpace(params), "q=")
+			if !ok {
+				return true
+			}
+			weight, err := strconv.ParseFloat(q, 64)
+			return err == nil && weight > 0
+		}
+	}
+	return false
 }
 
 func (s *Assets) ServeProblemArchive() http.HandlerFunc {
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/internal/util"
+	"go.uber.org/zap"
+)
+
+func (s *API) lockContestProblem(ctx context.Context, args struct {
+	ProblemID int `json:"problem_id"`
+}) *kilonova.StatusError {
+	return s.base.LockContestProblem(ctx, util.ContestContext(ctx), util.UserBriefContext(ctx), args.ProblemID)
+}
+
+func (s *API) contestLockedProblems(ctx context.Context, _ struct{}) ([]int, *kilonova.StatusError) {
+	return s.base.ContestLockedProblems(ctx, util.ContestContext(ctx), util.UserBriefContext(ctx)), nil
+}
+
+// contestHacks returns all the hacks in the contest for editors. Contestants only see the hacks they're involved in.
+func (s *API) contestHacks(ctx context.Context, args kilonova.HackFilter) ([]*kilonova.Hack, *kilonova.StatusError) {
+	contest, user := util.ContestContext(ctx), util.UserBriefContext(ctx)
+	args.ContestID = &contest.ID
+	if !s.base.IsContestEditor(user, contest) {
+		if !user.IsAuthed() {
+			return []*kilonova.Hack{}, nil
+		}
+		args.InvolvedUserID = &user.ID
+	}
+	if args.Limit <= 0 || args.Limit > 100 {
+		args.Limit = 100
+	}
+	return s.base.Hacks(ctx, args)
+}
+
+func (s *API) createHack(w http.ResponseWriter, r *http.Request) {
+	r.ParseMultipartForm(3 * 1024 * 1024) // 3MB
+	defer cleanupMultipart(r)
+	var args struct {
+		SubmissionID int    `json:"submission_id"`
+		Input        string `json:"input"`
+	}
+	if err := parseRequest(r, &args); err != nil {
+		err.WriteError(w)
+		return
+	}
+
+	sub, err1 := s.base.RawSubmission(r.Context(), args.SubmissionID)
+	if err1 != nil {
+		err1.WriteError(w)
+		return
+	}
+	if sub.ContestID == nil || *sub.ContestID != util.Contest(r).ID {
+		errorData(w, "Submission is not part of this contest", 400)
+		return
+	}
+
+	input := []byte(args.Input)
+	// The input file, if given, takes precedence over the text field
+	if f, _, err := r.FormFile("input"); err == nil {
+		input, err = io.ReadAll(f)
+		if err != nil {
+			zap.S().Warn(err)
+			errorData(w, "Could not read hack input", 500)
+			return
+		}
+	}
+
+	id, err1 := s.base.CreateHack(context.WithoutCancel(r.Context()), sub, util.UserBrief(r), input)
+	if err1 != nil {
+		err1.WriteError(w)
+		return
+	}
+
+	returnData(w, id)
+}
@@ -286,7 +286,8 @@ func (s *API) importProblemArchive(w http.ResponseWriter, r *http.Request) {
 	}
 
 	r = r.WithContext(context.WithValue(r.Context(), util.ProblemKey, pb))
-	if err := s.processArchive(r, true); err != nil {
+	warnings, err := s.processArchive(r, true)
+	if err != nil {
 		err.WriteError(w)
 		return
 	}
@@ -322,7 +323,7 @@ func (s *API) importProblemArchive(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	returnData(w, pb.ID)
+	returnData(w, archiveImportResult{ProblemID: pb.ID, Warnings: warnings})
 }
 
 func (s *API) getProblems(ctx context.Context, args kilonova.ProblemFilter) ([]*kilonova.Problem, *kilonova.StatusError) {
@@ -546,6 +547,23 @@ func (s *API) problemLanguages(ctx context.Context, _ struct{}) ([]eval.Language
 	return s.base.ProblemLanguages(ctx, util.ProblemContext(ctx).ID)
 }
 
+type recommendTimeLimitParams struct {
+	Runs            int   `json:"runs"`
+	SlowSubmissions []int `json:"slow_submissions"`
+}
+
+func (s *API) recommendTimeLimit(ctx context.Context, args recommendTimeLimitParams) (*sudoapi.TimeLimitJob, *kilonova.StatusError) {
+	return s.base.StartTimeLimitRecommendation(ctx, util.ProblemContext(ctx), args.Runs, args.SlowSubmissions)
+}
+
+func (s *API) timeLimitRecommendation(ctx context.Context, _ struct{}) (*sudoapi.TimeLimitJob, *kilonova.StatusError) {
+	job := s.base.TimeLimitRecommendation(util.ProblemContext(ctx).ID)
+	if job == nil {
+		return nil, kilonova.Statusf(404, "No time limit recommendation was started")
+	}
+	return job, nil
+}
+
 func (s *API) getProblem(ctx context.Context, _ struct{}) (*kilonova.Problem, *kilonova.StatusError) {
 	return util.ProblemContext(ctx), nil
 }
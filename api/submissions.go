@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"io"
+	"mime/multipart"
 	"net/http"
+	"strings"
 
 	"github.com/KiloProjects/kilonova"
 	"github.com/KiloProjects/kilonova/eval"
@@ -73,9 +75,10 @@ func (s *API) createSubmission(w http.ResponseWriter, r *http.Request) {
 	r.ParseMultipartForm(1 * 1024 * 1024) // 1MB
 	defer cleanupMultipart(r)
 	var args struct {
-		Lang      string `json:"language"`
-		ProblemID int    `json:"problem_id"`
-		ContestID *int   `json:"contest_id"`
+		Lang       string `json:"language"`
+		ProblemID  int    `json:"problem_id"`
+		ContestID  *int   `json:"contest_id"`
+		Entrypoint string `json:"entrypoint"`
 	}
 	if err := parseRequest(r, &args); err != nil {
 		err.WriteError(w)
@@ -99,29 +102,126 @@ func (s *API) createSubmission(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	f, _, err := r.FormFile("code")
-	if err != nil {
-		if errors.Is(err, http.ErrMissingFile) {
-			errorData(w, "Missing `code` file with source code", 400)
-			return
+	code, files, err1 := submissionSources(r, lang, problem, args.Entrypoint)
+	if err1 != nil {
+		err1.WriteError(w)
+		return
+	}
+
+	id, err1 := s.base.CreateSubmission(context.WithoutCancel(r.Context()), util.UserFull(r), problem, code, files, lang, args.ContestID, false)
+	if err1 != nil {
+		err1.WriteError(w)
+		return
+	}
+
+	returnData(w, id)
+}
+
+// submissionSources reads the source code from the `code` multipart file. Additional source files may be sent as `files`.
+// If `code` is missing or is a zip archive, the entrypoint is picked from the other files.
+func submissionSources(r *http.Request, lang eval.Language, problem *kilonova.Problem, entrypoint string) ([]byte, []*kilonova.SubmissionFile, *kilonova.StatusError) {
+	var uploads []*kilonova.SubmissionFile
+	if r.MultipartForm != nil {
+		for _, fh := range r.MultipartForm.File["files"] {
+			file, err := readMultipartFile(fh)
+			if err != nil {
+				return nil, nil, err
+			}
+			uploads = append(uploads, file)
 		}
+	}
+
+	var code []byte
+	if _, fh, err := r.FormFile("code"); err == nil {
+		file, err := readMultipartFile(fh)
+		if err != nil {
+			return nil, nil, err
+		}
+		if strings.HasSuffix(fh.Filename, ".zip") {
+			uploads = append(uploads, file)
+		} else {
+			code = file.Data
+		}
+	} else if !errors.Is(err, http.ErrMissingFile) {
 		zap.S().Warn(err)
-		errorData(w, "Could not open multipart file", 500)
-		return
+		return nil, nil, kilonova.Statusf(500, "Could not open multipart file")
+	}
+
+	if code == nil && len(uploads) == 0 {
+		return nil, nil, kilonova.Statusf(400, "Missing `code` file with source code")
 	}
 
-	code, err := io.ReadAll(f)
+	files, err := sudoapi.ExpandSubmissionFiles(uploads, problem.SourceSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	if code != nil {
+		return code, files, nil
+	}
+	return sudoapi.SplitSubmissionFiles(lang, files, entrypoint)
+}
+
+func readMultipartFile(fh *multipart.FileHeader) (*kilonova.SubmissionFile, *kilonova.StatusError) {
+	f, err := fh.Open()
 	if err != nil {
 		zap.S().Warn(err)
-		errorData(w, "Could not read source code", 500)
+		return nil, kilonova.Statusf(500, "Could not open multipart file")
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		zap.S().Warn(err)
+		return nil, kilonova.Statusf(500, "Could not read source code")
+	}
+	return &kilonova.SubmissionFile{Name: fh.Filename, Data: data}, nil
+}
+
+// customRun queues the code to be run once on the given input, without creating a submission
+func (s *API) customRun(w http.ResponseWriter, r *http.Request) {
+	r.ParseMultipartForm(2 * 1024 * 1024) // 2MB
+	defer cleanupMultipart(r)
+	var args struct {
+		Lang       string `json:"language"`
+		ProblemID  int    `json:"problem_id"`
+		ContestID  *int   `json:"contest_id"`
+		Input      string `json:"input"`
+		Entrypoint string `json:"entrypoint"`
+	}
+	if err := parseRequest(r, &args); err != nil {
+		err.WriteError(w)
 		return
 	}
 
-	id, err1 := s.base.CreateSubmission(context.WithoutCancel(r.Context()), util.UserFull(r), problem, code, lang, args.ContestID, false)
+	problem, err1 := s.base.Problem(r.Context(), args.ProblemID)
 	if err1 != nil {
 		err1.WriteError(w)
 		return
 	}
 
-	returnData(w, id)
+	lang, ok := eval.Langs[args.Lang]
+	if !ok {
+		errorData(w, "Invalid language", 400)
+		return
+	}
+
+	code, files, err1 := submissionSources(r, lang, problem, args.Entrypoint)
+	if err1 != nil {
+		err1.WriteError(w)
+		return
+	}
+
+	rez, err1 := s.base.CustomRun(r.Context(), util.UserFull(r), problem, code, files, lang, []byte(args.Input), args.ContestID)
+	if err1 != nil {
+		err1.WriteError(w)
+		return
+	}
+
+	returnData(w, rez)
+}
+
+// customRunStatus returns the progress of one of the user's custom runs
+func (s *API) customRunStatus(ctx context.Context, args struct {
+	ID int `json:"id"`
+}) (*sudoapi.CustomRunJob, *kilonova.StatusError) {
+	return s.base.CustomRunJob(args.ID, util.UserBriefContext(ctx))
 }
@@ -14,6 +14,12 @@ func (s *API) createSubTask(w http.ResponseWriter, r *http.Request) {
 		VisibleID int   `json:"visible_id"`
 		Score     int   `json:"score"`
 		Tests     []int `json:"tests"`
+
+		Aggregation kilonova.SubtaskAggregation `json:"aggregation"`
+		Threshold   *float64                    `json:"threshold"`
+
+		TimeLimit   *float64 `json:"time_limit"`
+		MemoryLimit *int     `json:"memory_limit"`
 	}
 	if err := parseJSONBody(r, &args); err != nil {
 		err.WriteError(w)
@@ -30,6 +36,15 @@ func (s *API) createSubTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if args.Aggregation != kilonova.AggregationNone && !kilonova.ValidAggregation(args.Aggregation) {
+		errorData(w, "Invalid aggregation type", 400)
+		return
+	}
+	if args.Threshold != nil && (*args.Threshold < 0 || *args.Threshold > 100) {
+		errorData(w, "Threshold must be between 0 and 100", 400)
+		return
+	}
+
 	realIDs := []int{}
 	for _, id := range args.Tests {
 		test, err := s.base.Test(r.Context(), util.Problem(r).ID, id)
@@ -44,6 +59,18 @@ func (s *API) createSubTask(w http.ResponseWriter, r *http.Request) {
 		VisibleID: args.VisibleID,
 		Score:     decimal.NewFromInt(int64(args.Score)),
 		Tests:     realIDs,
+
+		Aggregation: args.Aggregation,
+		Threshold:   decimal.NewFromInt(100),
+	}
+	if args.Threshold != nil && args.Aggregation == kilonova.AggregationThreshold {
+		stk.Threshold = decimal.NewFromFloat(*args.Threshold)
+	}
+	if args.TimeLimit != nil && *args.TimeLimit > 0 {
+		stk.TimeLimit = args.TimeLimit
+	}
+	if args.MemoryLimit != nil && *args.MemoryLimit > 0 {
+		stk.MemoryLimit = args.MemoryLimit
 	}
 
 	if err := s.base.CreateSubTask(r.Context(), &stk); err != nil {
@@ -60,6 +87,12 @@ func (s *API) updateSubTask(w http.ResponseWriter, r *http.Request) {
 		NewID     *int     `json:"new_id"`
 		Score     *float64 `json:"score"`
 		Tests     []int    `json:"tests"`
+
+		Aggregation kilonova.SubtaskAggregation `json:"aggregation"`
+		Threshold   *float64                    `json:"threshold"`
+
+		TimeLimit   *float64 `json:"time_limit"`
+		MemoryLimit *int     `json:"memory_limit"`
 	}
 	if err := parseJSONBody(r, &args); err != nil {
 		err.WriteError(w)
@@ -83,9 +116,36 @@ func (s *API) updateSubTask(w http.ResponseWriter, r *http.Request) {
 		score = &val
 	}
 
+	if args.Aggregation != kilonova.AggregationNone && !kilonova.ValidAggregation(args.Aggregation) {
+		errorData(w, "Invalid aggregation type", 400)
+		return
+	}
+	if args.Threshold != nil && (*args.Threshold < 0 || *args.Threshold > 100) {
+		errorData(w, "Threshold must be between 0 and 100", 400)
+		return
+	}
+
+	aggregation := args.Aggregation
+	if aggregation == kilonova.AggregationNone {
+		aggregation = stk.Aggregation
+	}
+
+	var threshold *decimal.Decimal
+	// The threshold is ignored unless the subtask uses threshold aggregation
+	if args.Threshold != nil && aggregation == kilonova.AggregationThreshold {
+		val := decimal.NewFromFloat(*args.Threshold)
+		threshold = &val
+	}
+
 	if err := s.base.UpdateSubTask(r.Context(), stk.ID, kilonova.SubTaskUpdate{
 		VisibleID: args.NewID,
 		Score:     score,
+
+		Aggregation: args.Aggregation,
+		Threshold:   threshold,
+
+		TimeLimit:   args.TimeLimit,
+		MemoryLimit: args.MemoryLimit,
 	}); err != nil {
 		err.WriteError(w)
 		return
@@ -33,14 +33,24 @@ func (s *API) saveTestData(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if err := s.base.TestDataChanged(r.Context(), util.Test(r)); err != nil {
+		err.WriteError(w)
+		return
+	}
+
 	returnData(w, "Updated test data")
 }
 
 func (s *API) updateTestInfo(w http.ResponseWriter, r *http.Request) {
 	r.ParseForm()
 	var args struct {
-		ID    int
-		Score string
+		ID      int
+		Score   string
+		Pretest *bool
+
+		// Empty or non-positive limits remove the override
+		TimeLimit   *float64 `json:"time_limit"`
+		MemoryLimit *int     `json:"memory_limit"`
 	}
 	if err := decoder.Decode(&args, r.Form); err != nil {
 		errorData(w, err, http.StatusBadRequest)
@@ -58,23 +68,79 @@ func (s *API) updateTestInfo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := s.base.UpdateTest(r.Context(), util.Test(r).ID, kilonova.TestUpdate{VisibleID: &args.ID, Score: &scoreValue}); err != nil {
+	if err := s.base.UpdateTest(r.Context(), util.Test(r).ID, kilonova.TestUpdate{
+		VisibleID: &args.ID, Score: &scoreValue, Pretest: args.Pretest,
+		TimeLimit: args.TimeLimit, MemoryLimit: args.MemoryLimit,
+	}); err != nil {
 		err.WriteError(w)
 		return
 	}
 	returnData(w, "Updated test info")
 }
 
+func (s *API) saveTestInputFile(w http.ResponseWriter, r *http.Request) {
+	r.ParseMultipartForm(20 * 1024 * 1024) // 20MB
+	defer cleanupMultipart(r)
+
+	f, fh, err := r.FormFile("file")
+	if err != nil {
+		errorData(w, "Missing input file", 400)
+		return
+	}
+	defer f.Close()
+	name := r.FormValue("name")
+	if name == "" {
+		name = fh.Filename
+	}
+
+	if err := s.base.AddTestInputFile(r.Context(), util.Problem(r), util.Test(r), name, f); err != nil {
+		err.WriteError(w)
+		return
+	}
+	if err := s.base.TestDataChanged(r.Context(), util.Test(r)); err != nil {
+		err.WriteError(w)
+		return
+	}
+	returnData(w, "Saved input file")
+}
+
+func (s *API) deleteTestInputFile(ctx context.Context, args struct{ Name string }) *kilonova.StatusError {
+	if err := s.base.RemoveTestInputFile(ctx, util.TestContext(ctx), args.Name); err != nil {
+		return err
+	}
+	return s.base.TestDataChanged(ctx, util.TestContext(ctx))
+}
+
 func (s *API) deleteTest(ctx context.Context, _ struct{}) *kilonova.StatusError {
 	return s.base.DeleteTest(ctx, util.TestContext(ctx).ID)
 }
 
 func (s *API) getTests(ctx context.Context, _ struct{}) ([]*kilonova.Test, *kilonova.StatusError) {
-	return s.base.Tests(ctx, util.ProblemContext(ctx).ID)
+	tests, err := s.base.Tests(ctx, util.ProblemContext(ctx).ID)
+	if err != nil {
+		return nil, err
+	}
+	s.filterTests(ctx, tests...)
+	return tests, nil
 }
 
 func (s *API) getTest(ctx context.Context, args struct{ ID int }) (*kilonova.Test, *kilonova.StatusError) {
-	return s.base.Test(ctx, util.ProblemContext(ctx).ID, args.ID)
+	test, err := s.base.Test(ctx, util.ProblemContext(ctx).ID, args.ID)
+	if err != nil {
+		return nil, err
+	}
+	s.filterTests(ctx, test)
+	return test, nil
+}
+
+// filterTests hides the best objective values from users that can't edit the problem
+func (s *API) filterTests(ctx context.Context, tests ...*kilonova.Test) {
+	if s.base.IsProblemEditor(util.UserBriefContext(ctx), util.ProblemContext(ctx)) {
+		return
+	}
+	for _, test := range tests {
+		test.BestObjective = nil
+	}
 }
 
 // createTest inserts a new test to the problem
@@ -116,10 +182,20 @@ func (s *API) createTest(w http.ResponseWriter, r *http.Request) {
 		errorData(w, "Couldn't create test output", 500)
 		return
 	}
+	if err := s.base.TestDataChanged(r.Context(), &test); err != nil {
+		err.WriteError(w)
+		return
+	}
 	returnData(w, "Created test")
 }
 
-func (s *API) processArchive(r *http.Request, firstImport bool) *kilonova.StatusError {
+// archiveImportResult is returned after importing an archive. Warnings describe the parts of the archive that couldn't be imported exactly
+type archiveImportResult struct {
+	ProblemID int      `json:"problem_id"`
+	Warnings  []string `json:"warnings"`
+}
+
+func (s *API) processArchive(r *http.Request, firstImport bool) ([]string, *kilonova.StatusError) {
 	// Since this operation can take a lot of space, I am putting this lock as a precaution.
 	// This might create a problem with timeouts, and this should be handled asynchronously.
 	// (ie not in a request), but eh, I cant be bothered right now to do it the right way.
@@ -130,20 +206,20 @@ func (s *API) processArchive(r *http.Request, firstImport bool) *kilonova.Status
 	defer cleanupMultipart(r)
 
 	if r.MultipartForm == nil || r.MultipartForm.File == nil {
-		return kilonova.Statusf(400, "Missing archive")
+		return nil, kilonova.Statusf(400, "Missing archive")
 	}
 
 	// Process zip file
 	file, fh, err := r.FormFile("testArchive")
 	if err != nil {
 		zap.S().Warn(err)
-		return kilonova.WrapError(err, "Couldn't open zip file")
+		return nil, kilonova.WrapError(err, "Couldn't open zip file")
 	}
 	defer file.Close()
 
 	ar, err := zip.NewReader(file, fh.Size)
 	if err != nil {
-		return kilonova.WrapError(err, "Couldn't read zip archive")
+		return nil, kilonova.WrapError(err, "Couldn't read zip archive")
 	}
 
 	params := &test.TestProcessParams{
@@ -157,11 +233,12 @@ func (s *API) processArchive(r *http.Request, firstImport bool) *kilonova.Status
 }
 
 func (s *API) processTestArchive(w http.ResponseWriter, r *http.Request) {
-	if err := s.processArchive(r, false); err != nil {
+	warnings, err := s.processArchive(r, false)
+	if err != nil {
 		err.WriteError(w)
 		return
 	}
-	returnData(w, "Processed tests")
+	returnData(w, archiveImportResult{ProblemID: util.Problem(r).ID, Warnings: warnings})
 }
 
 func (s *API) bulkDeleteTests(w http.ResponseWriter, r *http.Request) {
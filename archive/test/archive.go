@@ -2,9 +2,11 @@ package test
 
 import (
 	"archive/zip"
+	"bytes"
 	"cmp"
 	"context"
 	"fmt"
+	"io"
 	"io/fs"
 	"path"
 	"path/filepath"
@@ -35,6 +37,19 @@ type ArchiveCtx struct {
 	scoreParameters []ScoreParamEntry
 
 	testScores ScoreFileEntries
+
+	// warnings describe the parts of the archive that were not imported exactly, they are shown to the user
+	warnings []string
+
+	// generatedFiles are the files written to the tests bucket by the test generation, removed once the import is done
+	generatedFiles []string
+}
+
+// warnf records a warning for the import result
+func (actx *ArchiveCtx) warnf(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	zap.S().Warn(msg)
+	actx.warnings = append(actx.warnings, msg)
 }
 
 type properties struct {
@@ -58,6 +73,16 @@ type properties struct {
 
 	ScorePrecision  *int32
 	ScoringStrategy kilonova.ScoringType
+
+	ObjectiveType     *kilonova.ObjectiveType
+	ObjectiveExponent *decimal.Decimal
+
+	// Pretests holds the visible IDs of the tests marked as pretests
+	Pretests []int
+
+	// TestTimeLimits (seconds) and TestMemoryLimits (kbytes) hold limit overrides, by test visible ID
+	TestTimeLimits   map[int]float64
+	TestMemoryLimits map[int]int
 }
 
 func NewArchiveCtx(params *TestProcessParams) *ArchiveCtx {
@@ -70,6 +95,24 @@ func NewArchiveCtx(params *TestProcessParams) *ArchiveCtx {
 	}
 }
 
+// setFileIO maps the input and output files of imported problems.
+// Besides the standard streams, Kilonova only supports `<name>.in` and `<name>.out` files, so other names are left unchanged
+func setFileIO(actx *ArchiveCtx, inputFile, outputFile string) {
+	if inputFile == "" && outputFile == "" {
+		val := true
+		actx.props.ConsoleInput = &val
+		return
+	}
+	name := strings.TrimSuffix(inputFile, ".in")
+	if name == inputFile || outputFile != name+".out" {
+		actx.warnf("Unsupported input/output files %q and %q", inputFile, outputFile)
+		return
+	}
+	val := false
+	actx.props.ConsoleInput = &val
+	actx.props.TestName = &name
+}
+
 var (
 	testInputSuffixes  = []string{".in", ".input"}
 	testOutputSuffixes = []string{".out", ".output", ".ok", ".sol", ".a", ".ans"}
@@ -87,6 +130,10 @@ func ProcessArchiveFile(ctx *ArchiveCtx, file *zip.File) *kilonova.StatusError {
 		return ProcessSubmissionFile(ctx, file)
 	}
 
+	if strings.HasPrefix(file.Name, "inputs/") { // Additional test input files
+		return ProcessTestInputDirFile(ctx, file)
+	}
+
 	ext := strings.ToLower(path.Ext(file.Name))
 	if ext == ".txt" { // test score file
 		// if using score parameters, test score file is redundant
@@ -107,44 +154,6 @@ func ProcessArchiveFile(ctx *ArchiveCtx, file *zip.File) *kilonova.StatusError {
 		return ProcessPropertiesFile(ctx, file)
 	}
 
-	if strings.ToLower(file.Name) == "problem.xml" { // Polygon archive format
-		r, err := file.Open()
-		if err != nil {
-			return kilonova.WrapError(err, "Could not open problem.xml")
-		}
-		defer r.Close()
-		return ProcessProblemXMLFile(ctx, r)
-	}
-
-	// Polygon-specific handling
-	if ctx.params.Polygon {
-		if strings.HasPrefix(file.Name, "solutions") {
-			return ProcessSubmissionFile(ctx, file)
-		}
-
-		if strings.HasPrefix(file.Name, "tests") {
-			if ext == ".a" {
-				return ProcessTestOutputFile(ctx, file)
-			}
-
-			return ProcessTestInputFile(ctx, file)
-		}
-
-		if file.Name == "check.cpp" {
-			return ProcessPolygonCheckFile(ctx, file)
-		}
-
-		match, err := path.Match("statements/.pdf/*/problem.pdf", file.Name)
-		if err != nil {
-			zap.S().Warn(err)
-		}
-		if err == nil && match {
-			return ProcessPolygonPDFStatement(ctx, file)
-		}
-
-		return nil
-	}
-
 	// if nothing else is detected, it should be a test file
 	if slices.Contains(testInputSuffixes, ext) || strings.HasPrefix(file.Name, "input") { // test input file (ex: 01.in)
 		return ProcessTestInputFile(ctx, file)
@@ -163,6 +172,8 @@ type TestProcessParams struct {
 	ScoreParamsStr string
 
 	Polygon          bool
+	CMS              bool
+	Kattis           bool
 	MergeAttachments bool
 
 	// It's used when importing problems, since they use a stub name and, if not set, should be updated anyway, if available.
@@ -172,45 +183,78 @@ type TestProcessParams struct {
 	// MergeTests bool
 }
 
-func ProcessZipTestArchive(ctx context.Context, pb *kilonova.Problem, ar *zip.Reader, base *sudoapi.BaseAPI, params *TestProcessParams) *kilonova.StatusError {
+// ProcessZipTestArchive imports the archive into the problem.
+// On success, it returns the warnings about the parts of the archive that couldn't be imported exactly
+func ProcessZipTestArchive(ctx context.Context, pb *kilonova.Problem, ar *zip.Reader, base *sudoapi.BaseAPI, params *TestProcessParams) ([]string, *kilonova.StatusError) {
 	if params.Requestor == nil {
-		return kilonova.Statusf(400, "There must be a requestor")
+		return nil, kilonova.Statusf(400, "There must be a requestor")
 	}
 
 	aCtx := NewArchiveCtx(params)
+	defer aCtx.removeGeneratedFiles()
 
 	// Try to autodetect polygon archive
 	if _, err := fs.Stat(ar, "problem.xml"); err == nil {
 		aCtx.params.Polygon = true
 		aCtx.params.MergeAttachments = true
 	}
+	// CMS tasks in the Italian format
+	if _, err := fs.Stat(ar, "task.yaml"); err == nil && !aCtx.params.Polygon {
+		aCtx.params.CMS = true
+		aCtx.params.MergeAttachments = true
+	}
+	// Kattis problem packages
+	if _, err := fs.Stat(ar, "problem.yaml"); err == nil && !aCtx.params.Polygon && !aCtx.params.CMS {
+		aCtx.params.Kattis = true
+		aCtx.params.MergeAttachments = true
+	}
 
 	if len(params.ScoreParamsStr) > 0 {
 		scoreParams, err := ParseScoreParameters([]byte(params.ScoreParamsStr))
 		if err != nil {
-			return err
+			return nil, err
 		}
 		aCtx.scoreParameters = scoreParams
 	}
 
-	for _, file := range ar.File {
-		if file.FileInfo().IsDir() {
-			continue
+	switch {
+	case aCtx.params.Polygon:
+		if err := ProcessPolygonPackage(ctx, aCtx, ar, base); err != nil {
+			return nil, err
 		}
+	case aCtx.params.CMS:
+		if err := ProcessCMSTask(ctx, aCtx, ar, base); err != nil {
+			return nil, err
+		}
+	case aCtx.params.Kattis:
+		if err := ProcessKattisPackage(ctx, aCtx, ar); err != nil {
+			return nil, err
+		}
+	default:
+		for _, file := range ar.File {
+			if file.FileInfo().IsDir() {
+				continue
+			}
 
-		if err := ProcessArchiveFile(aCtx, file); err != nil {
-			return err
+			if err := ProcessArchiveFile(aCtx, file); err != nil {
+				return nil, err
+			}
 		}
 	}
 
 	if aCtx.props != nil && aCtx.props.Subtasks != nil && len(aCtx.props.SubtaskedTests) != len(aCtx.tests) {
 		zap.S().Info(len(aCtx.props.SubtaskedTests), len(aCtx.tests))
-		return kilonova.Statusf(400, "Mismatched number of tests in archive and tests that correspond to at least one subtask")
+		return nil, kilonova.Statusf(400, "Mismatched number of tests in archive and tests that correspond to at least one subtask")
 	}
 
 	for k, v := range aCtx.tests {
-		if v.InFile == nil || v.OutFile == nil {
-			return kilonova.Statusf(400, "Missing input or output file for test %q", k)
+		if !v.hasInput() || !v.hasOutput() {
+			return nil, kilonova.Statusf(400, "Missing input or output file for test %q", k)
+		}
+		for name := range v.InputFiles {
+			if err := sudoapi.ValidateTestInputFile(pb, name); err != nil {
+				return nil, kilonova.Statusf(400, "Invalid input file %q for test %q", name, k)
+			}
 		}
 	}
 
@@ -284,10 +328,11 @@ func ProcessZipTestArchive(ctx context.Context, pb *kilonova.Problem, ar *zip.Re
 			var n decimal.Decimal
 			totalScore := decimal.NewFromInt(100)
 			for _, test := range tests {
-				if test.Score.IsPositive() {
-					totalScore = totalScore.Sub(test.Score)
-				} else {
+				if test.Score.Equal(decimal.NewFromInt(-1)) {
 					n = n.Add(decimal.NewFromInt(1))
+				} else {
+					// Tests explicitly worth no points, such as examples, don't take a share
+					totalScore = totalScore.Sub(test.Score)
 				}
 			}
 
@@ -331,7 +376,7 @@ func ProcessZipTestArchive(ctx context.Context, pb *kilonova.Problem, ar *zip.Re
 		// So let's do it for them
 		if err := base.DeleteTests(ctx, pb.ID); err != nil {
 			zap.S().Warn(err)
-			return err
+			return nil, err
 		}
 
 		createdTests := map[int]kilonova.Test{}
@@ -341,38 +386,67 @@ func ProcessZipTestArchive(ctx context.Context, pb *kilonova.Problem, ar *zip.Re
 			test.ProblemID = pb.ID
 			test.VisibleID = v.VisibleID
 			test.Score = v.Score
+			if aCtx.props != nil {
+				test.Pretest = slices.Contains(aCtx.props.Pretests, v.VisibleID)
+				if tl, ok := aCtx.props.TestTimeLimits[v.VisibleID]; ok {
+					test.TimeLimit = &tl
+				}
+				if ml, ok := aCtx.props.TestMemoryLimits[v.VisibleID]; ok {
+					test.MemoryLimit = &ml
+				}
+			}
+			test.InputFiles = make([]string, 0, len(v.InputFiles))
+			for name := range v.InputFiles {
+				test.InputFiles = append(test.InputFiles, name)
+			}
+			slices.Sort(test.InputFiles)
 			if err := base.CreateTest(ctx, &test); err != nil {
 				zap.S().Warn(err)
-				return err
+				return nil, err
 			}
 
 			createdTests[v.VisibleID] = test
 
-			f, err := v.InFile.Open()
+			f, err := v.openInput()
 			if err != nil {
-				return kilonova.WrapError(err, "Couldn't open() input file")
+				return nil, kilonova.WrapError(err, "Couldn't open() input file")
 			}
 			if err := base.SaveTestInput(test.ID, f); err != nil {
 				zap.S().Warn("Couldn't create test input", err)
 				f.Close()
-				return kilonova.WrapError(err, "Couldn't create test input")
+				return nil, kilonova.WrapError(err, "Couldn't create test input")
 			}
 			f.Close()
-			f, err = v.OutFile.Open()
+			f, err = v.openOutput()
 			if err != nil {
-				return kilonova.WrapError(err, "Couldn't open() output file")
+				return nil, kilonova.WrapError(err, "Couldn't open() output file")
 			}
 			if err := base.SaveTestOutput(test.ID, f); err != nil {
 				zap.S().Warn("Couldn't create test output", err)
 				f.Close()
-				return kilonova.WrapError(err, "Couldn't create test output")
+				return nil, kilonova.WrapError(err, "Couldn't create test output")
 			}
 			f.Close()
+			for name, file := range v.InputFiles {
+				f, err := file.Open()
+				if err != nil {
+					return nil, kilonova.WrapError(err, "Couldn't open() input file")
+				}
+				if err := base.SaveTestInputFile(test.ID, name, f); err != nil {
+					zap.S().Warn("Couldn't create test input file", err)
+					f.Close()
+					return nil, kilonova.WrapError(err, "Couldn't create test input file")
+				}
+				f.Close()
+			}
+			if err := base.TestDataChanged(ctx, &test); err != nil {
+				return nil, err
+			}
 		}
 
 		if err := base.DeleteSubTasks(ctx, pb.ID); err != nil {
 			zap.S().Warn(err)
-			return kilonova.WrapError(err, "Couldn't delete existing subtasks")
+			return nil, kilonova.WrapError(err, "Couldn't delete existing subtasks")
 		}
 		if len(aCtx.scoreParameters) > 0 {
 			// Decide subtasks based on score parameters, if they exist
@@ -414,7 +488,7 @@ func ProcessZipTestArchive(ctx context.Context, pb *kilonova.Problem, ar *zip.Re
 						Tests:     testIDs,
 					}); err != nil {
 						zap.S().Warn(err)
-						return kilonova.WrapError(err, "Couldn't create subtask")
+						return nil, kilonova.WrapError(err, "Couldn't create subtask")
 					}
 				}
 			}
@@ -424,7 +498,7 @@ func ProcessZipTestArchive(ctx context.Context, pb *kilonova.Problem, ar *zip.Re
 				tests := make([]int, 0, len(stk.Tests))
 				for _, test := range stk.Tests {
 					if tt, exists := createdTests[test]; !exists {
-						return kilonova.Statusf(400, "Test %d not found in added tests. Aborting subtask creation", test)
+						return nil, kilonova.Statusf(400, "Test %d not found in added tests. Aborting subtask creation", test)
 					} else {
 						tests = append(tests, tt.ID)
 					}
@@ -435,9 +509,15 @@ func ProcessZipTestArchive(ctx context.Context, pb *kilonova.Problem, ar *zip.Re
 					VisibleID: stkId,
 					Score:     stk.Score,
 					Tests:     tests,
+
+					Aggregation: stk.Aggregation,
+					Threshold:   stk.Threshold,
+
+					TimeLimit:   stk.TimeLimit,
+					MemoryLimit: stk.MemoryLimit,
 				}); err != nil {
 					zap.S().Warn(err)
-					return kilonova.WrapError(err, "Couldn't create subtask")
+					return nil, kilonova.WrapError(err, "Couldn't create subtask")
 				}
 			}
 		}
@@ -445,7 +525,7 @@ func ProcessZipTestArchive(ctx context.Context, pb *kilonova.Problem, ar *zip.Re
 
 	if len(aCtx.attachments) > 0 {
 		if err := createAttachments(ctx, aCtx, pb, base, params); err != nil {
-			return err
+			return nil, err
 		}
 	}
 
@@ -473,6 +553,12 @@ func ProcessZipTestArchive(ctx context.Context, pb *kilonova.Problem, ar *zip.Re
 		if aCtx.props.ScorePrecision != nil {
 			upd.ScorePrecision, shouldUpd = aCtx.props.ScorePrecision, true
 		}
+		if aCtx.props.ObjectiveType != nil {
+			upd.ObjectiveType, shouldUpd = aCtx.props.ObjectiveType, true
+		}
+		if aCtx.props.ObjectiveExponent != nil {
+			upd.ObjectiveExponent, shouldUpd = aCtx.props.ObjectiveExponent, true
+		}
 		if aCtx.props.TestName != nil {
 			upd.TestName, shouldUpd = aCtx.props.TestName, true
 		}
@@ -497,7 +583,7 @@ func ProcessZipTestArchive(ctx context.Context, pb *kilonova.Problem, ar *zip.Re
 		if shouldUpd {
 			if err := base.UpdateProblem(ctx, pb.ID, upd, nil); err != nil {
 				zap.S().Warn(err)
-				return kilonova.WrapError(err, "Couldn't update problem medatada")
+				return nil, kilonova.WrapError(err, "Couldn't update problem medatada")
 			}
 		}
 
@@ -518,7 +604,7 @@ func ProcessZipTestArchive(ctx context.Context, pb *kilonova.Problem, ar *zip.Re
 			}
 			if err := base.UpdateProblemTags(ctx, pb.ID, realTagIDs); err != nil {
 				zap.S().Warn(err)
-				return kilonova.WrapError(err, "Couldn't update tags")
+				return nil, kilonova.WrapError(err, "Couldn't update tags")
 			}
 		}
 
@@ -542,7 +628,7 @@ func ProcessZipTestArchive(ctx context.Context, pb *kilonova.Problem, ar *zip.Re
 				cEditors, err := base.ProblemEditors(ctx, pb.ID)
 				if err != nil {
 					zap.S().Warn(err)
-					return err
+					return nil, err
 				}
 				for _, ed := range cEditors {
 					if err := base.StripProblemAccess(ctx, pb.ID, ed.ID); err != nil {
@@ -570,13 +656,20 @@ func ProcessZipTestArchive(ctx context.Context, pb *kilonova.Problem, ar *zip.Re
 				zap.S().Warn("Skipping submission")
 				continue
 			}
-			if _, err := base.CreateSubmission(ctx, params.Requestor, pb, sub.code, lang, nil, true); err != nil {
+			id, err := base.CreateSubmission(ctx, params.Requestor, pb, sub.code, nil, lang, nil, true)
+			if err != nil {
 				zap.S().Warn(err)
+				continue
+			}
+			if sub.expectedVerdict != "" {
+				if err := base.UpdateSubmission(ctx, id, kilonova.SubmissionUpdate{ExpectedVerdict: &sub.expectedVerdict}); err != nil {
+					zap.S().Warn(err)
+				}
 			}
 		}
 	}
 
-	return nil
+	return aCtx.warnings, nil
 }
 
 func createAttachments(ctx context.Context, aCtx *ArchiveCtx, pb *kilonova.Problem, base *sudoapi.BaseAPI, params *TestProcessParams) *kilonova.StatusError {
@@ -608,15 +701,19 @@ func createAttachments(ctx context.Context, aCtx *ArchiveCtx, pb *kilonova.Probl
 		}
 	}
 	for _, att := range aCtx.attachments {
-		if att.File == nil {
+		if att.File == nil && att.Data == nil {
 			zap.S().Infof("Skipping attachment %s since it only has props", att.Name)
 			continue
 		}
 
-		f, err := att.File.Open()
-		if err != nil {
-			zap.S().Warn("Couldn't open attachment zip file", err)
-			continue
+		var f io.ReadCloser = io.NopCloser(bytes.NewReader(att.Data))
+		if att.File != nil {
+			var err error
+			f, err = att.File.Open()
+			if err != nil {
+				zap.S().Warn("Couldn't open attachment zip file", err)
+				continue
+			}
 		}
 
 		var userID *int
@@ -7,11 +7,13 @@ import (
 	"strings"
 
 	"github.com/KiloProjects/kilonova"
-	"go.uber.org/zap"
 )
 
 type archiveAttachment struct {
-	File    *zip.File
+	File *zip.File
+	// Data holds the contents of attachments generated during import, which have no File
+	Data []byte
+
 	Name    string
 	Visible bool
 	Private bool
@@ -76,39 +78,3 @@ func ProcessAttachmentFile(ctx *ArchiveCtx, file *zip.File) *kilonova.StatusErro
 	}
 	return nil
 }
-
-func ProcessPolygonCheckFile(ctx *ArchiveCtx, file *zip.File) *kilonova.StatusError {
-	ctx.attachments["checker.cpp17"] = archiveAttachment{
-		File:    file,
-		Name:    "checker.cpp17",
-		Visible: false,
-		Private: true,
-		Exec:    true,
-	}
-	return nil
-}
-
-func ProcessPolygonPDFStatement(ctx *ArchiveCtx, file *zip.File) *kilonova.StatusError {
-	parts := strings.Split(file.Name, "/")
-	if len(parts) != 4 {
-		zap.S().Warn("Sanity check failed: Polygon PDF statement is not 4 parts")
-		return nil
-	}
-	filename := ""
-	switch parts[2] {
-	case "english":
-		filename = "statement-en.pdf"
-	case "romanian":
-		filename = "statement-ro.pdf"
-	default:
-		return nil
-	}
-	ctx.attachments[filename] = archiveAttachment{
-		File:    file,
-		Name:    filename,
-		Visible: false,
-		Private: false,
-		Exec:    false,
-	}
-	return nil
-}
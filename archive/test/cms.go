@@ -0,0 +1,589 @@
+package test
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/eval"
+	"github.com/KiloProjects/kilonova/eval/checkers"
+	"github.com/KiloProjects/kilonova/sudoapi"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// Documentation of the Italian task format is located here: https://cms.readthedocs.io/en/latest/External%20contest%20formats.html#italian-import-format
+
+// cmsTaskConfig is the task.yaml file of a CMS task
+type cmsTaskConfig struct {
+	Name  string `yaml:"name"`
+	Title string `yaml:"title"`
+
+	// seconds
+	TimeLimit float64 `yaml:"time_limit,omitempty"`
+	// MiB
+	MemoryLimit int `yaml:"memory_limit,omitempty"`
+
+	// InputFile and OutputFile are empty for the standard streams. CMS defaults to input.txt and output.txt if they're missing
+	InputFile  *string `yaml:"infile,omitempty"`
+	OutputFile *string `yaml:"outfile,omitempty"`
+
+	NumInputs       int    `yaml:"n_input"`
+	PublicTestcases any    `yaml:"public_testcases,omitempty"`
+	PrimaryLanguage string `yaml:"primary_language,omitempty"`
+	OutputOnly      bool   `yaml:"output_only,omitempty"`
+
+	ScoreMode  string  `yaml:"score_mode,omitempty"`
+	TokenMode  string  `yaml:"token_mode,omitempty"`
+	TotalValue float64 `yaml:"total_value,omitempty"`
+
+	ScoreType           string `yaml:"score_type,omitempty"`
+	ScoreTypeParameters any    `yaml:"score_type_parameters,omitempty"`
+}
+
+// cmsGenTest is a testcase line of gen/GEN, either a generator invocation or a copied file
+type cmsGenTest struct {
+	args     []string
+	copyFile string
+}
+
+// parseCMSGen reads the testcases and the subtasks of a gen/GEN file. Subtasks are started by `# ST: <score>` lines
+func parseCMSGen(r io.Reader) ([]cmsGenTest, []ScoreParamEntry, *kilonova.StatusError) {
+	var tests []cmsGenTest
+	var counts []int
+	var scores []decimal.Decimal
+	newTest := func(test cmsGenTest) {
+		if len(counts) == 0 {
+			// Tests before the first subtask don't award points
+			counts, scores = append(counts, 0), append(scores, decimal.Zero)
+		}
+		counts[len(counts)-1]++
+		tests = append(tests, test)
+	}
+
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line, comment, hasComment := strings.Cut(strings.TrimSpace(sc.Text()), "#")
+		line, comment = strings.TrimSpace(line), strings.TrimSpace(comment)
+		switch {
+		case line != "":
+			args, err := splitCommand(line)
+			if err != nil {
+				return nil, nil, kilonova.WrapError(err, "Invalid GEN file")
+			}
+			newTest(cmsGenTest{args: args})
+		case hasComment && strings.HasPrefix(comment, "COPY:"):
+			newTest(cmsGenTest{copyFile: strings.TrimSpace(strings.TrimPrefix(comment, "COPY:"))})
+		}
+		if hasComment && strings.HasPrefix(comment, "ST:") {
+			score, err := decimal.NewFromString(strings.TrimSpace(strings.TrimPrefix(comment, "ST:")))
+			if err != nil {
+				return nil, nil, kilonova.Statusf(400, "Invalid subtask score in GEN file: %q", comment)
+			}
+			counts, scores = append(counts, 0), append(scores, score)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, nil, kilonova.WrapError(err, "Couldn't read GEN file")
+	}
+
+	var params []ScoreParamEntry
+	if len(scores) > 1 || (len(scores) == 1 && !scores[0].IsZero()) {
+		for i := range counts {
+			if counts[i] == 0 {
+				continue
+			}
+			params = append(params, ScoreParamEntry{Score: scores[i], Count: &counts[i]})
+		}
+	}
+	return tests, params, nil
+}
+
+// cmsAuthorSolutionNames are the base names of the official solution in the sol/ directory
+var cmsAuthorSolutionNames = []string{"soluzione", "solution", "sol"}
+
+// ProcessCMSTask imports a task in the Italian format of CMS (task.yaml, input/, output/, gen/GEN, check/, sol/, statement/).
+// Subtasks come from gen/GEN or the score type of task.yaml, and checkers are imported as standard checkers, since they share the calling convention.
+// Missing test data is generated using gen/GEN, the generator in gen/ and the official solution in sol/.
+// Graders in sol/ are compiled with the submissions. Communication tasks are rejected, since their manager can't run alongside submissions
+func ProcessCMSTask(ctx context.Context, actx *ArchiveCtx, ar *zip.Reader, base *sudoapi.BaseAPI) *kilonova.StatusError {
+	files := make(map[string]*zip.File, len(ar.File))
+	for _, file := range ar.File {
+		if !file.FileInfo().IsDir() {
+			files[file.Name] = file
+		}
+	}
+
+	data, err := readZipFile(files["task.yaml"])
+	if err != nil {
+		return kilonova.WrapError(err, "Couldn't read task.yaml")
+	}
+	var conf cmsTaskConfig
+	if err := yaml.Unmarshal(data, &conf); err != nil {
+		return kilonova.WrapError(err, "Invalid task.yaml")
+	}
+
+	if actx.props == nil {
+		actx.props = &properties{}
+	}
+	if conf.Title != "" {
+		actx.props.ProblemName = &conf.Title
+	}
+	if conf.TimeLimit > 0 {
+		actx.props.TimeLimit = &conf.TimeLimit
+	}
+	if conf.MemoryLimit > 0 {
+		memoryLimit := conf.MemoryLimit * 1024
+		actx.props.MemoryLimit = &memoryLimit
+	}
+	inputFile, outputFile := "input.txt", "output.txt"
+	if conf.InputFile != nil {
+		inputFile = *conf.InputFile
+	}
+	if conf.OutputFile != nil {
+		outputFile = *conf.OutputFile
+	}
+	setFileIO(actx, inputFile, outputFile)
+	switch conf.ScoreMode {
+	case "":
+	case "max":
+		actx.props.ScoringStrategy = kilonova.ScoringTypeMaxSub
+	case "max_subtask":
+		actx.props.ScoringStrategy = kilonova.ScoringTypeSumSubtasks
+	default:
+		actx.warnf("Unsupported CMS score mode %q", conf.ScoreMode)
+	}
+	if conf.OutputOnly {
+		actx.warnf("Output only CMS tasks are imported as regular tasks")
+	}
+
+	var genTests []cmsGenTest
+	if file, ok := files["gen/GEN"]; ok {
+		f, err := file.Open()
+		if err != nil {
+			return kilonova.WrapError(err, "Couldn't open GEN file")
+		}
+		var params []ScoreParamEntry
+		var err1 *kilonova.StatusError
+		genTests, params, err1 = parseCMSGen(f)
+		f.Close()
+		if err1 != nil {
+			return err1
+		}
+		// Score parameters given on upload take precedence
+		if len(actx.scoreParameters) == 0 {
+			actx.scoreParameters = params
+		}
+	}
+	numTests := conf.NumInputs
+	if numTests == 0 {
+		numTests = len(genTests)
+	}
+	if numTests == 0 {
+		for ; ; numTests++ {
+			if _, ok := files[fmt.Sprintf("input/input%d.txt", numTests)]; !ok {
+				break
+			}
+		}
+	}
+	if len(actx.scoreParameters) == 0 && conf.ScoreType != "" {
+		if err := cmsScoreType(actx, &conf, numTests); err != nil {
+			return err
+		}
+	}
+
+	if err := processCMSAssets(actx, files, conf.PrimaryLanguage); err != nil {
+		return err
+	}
+
+	return processCMSTests(ctx, actx, files, numTests, genTests, base)
+}
+
+// cmsScoreType maps the score type of task.yaml. Group score types become subtasks, as if given as score parameters on upload
+func cmsScoreType(actx *ArchiveCtx, conf *cmsTaskConfig, numTests int) *kilonova.StatusError {
+	switch conf.ScoreType {
+	case "Sum":
+		score, ok := conf.ScoreTypeParameters.(float64)
+		if !ok {
+			if val, ok := conf.ScoreTypeParameters.(int); ok {
+				score = float64(val)
+			}
+		}
+		for i := range numTests {
+			actx.testScores[i] = decimal.NewFromFloat(score)
+		}
+		return nil
+	case "GroupMin", "GroupMul", "GroupThreshold":
+		if conf.ScoreType != "GroupMin" {
+			actx.warnf("CMS score type %q is imported as GroupMin", conf.ScoreType)
+		}
+		entries, ok := conf.ScoreTypeParameters.([]any)
+		if !ok {
+			return kilonova.Statusf(400, "Invalid score type parameters")
+		}
+		for i := range entries {
+			// GroupThreshold has an additional threshold, which is dropped
+			if entry, ok := entries[i].([]any); ok && len(entry) > 2 {
+				entries[i] = entry[:2]
+			}
+		}
+		data, err := json.Marshal(entries)
+		if err != nil {
+			return kilonova.WrapError(err, "Invalid score type parameters")
+		}
+		params, err1 := ParseScoreParameters(data)
+		if err1 != nil {
+			return err1
+		}
+		actx.scoreParameters = params
+		return nil
+	default:
+		actx.warnf("Unsupported CMS score type %q", conf.ScoreType)
+		return nil
+	}
+}
+
+// processCMSAssets imports the checker, graders, solutions, statements and attachments of the task
+func processCMSAssets(actx *ArchiveCtx, files map[string]*zip.File, primaryLang string) *kilonova.StatusError {
+	if len(primaryLang) != 2 {
+		primaryLang = "en"
+	}
+	for name, file := range files {
+		dir, base := path.Dir(name), path.Base(name)
+		stem := strings.TrimSuffix(base, path.Ext(base))
+		lang := eval.GetLangByFilename(base)
+		switch dir {
+		case "check", "cor":
+			switch {
+			case stem == "manager":
+				return kilonova.Statusf(400, "CMS communication tasks are not supported, since the manager can't run alongside submissions. Use graders in sol/ compiled with the submissions instead")
+			case lang == "":
+				if stem == "checker" || stem == "correttore" {
+					actx.warnf("Skipping compiled CMS checker %q, its source is required", name)
+				}
+			case stem == "checker" || stem == "correttore":
+				attName := langAttachmentName("checker", lang)
+				actx.attachments[attName] = archiveAttachment{File: file, Name: attName, Private: true, Exec: true}
+			}
+		case "sol":
+			switch {
+			case stem == "grader" || path.Ext(base) == ".h":
+				actx.attachments[base] = archiveAttachment{File: file, Name: base, Private: true, Exec: true}
+			case lang != "":
+				code, err := readZipFile(file)
+				if err != nil {
+					zap.S().Warn("Couldn't read CMS solution: ", err)
+					continue
+				}
+				if slices.Contains(cmsAuthorSolutionNames, stem) {
+					attName := langAttachmentName("solution", lang)
+					actx.attachments[attName] = archiveAttachment{File: file, Name: attName, Private: true, Exec: true}
+				}
+				actx.submissions = append(actx.submissions, &submissionStub{code: code, lang: lang})
+			}
+		case "statement", "testo":
+			switch {
+			case base == "statement.pdf" || base == "testo.pdf":
+				attName := fmt.Sprintf("statement-%s.pdf", primaryLang)
+				actx.attachments[attName] = archiveAttachment{File: file, Name: attName}
+			case strings.HasPrefix(base, "statement-"):
+				// Statement variants, as exported by Kilonova
+				actx.attachments[base] = archiveAttachment{File: file, Name: base}
+			case path.Ext(base) != ".tex" && path.Ext(base) != ".sty":
+				if _, ok := actx.attachments[base]; !ok {
+					actx.attachments[base] = archiveAttachment{File: file, Name: base}
+				}
+			}
+		case "att":
+			actx.attachments[base] = archiveAttachment{File: file, Name: base, Visible: true}
+		}
+	}
+	return nil
+}
+
+// processCMSTests loads the tests from input/ and output/, generating the missing ones
+func processCMSTests(ctx context.Context, actx *ArchiveCtx, files map[string]*zip.File, numTests int, genTests []cmsGenTest, base *sudoapi.BaseAPI) *kilonova.StatusError {
+	var generator string
+	generators := make(map[string]*kilonova.GeneratorProgram)
+	for name, file := range files {
+		stem := strings.TrimSuffix(path.Base(name), path.Ext(name))
+		lang := eval.GetLangByFilename(name)
+		if path.Dir(name) != "gen" || lang == "" || (stem != "generatore" && stem != "generator") {
+			continue
+		}
+		code, err := readZipFile(file)
+		if err != nil {
+			return kilonova.WrapError(err, "Couldn't read generator")
+		}
+		generator = stem
+		generators[stem] = &kilonova.GeneratorProgram{Lang: lang, Code: code}
+	}
+
+	gen := &kilonova.TestGeneration{Generators: generators}
+	var generated []*archiveTest
+	tests := make([]*archiveTest, 0, numTests)
+	for i := range numTests {
+		test := &archiveTest{
+			Key:     fmt.Sprintf("%03d", i),
+			InFile:  files[fmt.Sprintf("input/input%d.txt", i)],
+			OutFile: files[fmt.Sprintf("output/output%d.txt", i)],
+		}
+		tests = append(tests, test)
+		if test.hasInput() && test.hasOutput() {
+			continue
+		}
+
+		genTest := &kilonova.GeneratedTest{}
+		switch {
+		case test.InFile != nil:
+			genTest.Input = test.InFile.Open
+		case i < len(genTests) && genTests[i].copyFile != "":
+			file, ok := files[genTests[i].copyFile]
+			if !ok {
+				return kilonova.Statusf(400, "Missing file %q copied by test %d", genTests[i].copyFile, i)
+			}
+			test.InFile, genTest.Input = file, file.Open
+		case i < len(genTests) && generator != "":
+			genTest.Command = append([]string{generator}, genTests[i].args...)
+		default:
+			return kilonova.Statusf(400, "Missing input file for test %d", i)
+		}
+		gen.Tests = append(gen.Tests, genTest)
+		generated = append(generated, test)
+	}
+
+	if len(gen.Tests) > 0 {
+		for name, att := range actx.attachments {
+			if strings.TrimSuffix(name, path.Ext(name)) != "solution" || att.File == nil {
+				continue
+			}
+			code, err := readZipFile(att.File)
+			if err != nil {
+				return kilonova.WrapError(err, "Couldn't read solution")
+			}
+			gen.Solution = &kilonova.GeneratorProgram{Lang: eval.GetLangByFilename(name), Code: code}
+		}
+		switch {
+		case actx.props.ConsoleInput != nil && *actx.props.ConsoleInput:
+			gen.SolutionFilename = "stdin"
+		case actx.props.TestName != nil:
+			gen.SolutionFilename = *actx.props.TestName
+		default:
+			return kilonova.Statusf(400, "Test outputs can't be generated for the input and output files of the task")
+		}
+
+		zap.S().Infof("Generating %d tests of CMS task", len(gen.Tests))
+		if err := generateTests(ctx, actx, base, gen, generated); err != nil {
+			return err
+		}
+	}
+
+	for _, test := range tests {
+		actx.tests[test.Key] = *test
+	}
+	return nil
+}
+
+// cmsPrimaryLanguages are the preferred languages of the exported statement.pdf file
+var cmsPrimaryLanguages = []string{"ro", "en"}
+
+// generateCMSArchive writes the problem in the Italian format of CMS.
+// Subtasks are exported as GroupMin score type parameters matching the test codenames, since GEN files can't describe overlapping subtasks.
+// Tests without subtasks are exported as single-test groups, so their scores are kept
+func (ag *archiveGenerator) generateCMSArchive(ctx context.Context) *kilonova.StatusError {
+	conf := cmsTaskConfig{
+		Name:      kilonova.MakeSlug(ag.testName),
+		Title:     ag.pb.Name,
+		TokenMode: "disabled",
+
+		PublicTestcases: "all",
+	}
+	if ag.opts.ProblemDetails {
+		conf.TimeLimit = ag.pb.TimeLimit
+		conf.MemoryLimit = int(math.Ceil(float64(ag.pb.MemoryLimit) / 1024.0))
+		switch ag.pb.ScoringStrategy {
+		case kilonova.ScoringTypeMaxSub:
+			conf.ScoreMode = "max"
+		case kilonova.ScoringTypeSumSubtasks:
+			conf.ScoreMode = "max_subtask"
+		}
+	}
+	inputFile, outputFile := "", ""
+	if !ag.pb.ConsoleInput {
+		inputFile, outputFile = ag.testName+".in", ag.testName+".out"
+	}
+	conf.InputFile, conf.OutputFile = &inputFile, &outputFile
+
+	if ag.opts.Tests {
+		tests, err := ag.base.Tests(ctx, ag.pb.ID)
+		if err != nil {
+			return err
+		}
+		slices.SortFunc(tests, func(a, b *kilonova.Test) int { return a.VisibleID - b.VisibleID })
+		conf.NumInputs = len(tests)
+		codenames := make(map[int]string, len(tests))
+		for i, test := range tests {
+			codenames[test.ID] = fmt.Sprintf("%03d", i)
+			if err := ag.copyTestFile(fmt.Sprintf("input/input%d.txt", i), test.ID, ag.base.TestInput); err != nil {
+				return err
+			}
+			if err := ag.copyTestFile(fmt.Sprintf("output/output%d.txt", i), test.ID, ag.base.TestOutput); err != nil {
+				return err
+			}
+		}
+
+		subtasks, err := ag.base.SubTasks(ctx, ag.pb.ID)
+		if err != nil {
+			return err
+		}
+		var params [][]any
+		if len(subtasks) > 0 {
+			for _, st := range subtasks {
+				if st.Aggregation != kilonova.AggregationMin && st.Aggregation != "" {
+					zap.S().Warnf("Subtask #%d is exported with the minimum aggregation", st.VisibleID)
+				}
+				names := make([]string, 0, len(st.Tests))
+				for _, id := range st.Tests {
+					if name, ok := codenames[id]; ok {
+						names = append(names, name)
+					}
+				}
+				slices.Sort(names)
+				params = append(params, []any{st.Score.InexactFloat64(), "^(?:" + strings.Join(names, "|") + ")$"})
+			}
+		} else {
+			for i, test := range tests {
+				params = append(params, []any{test.Score.InexactFloat64(), "^" + fmt.Sprintf("%03d", i) + "$"})
+			}
+		}
+		conf.ScoreType, conf.ScoreTypeParameters = "GroupMin", params
+	}
+
+	data, err := yaml.Marshal(conf)
+	if err != nil {
+		return kilonova.WrapError(err, "Couldn't encode task.yaml")
+	}
+	if err := ag.writeFile("task.yaml", data); err != nil {
+		return err
+	}
+
+	if ag.opts.Attachments {
+		if err := ag.addCMSAttachments(ctx); err != nil {
+			return err
+		}
+	}
+	if ag.opts.Submissions {
+		// CMS doesn't know about extensions such as .cpp17, so the conventional ones are used
+		if err := ag.addSubmissions(ctx, func(sub *kilonova.Submission, lang eval.Language) string {
+			return fmt.Sprintf("sol/%d-%sp%s", sub.ID, sub.Score.String(), lang.Extensions[0])
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ag *archiveGenerator) writeFile(name string, data []byte) *kilonova.StatusError {
+	f, err := ag.ar.Create(name)
+	if err != nil {
+		return kilonova.WrapError(err, "Couldn't create archive file")
+	}
+	if _, err := f.Write(data); err != nil {
+		return kilonova.WrapError(err, "Couldn't write archive file")
+	}
+	return nil
+}
+
+func (ag *archiveGenerator) copyTestFile(name string, testID int, open func(int) (io.ReadCloser, error)) *kilonova.StatusError {
+	f, err := ag.ar.Create(name)
+	if err != nil {
+		return kilonova.WrapError(err, "Couldn't create archive file")
+	}
+	r, err := open(testID)
+	if err != nil {
+		return kilonova.WrapError(err, "Couldn't get test data")
+	}
+	defer r.Close()
+	if _, err := io.Copy(f, r); err != nil {
+		return kilonova.WrapError(err, "Couldn't save test file")
+	}
+	return nil
+}
+
+// addCMSAttachments places the attachments in the directories of the Italian format.
+// Legacy checkers and validators use different calling conventions than CMS, so they're skipped
+func (ag *archiveGenerator) addCMSAttachments(ctx context.Context) *kilonova.StatusError {
+	atts, err := ag.base.ProblemAttachments(ctx, ag.pb.ID)
+	if err != nil {
+		return kilonova.WrapError(err, "Couldn't get attachments")
+	}
+	settings, err := ag.base.ProblemSettings(ctx, ag.pb.ID)
+	if err != nil {
+		return err
+	}
+
+	var primaryStatement string
+	for _, lang := range cmsPrimaryLanguages {
+		name := fmt.Sprintf("statement-%s.pdf", lang)
+		if slices.ContainsFunc(atts, func(att *kilonova.Attachment) bool { return att.Name == name }) {
+			primaryStatement = name
+			break
+		}
+	}
+
+	for _, att := range atts {
+		if att.Private && !ag.opts.PrivateAttachments {
+			continue
+		}
+		var name string
+		switch {
+		case att.Name == settings.CheckerName:
+			if settings.LegacyChecker {
+				zap.S().Warn("Skipping legacy checker, which can't be used by CMS")
+				continue
+			}
+			name = "check/checker" + cmsSourceExt(att.Name)
+			if err := ag.writeFile("check/testlib.h", checkers.TestlibHeader()); err != nil {
+				return err
+			}
+		case att.Name == settings.ValidatorName:
+			continue
+		case att.Name == settings.SolutionName:
+			name = "sol/solution" + cmsSourceExt(att.Name)
+		case att.Exec:
+			name = "sol/" + att.Name
+		case att.Name == primaryStatement:
+			name = "statement/statement.pdf"
+		case strings.HasPrefix(att.Name, "statement-"):
+			name = "statement/" + att.Name
+		default:
+			name = "att/" + att.Name
+		}
+		data, err := ag.base.AttachmentData(ctx, att.ID)
+		if err != nil {
+			return kilonova.WrapError(err, "Couldn't get attachment data")
+		}
+		if err := ag.writeFile(name, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cmsSourceExt returns the conventional extension of the source file, since CMS doesn't know about extensions such as .cpp17
+func cmsSourceExt(name string) string {
+	lang, ok := eval.Langs[eval.GetLangByFilename(name)]
+	if !ok || len(lang.Extensions) == 0 {
+		return path.Ext(name)
+	}
+	return lang.Extensions[0]
+}
@@ -0,0 +1,175 @@
+package test
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/shopspring/decimal"
+)
+
+func TestParseCMSGen(t *testing.T) {
+	gen := `# Generated tests of the task
+# ST: 0
+1 1 # the sample
+#COPY: gen/sample.txt
+
+#ST: 30
+5 "a b" 'c'
+  # a comment between tests
+5 7
+# ST: 70
+# COPY: gen/big.txt
+100000 --seed=3
+`
+	tests, params, err := parseCMSGen(strings.NewReader(gen))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []cmsGenTest{
+		{args: []string{"1", "1"}},
+		{copyFile: "gen/sample.txt"},
+		{args: []string{"5", "a b", "c"}},
+		{args: []string{"5", "7"}},
+		{copyFile: "gen/big.txt"},
+		{args: []string{"100000", "--seed=3"}},
+	}
+	if len(tests) != len(expected) {
+		t.Fatalf("Expected %d tests, got %d: %#v", len(expected), len(tests), tests)
+	}
+	for i, tt := range expected {
+		if !slices.Equal(tests[i].args, tt.args) || tests[i].copyFile != tt.copyFile {
+			t.Errorf("Test %d: expected %#v, got %#v", i, tt, tests[i])
+		}
+	}
+
+	scores, counts := []int64{0, 30, 70}, []int{2, 2, 2}
+	if len(params) != len(scores) {
+		t.Fatalf("Expected %d subtasks, got %d", len(scores), len(params))
+	}
+	for i := range params {
+		if !params[i].Score.Equal(decimal.NewFromInt(scores[i])) || params[i].Count == nil || *params[i].Count != counts[i] {
+			t.Errorf("Subtask %d: expected %d tests worth %d, got %v tests worth %s", i, counts[i], scores[i], params[i].Count, params[i].Score)
+		}
+	}
+}
+
+func TestParseCMSGenWithoutSubtasks(t *testing.T) {
+	tests, params, err := parseCMSGen(strings.NewReader("1\n2\n# COPY: gen/3.txt\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tests) != 3 {
+		t.Errorf("Expected 3 tests, got %d", len(tests))
+	}
+	if len(params) != 0 {
+		t.Errorf("Expected no subtasks, got %d", len(params))
+	}
+}
+
+func TestParseCMSGenErrors(t *testing.T) {
+	for _, gen := range []string{"# ST: many\n1\n", "1 'unterminated\n"} {
+		if _, _, err := parseCMSGen(strings.NewReader(gen)); err == nil {
+			t.Errorf("Expected error for GEN file %q", gen)
+		}
+	}
+}
+
+// cmsFixture has two tests given as files, so the import doesn't need to generate tests
+func cmsFixture(taskYAML string, extra map[string]string) map[string]string {
+	files := map[string]string{
+		"task.yaml":          taskYAML,
+		"input/input0.txt":   "1 2\n",
+		"output/output0.txt": "3\n",
+		"input/input1.txt":   "2 2\n",
+		"output/output1.txt": "4\n",
+	}
+	for name, data := range extra {
+		files[name] = data
+	}
+	return files
+}
+
+func TestCMSTaskConfig(t *testing.T) {
+	ar := zipFixture(t, cmsFixture(`name: sum
+title: Sum
+time_limit: 0.5
+memory_limit: 64
+infile: sum.in
+outfile: sum.out
+n_input: 2
+score_mode: max_subtask
+score_type: GroupMin
+score_type_parameters: [[40, 1], [60, 1]]
+`, map[string]string{
+		"sol/grader.cpp": "int main() {}\n",
+		"sol/sum.h":      "int sum(int, int);\n",
+	}))
+	actx := NewArchiveCtx(&TestProcessParams{})
+	if err := ProcessCMSTask(context.Background(), actx, ar, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if actx.props.ProblemName == nil || *actx.props.ProblemName != "Sum" {
+		t.Errorf("Unexpected problem name %v", actx.props.ProblemName)
+	}
+	if actx.props.TimeLimit == nil || *actx.props.TimeLimit != 0.5 {
+		t.Errorf("Unexpected time limit %v", actx.props.TimeLimit)
+	}
+	// memory_limit is given in MiB
+	if actx.props.MemoryLimit == nil || *actx.props.MemoryLimit != 64*1024 {
+		t.Errorf("Unexpected memory limit %v", actx.props.MemoryLimit)
+	}
+	if actx.props.ConsoleInput == nil || *actx.props.ConsoleInput || actx.props.TestName == nil || *actx.props.TestName != "sum" {
+		t.Errorf("Expected sum.in and sum.out files, got console input %v and test name %v", actx.props.ConsoleInput, actx.props.TestName)
+	}
+	if actx.props.ScoringStrategy != kilonova.ScoringTypeSumSubtasks {
+		t.Errorf("Unexpected scoring strategy %q", actx.props.ScoringStrategy)
+	}
+	if len(actx.scoreParameters) != 2 || !actx.scoreParameters[1].Score.Equal(decimal.NewFromInt(60)) {
+		t.Errorf("Unexpected score parameters %#v", actx.scoreParameters)
+	}
+	if len(actx.tests) != 2 {
+		t.Errorf("Expected 2 tests, got %d", len(actx.tests))
+	}
+	// Graders are kept under their names, so they're compiled with the submissions
+	for _, name := range []string{"grader.cpp", "sum.h"} {
+		if att, ok := actx.attachments[name]; !ok || !att.Private || !att.Exec {
+			t.Errorf("Expected private grader attachment %q, got %#v", name, att)
+		}
+	}
+}
+
+func TestCMSTaskDefaults(t *testing.T) {
+	// Without limits, the problem defaults are kept. CMS defaults to input.txt and output.txt, which can't be mapped
+	ar := zipFixture(t, cmsFixture("name: sum\nscore_type: Sum\nscore_type_parameters: 50\n", nil))
+	actx := NewArchiveCtx(&TestProcessParams{})
+	if err := ProcessCMSTask(context.Background(), actx, ar, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if actx.props.TimeLimit != nil || actx.props.MemoryLimit != nil {
+		t.Errorf("Expected no limits, got %v and %v", actx.props.TimeLimit, actx.props.MemoryLimit)
+	}
+	if actx.props.ConsoleInput != nil {
+		t.Errorf("Expected the input/output files to be left unchanged")
+	}
+	if !slices.ContainsFunc(actx.warnings, func(w string) bool { return strings.Contains(w, "input.txt") }) {
+		t.Errorf("Missing warning about the input/output files in %q", actx.warnings)
+	}
+	if len(actx.tests) != 2 || !actx.testScores[1].Equal(decimal.NewFromInt(50)) {
+		t.Errorf("Expected 2 tests worth 50 points, got %d tests and scores %v", len(actx.tests), actx.testScores)
+	}
+}
+
+func TestCMSManager(t *testing.T) {
+	ar := zipFixture(t, cmsFixture("name: sum\ninfile: ''\noutfile: ''\n", map[string]string{
+		"check/manager.cpp": "int main() {}\n",
+	}))
+	if err := ProcessCMSTask(context.Background(), NewArchiveCtx(&TestProcessParams{}), ar, nil); err == nil {
+		t.Error("Communication tasks should be rejected")
+	}
+}
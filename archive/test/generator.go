@@ -16,7 +16,19 @@ import (
 	"go.uber.org/zap"
 )
 
+const (
+	// ArchiveFormatKilonova is the default archive format, which can be imported back
+	ArchiveFormatKilonova = ""
+	// ArchiveFormatCMS is the Italian task format used by CMS
+	ArchiveFormatCMS = "cms"
+	// ArchiveFormatKattis is the problem package format of Kattis
+	ArchiveFormatKattis = "kattis"
+)
+
 type ArchiveGenOptions struct {
+	// Format is the layout of the generated archive
+	Format string `json:"format"`
+
 	Tests bool `json:"tests"`
 
 	Attachments bool `json:"attachments"`
@@ -79,6 +91,27 @@ func (ag *archiveGenerator) addTests(ctx context.Context) *kilonova.StatusError
 		}(); err != nil {
 			return err
 		}
+		for _, name := range test.InputFiles {
+			if err := func() *kilonova.StatusError {
+				f, err := ag.ar.Create(fmt.Sprintf("inputs/%d-%s/%s", test.VisibleID, ag.testName, name))
+				if err != nil {
+					return kilonova.WrapError(err, "Couldn't create archive file")
+				}
+
+				r, err := ag.base.TestInputFile(test.ID, name)
+				if err != nil {
+					return kilonova.WrapError(err, "Couldn't get test input file")
+				}
+				defer r.Close()
+
+				if _, err := io.Copy(f, r); err != nil {
+					return kilonova.WrapError(err, "Couldn't save test input file")
+				}
+				return nil
+			}(); err != nil {
+				return err
+			}
+		}
 		if err := func() *kilonova.StatusError {
 			f, err := ag.ar.Create(fmt.Sprintf("%d-%s.ok", test.VisibleID, ag.testName))
 			if err != nil {
@@ -158,15 +191,38 @@ func (ag *archiveGenerator) addGraderProperties(ctx context.Context) *kilonova.S
 	var buf bytes.Buffer
 
 	if ag.opts.Tests {
+		tests, err := ag.base.Tests(ctx, ag.pb.ID)
+		if err != nil {
+			return err
+		}
+		pretests := []string{}
+		timeLimits, memoryLimits := []string{}, []string{}
+		for _, test := range tests {
+			if test.Pretest {
+				pretests = append(pretests, strconv.Itoa(test.VisibleID))
+			}
+			if test.TimeLimit != nil {
+				timeLimits = append(timeLimits, fmt.Sprintf("%d:%f", test.VisibleID, *test.TimeLimit))
+			}
+			if test.MemoryLimit != nil {
+				memoryLimits = append(memoryLimits, fmt.Sprintf("%d:%f", test.VisibleID, float64(*test.MemoryLimit)/1024.0))
+			}
+		}
+		if len(pretests) > 0 {
+			fmt.Fprintf(&buf, "pretests=%s\n", strings.Join(pretests, ";"))
+		}
+		if len(timeLimits) > 0 {
+			fmt.Fprintf(&buf, "test_time_limits=%s\n", strings.Join(timeLimits, ";"))
+		}
+		if len(memoryLimits) > 0 {
+			fmt.Fprintf(&buf, "test_memory_limits=%s\n", strings.Join(memoryLimits, ";"))
+		}
+
 		subtasks, err := ag.base.SubTasks(ctx, ag.pb.ID)
 		if err != nil {
 			return err
 		}
 		if len(subtasks) != 0 {
-			tests, err := ag.base.Tests(ctx, ag.pb.ID)
-			if err != nil {
-				return err
-			}
 			tmap := map[int]*kilonova.Test{}
 			for _, test := range tests {
 				tmap[test.ID] = test
@@ -174,6 +230,10 @@ func (ag *archiveGenerator) addGraderProperties(ctx context.Context) *kilonova.S
 
 			groups := []string{}
 			weights := []string{}
+			aggregations := []string{}
+			customAggregation := false
+			timeLimits, memoryLimits := []string{}, []string{}
+			customLimits := false
 
 			for _, st := range subtasks {
 				group := ""
@@ -190,9 +250,35 @@ func (ag *archiveGenerator) addGraderProperties(ctx context.Context) *kilonova.S
 				}
 				groups = append(groups, group)
 				weights = append(weights, st.Score.String())
+
+				aggregation := string(st.Aggregation)
+				if st.Aggregation == kilonova.AggregationThreshold {
+					aggregation += ":" + st.Threshold.String()
+				}
+				if st.Aggregation != kilonova.AggregationMin {
+					customAggregation = true
+				}
+				aggregations = append(aggregations, aggregation)
+
+				timeLimit, memoryLimit := "", ""
+				if st.TimeLimit != nil {
+					timeLimit, customLimits = fmt.Sprintf("%f", *st.TimeLimit), true
+				}
+				if st.MemoryLimit != nil {
+					memoryLimit, customLimits = fmt.Sprintf("%f", float64(*st.MemoryLimit)/1024.0), true
+				}
+				timeLimits = append(timeLimits, timeLimit)
+				memoryLimits = append(memoryLimits, memoryLimit)
 			}
 			fmt.Fprintf(&buf, "groups=%s\n", strings.Join(groups, ","))
 			fmt.Fprintf(&buf, "weights=%s\n", strings.Join(weights, ","))
+			if customAggregation {
+				fmt.Fprintf(&buf, "aggregation=%s\n", strings.Join(aggregations, ","))
+			}
+			if customLimits {
+				fmt.Fprintf(&buf, "group_time_limits=%s\n", strings.Join(timeLimits, ","))
+				fmt.Fprintf(&buf, "group_memory_limits=%s\n", strings.Join(memoryLimits, ","))
+			}
 		}
 	}
 	if ag.opts.ProblemDetails {
@@ -208,6 +294,10 @@ func (ag *archiveGenerator) addGraderProperties(ctx context.Context) *kilonova.S
 		fmt.Fprintf(&buf, "console_input=%t\n", ag.pb.ConsoleInput)
 		fmt.Fprintf(&buf, "test_name=%s\n", ag.testName)
 		fmt.Fprintf(&buf, "scoring_strategy=%s\n", ag.pb.ScoringStrategy)
+		if ag.pb.IsRelative() {
+			fmt.Fprintf(&buf, "objective=%s\n", ag.pb.ObjectiveType)
+			fmt.Fprintf(&buf, "objective_exponent=%s\n", ag.pb.ObjectiveExponent)
+		}
 
 		fmt.Fprintf(&buf, "problem_name=%s\n", ag.pb.Name)
 
@@ -259,7 +349,8 @@ func (ag *archiveGenerator) addGraderProperties(ctx context.Context) *kilonova.S
 	return nil
 }
 
-func (ag *archiveGenerator) addSubmissions(ctx context.Context) *kilonova.StatusError {
+// addSubmissions writes the submissions, naming their files with the given function
+func (ag *archiveGenerator) addSubmissions(ctx context.Context, filename func(sub *kilonova.Submission, lang eval.Language) string) *kilonova.StatusError {
 	filter := kilonova.SubmissionFilter{ProblemID: &ag.pb.ID}
 	if !ag.opts.AllSubmissions {
 		filter.FromAuthors = true
@@ -278,7 +369,7 @@ func (ag *archiveGenerator) addSubmissions(ctx context.Context) *kilonova.Status
 			zap.S().Infof("Skipping submission due to unknown/disabled language (%q): %d", sub.Language, sub.ID)
 			continue
 		}
-		f, err := ag.ar.Create(fmt.Sprintf("submissions/%d-%sp%s", sub.ID, sub.Score.String(), lang.Extensions[len(lang.Extensions)-1]))
+		f, err := ag.ar.Create(filename(sub, lang))
 		if err != nil {
 			return kilonova.WrapError(err, "Couldn't create archive submission file")
 		}
@@ -312,6 +403,16 @@ func GenerateArchive(ctx context.Context, pb *kilonova.Problem, w io.Writer, bas
 	}
 	ag.testName = testName
 
+	switch opts.Format {
+	case ArchiveFormatKilonova:
+	case ArchiveFormatCMS:
+		return ag.generateCMSArchive(ctx)
+	case ArchiveFormatKattis:
+		return ag.generateKattisArchive(ctx)
+	default:
+		return kilonova.Statusf(400, "Unknown archive format")
+	}
+
 	// tests
 	if opts.Tests {
 		if err := ag.addTests(ctx); err != nil {
@@ -335,7 +436,9 @@ func GenerateArchive(ctx context.Context, pb *kilonova.Problem, w io.Writer, bas
 
 	// submissions/
 	if opts.Submissions {
-		if err := ag.addSubmissions(ctx); err != nil {
+		if err := ag.addSubmissions(ctx, func(sub *kilonova.Submission, lang eval.Language) string {
+			return fmt.Sprintf("submissions/%d-%sp%s", sub.ID, sub.Score.String(), lang.Extensions[len(lang.Extensions)-1])
+		}); err != nil {
 			return err
 		}
 	}
@@ -0,0 +1,766 @@
+package test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	_ "embed"
+	"fmt"
+	"math"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/eval"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// Documentation of the problem package format is located here: https://www.kattis.com/problem-package-format/
+
+// kattisDefaultValidator is a standard checker behaving like the default output validator of Kattis, configured by the VALIDATOR_FLAGS macro
+//
+//go:embed kattisdata/default_validator.cpp
+var kattisDefaultValidator []byte
+
+// kattisValidatorFlagsMarker starts the first line of generated checkers, such that their flags can be exported back
+const kattisValidatorFlagsMarker = "// kattis validator_flags: "
+
+var kattisValidatorFlagsRegex = regexp.MustCompile(`^[A-Za-z0-9_.+\- ]*$`)
+
+// kattisProblemConfig is the problem.yaml file of a Kattis problem package
+type kattisProblemConfig struct {
+	// Name is either a string or a map from language codes to names
+	Name any    `yaml:"name,omitempty"`
+	Type string `yaml:"type,omitempty"`
+	// Source is usually a string, but it may also be a map in newer packages
+	Source   any `yaml:"source,omitempty"`
+	Keywords any `yaml:"keywords,omitempty"`
+
+	Validation     string `yaml:"validation,omitempty"`
+	ValidatorFlags string `yaml:"validator_flags,omitempty"`
+
+	Limits kattisLimits `yaml:"limits,omitempty"`
+}
+
+type kattisLimits struct {
+	// seconds
+	TimeLimit float64 `yaml:"time_limit,omitempty"`
+	// MiB
+	Memory int `yaml:"memory,omitempty"`
+}
+
+// kattisTestdata is the testdata.yaml file of a test group.
+// Scores are given either by the legacy grader options or by the scoring section of newer packages
+type kattisTestdata struct {
+	OnReject    string `yaml:"on_reject,omitempty"`
+	AcceptScore string `yaml:"accept_score,omitempty"`
+	Range       string `yaml:"range,omitempty"`
+	GraderFlags string `yaml:"grader_flags,omitempty"`
+
+	Scoring *kattisScoring `yaml:"scoring,omitempty"`
+}
+
+type kattisScoring struct {
+	Score       string `yaml:"score,omitempty"`
+	Aggregation string `yaml:"aggregation,omitempty"`
+}
+
+// groupScore returns the score and the aggregation of a group with the given number of tests.
+// The returned bool is false if the score isn't set explicitly
+func (td *kattisTestdata) groupScore(numTests int) (decimal.Decimal, kilonova.SubtaskAggregation, bool) {
+	aggregation := kilonova.AggregationSum
+	if td.Scoring != nil {
+		if td.Scoring.Aggregation == "min" {
+			aggregation = kilonova.AggregationMin
+		}
+		score, err := decimal.NewFromString(td.Scoring.Score)
+		return score, aggregation, err == nil
+	}
+
+	if slices.Contains(strings.Fields(td.GraderFlags), "min") {
+		aggregation = kilonova.AggregationMin
+	}
+	if td.AcceptScore != "" {
+		score, err := decimal.NewFromString(td.AcceptScore)
+		if err != nil {
+			return decimal.Zero, aggregation, false
+		}
+		if aggregation == kilonova.AggregationSum {
+			score = score.Mul(decimal.NewFromInt(int64(numTests)))
+		}
+		return score, aggregation, true
+	}
+	if bounds := strings.Fields(td.Range); len(bounds) == 2 {
+		score, err := decimal.NewFromString(bounds[1])
+		return score, aggregation, err == nil
+	}
+	return decimal.Zero, aggregation, false
+}
+
+// kattisTestCase is a test case of the data/ directory. Its group is the subdirectory of data/secret holding it
+type kattisTestCase struct {
+	group string
+	in    *zip.File
+	ans   *zip.File
+}
+
+// ProcessKattisPackage imports a Kattis problem package, as described by its problem.yaml file.
+// Samples are imported as tests worth no points, and the subdirectories of data/secret become subtasks of scoring problems.
+// The default output validator is replaced by an equivalent checker if it has flags, otherwise by the diff checker, which is case sensitive.
+// Custom output validators use a different protocol, so they're only kept for reference, with a warning in the import result. Interactive problems are rejected
+func ProcessKattisPackage(ctx context.Context, actx *ArchiveCtx, ar *zip.Reader) *kilonova.StatusError {
+	files := make(map[string]*zip.File, len(ar.File))
+	for _, file := range ar.File {
+		if !file.FileInfo().IsDir() {
+			files[file.Name] = file
+		}
+	}
+
+	data, err := readZipFile(files["problem.yaml"])
+	if err != nil {
+		return kilonova.WrapError(err, "Couldn't read problem.yaml")
+	}
+	var conf kattisProblemConfig
+	if err := yaml.Unmarshal(data, &conf); err != nil {
+		return kilonova.WrapError(err, "Invalid problem.yaml")
+	}
+
+	if actx.props == nil {
+		actx.props = &properties{}
+	}
+	if name := kattisProblemName(conf.Name); name != "" {
+		actx.props.ProblemName = &name
+	}
+	if source, ok := conf.Source.(string); ok && strings.TrimSpace(source) != "" {
+		source = strings.TrimSpace(source)
+		actx.props.Source = &source
+	}
+	for _, keyword := range kattisKeywords(conf.Keywords) {
+		actx.props.Tags = append(actx.props.Tags, &mockTag{Name: keyword, Type: kilonova.TagTypeOther})
+	}
+	if conf.Limits.Memory > 0 {
+		memoryLimit := conf.Limits.Memory * 1024
+		actx.props.MemoryLimit = &memoryLimit
+	}
+	if conf.Limits.TimeLimit > 0 {
+		actx.props.TimeLimit = &conf.Limits.TimeLimit
+	} else if file, ok := files[".timelimit"]; ok {
+		// The time limit is usually computed by the Kattis tools, which save it here
+		data, err := readZipFile(file)
+		if err == nil {
+			if timeLimit, err := strconv.ParseFloat(strings.TrimSpace(string(data)), 64); err == nil && timeLimit > 0 {
+				actx.props.TimeLimit = &timeLimit
+			}
+		}
+	}
+	// Problem packages always use the standard streams
+	setFileIO(actx, "", "")
+
+	samples, err1 := processKattisTests(actx, files, conf.Type == "scoring")
+	if err1 != nil {
+		return err1
+	}
+	if err := processKattisValidation(actx, files, &conf); err != nil {
+		return err
+	}
+	if err := processKattisSubmissions(actx, files); err != nil {
+		return err
+	}
+	return processKattisStatements(actx, files, samples)
+}
+
+// kattisProblemName returns the name of the problem, preferring the romanian and english names of multilingual packages
+func kattisProblemName(name any) string {
+	switch name := name.(type) {
+	case string:
+		return strings.TrimSpace(name)
+	case map[string]any:
+		for _, lang := range []string{"ro", "en"} {
+			if val, ok := name[lang].(string); ok && strings.TrimSpace(val) != "" {
+				return strings.TrimSpace(val)
+			}
+		}
+		langs := make([]string, 0, len(name))
+		for lang := range name {
+			langs = append(langs, lang)
+		}
+		slices.Sort(langs)
+		for _, lang := range langs {
+			if val, ok := name[lang].(string); ok && strings.TrimSpace(val) != "" {
+				return strings.TrimSpace(val)
+			}
+		}
+	}
+	return ""
+}
+
+// kattisKeywords returns the keywords of the problem, given either as a space separated string or as a list
+func kattisKeywords(keywords any) []string {
+	switch keywords := keywords.(type) {
+	case string:
+		return strings.Fields(keywords)
+	case []any:
+		var vals []string
+		for _, keyword := range keywords {
+			if val, ok := keyword.(string); ok && strings.TrimSpace(val) != "" {
+				vals = append(vals, strings.TrimSpace(val))
+			}
+		}
+		return vals
+	}
+	return nil
+}
+
+// kattisTestCases returns the test cases in the given directory and its subdirectories, sorted by their path
+func kattisTestCases(files map[string]*zip.File, dir string) ([]kattisTestCase, *kilonova.StatusError) {
+	var tests []kattisTestCase
+	for name, file := range files {
+		if !strings.HasPrefix(name, dir+"/") || path.Ext(name) != ".in" {
+			continue
+		}
+		ans, ok := files[strings.TrimSuffix(name, ".in")+".ans"]
+		if !ok {
+			return nil, kilonova.Statusf(400, "Missing answer file for test %q", name)
+		}
+		var group string
+		if val, _, ok := strings.Cut(strings.TrimPrefix(name, dir+"/"), "/"); ok {
+			group = val
+		}
+		tests = append(tests, kattisTestCase{group: group, in: file, ans: ans})
+	}
+	slices.SortFunc(tests, func(a, b kattisTestCase) int { return strings.Compare(a.in.Name, b.in.Name) })
+	return tests, nil
+}
+
+// processKattisTests loads the samples, followed by the secret tests. It returns the samples, for generating statements
+func processKattisTests(actx *ArchiveCtx, files map[string]*zip.File, scoring bool) ([]statementSample, *kilonova.StatusError) {
+	samples, err := kattisTestCases(files, "data/sample")
+	if err != nil {
+		return nil, err
+	}
+	secret, err := kattisTestCases(files, "data/secret")
+	if err != nil {
+		return nil, err
+	}
+	if !scoring && actx.params.FirstImport && len(actx.params.ScoreParamsStr) == 0 {
+		actx.props.ScoringStrategy = kilonova.ScoringTypeICPC
+	}
+
+	for i, tc := range append(slices.Clone(samples), secret...) {
+		key := strconv.Itoa(i + 1)
+		actx.tests[key] = archiveTest{Key: key, InFile: tc.in, OutFile: tc.ans}
+	}
+
+	examples := make([]statementSample, 0, len(samples))
+	for i, tc := range samples {
+		actx.testScores[i+1] = decimal.Zero
+		in, err := readZipFile(tc.in)
+		if err != nil {
+			return nil, kilonova.WrapError(err, "Couldn't read example input")
+		}
+		out, err := readZipFile(tc.ans)
+		if err != nil {
+			return nil, kilonova.WrapError(err, "Couldn't read example output")
+		}
+		examples = append(examples, statementSample{Input: string(in), Output: string(out)})
+	}
+
+	// Score parameters given on upload take precedence
+	if scoring && len(actx.scoreParameters) == 0 {
+		if err := processKattisGroups(actx, files, len(samples), secret); err != nil {
+			return nil, err
+		}
+	}
+	return examples, nil
+}
+
+func readKattisTestdata(files map[string]*zip.File, dir string) (*kattisTestdata, *kilonova.StatusError) {
+	var td kattisTestdata
+	file, ok := files[path.Join(dir, "testdata.yaml")]
+	if !ok {
+		return &td, nil
+	}
+	data, err := readZipFile(file)
+	if err != nil {
+		return nil, kilonova.WrapError(err, "Couldn't read testdata.yaml")
+	}
+	if err := yaml.Unmarshal(data, &td); err != nil {
+		return nil, kilonova.Statusf(400, "Invalid testdata.yaml in %q", dir)
+	}
+	return &td, nil
+}
+
+// processKattisGroups sets the scores of the secret tests. Test groups become subtasks, numbered in order, with the samples as subtask 0.
+// Groups without an explicit score share the remaining points equally
+func processKattisGroups(actx *ArchiveCtx, files map[string]*zip.File, numSamples int, secret []kattisTestCase) *kilonova.StatusError {
+	var groups []string
+	groupTests := make(map[string][]int)
+	for i, tc := range secret {
+		if _, ok := groupTests[tc.group]; !ok {
+			groups = append(groups, tc.group)
+		}
+		groupTests[tc.group] = append(groupTests[tc.group], numSamples+i+1)
+	}
+	if len(groups) == 0 {
+		return nil
+	}
+
+	if len(groups) == 1 && groups[0] == "" {
+		// Without groups, each test is worth the accepted score of data/secret
+		td, err := readKattisTestdata(files, "data/secret")
+		if err != nil {
+			return err
+		}
+		if score, _, ok := td.groupScore(1); ok {
+			for _, id := range groupTests[""] {
+				actx.testScores[id] = score
+			}
+		}
+		return nil
+	}
+
+	subtasks := make(map[string]parsedSubtask)
+	if numSamples > 0 {
+		stk := parsedSubtask{Aggregation: kilonova.AggregationMin}
+		for i := range numSamples {
+			stk.Tests = append(stk.Tests, i+1)
+		}
+		subtasks["0"] = stk
+	}
+
+	var unscored []string
+	remaining := decimal.NewFromInt(100)
+	for i, group := range groups {
+		td, err := readKattisTestdata(files, path.Join("data/secret", group))
+		if err != nil {
+			return err
+		}
+		score, aggregation, ok := td.groupScore(len(groupTests[group]))
+		key := strconv.Itoa(i + 1)
+		if ok {
+			remaining = remaining.Sub(score)
+		} else {
+			unscored = append(unscored, key)
+		}
+		subtasks[key] = parsedSubtask{Score: score, Tests: groupTests[group], Aggregation: aggregation}
+	}
+
+	if len(unscored) > 0 {
+		remaining = decimal.Max(remaining, decimal.Zero)
+		share := remaining.Div(decimal.NewFromInt(int64(len(unscored)))).RoundDown(2)
+		for i, key := range unscored {
+			stk := subtasks[key]
+			stk.Score = share
+			if i == len(unscored)-1 {
+				stk.Score = remaining.Sub(share.Mul(decimal.NewFromInt(int64(len(unscored) - 1))))
+			}
+			subtasks[key] = stk
+		}
+	}
+
+	actx.props.Subtasks, actx.props.SubtaskedTests = solveSubtaskDependencies(subtasks)
+	return nil
+}
+
+// kattisChecker returns the source of the checker equivalent to the default output validator with the given flags
+func kattisChecker(flags string) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s%s\n#define VALIDATOR_FLAGS %q\n", kattisValidatorFlagsMarker, flags, flags)
+	buf.Write(kattisDefaultValidator)
+	return buf.Bytes()
+}
+
+// processKattisValidation sets up the checker corresponding to the output validation of the package
+func processKattisValidation(actx *ArchiveCtx, files map[string]*zip.File, conf *kattisProblemConfig) *kilonova.StatusError {
+	validation := strings.Fields(conf.Validation)
+	if len(validation) == 0 || validation[0] == "default" {
+		flags := strings.Join(strings.Fields(conf.ValidatorFlags), " ")
+		if flags == "" {
+			return nil
+		}
+		if !kattisValidatorFlagsRegex.MatchString(flags) {
+			return kilonova.Statusf(400, "Invalid validator flags %q", flags)
+		}
+		name := langAttachmentName("checker", "cpp17")
+		actx.attachments[name] = archiveAttachment{Data: kattisChecker(flags), Name: name, Private: true, Exec: true}
+		return nil
+	}
+
+	if slices.Contains(validation, "interactive") {
+		return kilonova.Statusf(400, "Interactive Kattis problems are not supported, since the output validator can't run alongside submissions")
+	}
+	var imported []string
+	for name, file := range files {
+		if !strings.HasPrefix(name, "output_validators/") {
+			continue
+		}
+		attName := "output_validator_" + path.Base(name)
+		actx.attachments[attName] = archiveAttachment{File: file, Name: attName, Private: true}
+		imported = append(imported, attName)
+	}
+	slices.Sort(imported)
+	actx.warnf("Custom Kattis output validators are not supported, tests are judged by comparing the outputs until a checker is added. The validator files were imported as private attachments: %s", strings.Join(imported, ", "))
+	return nil
+}
+
+// processKattisSubmissions imports the solutions in submissions/, whose directories give their expected verdicts.
+// The first accepted solution is also used as the author solution
+func processKattisSubmissions(actx *ArchiveCtx, files map[string]*zip.File) *kilonova.StatusError {
+	var names []string
+	for name := range files {
+		if strings.HasPrefix(name, "submissions/") {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	var hasSolution bool
+	for _, name := range names {
+		dir, base, ok := strings.Cut(strings.TrimPrefix(name, "submissions/"), "/")
+		if !ok || strings.Contains(base, "/") {
+			zap.S().Debugf("Skipping submission file %q", name)
+			continue
+		}
+		lang := eval.GetLangByFilename(base)
+		if lang == "" {
+			zap.S().Warnf("Unrecognized submission language for file %q", name)
+			continue
+		}
+		code, err := readZipFile(files[name])
+		if err != nil {
+			return kilonova.WrapError(err, "Couldn't read submission file")
+		}
+		var verdict string
+		if kilonova.ValidExpectedVerdict(dir) {
+			verdict = dir
+		}
+		if verdict == kilonova.ExpectedAccepted && !hasSolution {
+			attName := langAttachmentName("solution", lang)
+			actx.attachments[attName] = archiveAttachment{File: files[name], Name: attName, Private: true, Exec: true}
+			hasSolution = true
+		}
+		actx.submissions = append(actx.submissions, &submissionStub{code: code, lang: lang, expectedVerdict: verdict})
+	}
+	return nil
+}
+
+var kattisStatementRegex = regexp.MustCompile(`^(?:problem_statement|statement)/problem(?:\.([a-z]{2}))?\.(tex|md|pdf)$`)
+
+// processKattisStatements imports the markdown and PDF statements as they are.
+// LaTeX statements are kept as private sources and converted to markdown if there is no markdown statement in their language.
+// The other files in the statement directory, such as images, become attachments
+func processKattisStatements(actx *ArchiveCtx, files map[string]*zip.File, samples []statementSample) *kilonova.StatusError {
+	texStatements := make(map[string]*zip.File)
+	images := make(map[string]string)
+	for name, file := range files {
+		if dir := path.Dir(name); dir != "problem_statement" && dir != "statement" {
+			continue
+		}
+		if matches := kattisStatementRegex.FindStringSubmatch(name); matches != nil {
+			lang := matches[1]
+			if lang == "" {
+				lang = "en"
+			}
+			attName := fmt.Sprintf("statement-%s.%s", lang, matches[2])
+			if matches[2] == "tex" {
+				texStatements[lang] = file
+				actx.attachments[attName] = archiveAttachment{File: file, Name: attName, Private: true}
+				continue
+			}
+			actx.attachments[attName] = archiveAttachment{File: file, Name: attName}
+			continue
+		}
+		base := path.Base(name)
+		switch path.Ext(base) {
+		case ".tex", ".cls", ".sty":
+			continue
+		case ".png", ".jpg", ".jpeg", ".svg", ".gif":
+			images[strings.TrimSuffix(base, path.Ext(base))] = base
+		}
+		if _, ok := actx.attachments[base]; !ok {
+			actx.attachments[base] = archiveAttachment{File: file, Name: base}
+		}
+	}
+
+	langs := make([]string, 0, len(texStatements))
+	for lang := range texStatements {
+		langs = append(langs, lang)
+	}
+	slices.Sort(langs)
+	for _, lang := range langs {
+		name := fmt.Sprintf("statement-%s.md", lang)
+		if _, ok := actx.attachments[name]; ok {
+			continue
+		}
+		data, err := readZipFile(texStatements[lang])
+		if err != nil {
+			return kilonova.WrapError(err, "Couldn't read statement")
+		}
+		problemName, md := kattisTexToMarkdown(string(data), lang, images, samples)
+		if actx.props.ProblemName == nil && problemName != "" {
+			actx.props.ProblemName = &problemName
+		}
+		actx.attachments[name] = archiveAttachment{Data: md, Name: name}
+	}
+	return nil
+}
+
+var (
+	kattisProblemNameRegex  = regexp.MustCompile(`\\problemname\{([^{}]*)\}`)
+	kattisSectionRegex      = regexp.MustCompile(`\\(?:sub)*section\*?\{([^{}]*)\}`)
+	kattisIllustrationRegex = regexp.MustCompile(`\\illustration\{[^{}]*\}\{([^{}]*)\}\{[^{}]*\}`)
+	kattisGraphicsRegex     = regexp.MustCompile(`(\\includegraphics(?:\[[^\]]*\])?)\{([^{}.]*)\}`)
+	kattisBlankLinesRegex   = regexp.MustCompile(`\n{3,}`)
+)
+
+// kattisTexToMarkdown converts a LaTeX statement to markdown, adding the examples at its end.
+// It also returns the name of the problem given in the statement.
+// Images are usually referenced without extension, so they're looked up in the given map of image names
+func kattisTexToMarkdown(tex string, lang string, images map[string]string, samples []statementSample) (string, []byte) {
+	var name string
+	if matches := kattisProblemNameRegex.FindStringSubmatch(tex); matches != nil {
+		name = strings.TrimSpace(matches[1])
+	}
+	tex = kattisProblemNameRegex.ReplaceAllString(tex, "")
+	tex = kattisIllustrationRegex.ReplaceAllString(tex, `\includegraphics{$1}`)
+	tex = kattisGraphicsRegex.ReplaceAllStringFunc(tex, func(cmd string) string {
+		matches := kattisGraphicsRegex.FindStringSubmatch(cmd)
+		if image, ok := images[matches[2]]; ok {
+			return matches[1] + "{" + image + "}"
+		}
+		return cmd
+	})
+	tex = kattisSectionRegex.ReplaceAllString(tex, "\n\n# $1\n\n")
+
+	headings, ok := statementHeadings[lang]
+	if !ok {
+		headings = statementHeadings["en"]
+	}
+	var buf bytes.Buffer
+	md := polygonTexToMarkdown(strings.TrimSpace(tex))
+	buf.WriteString(strings.TrimSpace(kattisBlankLinesRegex.ReplaceAllString(md, "\n\n")))
+	buf.WriteString("\n\n")
+	writeStatementExamples(&buf, headings["example"], "", "", samples)
+	return name, buf.Bytes()
+}
+
+// generateKattisArchive writes the problem as a Kattis problem package.
+// Disjoint subtasks become test groups, while overlapping subtasks can't be represented, so their tests are exported without scores.
+// Checkers generated for the default output validator are exported as its flags, the other ones are skipped
+func (ag *archiveGenerator) generateKattisArchive(ctx context.Context) *kilonova.StatusError {
+	conf := kattisProblemConfig{Name: ag.pb.Name, Type: "scoring"}
+	if ag.pb.ScoringStrategy == kilonova.ScoringTypeICPC {
+		conf.Type = "pass-fail"
+	}
+	if ag.opts.ProblemDetails {
+		if ag.pb.SourceCredits != "" {
+			conf.Source = ag.pb.SourceCredits
+		}
+		conf.Limits = kattisLimits{
+			TimeLimit: ag.pb.TimeLimit,
+			Memory:    int(math.Ceil(float64(ag.pb.MemoryLimit) / 1024.0)),
+		}
+		if !ag.pb.ConsoleInput {
+			zap.S().Warn("Kattis problems use the standard streams, the input and output files are not exported")
+		}
+	}
+	if ag.opts.Tags {
+		tags, err := ag.base.ProblemTags(ctx, ag.pb.ID)
+		if err != nil {
+			return err
+		}
+		keywords := make([]string, 0, len(tags))
+		for _, tag := range tags {
+			keywords = append(keywords, tag.Name)
+		}
+		if len(keywords) > 0 {
+			conf.Keywords = keywords
+		}
+	}
+
+	settings, err := ag.base.ProblemSettings(ctx, ag.pb.ID)
+	if err != nil {
+		return err
+	}
+	if settings.CheckerName != "" {
+		data, err := ag.base.ProblemAttDataByName(ctx, ag.pb.ID, settings.CheckerName)
+		if err != nil {
+			return kilonova.WrapError(err, "Couldn't get checker")
+		}
+		firstLine, _, _ := strings.Cut(string(data), "\n")
+		if flags, ok := strings.CutPrefix(firstLine, kattisValidatorFlagsMarker); ok && !settings.LegacyChecker {
+			conf.Validation, conf.ValidatorFlags = "default", flags
+		} else {
+			zap.S().Warnf("Checker %q can't be exported as a Kattis output validator", settings.CheckerName)
+		}
+	}
+
+	data, err1 := yaml.Marshal(conf)
+	if err1 != nil {
+		return kilonova.WrapError(err1, "Couldn't encode problem.yaml")
+	}
+	if err := ag.writeFile("problem.yaml", data); err != nil {
+		return err
+	}
+
+	if ag.opts.Tests {
+		if err := ag.addKattisTests(ctx, conf.Type == "scoring"); err != nil {
+			return err
+		}
+	}
+	if ag.opts.Attachments {
+		if err := ag.addKattisAttachments(ctx, settings); err != nil {
+			return err
+		}
+	}
+	if ag.opts.Submissions {
+		if err := ag.addSubmissions(ctx, func(sub *kilonova.Submission, lang eval.Language) string {
+			return fmt.Sprintf("submissions/%s/%d%s", kattisSubmissionDir(sub), sub.ID, lang.Extensions[0])
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// kattisSubmissionDir returns the directory of the submission's expected verdict, deduced from its score if it's not set
+func kattisSubmissionDir(sub *kilonova.Submission) string {
+	if sub.ExpectedVerdict != nil && *sub.ExpectedVerdict != "" {
+		return *sub.ExpectedVerdict
+	}
+	if sub.Score.GreaterThanOrEqual(decimal.NewFromInt(100)) {
+		return kilonova.ExpectedAccepted
+	}
+	return kilonova.ExpectedWrongAnswer
+}
+
+// addKattisTests writes the tests in data/secret. Scores of scoring problems are kept with a test group for each subtask,
+// or with a single group if all tests have the same score
+func (ag *archiveGenerator) addKattisTests(ctx context.Context, scoring bool) *kilonova.StatusError {
+	tests, err := ag.base.Tests(ctx, ag.pb.ID)
+	if err != nil {
+		return err
+	}
+	slices.SortFunc(tests, func(a, b *kilonova.Test) int { return a.VisibleID - b.VisibleID })
+	subtasks, err := ag.base.SubTasks(ctx, ag.pb.ID)
+	if err != nil {
+		return err
+	}
+
+	dirs := make(map[int]string, len(tests))
+	groups := make(map[string]*kattisTestdata)
+	if scoring {
+		secret := &kattisTestdata{OnReject: "continue"}
+		groups["data/secret"] = secret
+		switch {
+		case len(subtasks) == 0:
+			if len(tests) > 0 && !slices.ContainsFunc(tests, func(test *kilonova.Test) bool { return !test.Score.Equal(tests[0].Score) }) {
+				secret.AcceptScore = tests[0].Score.String()
+			} else {
+				zap.S().Warn("Tests with different scores can't be exported to Kattis, their scores are dropped")
+			}
+		case !kattisDisjointSubtasks(subtasks):
+			zap.S().Warn("Overlapping subtasks can't be exported to Kattis, their scores are dropped")
+		default:
+			for _, st := range subtasks {
+				dir := fmt.Sprintf("data/secret/group%d", st.VisibleID)
+				td := &kattisTestdata{OnReject: "continue", Range: "0 " + st.Score.String()}
+				if st.Aggregation == kilonova.AggregationMin || len(st.Tests) == 0 {
+					td.AcceptScore, td.GraderFlags = st.Score.String(), "min"
+				} else {
+					td.AcceptScore = st.Score.Div(decimal.NewFromInt(int64(len(st.Tests)))).String()
+				}
+				groups[dir] = td
+				for _, id := range st.Tests {
+					dirs[id] = dir
+				}
+			}
+		}
+	}
+
+	for _, test := range tests {
+		dir, ok := dirs[test.ID]
+		if !ok {
+			dir = "data/secret"
+		}
+		name := fmt.Sprintf("%s/%03d", dir, test.VisibleID)
+		if err := ag.copyTestFile(name+".in", test.ID, ag.base.TestInput); err != nil {
+			return err
+		}
+		if err := ag.copyTestFile(name+".ans", test.ID, ag.base.TestOutput); err != nil {
+			return err
+		}
+	}
+
+	for dir, td := range groups {
+		data, err := yaml.Marshal(td)
+		if err != nil {
+			return kilonova.WrapError(err, "Couldn't encode testdata.yaml")
+		}
+		if err := ag.writeFile(path.Join(dir, "testdata.yaml"), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// kattisDisjointSubtasks returns true if each test belongs to at most one subtask
+func kattisDisjointSubtasks(subtasks []*kilonova.SubTask) bool {
+	seen := make(map[int]bool)
+	for _, st := range subtasks {
+		for _, id := range st.Tests {
+			if seen[id] {
+				return false
+			}
+			seen[id] = true
+		}
+	}
+	return true
+}
+
+var kattisStatementAttRegex = regexp.MustCompile(`^statement-([a-z]{2})\.(tex|md|pdf)$`)
+
+// addKattisAttachments places the statements and their resources in problem_statement/ and the author solution in submissions/accepted/.
+// Graders and the other statement variants, such as editorials, have no place in the package, so they're skipped
+func (ag *archiveGenerator) addKattisAttachments(ctx context.Context, settings *kilonova.ProblemEvalSettings) *kilonova.StatusError {
+	atts, err := ag.base.ProblemAttachments(ctx, ag.pb.ID)
+	if err != nil {
+		return kilonova.WrapError(err, "Couldn't get attachments")
+	}
+	for _, att := range atts {
+		if att.Private && !ag.opts.PrivateAttachments {
+			continue
+		}
+		var name string
+		switch {
+		case att.Name == settings.CheckerName, att.Name == settings.ValidatorName:
+			continue
+		case att.Name == settings.SolutionName:
+			name = "submissions/accepted/solution" + cmsSourceExt(att.Name)
+		case att.Exec:
+			zap.S().Warnf("Skipping attachment %q, since Kattis problems don't support graders", att.Name)
+			continue
+		case kattisStatementAttRegex.MatchString(att.Name):
+			matches := kattisStatementAttRegex.FindStringSubmatch(att.Name)
+			name = fmt.Sprintf("problem_statement/problem.%s.%s", matches[1], matches[2])
+		case strings.HasPrefix(att.Name, "statement-"):
+			zap.S().Debugf("Skipping statement variant %q", att.Name)
+			continue
+		default:
+			name = "problem_statement/" + att.Name
+		}
+		data, err := ag.base.AttachmentData(ctx, att.ID)
+		if err != nil {
+			return kilonova.WrapError(err, "Couldn't get attachment data")
+		}
+		if err := ag.writeFile(name, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package test
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/shopspring/decimal"
+)
+
+// kattisFixture is a pass-fail package with two samples and two secret tests
+var kattisFixture = map[string]string{
+	"problem.yaml": `name: Sum
+validator_flags: float_tolerance 1e-6
+limits:
+  time_limit: 2
+  memory: 512
+`,
+	"data/sample/1.in": "1 2\n", "data/sample/1.ans": "3\n",
+	"data/sample/2.in": "2 2\n", "data/sample/2.ans": "4\n",
+	"data/secret/a.in": "3 4\n", "data/secret/a.ans": "7\n",
+	"data/secret/b.in": "5 5\n", "data/secret/b.ans": "10\n",
+	"problem_statement/problem.en.tex": "\\problemname{Sum}\nAdd the numbers.\n",
+}
+
+func kattisPackage(t *testing.T, actx *ArchiveCtx, extra map[string]string) *kilonova.StatusError {
+	t.Helper()
+	files := make(map[string]string, len(kattisFixture)+len(extra))
+	for name, data := range kattisFixture {
+		files[name] = data
+	}
+	for name, data := range extra {
+		files[name] = data
+	}
+	return ProcessKattisPackage(context.Background(), actx, zipFixture(t, files))
+}
+
+func TestKattisPackage(t *testing.T) {
+	actx := NewArchiveCtx(&TestProcessParams{FirstImport: true})
+	if err := kattisPackage(t, actx, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if actx.props.TimeLimit == nil || *actx.props.TimeLimit != 2 {
+		t.Errorf("Unexpected time limit %v", actx.props.TimeLimit)
+	}
+	if actx.props.MemoryLimit == nil || *actx.props.MemoryLimit != 512*1024 {
+		t.Errorf("Unexpected memory limit %v", actx.props.MemoryLimit)
+	}
+	if actx.props.ScoringStrategy != kilonova.ScoringTypeICPC {
+		t.Errorf("Expected pass-fail problems to use ICPC scoring, got %q", actx.props.ScoringStrategy)
+	}
+
+	// Samples come first and are worth no points
+	inputs := []string{"1 2\n", "2 2\n", "3 4\n", "5 5\n"}
+	if len(actx.tests) != len(inputs) {
+		t.Fatalf("Expected %d tests, got %d", len(inputs), len(actx.tests))
+	}
+	for i, input := range inputs {
+		test := actx.tests[strconv.Itoa(i+1)]
+		if in, err := readArchiveTest(test.openInput); err != nil || string(in) != input {
+			t.Errorf("Test %d has input %q (err: %v), expected %q", i+1, in, err, input)
+		}
+	}
+	for _, id := range []int{1, 2} {
+		if score, ok := actx.testScores[id]; !ok || !score.IsZero() {
+			t.Errorf("Expected sample %d to be worth no points, got %v", id, actx.testScores[id])
+		}
+	}
+	for _, id := range []int{3, 4} {
+		if _, ok := actx.testScores[id]; ok {
+			t.Errorf("Expected secret test %d to keep the default score", id)
+		}
+	}
+
+	// The samples are the examples of the converted statement
+	statement, ok := actx.attachments["statement-en.md"]
+	if !ok {
+		t.Fatal("Missing converted statement")
+	}
+	for _, sample := range []string{"1 2", "3", "2 2", "4"} {
+		if !strings.Contains(string(statement.Data), sample) {
+			t.Errorf("Example %q is missing from the statement:\n%s", sample, statement.Data)
+		}
+	}
+
+	checker, ok := actx.attachments[langAttachmentName("checker", "cpp17")]
+	if !ok || !checker.Private || !checker.Exec {
+		t.Fatalf("Expected a private checker, got %#v", checker)
+	}
+	if !strings.HasPrefix(string(checker.Data), kattisValidatorFlagsMarker+"float_tolerance 1e-6\n") {
+		t.Errorf("Checker doesn't start with its flags")
+	}
+}
+
+func TestKattisDefaultValidation(t *testing.T) {
+	// Without flags, the diff checker is used
+	actx := NewArchiveCtx(&TestProcessParams{})
+	if err := kattisPackage(t, actx, map[string]string{"problem.yaml": "name: Sum\n"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := actx.attachments[langAttachmentName("checker", "cpp17")]; ok {
+		t.Error("Expected no checker without validator flags")
+	}
+
+	if err := kattisPackage(t, NewArchiveCtx(&TestProcessParams{}), map[string]string{"problem.yaml": "validator_flags: \"a'; system(\"\n"}); err == nil {
+		t.Error("Expected invalid validator flags to be rejected")
+	}
+}
+
+func TestKattisCustomValidation(t *testing.T) {
+	actx := NewArchiveCtx(&TestProcessParams{})
+	err := kattisPackage(t, actx, map[string]string{
+		"problem.yaml":                      "name: Sum\nvalidation: custom\n",
+		"output_validators/check/check.cpp": "int main() { return 42; }\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := actx.attachments["output_validator_check.cpp"]; !ok {
+		t.Error("Expected the validator to be kept as an attachment")
+	}
+	if _, ok := actx.attachments[langAttachmentName("checker", "cpp17")]; ok {
+		t.Error("Expected no checker for custom validation")
+	}
+	if !slices.ContainsFunc(actx.warnings, func(w string) bool { return strings.Contains(w, "output_validator_check.cpp") }) {
+		t.Errorf("Missing warning about the custom validator in %q", actx.warnings)
+	}
+
+	err = kattisPackage(t, NewArchiveCtx(&TestProcessParams{}), map[string]string{"problem.yaml": "validation: custom interactive\n"})
+	if err == nil {
+		t.Error("Interactive problems should be rejected")
+	}
+}
+
+func TestKattisValidatorFlags(t *testing.T) {
+	compiler, err := exec.LookPath("g++")
+	if err != nil {
+		t.Skip("g++ is required to build the checker")
+	}
+
+	tests := []struct {
+		flags string
+		judge string
+		team  string
+		score string
+	}{
+		{"", "Yes 3\n", "yes   3", "1"},
+		{"", "1.5\n", "1.5000001\n", "0"},
+		{"", "a b\n", "a\n", "0"},
+		{"case_sensitive", "Yes\n", "yes\n", "0"},
+		{"case_sensitive", "Yes\n", "Yes\n", "1"},
+		{"space_change_sensitive", "1 2\n", "1  2\n", "0"},
+		{"space_change_sensitive", "1 2\n", "1 2\n", "1"},
+		{"space_change_sensitive", "1 2\n", "1 2", "0"},
+		{"float_tolerance 1e-6", "1.5\n", "1.5000001\n", "1"},
+		{"float_tolerance 1e-6", "1.5\n", "1.5001\n", "0"},
+		{"float_tolerance 1e-6", "1000000\n", "1000000.5\n", "1"},
+		{"float_tolerance 1e-6", "1.5\n", "abc\n", "0"},
+		{"float_absolute_tolerance 1e-6", "1000000\n", "1000000.5\n", "0"},
+		{"float_relative_tolerance 1e-6", "1000000\n", "1000000.5\n", "1"},
+		{"float_tolerance 1e-6 case_sensitive", "1.0 Yes\n", "1.0000001 YES\n", "0"},
+	}
+
+	dir := t.TempDir()
+	checkers := make(map[string]string)
+	for _, tt := range tests {
+		if _, ok := checkers[tt.flags]; ok {
+			continue
+		}
+		src := filepath.Join(dir, "checker.cpp")
+		if err := os.WriteFile(src, kattisChecker(tt.flags), 0644); err != nil {
+			t.Fatal(err)
+		}
+		bin := filepath.Join(dir, "checker"+strconv.Itoa(len(checkers)))
+		if out, err := exec.Command(compiler, "-std=c++17", "-O2", "-o", bin, src).CombinedOutput(); err != nil {
+			t.Fatalf("Couldn't compile the checker with flags %q: %v\n%s", tt.flags, err, out)
+		}
+		checkers[tt.flags] = bin
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.flags+"/"+tt.team, func(t *testing.T) {
+			files := make([]string, 3)
+			for i, data := range []string{"", tt.judge, tt.team} {
+				files[i] = filepath.Join(t.TempDir(), "file")
+				if err := os.WriteFile(files[i], []byte(data), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			out, err := exec.Command(checkers[tt.flags], files...).Output()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if score := strings.TrimSpace(string(out)); !decimal.RequireFromString(score).Equal(decimal.RequireFromString(tt.score)) {
+				t.Errorf("Expected score %s for %q against %q, got %s", tt.score, tt.team, tt.judge, score)
+			}
+		})
+	}
+}
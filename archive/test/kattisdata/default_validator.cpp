@@ -0,0 +1,123 @@
+// Checker equivalent to the default output validator of Kattis problem packages.
+// It compares the tokens of the outputs, as configured by VALIDATOR_FLAGS:
+//  - case_sensitive: letters must have the same case;
+//  - space_change_sensitive: whitespace must match exactly;
+//  - float_tolerance e, float_absolute_tolerance e, float_relative_tolerance e: numbers may differ by at most e.
+#include <cctype>
+#include <cmath>
+#include <cstdio>
+#include <cstdlib>
+#include <fstream>
+#include <sstream>
+#include <string>
+
+#ifndef VALIDATOR_FLAGS
+#define VALIDATOR_FLAGS ""
+#endif
+
+static bool caseSensitive = false, spaceSensitive = false;
+static double absoluteTolerance = -1, relativeTolerance = -1;
+
+static void verdict(double score, const std::string &message) {
+	printf("%g\n", score);
+	fprintf(stderr, "%s\n", message.c_str());
+	exit(0);
+}
+
+static void parseFlags() {
+	std::istringstream in(VALIDATOR_FLAGS);
+	std::string flag;
+	while (in >> flag) {
+		if (flag == "case_sensitive") {
+			caseSensitive = true;
+		} else if (flag == "space_change_sensitive") {
+			spaceSensitive = true;
+		} else if (flag == "float_tolerance") {
+			in >> absoluteTolerance;
+			relativeTolerance = absoluteTolerance;
+		} else if (flag == "float_absolute_tolerance") {
+			in >> absoluteTolerance;
+		} else if (flag == "float_relative_tolerance") {
+			in >> relativeTolerance;
+		}
+	}
+}
+
+// nextToken reads the next token. If whitespace changes matter, runs of whitespace are tokens as well
+static bool nextToken(std::istream &in, std::string &token) {
+	token.clear();
+	int c = in.peek();
+	if (!spaceSensitive) {
+		while (c != EOF && isspace(c)) {
+			in.get();
+			c = in.peek();
+		}
+	}
+	if (c == EOF) {
+		return false;
+	}
+	bool space = isspace(c);
+	while (c != EOF && (bool)isspace(c) == space) {
+		token += (char)in.get();
+		c = in.peek();
+	}
+	return true;
+}
+
+static bool parseFloat(const std::string &token, double &value) {
+	char *end;
+	value = strtod(token.c_str(), &end);
+	return !token.empty() && *end == '\0' && std::isfinite(value);
+}
+
+static bool sameTokens(const std::string &judge, const std::string &team) {
+	double judgeValue, teamValue;
+	if ((absoluteTolerance >= 0 || relativeTolerance >= 0) && parseFloat(judge, judgeValue)) {
+		if (!parseFloat(team, teamValue)) {
+			return false;
+		}
+		double diff = fabs(judgeValue - teamValue);
+		return (absoluteTolerance >= 0 && diff <= absoluteTolerance) ||
+		       (relativeTolerance >= 0 && diff <= relativeTolerance * fabs(judgeValue));
+	}
+	if (caseSensitive) {
+		return judge == team;
+	}
+	if (judge.size() != team.size()) {
+		return false;
+	}
+	for (size_t i = 0; i < judge.size(); i++) {
+		if (tolower((unsigned char)judge[i]) != tolower((unsigned char)team[i])) {
+			return false;
+		}
+	}
+	return true;
+}
+
+int main(int argc, char **argv) {
+	if (argc < 4) {
+		verdict(0, "Invalid checker arguments");
+	}
+	parseFlags();
+	std::ifstream judge(argv[2]), team(argv[3]);
+	if (!judge || !team) {
+		verdict(0, "Couldn't open output files");
+	}
+
+	std::string judgeToken, teamToken;
+	for (int i = 1;; i++) {
+		bool hasJudge = nextToken(judge, judgeToken), hasTeam = nextToken(team, teamToken);
+		if (!hasJudge && !hasTeam) {
+			verdict(1, "translate:success");
+		}
+		if (!hasJudge) {
+			verdict(0, "Output has more tokens than expected");
+		}
+		if (!hasTeam) {
+			verdict(0, "Output has fewer tokens than expected");
+		}
+		if (!sameTokens(judgeToken, teamToken)) {
+			verdict(0, "Token " + std::to_string(i) + " differs");
+		}
+	}
+}
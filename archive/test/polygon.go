@@ -0,0 +1,903 @@
+package test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/eval"
+	"github.com/KiloProjects/kilonova/sudoapi"
+	"github.com/antchfx/xmlquery"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+// polygonLanguages maps the statement languages of Polygon to the language codes of statement variants
+var polygonLanguages = map[string]string{
+	"english":     "en",
+	"romanian":    "ro",
+	"russian":     "ru",
+	"ukrainian":   "uk",
+	"french":      "fr",
+	"german":      "de",
+	"spanish":     "es",
+	"italian":     "it",
+	"polish":      "pl",
+	"portuguese":  "pt",
+	"hungarian":   "hu",
+	"bulgarian":   "bg",
+	"serbian":     "sr",
+	"croatian":    "hr",
+	"czech":       "cs",
+	"slovak":      "sk",
+	"greek":       "el",
+	"turkish":     "tr",
+	"georgian":    "ka",
+	"armenian":    "hy",
+	"azerbaijani": "az",
+	"kazakh":      "kk",
+	"persian":     "fa",
+	"arabic":      "ar",
+	"chinese":     "zh",
+	"japanese":    "ja",
+	"korean":      "ko",
+	"vietnamese":  "vi",
+	"indonesian":  "id",
+}
+
+// polygonExpectedVerdicts maps the tags of Polygon solutions to expected verdicts. Tags allowing multiple verdicts are left out
+var polygonExpectedVerdicts = map[string]string{
+	"main":                  kilonova.ExpectedAccepted,
+	"accepted":              kilonova.ExpectedAccepted,
+	"wrong-answer":          kilonova.ExpectedWrongAnswer,
+	"presentation-error":    kilonova.ExpectedWrongAnswer,
+	"time-limit-exceeded":   kilonova.ExpectedTimeLimitExceeded,
+	"memory-limit-exceeded": kilonova.ExpectedRunTimeError,
+	"failed":                kilonova.ExpectedRunTimeError,
+	"rejected":              kilonova.ExpectedRejected,
+}
+
+// polygonPackage is a Polygon package, along with its problem.xml descriptor
+type polygonPackage struct {
+	doc   *xmlquery.Node
+	files map[string]*zip.File
+
+	// inputFile is empty if the problem uses the standard streams
+	inputFile  string
+	outputFile string
+}
+
+// polygonTestset holds the tests of a testset, in order
+type polygonTestset struct {
+	name  string
+	node  *xmlquery.Node
+	tests []*archiveTest
+	// samples are the indices of the tests marked as examples
+	samples []int
+}
+
+// statementSample is an example shown in generated statements
+type statementSample struct {
+	Input  string `json:"input"`
+	Output string `json:"output"`
+}
+
+// polygonStatementProps is the problem-properties.json file Polygon generates next to the LaTeX statement sources
+type polygonStatementProps struct {
+	Name        string            `json:"name"`
+	Legend      string            `json:"legend"`
+	Input       string            `json:"input"`
+	Output      string            `json:"output"`
+	Interaction string            `json:"interaction"`
+	Scoring     string            `json:"scoring"`
+	Notes       string            `json:"notes"`
+	Tutorial    string            `json:"tutorial"`
+	SampleTests []statementSample `json:"sampleTests"`
+}
+
+// ProcessPolygonPackage imports a Polygon package, as described by its problem.xml file.
+// Statements, tags, testsets, groups, checkers, validators and solutions are mapped to their Kilonova counterparts.
+// Tests missing from the package are generated on the grader, using the package's generators and main solution.
+// Interactive problems are rejected, and group feedback policies, which have no Kilonova equivalent, are reported as warnings.
+func ProcessPolygonPackage(ctx context.Context, actx *ArchiveCtx, ar *zip.Reader, base *sudoapi.BaseAPI) *kilonova.StatusError {
+	f, err := ar.Open("problem.xml")
+	if err != nil {
+		return kilonova.WrapError(err, "Could not open problem.xml")
+	}
+	defer f.Close()
+	doc, err := xmlquery.Parse(f)
+	if err != nil {
+		return kilonova.WrapError(err, "Could not read problem.xml")
+	}
+
+	pkg := &polygonPackage{doc: doc, files: make(map[string]*zip.File, len(ar.File))}
+	for _, file := range ar.File {
+		if !file.FileInfo().IsDir() {
+			pkg.files[file.Name] = file
+		}
+	}
+	if judging := xmlquery.FindOne(doc, "/problem/judging"); judging != nil {
+		pkg.inputFile, pkg.outputFile = judging.SelectAttr("input-file"), judging.SelectAttr("output-file")
+	}
+
+	if actx.props == nil {
+		actx.props = &properties{}
+	}
+
+	pkg.processNames(actx)
+	pkg.processTags(actx)
+	setFileIO(actx, pkg.inputFile, pkg.outputFile)
+	if err := pkg.processAssets(actx); err != nil {
+		return err
+	}
+	samples, err1 := pkg.processTests(ctx, actx, base)
+	if err1 != nil {
+		return err1
+	}
+	return pkg.processStatements(actx, samples)
+}
+
+func (pkg *polygonPackage) processNames(actx *ArchiveCtx) {
+	names := make(map[string]string)
+	for _, node := range xmlquery.Find(pkg.doc, "/problem/names/name") {
+		names[node.SelectAttr("language")] = node.SelectAttr("value")
+	}
+	for _, lang := range []string{"romanian", "english"} {
+		if val, ok := names[lang]; ok && val != "" {
+			actx.props.ProblemName = &val
+			return
+		}
+	}
+	if node := xmlquery.FindOne(pkg.doc, "/problem/names/name"); node != nil && node.SelectAttr("value") != "" {
+		val := node.SelectAttr("value")
+		actx.props.ProblemName = &val
+	}
+}
+
+func (pkg *polygonPackage) processTags(actx *ArchiveCtx) {
+	for _, node := range xmlquery.Find(pkg.doc, "/problem/tags/tag") {
+		if val := strings.TrimSpace(node.SelectAttr("value")); val != "" {
+			actx.props.Tags = append(actx.props.Tags, &mockTag{Name: val, Type: kilonova.TagTypeMethod})
+		}
+	}
+}
+
+// solutionFilename is the name of the input and output files used when running the solution, in the format of custom runs
+func (pkg *polygonPackage) solutionFilename() string {
+	if pkg.inputFile == "" {
+		return "stdin"
+	}
+	return strings.TrimSuffix(pkg.inputFile, path.Ext(pkg.inputFile))
+}
+
+var polygonCppVersionRegex = regexp.MustCompile(`g\+\+(\d+)`)
+
+// polygonSourceLang returns the Kilonova language of a Polygon source file, based on its type, such as `cpp.g++17`
+func polygonSourceLang(sourceType string, filename string) string {
+	switch {
+	case strings.HasPrefix(sourceType, "cpp."):
+		if matches := polygonCppVersionRegex.FindStringSubmatch(sourceType); len(matches) > 0 {
+			switch version, _ := strconv.Atoi(matches[1]); {
+			case version >= 20:
+				return "cpp20"
+			case version >= 17:
+				return "cpp17"
+			case version >= 14:
+				return "cpp14"
+			default:
+				return "cpp11"
+			}
+		}
+		return "cpp17"
+	case strings.HasPrefix(sourceType, "c."):
+		return "c"
+	case strings.HasPrefix(sourceType, "java"):
+		return "java"
+	case strings.HasPrefix(sourceType, "python.3"), strings.HasPrefix(sourceType, "python.pypy3"):
+		return "python3"
+	case strings.HasPrefix(sourceType, "pas."), strings.HasPrefix(sourceType, "delphi"):
+		return "pascal"
+	case strings.HasPrefix(sourceType, "kotlin"):
+		return "kotlin"
+	case strings.HasPrefix(sourceType, "go"):
+		return "golang"
+	case strings.HasPrefix(sourceType, "haskell"):
+		return "haskell"
+	}
+	return eval.GetLangByFilename(filename)
+}
+
+// langAttachmentName returns the attachment name of a program with the given base name,
+// using the language's unique extension such that the language is detected correctly
+func langAttachmentName(name string, lang string) string {
+	exts := eval.Langs[lang].Extensions
+	if len(exts) == 0 {
+		return name
+	}
+	return name + exts[len(exts)-1]
+}
+
+// source returns the file and language of the source of the given asset node
+func (pkg *polygonPackage) source(node *xmlquery.Node) (*zip.File, string) {
+	src := xmlquery.FindOne(node, "source")
+	if src == nil {
+		return nil, ""
+	}
+	file, ok := pkg.files[src.SelectAttr("path")]
+	if !ok {
+		zap.S().Warnf("Polygon source %q is missing from the package", src.SelectAttr("path"))
+		return nil, ""
+	}
+	return file, polygonSourceLang(src.SelectAttr("type"), file.Name)
+}
+
+func readZipFile(file *zip.File) ([]byte, error) {
+	f, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// processAssets imports the checker, validator, compile-time resources and solutions.
+// Interactors run alongside the solution, which the grader can't do, so interactive problems are rejected
+func (pkg *polygonPackage) processAssets(actx *ArchiveCtx) *kilonova.StatusError {
+	if xmlquery.FindOne(pkg.doc, "/problem/assets/interactor") != nil {
+		return kilonova.Statusf(400, "Interactive Polygon problems are not supported, since the interactor can't run alongside submissions. Use graders compiled with the submissions instead")
+	}
+	addProgram := func(node *xmlquery.Node, name string, exec bool) {
+		if node == nil {
+			return
+		}
+		file, lang := pkg.source(node)
+		if file == nil {
+			return
+		}
+		if lang == "" {
+			zap.S().Warnf("Unknown language for Polygon %s %q", name, file.Name)
+			return
+		}
+		attName := langAttachmentName(name, lang)
+		actx.attachments[attName] = archiveAttachment{File: file, Name: attName, Private: true, Exec: exec}
+	}
+
+	addProgram(xmlquery.FindOne(pkg.doc, "/problem/assets/checker"), "checker", true)
+	addProgram(xmlquery.FindOne(pkg.doc, "/problem/assets/validators/validator"), "validator", true)
+
+	// Graders and headers compiled along with the solutions
+	for _, node := range xmlquery.Find(pkg.doc, "/problem/files/resources/file") {
+		name := path.Base(node.SelectAttr("path"))
+		if node.SelectAttr("stage") != "compile" || name == "testlib.h" {
+			continue
+		}
+		file, ok := pkg.files[node.SelectAttr("path")]
+		if !ok {
+			continue
+		}
+		actx.attachments[name] = archiveAttachment{File: file, Name: name, Private: true, Exec: true}
+	}
+
+	for _, node := range xmlquery.Find(pkg.doc, "/problem/assets/solutions/solution") {
+		file, lang := pkg.source(node)
+		if file == nil {
+			continue
+		}
+		if lang == "" {
+			zap.S().Warnf("Unrecognized submission language for file %q", file.Name)
+			continue
+		}
+		code, err := readZipFile(file)
+		if err != nil {
+			return kilonova.WrapError(err, "Couldn't read solution file")
+		}
+		// The main solution is also used as the author solution, for generating the outputs of hacks
+		if node.SelectAttr("tag") == "main" {
+			addProgram(node, "solution", true)
+		}
+		actx.submissions = append(actx.submissions, &submissionStub{code: code, lang: lang, expectedVerdict: polygonExpectedVerdicts[node.SelectAttr("tag")]})
+	}
+	return nil
+}
+
+// processTests loads the main testset, generating the missing test data. Tests of the pretests testset are marked as pretests,
+// while the other testsets are merged into the main one, since Kilonova judges a single set of tests.
+// It returns the examples of the main testset
+func (pkg *polygonPackage) processTests(ctx context.Context, actx *ArchiveCtx, base *sudoapi.BaseAPI) ([]statementSample, *kilonova.StatusError) {
+	var testsets []*polygonTestset
+	for _, node := range xmlquery.Find(pkg.doc, "/problem/judging/testset") {
+		testsets = append(testsets, &polygonTestset{name: node.SelectAttr("name"), node: node})
+	}
+	if len(testsets) == 0 {
+		return nil, kilonova.Statusf(400, "There must be at least one testset")
+	}
+
+	var mainTestset, pretestTestset *polygonTestset
+	var extraTestsets []*polygonTestset
+	for _, ts := range testsets {
+		switch ts.name {
+		case "tests":
+			mainTestset = ts
+		case "pretests":
+			pretestTestset = ts
+		default:
+			extraTestsets = append(extraTestsets, ts)
+		}
+	}
+	if mainTestset == nil {
+		if len(testsets) > 1 {
+			return nil, kilonova.Statusf(400, "There must be a `tests` testset")
+		}
+		mainTestset, pretestTestset, extraTestsets = testsets[0], nil, nil
+	}
+
+	gen := &kilonova.TestGeneration{SolutionFilename: pkg.solutionFilename()}
+	var generated []*archiveTest
+	for _, ts := range append([]*polygonTestset{mainTestset, pretestTestset}, extraTestsets...) {
+		if ts == nil {
+			continue
+		}
+		if err := pkg.loadTestset(ts, gen); err != nil {
+			return nil, err
+		}
+		for _, test := range ts.tests {
+			if !test.hasInput() || !test.hasOutput() {
+				generated = append(generated, test)
+			}
+		}
+	}
+
+	if len(gen.Tests) > 0 {
+		if err := pkg.loadGenerators(gen); err != nil {
+			return nil, err
+		}
+		zap.S().Infof("Generating %d tests of Polygon package", len(gen.Tests))
+		if err := generateTests(ctx, actx, base, gen, generated); err != nil {
+			return nil, err
+		}
+	}
+
+	// Only the main testset's tests are examples, so they are read before merging the other testsets
+	samples := make([]statementSample, 0, len(mainTestset.samples))
+	for _, idx := range mainTestset.samples {
+		test := mainTestset.tests[idx]
+		in, err := readArchiveTest(test.openInput)
+		if err != nil {
+			return nil, kilonova.WrapError(err, "Couldn't read example input")
+		}
+		out, err := readArchiveTest(test.openOutput)
+		if err != nil {
+			return nil, kilonova.WrapError(err, "Couldn't read example output")
+		}
+		samples = append(samples, statementSample{Input: string(in), Output: string(out)})
+	}
+
+	if err := pkg.processGroups(actx, mainTestset); err != nil {
+		return nil, err
+	}
+	for _, ts := range extraTestsets {
+		if err := mergePolygonTestset(actx, mainTestset, ts); err != nil {
+			return nil, err
+		}
+	}
+	for _, test := range mainTestset.tests {
+		actx.tests[test.Key] = *test
+	}
+	if pretestTestset != nil {
+		if err := markPolygonPretests(actx, mainTestset, pretestTestset); err != nil {
+			return nil, err
+		}
+	}
+
+	// Parse time/memory limit
+	if node := xmlquery.FindOne(mainTestset.node, "time-limit"); node != nil {
+		timeLimit, err := strconv.Atoi(node.InnerText())
+		if err == nil {
+			timeLimitF := float64(timeLimit) / 1000.0
+			actx.props.TimeLimit = &timeLimitF
+		}
+	}
+	if node := xmlquery.FindOne(mainTestset.node, "memory-limit"); node != nil {
+		memoryLimit, err := strconv.Atoi(node.InnerText())
+		if err == nil {
+			memoryLimit /= 1024
+			actx.props.MemoryLimit = &memoryLimit
+		}
+	}
+	return samples, nil
+}
+
+func readArchiveTest(open func() (io.ReadCloser, error)) ([]byte, error) {
+	r, err := open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// loadTestset finds the files of the testset's tests, adding the ones that must be generated to gen.
+// Tests are keyed by their 1-based index in the testset
+func (pkg *polygonPackage) loadTestset(ts *polygonTestset, gen *kilonova.TestGeneration) *kilonova.StatusError {
+	var inputPattern, answerPattern string
+	if node := xmlquery.FindOne(ts.node, "input-path-pattern"); node != nil {
+		inputPattern = node.InnerText()
+	}
+	if node := xmlquery.FindOne(ts.node, "answer-path-pattern"); node != nil {
+		answerPattern = node.InnerText()
+	}
+
+	for i, node := range xmlquery.Find(ts.node, "tests/test") {
+		test := &archiveTest{Key: strconv.Itoa(i + 1)}
+		if inputPattern != "" {
+			test.InFile = pkg.files[fmt.Sprintf(inputPattern, i+1)]
+		}
+		if answerPattern != "" {
+			test.OutFile = pkg.files[fmt.Sprintf(answerPattern, i+1)]
+		}
+		if node.SelectAttr("sample") == "true" {
+			ts.samples = append(ts.samples, i)
+		}
+		ts.tests = append(ts.tests, test)
+
+		if test.hasInput() && test.hasOutput() {
+			continue
+		}
+		genTest := &kilonova.GeneratedTest{}
+		if test.InFile != nil {
+			genTest.Input = test.InFile.Open
+		} else {
+			if node.SelectAttr("method") != "generated" {
+				return kilonova.Statusf(400, "Missing input file for test %d of testset %q", i+1, ts.name)
+			}
+			if node.SelectAttr("from-file") != "" {
+				return kilonova.Statusf(400, "Test %d of testset %q uses a generator with multiple outputs, which is not supported", i+1, ts.name)
+			}
+			cmd, err := splitCommand(node.SelectAttr("cmd"))
+			if err != nil {
+				return kilonova.Statusf(400, "Invalid generator command for test %d of testset %q: %v", i+1, ts.name, err)
+			}
+			genTest.Command = cmd
+		}
+		gen.Tests = append(gen.Tests, genTest)
+	}
+	return nil
+}
+
+// loadGenerators adds the package's executables and its main solution to the generation
+func (pkg *polygonPackage) loadGenerators(gen *kilonova.TestGeneration) *kilonova.StatusError {
+	gen.Generators = make(map[string]*kilonova.GeneratorProgram)
+	for _, node := range xmlquery.Find(pkg.doc, "/problem/files/executables/executable") {
+		file, lang := pkg.source(node)
+		if file == nil || lang == "" {
+			continue
+		}
+		code, err := readZipFile(file)
+		if err != nil {
+			return kilonova.WrapError(err, "Couldn't read generator")
+		}
+		name := strings.TrimSuffix(path.Base(file.Name), path.Ext(file.Name))
+		gen.Generators[name] = &kilonova.GeneratorProgram{Lang: lang, Code: code}
+	}
+	if node := xmlquery.FindOne(pkg.doc, "/problem/assets/solutions/solution[@tag='main']"); node != nil {
+		if file, lang := pkg.source(node); file != nil && lang != "" {
+			code, err := readZipFile(file)
+			if err != nil {
+				return kilonova.WrapError(err, "Couldn't read main solution")
+			}
+			gen.Solution = &kilonova.GeneratorProgram{Lang: lang, Code: code}
+		}
+	}
+	return nil
+}
+
+// processGroups reads the test points and groups of the testset.
+// Groups whose points are given for each test become subtasks summing their tests' scores, while the other ones are all-or-nothing
+func (pkg *polygonPackage) processGroups(actx *ArchiveCtx, ts *polygonTestset) *kilonova.StatusError {
+	var isICPC = true
+	var subtasks = make(map[string]parsedSubtask)
+	testPoints := make(map[string]decimal.Decimal)
+	for id, test := range xmlquery.Find(ts.node, "tests/test") {
+		if points := test.SelectAttr("points"); points != "" {
+			isICPC = false
+			val, err := decimal.NewFromString(points)
+			if err == nil {
+				actx.testScores[id+1] = val
+			}
+		}
+		if group := test.SelectAttr("group"); group != "" {
+			isICPC = false
+			stk := subtasks[group]
+			stk.Tests = append(stk.Tests, id+1)
+			subtasks[group] = stk
+			testPoints[group] = testPoints[group].Add(actx.testScores[id+1])
+		}
+	}
+	if isICPC && actx.params.FirstImport && len(actx.params.ScoreParamsStr) == 0 {
+		actx.props.ScoringStrategy = kilonova.ScoringTypeICPC
+	}
+	if len(subtasks) == 0 {
+		return nil
+	}
+
+	for _, group := range xmlquery.Find(ts.node, "groups/group") {
+		name := group.SelectAttr("name")
+		stk, ok := subtasks[name]
+		if !ok {
+			continue
+		}
+		if group.SelectAttr("points-policy") == "each-test" {
+			stk.Aggregation = kilonova.AggregationSum
+			stk.Score = testPoints[name]
+		} else {
+			stk.Aggregation = kilonova.AggregationMin
+		}
+		if points := group.SelectAttr("points"); points != "" {
+			val, err := decimal.NewFromString(points)
+			if err == nil {
+				stk.Score = val
+			}
+		}
+
+		var dependencies []string
+		for _, dep := range xmlquery.Find(group, "dependencies/dependency") {
+			val := dep.SelectAttr("group")
+			if val == "" {
+				val = dep.SelectAttr("name")
+			}
+			if len(val) > 0 {
+				dependencies = append(dependencies, val)
+			}
+		}
+		if len(dependencies) > 0 {
+			stk.Dependencies = dependencies
+		}
+		if policy := group.SelectAttr("feedback-policy"); policy != "" && policy != "complete" {
+			actx.warnf("Group %q of testset %q uses the %q feedback policy, but submissions get feedback on every test", name, ts.name, policy)
+		}
+
+		subtasks[name] = stk
+	}
+
+	actx.props.Subtasks, actx.props.SubtaskedTests = solveSubtaskDependencies(subtasks)
+	return nil
+}
+
+// testsetInputHashes maps the hashes of the testset's inputs to the 1-based indices of their first tests
+func testsetInputHashes(ts *polygonTestset) (map[[sha256.Size]byte]int, *kilonova.StatusError) {
+	hashes := make(map[[sha256.Size]byte]int, len(ts.tests))
+	for i, test := range ts.tests {
+		data, err := readArchiveTest(test.openInput)
+		if err != nil {
+			return nil, kilonova.WrapError(err, "Couldn't read test input")
+		}
+		if _, ok := hashes[sha256.Sum256(data)]; !ok {
+			hashes[sha256.Sum256(data)] = i + 1
+		}
+	}
+	return hashes, nil
+}
+
+// mergePolygonTestset appends the tests of an additional testset that are not in the main testset, along with their points.
+// If the main testset has groups, the new tests form an additional subtask worth their points
+func mergePolygonTestset(actx *ArchiveCtx, mainTestset *polygonTestset, ts *polygonTestset) *kilonova.StatusError {
+	hashes, err := testsetInputHashes(mainTestset)
+	if err != nil {
+		return err
+	}
+	nodes := xmlquery.Find(ts.node, "tests/test")
+	var added []int
+	var score decimal.Decimal
+	for i, test := range ts.tests {
+		data, err := readArchiveTest(test.openInput)
+		if err != nil {
+			return kilonova.WrapError(err, "Couldn't read test input")
+		}
+		if _, ok := hashes[sha256.Sum256(data)]; ok {
+			continue
+		}
+		id := len(mainTestset.tests) + 1
+		hashes[sha256.Sum256(data)] = id
+		test.Key = strconv.Itoa(id)
+		mainTestset.tests = append(mainTestset.tests, test)
+		added = append(added, id)
+		if points, err := decimal.NewFromString(nodes[i].SelectAttr("points")); err == nil {
+			actx.testScores[id] = points
+			score = score.Add(points)
+		}
+	}
+	if len(added) == 0 {
+		return nil
+	}
+	actx.warnf("Testset %q was merged into testset %q, adding %d tests", ts.name, mainTestset.name, len(added))
+
+	if len(actx.props.Subtasks) == 0 {
+		return nil
+	}
+	subtaskID := 0
+	for id := range actx.props.Subtasks {
+		subtaskID = max(subtaskID, id)
+	}
+	actx.props.Subtasks[subtaskID+1] = Subtask{Score: score, Tests: added, Aggregation: kilonova.AggregationSum}
+	actx.props.SubtaskedTests = append(actx.props.SubtaskedTests, added...)
+	return nil
+}
+
+// markPolygonPretests marks the tests whose input matches a test of the pretests testset as pretests
+func markPolygonPretests(actx *ArchiveCtx, mainTestset *polygonTestset, pretestTestset *polygonTestset) *kilonova.StatusError {
+	hashes, err := testsetInputHashes(mainTestset)
+	if err != nil {
+		return err
+	}
+	for i, test := range pretestTestset.tests {
+		data, err := readArchiveTest(test.openInput)
+		if err != nil {
+			return kilonova.WrapError(err, "Couldn't read pretest input")
+		}
+		id, ok := hashes[sha256.Sum256(data)]
+		if !ok {
+			return kilonova.Statusf(400, "Pretest %d doesn't match any test of the main testset", i+1)
+		}
+		if !slices.Contains(actx.props.Pretests, id) {
+			actx.props.Pretests = append(actx.props.Pretests, id)
+		}
+	}
+	slices.Sort(actx.props.Pretests)
+	return nil
+}
+
+// statementHeadings are the section titles of generated markdown statements. Languages without headings use the english ones
+var statementHeadings = map[string]map[string]string{
+	"en": {
+		"legend":      "Task",
+		"input":       "Input data",
+		"output":      "Output data",
+		"interaction": "Interaction",
+		"scoring":     "Scoring",
+		"example":     "Example",
+		"notes":       "Notes",
+	},
+	"ro": {
+		"legend":      "Cerință",
+		"input":       "Date de intrare",
+		"output":      "Date de ieșire",
+		"interaction": "Interacțiune",
+		"scoring":     "Punctare",
+		"example":     "Exemplul",
+		"notes":       "Observații",
+	},
+}
+
+// processStatements imports the PDF and markdown statements as they are.
+// LaTeX statements are kept as private sources and converted to markdown, along with their resources and tutorials
+func (pkg *polygonPackage) processStatements(actx *ArchiveCtx, samples []statementSample) *kilonova.StatusError {
+	for _, node := range xmlquery.Find(pkg.doc, "/problem/statements/statement") {
+		lang, ok := polygonLanguages[node.SelectAttr("language")]
+		if !ok {
+			zap.S().Warnf("Skipping statement in unsupported language %q", node.SelectAttr("language"))
+			continue
+		}
+		file, ok := pkg.files[node.SelectAttr("path")]
+		if !ok {
+			continue
+		}
+		switch {
+		case node.SelectAttr("type") == "application/pdf":
+			name := fmt.Sprintf("statement-%s.pdf", lang)
+			actx.attachments[name] = archiveAttachment{File: file, Name: name}
+		case node.SelectAttr("type") == "text/markdown" || path.Ext(file.Name) == ".md":
+			name := fmt.Sprintf("statement-%s.md", lang)
+			actx.attachments[name] = archiveAttachment{File: file, Name: name}
+			pkg.addStatementResources(actx, path.Dir(file.Name))
+		case node.SelectAttr("type") == "application/x-tex":
+			name := fmt.Sprintf("statement-%s.tex", lang)
+			actx.attachments[name] = archiveAttachment{File: file, Name: name, Private: true}
+			if err := pkg.convertStatement(actx, lang, path.Dir(file.Name), samples); err != nil {
+				return err
+			}
+			pkg.addStatementResources(actx, path.Dir(file.Name))
+		}
+	}
+	return nil
+}
+
+// convertStatement builds the markdown statement from the sections in problem-properties.json
+func (pkg *polygonPackage) convertStatement(actx *ArchiveCtx, lang string, dir string, samples []statementSample) *kilonova.StatusError {
+	file, ok := pkg.files[path.Join(dir, "problem-properties.json")]
+	if !ok {
+		zap.S().Warnf("Polygon statement in %q has no problem-properties.json, skipping markdown conversion", dir)
+		return nil
+	}
+	f, err := file.Open()
+	if err != nil {
+		return kilonova.WrapError(err, "Couldn't open statement properties")
+	}
+	defer f.Close()
+	var props polygonStatementProps
+	if err := json.NewDecoder(f).Decode(&props); err != nil {
+		return kilonova.WrapError(err, "Invalid statement properties")
+	}
+	if len(props.SampleTests) > 0 {
+		samples = props.SampleTests
+	}
+
+	headings, ok := statementHeadings[lang]
+	if !ok {
+		headings = statementHeadings["en"]
+	}
+
+	var buf bytes.Buffer
+	section := func(key string, tex string) {
+		if strings.TrimSpace(tex) == "" {
+			return
+		}
+		fmt.Fprintf(&buf, "# %s\n\n%s\n\n", headings[key], polygonTexToMarkdown(strings.TrimSpace(tex)))
+	}
+	section("legend", props.Legend)
+	section("input", props.Input)
+	section("output", props.Output)
+	section("interaction", props.Interaction)
+	section("scoring", props.Scoring)
+	writeStatementExamples(&buf, headings["example"], pkg.inputFile, pkg.outputFile, samples)
+	section("notes", props.Notes)
+
+	name := fmt.Sprintf("statement-%s.md", lang)
+	actx.attachments[name] = archiveAttachment{Data: buf.Bytes(), Name: name}
+
+	if strings.TrimSpace(props.Tutorial) != "" {
+		name := fmt.Sprintf("statement-%s-editorial.md", lang)
+		actx.attachments[name] = archiveAttachment{Data: []byte(polygonTexToMarkdown(strings.TrimSpace(props.Tutorial)) + "\n"), Name: name, Private: true}
+	}
+	return nil
+}
+
+// writeStatementExamples writes the example sections of generated statements. Empty file names stand for the standard streams
+func writeStatementExamples(buf *bytes.Buffer, heading string, inputFile, outputFile string, samples []statementSample) {
+	if inputFile == "" {
+		inputFile, outputFile = "stdin", "stdout"
+	}
+	for i, sample := range samples {
+		fmt.Fprintf(buf, "# %s %d\n\n`%s`\n```\n%s\n```\n\n`%s`\n```\n%s\n```\n\n", heading, i+1,
+			inputFile, strings.TrimRight(sample.Input, "\n"), outputFile, strings.TrimRight(sample.Output, "\n"))
+	}
+}
+
+// addStatementResources imports the files next to the statement, such as images, as public attachments.
+// The LaTeX sources and the files generated by Polygon for the examples are skipped
+func (pkg *polygonPackage) addStatementResources(actx *ArchiveCtx, dir string) {
+	for name, file := range pkg.files {
+		if path.Dir(name) != dir {
+			continue
+		}
+		base := path.Base(name)
+		switch path.Ext(base) {
+		case ".tex", ".json", ".ftl", ".sty", ".md":
+			continue
+		}
+		if strings.HasPrefix(base, "example.") {
+			continue
+		}
+		if _, ok := actx.attachments[base]; ok {
+			continue
+		}
+		actx.attachments[base] = archiveAttachment{File: file, Name: base}
+	}
+}
+
+var (
+	texGraphicsRegex  = regexp.MustCompile(`\\includegraphics(?:\[[^\]]*\])?\{([^{}]*)\}`)
+	texBoldRegex      = regexp.MustCompile(`\\(?:textbf|bf)\{([^{}]*)\}`)
+	texItalicRegex    = regexp.MustCompile(`\\(?:textit|emph|it)\{([^{}]*)\}`)
+	texMonospaceRegex = regexp.MustCompile(`\\(?:texttt|tt|t)\{([^{}]*)\}`)
+	texPlainRegex     = regexp.MustCompile(`\\(?:underline|textrm|textsf|mbox)\{([^{}]*)\}`)
+	texItemizeRegex   = regexp.MustCompile(`(?s)\\begin\{itemize\}(.*?)\\end\{itemize\}`)
+	texEnumerateRegex = regexp.MustCompile(`(?s)\\begin\{enumerate\}(.*?)\\end\{enumerate\}`)
+	texEnvRegex       = regexp.MustCompile(`\\(?:begin|end)\{(?:center|flushleft|flushright|quote)\}`)
+	texItemRegex      = regexp.MustCompile(`\\item\s*`)
+
+	texReplacer = strings.NewReplacer(
+		"\\\\", "\n\n",
+		"---", "—",
+		"--", "–",
+		"<<", "«",
+		">>", "»",
+		"~", " ",
+		"\\%", "%",
+		"\\&", "&",
+		"\\_", "_",
+		"\\#", "#",
+		"\\ldots", "…",
+		"\\dots", "…",
+	)
+)
+
+// polygonTexToMarkdown converts the usual LaTeX formatting of Polygon statements to markdown. Math is left as is, since it's rendered by KaTeX
+func polygonTexToMarkdown(tex string) string {
+	// Math is swapped with placeholders, so formatting commands may contain it
+	segments := splitTexMath(tex)
+	var b strings.Builder
+	for i, segment := range segments {
+		if i%2 == 1 {
+			fmt.Fprintf(&b, "\x00%d\x00", i)
+			continue
+		}
+		b.WriteString(segment)
+	}
+	text := b.String()
+
+	// Images are marked with \x01 until the end, since ~ is a non-breaking space in LaTeX
+	text = texGraphicsRegex.ReplaceAllString(text, "\x01[$1]")
+	for _, list := range []struct {
+		re     *regexp.Regexp
+		marker string
+	}{{texItemizeRegex, "\n- "}, {texEnumerateRegex, "\n1. "}} {
+		text = list.re.ReplaceAllStringFunc(text, func(env string) string {
+			items := strings.TrimSpace(list.re.FindStringSubmatch(env)[1])
+			return "\n" + texItemRegex.ReplaceAllString(items, list.marker) + "\n\n"
+		})
+	}
+	for prev := ""; prev != text; {
+		prev = text
+		text = texBoldRegex.ReplaceAllString(text, "**$1**")
+		text = texItalicRegex.ReplaceAllString(text, "*$1*")
+		text = texMonospaceRegex.ReplaceAllString(text, "`$1`")
+		text = texPlainRegex.ReplaceAllString(text, "$1")
+	}
+	text = texEnvRegex.ReplaceAllString(text, "")
+	text = strings.ReplaceAll(texReplacer.Replace(text), "\x01", "~")
+
+	for i := 1; i < len(segments); i += 2 {
+		text = strings.Replace(text, fmt.Sprintf("\x00%d\x00", i), segments[i], 1)
+	}
+	return text
+}
+
+// splitTexMath splits the text in alternating text and math segments, such that the odd segments are math, along with their delimiters
+func splitTexMath(tex string) []string {
+	var segments []string
+	start := 0
+	inMath := false
+	delim := ""
+	for i := 0; i < len(tex); i++ {
+		if tex[i] == '\\' {
+			i++
+			continue
+		}
+		if tex[i] != '$' {
+			continue
+		}
+		cur := "$"
+		if i+1 < len(tex) && tex[i+1] == '$' {
+			cur = "$$"
+		}
+		switch {
+		case !inMath:
+			segments = append(segments, tex[start:i])
+			start, inMath, delim = i, true, cur
+		case cur == delim || (delim == "$$" && cur == "$"):
+			if delim == "$$" && cur == "$" {
+				// A lone dollar inside display math
+				continue
+			}
+			segments = append(segments, tex[start:i+len(cur)])
+			start, inMath = i+len(cur), false
+		}
+		i += len(cur) - 1
+	}
+	if inMath {
+		// Unterminated math is left as text
+		segments[len(segments)-1] += tex[start:]
+		return segments
+	}
+	return append(segments, tex[start:])
+}
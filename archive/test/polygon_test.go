@@ -0,0 +1,230 @@
+package test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/antchfx/xmlquery"
+	"github.com/shopspring/decimal"
+)
+
+// polygonFixtureFiles are the test files of testdata/polygon/problem.xml. The stress testset repeats the second test
+var polygonFixtureFiles = map[string]string{
+	"tests/01": "1 2\n", "tests/01.a": "3\n",
+	"tests/02": "2 2\n", "tests/02.a": "4\n",
+	"tests/03": "3 4\n", "tests/03.a": "7\n",
+	"tests/04": "5 5\n", "tests/04.a": "10\n",
+	"pretests/01": "1 2\n", "pretests/01.a": "3\n",
+	"pretests/02": "3 4\n", "pretests/02.a": "7\n",
+	"stress/01": "2 2\n", "stress/01.a": "4\n",
+	"stress/02": "100 200\n", "stress/02.a": "300\n",
+}
+
+// zipFixture builds an in-memory archive with the given files
+func zipFixture(t *testing.T, files map[string]string) *zip.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ar, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ar
+}
+
+// polygonFixture builds the package of the fixture problem.xml, leaving out the given files
+func polygonFixture(t *testing.T, omit ...string) *polygonPackage {
+	t.Helper()
+	xml, err := os.ReadFile("testdata/polygon/problem.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{"problem.xml": string(xml)}
+	for name, data := range polygonFixtureFiles {
+		if !slices.Contains(omit, name) {
+			files[name] = data
+		}
+	}
+	ar := zipFixture(t, files)
+	doc, err := xmlquery.Parse(bytes.NewReader(xml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := &polygonPackage{doc: doc, files: make(map[string]*zip.File)}
+	for _, file := range ar.File {
+		pkg.files[file.Name] = file
+	}
+	return pkg
+}
+
+func TestPolygonTestsets(t *testing.T) {
+	pkg := polygonFixture(t)
+	actx := NewArchiveCtx(&TestProcessParams{})
+	actx.props = &properties{}
+	samples, err := pkg.processTests(context.Background(), actx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(samples) != 1 || samples[0].Input != "1 2\n" || samples[0].Output != "3\n" {
+		t.Errorf("Unexpected examples: %#v", samples)
+	}
+	// The duplicate test of the stress testset is skipped
+	if len(actx.tests) != 5 {
+		t.Fatalf("Expected 5 tests, got %d", len(actx.tests))
+	}
+	if in, err := readArchiveTest(actx.tests["5"].openInput); err != nil || string(in) != "100 200\n" {
+		t.Errorf("Merged test has input %q (err: %v)", in, err)
+	}
+	if !slices.Equal(actx.props.Pretests, []int{1, 3}) {
+		t.Errorf("Expected tests 1 and 3 to be pretests, got %v", actx.props.Pretests)
+	}
+	if actx.props.TimeLimit == nil || *actx.props.TimeLimit != 1.5 {
+		t.Errorf("Unexpected time limit %v", actx.props.TimeLimit)
+	}
+	if actx.props.MemoryLimit == nil || *actx.props.MemoryLimit != 256*1024 {
+		t.Errorf("Unexpected memory limit %v", actx.props.MemoryLimit)
+	}
+
+	// Subtasks are numbered in no particular order, so they are looked up by their tests
+	subtasks := []struct {
+		name        string
+		tests       []int
+		score       int64
+		aggregation kilonova.SubtaskAggregation
+	}{
+		{"samples", []int{1}, 0, kilonova.AggregationSum},
+		{"small", []int{1, 2}, 20, kilonova.AggregationMin},
+		{"large", []int{2, 3, 4}, 80, kilonova.AggregationSum},
+		{"stress", []int{5}, 10, kilonova.AggregationSum},
+	}
+	if len(actx.props.Subtasks) != len(subtasks) {
+		t.Fatalf("Expected %d subtasks, got %d", len(subtasks), len(actx.props.Subtasks))
+	}
+	for _, tt := range subtasks {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, stk := range actx.props.Subtasks {
+				if !slices.Equal(stk.Tests, tt.tests) {
+					continue
+				}
+				if !stk.Score.Equal(decimal.NewFromInt(tt.score)) {
+					t.Errorf("Expected score %d, got %s", tt.score, stk.Score)
+				}
+				if stk.Aggregation != tt.aggregation {
+					t.Errorf("Expected aggregation %q, got %q", tt.aggregation, stk.Aggregation)
+				}
+				return
+			}
+			t.Errorf("No subtask with tests %v", tt.tests)
+		})
+	}
+	if len(actx.props.SubtaskedTests) != 5 {
+		t.Errorf("Expected all tests to be in subtasks, got %v", actx.props.SubtaskedTests)
+	}
+	if !actx.testScores[5].Equal(decimal.NewFromInt(10)) {
+		t.Errorf("Merged test has score %s", actx.testScores[5])
+	}
+
+	for _, warning := range []string{`"icpc" feedback policy`, `"points" feedback policy`, `Testset "stress" was merged`} {
+		if !slices.ContainsFunc(actx.warnings, func(w string) bool { return strings.Contains(w, warning) }) {
+			t.Errorf("Missing warning about %s in %q", warning, actx.warnings)
+		}
+	}
+}
+
+func TestPolygonGeneratedTests(t *testing.T) {
+	// Without their files, the generated tests are run through the generation, keeping the input files of the others
+	pkg := polygonFixture(t, "tests/03", "tests/03.a", "tests/04", "tests/04.a", "tests/02.a")
+	ts := &polygonTestset{name: "tests", node: xmlquery.FindOne(pkg.doc, "/problem/judging/testset[@name='tests']")}
+	gen := &kilonova.TestGeneration{}
+	if err := pkg.loadTestset(ts, gen); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		input   string
+		command []string
+	}{
+		{input: "2 2\n"},
+		{command: []string{"gen", "a b", `c "d"`, "3"}},
+		{command: []string{"gen", "--n=5", "seed 7"}},
+	}
+	if len(gen.Tests) != len(expected) {
+		t.Fatalf("Expected %d tests to generate, got %d", len(expected), len(gen.Tests))
+	}
+	for i, tt := range expected {
+		var input []byte
+		if gen.Tests[i].Input != nil {
+			data, err := readArchiveTest(gen.Tests[i].Input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			input = data
+		}
+		if string(input) != tt.input {
+			t.Errorf("Test %d: expected input %q, got %q", i, tt.input, input)
+		}
+		if !slices.Equal(gen.Tests[i].Command, tt.command) {
+			t.Errorf("Test %d: expected command %q, got %q", i, tt.command, gen.Tests[i].Command)
+		}
+	}
+}
+
+func TestPolygonInteractor(t *testing.T) {
+	doc, err := xmlquery.Parse(strings.NewReader(`<problem><assets><interactor><source path="files/interactor.cpp" type="cpp.g++17"/></interactor></assets></problem>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := &polygonPackage{doc: doc, files: map[string]*zip.File{}}
+	if err := pkg.processAssets(NewArchiveCtx(&TestProcessParams{})); err == nil {
+		t.Error("Interactive problems should be rejected")
+	}
+}
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		cmd   string
+		args  []string
+		error bool
+	}{
+		{cmd: "gen 1 2 3", args: []string{"gen", "1", "2", "3"}},
+		{cmd: "  gen\t1   2 ", args: []string{"gen", "1", "2"}},
+		{cmd: `gen 'a b' "c d"`, args: []string{"gen", "a b", "c d"}},
+		{cmd: `gen '' ""`, args: []string{"gen", "", ""}},
+		{cmd: `gen "a \"b\" \c" 'x\y'`, args: []string{"gen", `a "b" \c`, `x\y`}},
+		{cmd: `gen a\ b pre"fix"'suf'`, args: []string{"gen", "a b", "prefixsuf"}},
+		{cmd: "", args: nil},
+		{cmd: `gen 'a`, error: true},
+		{cmd: `gen "a`, error: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cmd, func(t *testing.T) {
+			args, err := splitCommand(tt.cmd)
+			if (err != nil) != tt.error {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !slices.Equal(args, tt.args) {
+				t.Errorf("Expected %q, got %q", tt.args, args)
+			}
+		})
+	}
+}
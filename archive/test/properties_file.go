@@ -19,6 +19,14 @@ import (
 type Subtask struct {
 	Score decimal.Decimal
 	Tests []int
+
+	Aggregation kilonova.SubtaskAggregation
+	Threshold   decimal.Decimal
+
+	// seconds
+	TimeLimit *float64
+	// kbytes
+	MemoryLimit *int
 }
 
 type mockTag struct {
@@ -30,6 +38,12 @@ type PropertiesRaw struct {
 	Groups       string   `props:"groups"`
 	Weights      string   `props:"weights"`
 	Dependencies string   `props:"dependencies"`
+	Aggregation  string   `props:"aggregation"`
+	Pretests     string   `props:"pretests"`
+	GroupTime    string   `props:"group_time_limits"`
+	GroupMemory  string   `props:"group_memory_limits"`
+	TestTime     string   `props:"test_time_limits"`
+	TestMemory   string   `props:"test_memory_limits"`
 	Time         *float64 `props:"time"`
 	Memory       *float64 `props:"memory"`
 	Tags         *string  `props:"tags"`
@@ -44,6 +58,9 @@ type PropertiesRaw struct {
 
 	ScorePrecision  *int32  `props:"score_precision"`
 	ScoringStrategy *string `props:"scoring_strategy"`
+
+	Objective         *string `props:"objective"`
+	ObjectiveExponent *string `props:"objective_exponent"`
 }
 
 func ParsePropertiesFile(r io.Reader) (*PropertiesRaw, bool, error) {
@@ -109,6 +126,72 @@ func parsePropListItem(item string, field string) ([]int, *kilonova.StatusError)
 	return glist, nil
 }
 
+// parseAggregation parses a subtask aggregation item, in the form of `min`, `sum`, `average` or `threshold:<percentage>`
+func parseAggregation(item string) (kilonova.SubtaskAggregation, decimal.Decimal, *kilonova.StatusError) {
+	threshold := decimal.NewFromInt(100)
+	name, val, found := strings.Cut(strings.TrimSpace(item), ":")
+	if name == "" {
+		return kilonova.AggregationMin, threshold, nil
+	}
+	agg := kilonova.SubtaskAggregation(name)
+	if !kilonova.ValidAggregation(agg) {
+		return kilonova.AggregationNone, threshold, kilonova.Statusf(400, "Invalid aggregation %q in properties", name)
+	}
+	if found {
+		if agg != kilonova.AggregationThreshold {
+			return kilonova.AggregationNone, threshold, kilonova.Statusf(400, "Only threshold aggregations may specify a percentage")
+		}
+		var err error
+		threshold, err = decimal.NewFromString(strings.TrimSpace(val))
+		if err != nil || threshold.IsNegative() || threshold.GreaterThan(decimal.NewFromInt(100)) {
+			return kilonova.AggregationNone, threshold, kilonova.Statusf(400, "Invalid aggregation threshold in properties")
+		}
+	}
+	return agg, threshold, nil
+}
+
+// parseLimit parses a time (in seconds) or memory (in MB) limit. Memory limits are returned in kbytes
+func parseLimit(item string, memory bool, field string) (float64, *kilonova.StatusError) {
+	val, err := strconv.ParseFloat(strings.TrimSpace(item), 64)
+	if err != nil || val <= 0 {
+		return 0, kilonova.Statusf(400, "Invalid %q limit in properties", field)
+	}
+	if memory {
+		val *= 1024.0
+		if int(val) > config.Common.TestMaxMemKB {
+			return 0, kilonova.Statusf(400, "Maximum memory must not exceed %f MB", float64(config.Common.TestMaxMemKB)/1024.0)
+		}
+	}
+	return val, nil
+}
+
+// parseTestLimits parses per-test limits, in the form of `<tests>:<limit>;<tests>:<limit>`,
+// where tests is a test ID, a range (ie. `1-3`) or a comma-separated list of those
+func parseTestLimits(item string, memory bool, field string) (map[int]float64, *kilonova.StatusError) {
+	limits := make(map[int]float64)
+	for _, entry := range strings.Split(item, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		tests, val, found := strings.Cut(entry, ":")
+		if !found {
+			return nil, kilonova.Statusf(400, "Invalid %q string in properties, expected `<tests>:<limit>`", field)
+		}
+		limit, err := parseLimit(val, memory, field)
+		if err != nil {
+			return nil, err
+		}
+		ids, err := parsePropListItem(strings.ReplaceAll(strings.TrimSpace(tests), ",", ";"), field)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			limits[id] = limit
+		}
+	}
+	return limits, nil
+}
+
 var (
 	simpleTagRegex  = regexp.MustCompile(`^"(.*)"$`)
 	complexTagRegex = regexp.MustCompile(`^"(.*)":(.*)$`)
@@ -190,11 +273,54 @@ func ProcessPropertiesFile(ctx *ArchiveCtx, file *zip.File) *kilonova.StatusErro
 	if rawProps.ScoringStrategy != nil && (*rawProps.ScoringStrategy == string(kilonova.ScoringTypeMaxSub) || *rawProps.ScoringStrategy == string(kilonova.ScoringTypeSumSubtasks) || *rawProps.ScoringStrategy == string(kilonova.ScoringTypeICPC)) {
 		props.ScoringStrategy = kilonova.ScoringType(*rawProps.ScoringStrategy)
 	}
+	if rawProps.Objective != nil {
+		val := kilonova.ObjectiveType(*rawProps.Objective)
+		if val == "none" {
+			val = kilonova.ObjectiveNone
+		}
+		if val != kilonova.ObjectiveNone && val != kilonova.ObjectiveMaximize && val != kilonova.ObjectiveMinimize {
+			return kilonova.Statusf(400, "Invalid objective type %q", *rawProps.Objective)
+		}
+		props.ObjectiveType = &val
+	}
+	if rawProps.ObjectiveExponent != nil {
+		val, err := decimal.NewFromString(*rawProps.ObjectiveExponent)
+		if err != nil || !val.IsPositive() {
+			return kilonova.Statusf(400, "Invalid objective exponent")
+		}
+		props.ObjectiveExponent = &val
+	}
 	if rawProps.ConsoleInput != nil && (*rawProps.ConsoleInput == "true" || *rawProps.ConsoleInput == "false") {
 		val := *rawProps.ConsoleInput == "true"
 		props.ConsoleInput = &val
 	}
 
+	if rawProps.Pretests != "" {
+		pretests, err := parsePropListItem(rawProps.Pretests, "pretests")
+		if err != nil {
+			return err
+		}
+		props.Pretests = pretests
+	}
+
+	if rawProps.TestTime != "" {
+		limits, err := parseTestLimits(rawProps.TestTime, false, "test_time_limits")
+		if err != nil {
+			return err
+		}
+		props.TestTimeLimits = limits
+	}
+	if rawProps.TestMemory != "" {
+		limits, err := parseTestLimits(rawProps.TestMemory, true, "test_memory_limits")
+		if err != nil {
+			return err
+		}
+		props.TestMemoryLimits = make(map[int]int, len(limits))
+		for id, limit := range limits {
+			props.TestMemoryLimits[id] = int(limit)
+		}
+	}
+
 	// handle subtasks
 	if rawProps.Groups != "" {
 		// if using score parameters, groups/weights data is redundant
@@ -225,6 +351,62 @@ func ProcessPropertiesFile(ctx *ArchiveCtx, file *zip.File) *kilonova.StatusErro
 			stks[strconv.Itoa(i+1)] = stk
 		}
 
+		if rawProps.Aggregation != "" {
+			aggStrings := strings.Split(rawProps.Aggregation, ",")
+			if len(aggStrings) != len(groupStrings) {
+				return kilonova.Statusf(400, "Number of aggregations must match number of groups")
+			}
+			for i, a := range aggStrings {
+				agg, threshold, err := parseAggregation(a)
+				if err != nil {
+					return err
+				}
+				stk := stks[strconv.Itoa(i+1)]
+				stk.Aggregation = agg
+				stk.Threshold = threshold
+				stks[strconv.Itoa(i+1)] = stk
+			}
+		}
+
+		if rawProps.GroupTime != "" {
+			limitStrings := strings.Split(rawProps.GroupTime, ",")
+			if len(limitStrings) != len(groupStrings) {
+				return kilonova.Statusf(400, "Number of group time limits must match number of groups")
+			}
+			for i, l := range limitStrings {
+				if strings.TrimSpace(l) == "" {
+					continue
+				}
+				val, err := parseLimit(l, false, "group_time_limits")
+				if err != nil {
+					return err
+				}
+				stk := stks[strconv.Itoa(i+1)]
+				stk.TimeLimit = &val
+				stks[strconv.Itoa(i+1)] = stk
+			}
+		}
+
+		if rawProps.GroupMemory != "" {
+			limitStrings := strings.Split(rawProps.GroupMemory, ",")
+			if len(limitStrings) != len(groupStrings) {
+				return kilonova.Statusf(400, "Number of group memory limits must match number of groups")
+			}
+			for i, l := range limitStrings {
+				if strings.TrimSpace(l) == "" {
+					continue
+				}
+				val, err := parseLimit(l, true, "group_memory_limits")
+				if err != nil {
+					return err
+				}
+				mem := int(val)
+				stk := stks[strconv.Itoa(i+1)]
+				stk.MemoryLimit = &mem
+				stks[strconv.Itoa(i+1)] = stk
+			}
+		}
+
 		if rawProps.Dependencies != "" {
 			depStrings := strings.Split(rawProps.Dependencies, ",")
 			if len(depStrings) != len(weightStrings) {
@@ -264,6 +446,12 @@ type parsedSubtask struct {
 	Score decimal.Decimal
 	Tests []int
 
+	Aggregation kilonova.SubtaskAggregation
+	Threshold   decimal.Decimal
+
+	TimeLimit   *float64
+	MemoryLimit *int
+
 	// The current subtask is automatically considered a dependency
 	Dependencies []string
 }
@@ -275,7 +463,13 @@ func solveSubtaskDependencies(subtasks map[string]parsedSubtask) (stks map[int]S
 	finalSubtasks := make(map[string]Subtask)
 
 	for id, group := range subtasks {
-		stk := Subtask{Score: group.Score}
+		stk := Subtask{
+			Score:       group.Score,
+			Aggregation: group.Aggregation,
+			Threshold:   group.Threshold,
+			TimeLimit:   group.TimeLimit,
+			MemoryLimit: group.MemoryLimit,
+		}
 		stk.Tests = slices.Clone(group.Tests)
 		for _, dependency := range group.Dependencies {
 			dep, ok := subtasks[dependency]
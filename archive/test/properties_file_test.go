@@ -0,0 +1,54 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/shopspring/decimal"
+)
+
+type aggregationTest struct {
+	Str         string
+	Aggregation kilonova.SubtaskAggregation
+	Threshold   int64
+	Error       bool
+}
+
+var aggregationExamples = map[string]aggregationTest{
+	"empty":             {Str: "", Aggregation: kilonova.AggregationMin, Threshold: 100},
+	"min":               {Str: "min", Aggregation: kilonova.AggregationMin, Threshold: 100},
+	"sum":               {Str: " sum ", Aggregation: kilonova.AggregationSum, Threshold: 100},
+	"average":           {Str: "average", Aggregation: kilonova.AggregationAverage, Threshold: 100},
+	"threshold":         {Str: "threshold", Aggregation: kilonova.AggregationThreshold, Threshold: 100},
+	"threshold_value":   {Str: "threshold: 60", Aggregation: kilonova.AggregationThreshold, Threshold: 60},
+	"fail_unknown":      {Str: "max", Error: true},
+	"fail_percentage":   {Str: "sum:50", Error: true},
+	"fail_not_number":   {Str: "threshold:half", Error: true},
+	"fail_negative":     {Str: "threshold:-1", Error: true},
+	"fail_over_hundred": {Str: "threshold:101", Error: true},
+}
+
+func TestParseAggregation(t *testing.T) {
+	for k, v := range aggregationExamples {
+		v := v
+		t.Run(k, func(t *testing.T) {
+			t.Parallel()
+			agg, threshold, err := parseAggregation(v.Str)
+			if err != nil && !v.Error {
+				t.Fatalf("Error parsing aggregation: %#v", err)
+			}
+			if err == nil && v.Error {
+				t.Fatalf("Test should not succeed")
+			}
+			if v.Error {
+				return
+			}
+			if agg != v.Aggregation {
+				t.Fatalf("Invalid aggregation, expected %q, got %q", v.Aggregation, agg)
+			}
+			if !threshold.Equal(decimal.NewFromInt(v.Threshold)) {
+				t.Fatalf("Invalid threshold, expected %d, got %s", v.Threshold, threshold)
+			}
+		})
+	}
+}
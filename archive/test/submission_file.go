@@ -14,6 +14,9 @@ import (
 type submissionStub struct {
 	code []byte
 	lang string
+
+	// expectedVerdict is set for the reference solutions of problem packages
+	expectedVerdict string
 }
 
 func ProcessSubmissionFile(ctx *ArchiveCtx, file *zip.File) *kilonova.StatusError {
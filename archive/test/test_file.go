@@ -2,7 +2,11 @@ package test
 
 import (
 	"archive/zip"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"path"
 	"regexp"
 	"slices"
@@ -10,6 +14,8 @@ import (
 	"strings"
 
 	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/datastore"
+	"github.com/KiloProjects/kilonova/sudoapi"
 	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
 )
@@ -30,11 +36,122 @@ type archiveTest struct {
 	InFile  *zip.File
 	OutFile *zip.File
 
+	// InBucketFile and OutBucketFile name the generated test data in the tests bucket, for tests whose files are not in the archive
+	InBucketFile  string
+	OutBucketFile string
+
+	// InputFiles are the test's additional input files, by name
+	InputFiles map[string]*zip.File
+
 	VisibleID int
 	Key       string
 	Score     decimal.Decimal
 }
 
+func (t archiveTest) hasInput() bool  { return t.InFile != nil || t.InBucketFile != "" }
+func (t archiveTest) hasOutput() bool { return t.OutFile != nil || t.OutBucketFile != "" }
+
+func (t archiveTest) openInput() (io.ReadCloser, error) {
+	if t.InFile == nil {
+		return datastore.GetBucket(datastore.BucketTypeTests).Reader(t.InBucketFile)
+	}
+	return t.InFile.Open()
+}
+
+func (t archiveTest) openOutput() (io.ReadCloser, error) {
+	if t.OutFile == nil {
+		return datastore.GetBucket(datastore.BucketTypeTests).Reader(t.OutBucketFile)
+	}
+	return t.OutFile.Open()
+}
+
+// generateTests runs the test generation and fills in the missing data of the generated tests, in the order of gen.Tests
+func generateTests(ctx context.Context, actx *ArchiveCtx, base *sudoapi.BaseAPI, gen *kilonova.TestGeneration, generated []*archiveTest) *kilonova.StatusError {
+	err := base.GenerateTests(ctx, gen)
+	for _, test := range gen.Tests {
+		for _, name := range []string{test.InputFile, test.OutputFile} {
+			if name != "" {
+				actx.generatedFiles = append(actx.generatedFiles, name)
+			}
+		}
+	}
+	if err != nil {
+		return kilonova.WrapError(err, "Couldn't generate tests")
+	}
+	for i, test := range generated {
+		if !test.hasInput() {
+			test.InBucketFile = gen.Tests[i].InputFile
+		}
+		if !test.hasOutput() {
+			test.OutBucketFile = gen.Tests[i].OutputFile
+		}
+	}
+	return nil
+}
+
+func (actx *ArchiveCtx) removeGeneratedFiles() {
+	bucket := datastore.GetBucket(datastore.BucketTypeTests)
+	for _, name := range actx.generatedFiles {
+		if err := bucket.RemoveFile(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			zap.S().Warn("Couldn't remove generated test file: ", err)
+		}
+	}
+	actx.generatedFiles = nil
+}
+
+// splitCommand splits a generator command line like a POSIX shell would, without expansions.
+// Single quotes keep their contents as is, while backslashes escape the next character outside them
+func splitCommand(cmd string) ([]string, error) {
+	var args []string
+	var arg strings.Builder
+	inArg := false
+	for i := 0; i < len(cmd); i++ {
+		c := cmd[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		case c == '\'':
+			end := strings.IndexByte(cmd[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quote in %q", cmd)
+			}
+			arg.WriteString(cmd[i+1 : i+1+end])
+			i += end + 1
+			inArg = true
+		case c == '"':
+			i++
+			for ; i < len(cmd) && cmd[i] != '"'; i++ {
+				// Inside double quotes, backslashes only escape the characters that are special there
+				if cmd[i] == '\\' && i+1 < len(cmd) && strings.IndexByte("\"\\$`", cmd[i+1]) >= 0 {
+					i++
+				}
+				arg.WriteByte(cmd[i])
+			}
+			if i == len(cmd) {
+				return nil, fmt.Errorf("unterminated quote in %q", cmd)
+			}
+			inArg = true
+		case c == '\\':
+			if i+1 < len(cmd) {
+				i++
+				arg.WriteByte(cmd[i])
+			}
+			inArg = true
+		default:
+			arg.WriteByte(c)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
 func (t archiveTest) Matches(re *regexp.Regexp) bool {
 	places := re.FindStringIndex(t.Key)
 	if places == nil {
@@ -61,6 +178,23 @@ func ProcessTestInputFile(ctx *ArchiveCtx, file *zip.File) *kilonova.StatusError
 	return nil
 }
 
+// ProcessTestInputDirFile handles additional input files of tests, stored as `inputs/<test>/<name>`.
+// The test is identified the same way as its input file, ie. `inputs/01/grid.txt` belongs to the test of `01.in`
+func ProcessTestInputDirFile(ctx *ArchiveCtx, file *zip.File) *kilonova.StatusError {
+	testName, name, ok := strings.Cut(strings.TrimPrefix(file.Name, "inputs/"), "/")
+	if !ok || testName == "" || name == "" || strings.Contains(name, "/") {
+		return kilonova.Statusf(400, "Invalid input file path %q", file.Name)
+	}
+	tf := ctx.tests[testName]
+	if tf.InputFiles == nil {
+		tf.InputFiles = make(map[string]*zip.File)
+	}
+	tf.InputFiles[name] = file
+	tf.Key = testName
+	ctx.tests[testName] = tf
+	return nil
+}
+
 func ProcessTestOutputFile(ctx *ArchiveCtx, file *zip.File) *kilonova.StatusError {
 	testName := path.Base(file.Name)
 	if slices.Contains(testOutputSuffixes, path.Ext(testName)) {
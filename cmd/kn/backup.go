@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"strings"
+
+	"github.com/KiloProjects/kilonova/sudoapi"
+	"go.uber.org/zap"
+)
+
+// backup writes a consistent archive of the database and the persistent buckets
+func backup(args []string) error {
+	fset := flag.NewFlagSet("backup", flag.ExitOnError)
+	out := fset.String("out", "", "Path of the backup archive")
+	basePath := fset.String("base", "", "Previous backup archive. Contents already stored in it are skipped, making the backup incremental")
+	fset.Parse(args)
+	if *out == "" {
+		return errors.New("missing output path")
+	}
+
+	var baseManifest *sudoapi.BackupManifest
+	if *basePath != "" {
+		var err error
+		baseManifest, err = sudoapi.ReadBackupManifest(*basePath)
+		if err != nil {
+			return err
+		}
+	}
+
+	ctx := context.Background()
+	base, err := sudoapi.InitializeBaseAPI(ctx)
+	if err != nil {
+		return err
+	}
+	defer base.Close()
+
+	f, err1 := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err1 != nil {
+		return err1
+	}
+	manifest, err := base.Backup(ctx, f, baseManifest)
+	if err1 := f.Close(); err1 != nil && err == nil {
+		err = sudoapi.WrapError(err1, "Couldn't close archive")
+	}
+	if err != nil {
+		os.Remove(*out)
+		return err
+	}
+	zap.S().Infof("Backup %s written to %s (%d new files stored)", manifest.ID, *out, len(manifest.Blobs))
+	return nil
+}
+
+// restore loads a backup into an empty instance
+func restore(args []string) error {
+	fset := flag.NewFlagSet("restore", flag.ExitOnError)
+	archive := fset.String("archive", "", "Path of the backup archive")
+	bases := fset.String("base", "", "For incremental backups, the comma-separated base archives, from the most recent to the full backup")
+	fset.Parse(args)
+	if *archive == "" {
+		return errors.New("missing archive path")
+	}
+
+	var basePaths []string
+	if *bases != "" {
+		basePaths = strings.Split(*bases, ",")
+	}
+	if err := sudoapi.RestoreBackup(context.Background(), *archive, basePaths); err != nil {
+		return err
+	}
+	zap.S().Info("Backup restored. Migrations will be run on the next start, if needed")
+	return nil
+}
@@ -66,6 +66,9 @@ func Kilonova() error {
 	if err := base.ResetWaitingSubmissions(ctx); err != nil {
 		zap.S().Warn("Couldn't reset initial working submissions:", err)
 	}
+	if err := base.ResetRunningHacks(ctx); err != nil {
+		zap.S().Warn("Couldn't reset initial running hacks:", err)
+	}
 
 	// for graceful setup and shutdown
 	server := webV1(true, base)
@@ -3,6 +3,7 @@ package main
 import (
 	"flag"
 	"os"
+	"strings"
 
 	"github.com/KiloProjects/kilonova/eval"
 	"github.com/KiloProjects/kilonova/internal/config"
@@ -12,6 +13,9 @@ import (
 var (
 	confPath = flag.String("config", "./config.toml", "Config path")
 	flagPath = flag.String("flags", "./flags.json", "Flag configuration path")
+
+	scrubBuckets  = flag.String("scrub", "", "Check the given comma-separated buckets (tests, subtests, attachments) against the database and exit")
+	deleteOrphans = flag.Bool("delete_orphans", false, "Delete the orphaned files found while scrubbing")
 )
 
 func main() {
@@ -42,6 +46,26 @@ func main() {
 		zap.S().Fatal(err)
 	}
 
+	switch flag.Arg(0) {
+	case "backup":
+		if err := backup(flag.Args()[1:]); err != nil {
+			zap.S().Fatal(err)
+		}
+		os.Exit(0)
+	case "restore":
+		if err := restore(flag.Args()[1:]); err != nil {
+			zap.S().Fatal(err)
+		}
+		os.Exit(0)
+	}
+
+	if *scrubBuckets != "" {
+		if err := scrub(strings.Split(*scrubBuckets, ","), *deleteOrphans); err != nil {
+			zap.S().Fatal(err)
+		}
+		os.Exit(0)
+	}
+
 	if err := eval.Initialize(); err != nil {
 		zap.S().Fatal("Could not initialize the box manager:", err)
 	}
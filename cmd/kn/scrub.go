@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/KiloProjects/kilonova/datastore"
+	"github.com/KiloProjects/kilonova/sudoapi"
+)
+
+// scrub prints the integrity reports of the buckets as JSON
+func scrub(buckets []string, deleteOrphans bool) error {
+	ctx := context.Background()
+	base, err := sudoapi.InitializeBaseAPI(ctx)
+	if err != nil {
+		return err
+	}
+	defer base.Close()
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "\t")
+	for _, name := range buckets {
+		name = strings.TrimSpace(name)
+		if !datastore.BucketType(name).Valid() {
+			return fmt.Errorf("unknown bucket %q", name)
+		}
+		report, err := base.ScrubBucket(ctx, datastore.GetBucket(datastore.BucketType(name)), deleteOrphans)
+		if err != nil {
+			return err
+		}
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -33,6 +33,17 @@ const (
 	ContestTypeVirtual  ContestType = "virtual"
 )
 
+// SystemTestMode is the way submissions are picked for system testing after the contest ends
+type SystemTestMode string
+
+const (
+	SystemTestNone SystemTestMode = ""
+	// SystemTestFinal retests the last submission of every contestant on every problem
+	SystemTestFinal SystemTestMode = "final"
+	// SystemTestBest retests the best submission of every contestant on every problem
+	SystemTestBest SystemTestMode = "best"
+)
+
 type Contest struct {
 	ID        int          `json:"id"`
 	CreatedAt time.Time    `json:"created_at"`
@@ -84,6 +95,19 @@ type Contest struct {
 	// that someone is allowed to send to a problem during a contest
 	// < 0 => no limit
 	MaxSubs int `json:"max_subs"`
+
+	// AllowCustomRuns controls whether contestants can run their code on custom input while the contest is running
+	AllowCustomRuns bool `json:"allow_custom_runs"`
+
+	// SystemTesting, if set, makes submissions sent during the contest be judged only on pretests.
+	// After the contest ends, the selected submissions are reevaluated on all tests.
+	SystemTesting  SystemTestMode `json:"system_testing"`
+	SystemTestedAt *time.Time     `json:"system_tested_at"`
+
+	// AllowHacking lets contestants that locked a problem challenge others' accepted submissions with their own inputs.
+	// HackScore is added to the leaderboard total for every successful hack.
+	AllowHacking bool            `json:"allow_hacking"`
+	HackScore    decimal.Decimal `json:"hack_score"`
 }
 
 func (c *Contest) Started() bool {
@@ -107,6 +131,14 @@ func (c *Contest) Running() bool {
 	return c.Started() && !c.Ended()
 }
 
+// PretestsOnly returns whether submissions sent now should be judged only on pretests
+func (c *Contest) PretestsOnly() bool {
+	if c == nil {
+		return false
+	}
+	return c.SystemTesting != SystemTestNone && c.SystemTestedAt == nil
+}
+
 func (c *Contest) LogValue() slog.Value {
 	return slog.GroupValue(slog.Int("id", c.ID), slog.String("name", c.Name))
 }
@@ -160,6 +192,13 @@ type ContestUpdate struct {
 
 	MaxSubs *int `json:"max_subs"`
 
+	AllowCustomRuns *bool `json:"allow_custom_runs"`
+
+	SystemTesting *SystemTestMode `json:"system_testing"`
+
+	AllowHacking *bool            `json:"allow_hacking"`
+	HackScore    *decimal.Decimal `json:"hack_score"`
+
 	RegisterDuringContest *bool `json:"register_during_contest"`
 
 	PublicLeaderboard     *bool           `json:"public_leaderboard"`
@@ -247,6 +286,8 @@ type LeaderboardEntry struct {
 	// For classic mode
 	ProblemScores map[int]decimal.Decimal `json:"scores"`
 	TotalScore    decimal.Decimal         `json:"total"`
+	// NumHacks is the number of successful hacks, whose points are included in TotalScore
+	NumHacks int `json:"num_hacks"`
 
 	// For ICPC mode
 	ProblemAttempts map[int]int `json:"attempts"`
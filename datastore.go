@@ -8,8 +8,6 @@ var (
 	ErrNotExist = Statusf(404, "File doesn't exist")
 )
 
-// TODO: Bucket interface
-
 func init() {
 	ErrNotExist.WrappedError = fs.ErrNotExist
 }
@@ -1,47 +1,98 @@
 package datastore
 
 import (
-	"cmp"
 	"errors"
+	"fmt"
 	"io"
 	"io/fs"
 	"log/slog"
 	"os"
-	"path"
-	"slices"
-	"sync"
+	"strings"
 	"time"
 
-	"github.com/KiloProjects/kilonova"
 	"github.com/dustin/go-humanize"
-	"github.com/klauspost/compress/zstd"
 	"go.uber.org/zap"
 )
 
-var _ slog.LogValuer = &Bucket{}
+// Bucket is a flat collection of files, stored either on the local disk or in a remote object store
+type Bucket interface {
+	// It also implements slog.LogValuer, for pretty printing the bucket name in logs
+	slog.LogValuer
+
+	Name() string
+	// IsPersistent is a sanity check flag for important buckets such as the tests bucket, such that eviction or cleaning is never performed
+	IsPersistent() bool
+	// IsCache is true only if the bucket can be fully purged using ResetCache
+	IsCache() bool
+	// Compression returns the codec used when writing files
+	Compression() Compression
+
+	Reader(name string) (io.ReadCloser, error)
+	// ReadSeeker is like Reader, but the file may be seeked. Compressed and remote files are first copied in a temporary file
+	ReadSeeker(name string) (io.ReadSeekCloser, error)
+	Stat(name string) (fs.FileInfo, error)
+	WriteFile(name string, r io.Reader, mode fs.FileMode) error
+	RemoveFile(name string) error
+	// FileList returns the raw stored files, whose names may contain the compression extension
+	FileList() ([]fs.DirEntry, error)
+
+	Statistics(refresh bool) *BucketStats
+	Evictable() bool
+	RunEvictionPolicy(logger *slog.Logger) (int, error)
+	ResetCache() error
+}
 
-type Bucket struct {
-	RootPath string
-	Name     string
+// versionedInfo is implemented by the file infos that know an identifier of the file's contents
+type versionedInfo interface {
+	Version() string
+}
 
-	// Persistent is a sanity check flag for important buckets such as the tests bucket
-	// Such that eviction or cleaning is never performed
-	Persistent bool
+// FileVersion returns an identifier that changes whenever the file is rewritten.
+// The modification time is only used if the bucket doesn't know better, since some stores have a 1 second resolution
+func FileVersion(info fs.FileInfo) string {
+	if v, ok := info.(versionedInfo); ok && v.Version() != "" {
+		return v.Version()
+	}
+	return fmt.Sprintf("%d-%d", info.ModTime().UnixNano(), info.Size())
+}
 
-	// Cache is true only if the bucket should act like a cache
-	// That is, it can be fully purged using the Reset() method
-	// It's a safeguard against accidentally removing real data
-	Cache bool
+// bucketSettings holds the options common to all bucket implementations
+type bucketSettings struct {
+	name string
 
-	MaxSize int64         // Maximum size in bytes. Values < 1024 mean system is off
-	MaxTTL  time.Duration // Maximum duration before emptying
+	persistent bool
+	cache      bool
 
-	// 0 = flate.NoCompression
-	// -1 = flate.DefaultCompression
-	CompressionLevel int
+	maxSize int64         // Maximum size in bytes. Values < 1024 mean system is off
+	maxTTL  time.Duration // Maximum duration before emptying
 
-	lastStatsMu sync.RWMutex
-	lastStats   *BucketStats
+	// compression is used for newly written files. Files stored with other codecs are still read
+	compression Compression
+
+	// index is maintained on every write, read and removal
+	index objectIndex
+}
+
+func (b *bucketSettings) Name() string       { return b.name }
+func (b *bucketSettings) IsPersistent() bool { return b.persistent }
+func (b *bucketSettings) IsCache() bool      { return b.cache }
+
+func (b *bucketSettings) Compression() Compression { return b.compression }
+
+func (b *bucketSettings) Evictable() bool {
+	return !b.persistent && (b.maxSize > 1024 || b.maxTTL > time.Second)
+}
+
+func (b *bucketSettings) LogValue() slog.Value {
+	return slog.StringValue(b.name)
+}
+
+func (b *bucketSettings) newStats() *BucketStats {
+	return &BucketStats{
+		Name: b.name, Cache: b.cache,
+		Persistent: b.persistent, MaxSize: b.maxSize, MaxTTL: b.maxTTL,
+		Compression: b.compression.String(),
+	}
 }
 
 type BucketStats struct {
@@ -52,264 +103,113 @@ type BucketStats struct {
 	MaxSize    int64         // Maximum size in bytes.
 	MaxTTL     time.Duration // Maximum duration before cleaning up object
 
+	Deduplicated bool
+	Compression  string
+
 	CreatedAt time.Time
 
 	// Actual statistics
 	NumItems   int
 	OnDiskSize int64
+
+	// Hits and Misses count the reads of existing and missing files since startup
+	Hits   int64
+	Misses int64
 }
 
-func (b *Bucket) Statistics(refresh bool) *BucketStats {
-	if !refresh && b.lastStats != nil {
-		b.lastStatsMu.RLock()
-		defer b.lastStatsMu.RUnlock()
-		return b.lastStats
-	}
-	b.lastStatsMu.Lock()
-	defer b.lastStatsMu.Unlock()
-	b.lastStats = &BucketStats{
-		Name: b.Name, Cache: b.Cache,
-		Persistent: b.Persistent, MaxSize: b.MaxSize, MaxTTL: b.MaxTTL,
-	}
-	entries, err := b.FileList()
-	if err != nil {
-		zap.S().Warn(err)
-	}
-	for _, entry := range entries {
-		info, err := entry.Info()
-		if err != nil {
+// statistics returns the bucket statistics from its index. A refresh rebuilds the index from the file list
+func statistics(b *bucketSettings, list func() ([]fs.DirEntry, error), refresh bool) *BucketStats {
+	if refresh || !b.index.isLoaded() {
+		if err := b.index.load(list); err != nil {
 			zap.S().Warn(err)
 			return nil
 		}
-		b.lastStats.NumItems++
-		b.lastStats.OnDiskSize += info.Size()
 	}
-	b.lastStats.CreatedAt = time.Now()
-	return b.lastStats
-}
-
-func (b *Bucket) Init() error {
-	return os.MkdirAll(path.Join(b.RootPath, b.Name), 0755)
+	stats := b.newStats()
+	b.index.fillStats(stats)
+	return stats
 }
 
-func (b *Bucket) Stat(name string) (fs.FileInfo, error) {
-	stat, err := os.Stat(b.filePath(name) + ".zst")
-	if err == nil {
-		return stat, nil
-	}
-	if !errors.Is(err, fs.ErrNotExist) {
-		return nil, err
-	}
-
-	stat, err = os.Stat(b.filePath(name) + ".gz")
-	if err == nil {
-		return stat, nil
+// runEvictionPolicy removes the files older than the bucket's TTL, and then the least recently used ones until it fits its maximum size
+func runEvictionPolicy(b *bucketSettings, list func() ([]fs.DirEntry, error), remove func(name string) error, logger *slog.Logger) (int, error) {
+	if b.persistent {
+		return -1, errors.New("Bucket is marked as persistent, refusing to run eviction policy")
 	}
-	if !errors.Is(err, fs.ErrNotExist) {
-		return nil, err
+	if !b.index.isLoaded() {
+		if err := b.index.load(list); err != nil {
+			return -1, err
+		}
 	}
 
-	return os.Stat(b.filePath(name))
-}
-
-func (b *Bucket) WriteFile(name string, r io.Reader, mode fs.FileMode) error {
-	filename := b.filePath(name)
-	if b.CompressionLevel != NoCompression {
-		filename += ".zst"
+	stats := b.newStats()
+	b.index.fillStats(stats)
+	if logger != nil {
+		logger.Info("Before cleanup", slog.Any("bucket", b), slog.Int("object_count", stats.NumItems), slog.String("bucket_size", humanize.IBytes(uint64(stats.OnDiskSize))))
 	}
 
-	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
-	if err != nil {
-		return err
-	}
-	if b.CompressionLevel == NoCompression {
-		_, err = io.Copy(f, r)
-		if err1 := f.Close(); err1 != nil && err == nil {
-			err = err1
+	var numDeleted int
+	for _, name := range b.index.evict(b.maxSize, b.maxTTL, "") {
+		if err := remove(name); err != nil {
+			return numDeleted, err
 		}
-		return err
+		numDeleted++
 	}
 
-	zw, err := zstd.NewWriter(f, zstd.WithEncoderConcurrency(1))
-	if err != nil {
-		f.Close()
-		return err
+	b.index.fillStats(stats)
+	if logger != nil {
+		logger.Info("After cleanup", slog.Any("bucket", b), slog.Int("object_count", stats.NumItems), slog.String("bucket_size", humanize.IBytes(uint64(stats.OnDiskSize))))
 	}
 
-	_, err = io.Copy(zw, r)
-	if err1 := zw.Close(); err1 != nil && err == nil {
-		err = err1
-	}
-	if err1 := f.Close(); err1 != nil && err == nil {
-		err = err1
-	}
-	return err
+	return numDeleted, nil
 }
 
-func (b *Bucket) Reader(name string) (io.ReadCloser, error) {
-	f, err := os.Open(b.filePath(name) + ".zst")
-	if err == nil {
-		return &zstdFileReader{f, newZstdReader(f)}, nil
-	}
-	if !errors.Is(err, fs.ErrNotExist) {
-		return nil, err
+// written records the new file in the index. If the bucket got bigger than its maximum size, the least recently used files are evicted right away
+func (b *bucketSettings) written(name string, size int64, remove func(name string) error) {
+	b.index.put(name, size)
+	if b.persistent || b.maxSize <= 1024 || !b.index.isLoaded() {
+		return
 	}
-
-	f, err = os.Open(b.filePath(name) + ".gz")
-	if err == nil {
-		gz, err := newGzipReader(f)
-		if err != nil {
-			return nil, err
+	for _, evicted := range b.index.evict(b.maxSize, 0, name) {
+		if err := remove(evicted); err != nil {
+			zap.S().Warnf("Couldn't evict file %q from bucket %q: %v", evicted, b.name, err)
 		}
-		return &gzipFileReader{f, gz}, nil
-	}
-	if !errors.Is(err, fs.ErrNotExist) {
-		return nil, err
-	}
-
-	f, err = os.Open(b.filePath(name))
-	if err == nil {
-		return f, nil
-	}
-	if errors.Is(err, fs.ErrNotExist) {
-		return nil, kilonova.ErrNotExist
 	}
-	return nil, err
 }
 
-// ReadSeeker tries to open the given file using the normal reader function. If the output implements ReadSeekCloser,
-// then it is used directly. Otherwise, we decompress on the fly into a temp file and return that instead (it will be deleted on Close()).
-// TODO: Better caching, maybe some kind of sub-bucket concept?
-func (b *Bucket) ReadSeeker(name string) (io.ReadSeekCloser, error) {
-	rc, err := b.Reader(name)
-	if err != nil {
-		return nil, err
+// Pin protects the file from eviction until the returned function is called, such as while an executable is being run
+func Pin(b Bucket, name string) (unpin func()) {
+	if p, ok := rawBucket(b).(interface{ pin(string) func() }); ok {
+		return p.pin(name)
 	}
-	if rsc, ok := rc.(io.ReadSeekCloser); ok {
-		return rsc, nil
-	}
-	zap.S().Debug("ReadSeeker called on compressed file")
-	defer rc.Close()
-	f, err := os.CreateTemp("", "bucket-temp-*")
-	if err != nil {
-		return nil, err
-	}
-	if _, err := io.Copy(f, rc); err != nil {
-		f.Close()
-		return nil, err
-	}
-	if _, err := f.Seek(0, io.SeekStart); err != nil {
-		f.Close()
-		return nil, err
-	}
-	return &deletingClosedFile{f}, nil
+	return func() {}
 }
 
-func (b *Bucket) RemoveFile(name string) error {
-	if err := os.Remove(b.filePath(name) + ".zst"); err != nil && !errors.Is(err, fs.ErrNotExist) {
-		return err
-	}
-	if err := os.Remove(b.filePath(name) + ".gz"); err != nil && !errors.Is(err, fs.ErrNotExist) {
-		return err
-	}
-	if err := os.Remove(b.filePath(name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
-		return err
-	}
-	return nil
-}
+func (b *bucketSettings) pin(name string) func() { return b.index.pin(name) }
 
-func (b *Bucket) FileList() ([]fs.DirEntry, error) {
-	entries, err := os.ReadDir(path.Join(b.RootPath, b.Name))
-	if errors.Is(err, fs.ErrNotExist) {
-		return nil, nil
+// rawBucket returns the bucket actually storing the files, without the content-addressing layer
+func rawBucket(b Bucket) Bucket {
+	if cb, ok := b.(*contentBucket); ok {
+		return cb.Bucket
 	}
-	return entries, err
-}
-
-type evictionEntry struct {
-	name    string
-	modTime time.Time
-	size    int64
+	return b
 }
 
-func (b *Bucket) Evictable() bool {
-	return !b.Persistent && (b.MaxSize > 1024 || b.MaxTTL > time.Second)
+// IsEmpty returns whether the bucket stores no files. Content-addressed buckets don't query their index
+func IsEmpty(b Bucket) (bool, error) {
+	entries, err := rawBucket(b).FileList()
+	return len(entries) == 0, err
 }
 
-func (b *Bucket) RunEvictionPolicy(logger *slog.Logger) (int, error) {
-	if b.Persistent {
-		return -1, errors.New("Bucket is marked as persistent, refusing to run eviction policy")
-	}
-	b.lastStatsMu.Lock()
-	defer b.lastStatsMu.Unlock()
-	entries, err := os.ReadDir(path.Join(b.RootPath, b.Name))
-	if err != nil {
-		return -1, err
-	}
-	var dirSize int64
-	// Get directory size and file entries
-	evictionEntries := make([]evictionEntry, len(entries))
-	for i := range entries {
-		info, err := entries[i].Info()
-		if err != nil {
-			zap.S().Warn(err)
-			return -1, nil
-		}
-		evictionEntries[i].name = info.Name()
-		evictionEntries[i].modTime = info.ModTime()
-		evictionEntries[i].size = info.Size()
-		dirSize += info.Size()
-	}
-	// Order entries ascending based on file last modified date
-	slices.SortFunc(evictionEntries, func(a, b evictionEntry) int {
-		return cmp.Compare(a.modTime.UnixMicro(), b.modTime.UnixMicro())
-	})
-
-	if logger != nil {
-		logger.Info("Before cleanup", slog.Any("bucket", b), slog.Int("object_count", len(evictionEntries)), slog.String("bucket_size", humanize.IBytes(uint64(dirSize))))
-	}
-
-	var numDeleted int
-	for len(evictionEntries) > 0 {
-		var ok bool = true
-		// If MaxTTL is big enough and file is earlier than that policy, mark for deletion
-		if b.MaxTTL > time.Second && time.Since(evictionEntries[0].modTime) > b.MaxTTL {
-			ok = false
-		}
-		// If directory size is still bigger than maximum
-		if b.MaxSize > 1024 && dirSize > b.MaxSize {
-			ok = false
-		}
-		if ok {
-			break
-		}
-		dirSize -= evictionEntries[0].size
-		if err := os.Remove(b.filePath(evictionEntries[0].name)); err != nil {
-			return numDeleted, err
-		}
-		numDeleted++
-		evictionEntries = evictionEntries[1:]
-	}
-
-	b.lastStats = &BucketStats{
-		Name: b.Name, Cache: b.Cache,
-		Persistent: b.Persistent, MaxSize: b.MaxSize, MaxTTL: b.MaxTTL,
-		NumItems: len(evictionEntries), OnDiskSize: dirSize,
-		CreatedAt: time.Now(),
-	}
-
-	if logger != nil {
-		logger.Info("After cleanup", slog.Any("bucket", b), slog.Int("object_count", len(evictionEntries)), slog.String("bucket_size", humanize.IBytes(uint64(dirSize))))
-	}
-
-	return numDeleted, nil
+// TrimCompressionExt returns the file name from a raw name returned by FileList
+func TrimCompressionExt(name string) string {
+	return strings.TrimSuffix(name, codecOf(name).Ext())
 }
 
-func (b *Bucket) ResetCache() error {
-	if b.Persistent {
+func resetCache(b Bucket) error {
+	if b.IsPersistent() {
 		return errors.New("Bucket is marked as persistent, refusing to delete")
 	}
-	if !b.Cache {
+	if !b.IsCache() {
 		return errors.New("Bucket is not marked as cache, refusing to delete")
 	}
 	var errs []error
@@ -318,7 +218,7 @@ func (b *Bucket) ResetCache() error {
 		zap.S().Warn(err)
 	}
 	for _, entry := range entries {
-		if err := b.RemoveFile(entry.Name()); err != nil {
+		if err := b.RemoveFile(TrimCompressionExt(entry.Name())); err != nil {
 			errs = append(errs, err)
 		}
 	}
@@ -327,26 +227,28 @@ func (b *Bucket) ResetCache() error {
 	return errors.Join(errs...)
 }
 
-func (b *Bucket) LogValue() slog.Value {
-	return slog.StringValue(b.Name)
-}
-
-func NewBucket(path string, name string, compressionLevel int, cache bool, persistent bool, maxSize int64, maxTTL time.Duration) (*Bucket, error) {
-	b := &Bucket{
-		RootPath:   path,
-		Name:       name,
-		Persistent: persistent,
-		Cache:      cache,
-		MaxSize:    maxSize,
-		MaxTTL:     maxTTL,
-
-		CompressionLevel: compressionLevel,
+// seekableReader returns the reader if it can be seeked. Otherwise, its contents are copied into a temp file, which is deleted on Close()
+func seekableReader(rc io.ReadCloser) (io.ReadSeekCloser, error) {
+	if rsc, ok := rc.(io.ReadSeekCloser); ok {
+		return rsc, nil
 	}
-	return b, b.Init()
-}
-
-func (b *Bucket) filePath(name string) string {
-	return path.Join(b.RootPath, b.Name, name)
+	zap.S().Debug("ReadSeeker called on compressed file")
+	defer rc.Close()
+	f, err := os.CreateTemp("", "bucket-temp-*")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(f, rc); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return &deletingClosedFile{f}, nil
 }
 
 type deletingClosedFile struct {
@@ -1,8 +1,10 @@
 package datastore
 
 import (
+	"fmt"
 	"io"
-	"os"
+	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/klauspost/compress/gzip"
@@ -11,13 +13,133 @@ import (
 	"go.uber.org/zap"
 )
 
+// Codec is the compression format of the stored files, recognized by the file extension
+type Codec string
+
+const (
+	CodecNone Codec = "none"
+	CodecGzip Codec = "gzip"
+	CodecZstd Codec = "zstd"
+)
+
+// readCodecs is the order in which the variants of a file are looked up
+var readCodecs = []Codec{CodecZstd, CodecGzip, CodecNone}
+
+func (c Codec) Ext() string {
+	switch c {
+	case CodecGzip:
+		return ".gz"
+	case CodecZstd:
+		return ".zst"
+	default:
+		return ""
+	}
+}
+
+func (c Codec) Valid() bool {
+	return c == CodecNone || c == CodecGzip || c == CodecZstd
+}
+
+// codecOf returns the codec of a raw name returned by FileList
+func codecOf(rawName string) Codec {
+	for _, codec := range readCodecs {
+		if codec != CodecNone && strings.HasSuffix(rawName, codec.Ext()) {
+			return codec
+		}
+	}
+	return CodecNone
+}
+
+// Compression is the codec and level used for writing the files of a bucket
+type Compression struct {
+	Codec Codec
+	// Level is specific to the codec (1-9 for gzip, 1-22 for zstd). 0 selects the codec's default
+	Level int
+}
+
 var (
-	NoCompression      = gzip.NoCompression
-	DefaultCompression = gzip.DefaultCompression
+	NoCompression = Compression{Codec: CodecNone}
+	// DefaultCompression is the codec of the test data unless configured otherwise, kept as gzip such that existing installs don't change codecs
+	DefaultCompression = Compression{Codec: CodecGzip}
 )
 
+func (c Compression) String() string {
+	if c.Level == 0 || c.Codec == CodecNone {
+		return string(c.Codec)
+	}
+	return string(c.Codec) + ":" + strconv.Itoa(c.Level)
+}
+
+// ParseCompression parses the compression from the config, in the form of "codec" or "codec:level"
+func ParseCompression(s string) (Compression, error) {
+	codec, level, hasLevel := strings.Cut(s, ":")
+	c := Compression{Codec: Codec(codec)}
+	if !c.Codec.Valid() {
+		return c, fmt.Errorf("unknown compression codec %q", codec)
+	}
+	if hasLevel {
+		lvl, err := strconv.Atoi(level)
+		if err != nil {
+			return c, fmt.Errorf("invalid compression level %q", level)
+		}
+		c.Level = lvl
+	}
+	switch {
+	case c.Codec == CodecNone && c.Level != 0:
+		return c, fmt.Errorf("uncompressed buckets don't have a compression level")
+	case c.Codec == CodecGzip && (c.Level < 0 || c.Level > gzip.BestCompression):
+		return c, fmt.Errorf("gzip compression level must be between 1 and %d", gzip.BestCompression)
+	case c.Codec == CodecZstd && (c.Level < 0 || c.Level > 22):
+		return c, fmt.Errorf("zstd compression level must be between 1 and 22")
+	}
+	return c, nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// newCompressWriter returns a writer compressing into w. Closing it does not close w
+func newCompressWriter(w io.Writer, c Compression) (io.WriteCloser, error) {
+	switch c.Codec {
+	case CodecGzip:
+		level := c.Level
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		return gzip.NewWriterLevel(w, level)
+	case CodecZstd:
+		opts := []zstd.EOption{zstd.WithEncoderConcurrency(1)}
+		if c.Level > 0 {
+			opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(c.Level)))
+		}
+		return zstd.NewWriter(w, opts...)
+	default:
+		return nopWriteCloser{w}, nil
+	}
+}
+
+// newDecompressReader wraps the stored file such that it's transparently decompressed. The file is closed along with the reader
+func newDecompressReader(codec Codec, rc io.ReadCloser) (io.ReadCloser, error) {
+	switch codec {
+	case CodecGzip:
+		gz, err := newGzipReader(rc)
+		if err != nil {
+			rc.Close()
+			return nil, err
+		}
+		return &gzipFileReader{rc, gz}, nil
+	case CodecZstd:
+		return &zstdFileReader{rc, newZstdReader(rc)}, nil
+	default:
+		return rc, nil
+	}
+}
+
 type gzipFileReader struct {
-	f  *os.File
+	f  io.ReadCloser
 	gz *gzip.Reader
 }
 
@@ -55,7 +177,7 @@ func newGzipReader(r io.Reader) (*gzip.Reader, error) {
 }
 
 type zstdFileReader struct {
-	f  *os.File
+	f  io.ReadCloser
 	zr *zstd.Decoder
 }
 
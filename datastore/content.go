@@ -0,0 +1,354 @@
+package datastore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// BlobRef links a file name of a content-addressed bucket to the blob holding its contents
+type BlobRef struct {
+	Name      string    `json:"name"`
+	Hash      string    `json:"hash"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// BlobIndex stores the references of content-addressed buckets
+type BlobIndex interface {
+	// BlobRef returns nil if the file has no reference
+	BlobRef(ctx context.Context, bucket string, name string) (*BlobRef, error)
+	SetBlobRef(ctx context.Context, bucket string, name string, hash string) error
+	RemoveBlobRef(ctx context.Context, bucket string, name string) error
+	BlobRefs(ctx context.Context, bucket string) ([]*BlobRef, error)
+	BlobReferenced(ctx context.Context, bucket string, hash string) (bool, error)
+}
+
+// ContentAddressed is implemented by buckets that store files by the hash of their contents
+type ContentAddressed interface {
+	// CollectGarbage removes the stored contents that are no longer used by any file
+	CollectGarbage(ctx context.Context, logger *slog.Logger) (int, error)
+	// MigrateFiles deduplicates the files stored before content addressing was enabled
+	MigrateFiles(ctx context.Context) (int, error)
+}
+
+var _ Bucket = &contentBucket{}
+var _ ContentAddressed = &contentBucket{}
+
+// blobNameRegex matches the names of the blobs in the underlying bucket, with the optional compression extension
+var blobNameRegex = regexp.MustCompile(`^[0-9a-f]{64}(\.zst|\.gz)?$`)
+
+// blobGracePeriod protects the blobs being written from garbage collection, before their reference is saved
+const blobGracePeriod = time.Hour
+
+// contentBucket deduplicates files by storing them in the underlying bucket under the hash of their contents.
+// The index links the file names to the blobs, so identical files are only stored once.
+// Files written before deduplication was enabled are still read directly from the underlying bucket.
+type contentBucket struct {
+	Bucket
+
+	index BlobIndex
+
+	// blobMu makes taking a reference to an existing blob and garbage collecting it mutually exclusive
+	blobMu sync.Mutex
+}
+
+// NewContentBucket wraps the bucket such that files are stored by their content hash
+func NewContentBucket(bucket Bucket, index BlobIndex) Bucket {
+	return &contentBucket{Bucket: bucket, index: index}
+}
+
+// blobInfo reports the blob's size under the file's name. The modification time is the one of the reference,
+// since blobs may be older than the files pointing to them
+type blobInfo struct {
+	fs.FileInfo
+	name    string
+	modTime time.Time
+	hash    string
+}
+
+func (i *blobInfo) Name() string       { return i.name }
+func (i *blobInfo) ModTime() time.Time { return i.modTime }
+func (i *blobInfo) Version() string    { return i.hash }
+
+func (b *contentBucket) ref(name string) (*BlobRef, error) {
+	return b.index.BlobRef(context.Background(), b.Name(), name)
+}
+
+func (b *contentBucket) Stat(name string) (fs.FileInfo, error) {
+	ref, err := b.ref(name)
+	if err != nil {
+		return nil, err
+	}
+	if ref == nil {
+		return b.Bucket.Stat(name)
+	}
+	info, err := b.Bucket.Stat(ref.Hash)
+	if err != nil {
+		return nil, err
+	}
+	return &blobInfo{FileInfo: info, name: name, modTime: ref.UpdatedAt, hash: ref.Hash}, nil
+}
+
+func (b *contentBucket) Reader(name string) (io.ReadCloser, error) {
+	ref, err := b.ref(name)
+	if err != nil {
+		return nil, err
+	}
+	if ref == nil {
+		return b.Bucket.Reader(name)
+	}
+	return b.Bucket.Reader(ref.Hash)
+}
+
+func (b *contentBucket) ReadSeeker(name string) (io.ReadSeekCloser, error) {
+	ref, err := b.ref(name)
+	if err != nil {
+		return nil, err
+	}
+	if ref == nil {
+		return b.Bucket.ReadSeeker(name)
+	}
+	return b.Bucket.ReadSeeker(ref.Hash)
+}
+
+// WriteFile hashes the contents before storing them, so files whose contents are already stored only get a new reference
+func (b *contentBucket) WriteFile(name string, r io.Reader, mode fs.FileMode) error {
+	if blobNameRegex.MatchString(name) {
+		return errors.New("file name is reserved for content-addressed blobs")
+	}
+	f, err := os.CreateTemp("", "bucket-blob-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, h), r); err != nil {
+		return err
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+
+	ctx := context.Background()
+	oldRef, err := b.index.BlobRef(ctx, b.Name(), name)
+	if err != nil {
+		return err
+	}
+	// The reference is taken before checking for the blob, otherwise an existing unreferenced blob
+	// could be garbage collected between the check and saving the reference
+	b.blobMu.Lock()
+	if err := b.index.SetBlobRef(ctx, b.Name(), name, hash); err != nil {
+		b.blobMu.Unlock()
+		return err
+	}
+	_, err = b.Bucket.Stat(hash)
+	b.blobMu.Unlock()
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			err = b.writeBlob(hash, f, mode)
+		}
+		if err != nil {
+			b.restoreRef(ctx, name, oldRef)
+			return err
+		}
+	}
+	// The file written before deduplication would otherwise be left behind
+	return b.Bucket.RemoveFile(name)
+}
+
+func (b *contentBucket) writeBlob(hash string, f *os.File, mode fs.FileMode) error {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return b.Bucket.WriteFile(hash, f, mode)
+}
+
+// restoreRef puts back the file's previous reference after a failed write
+func (b *contentBucket) restoreRef(ctx context.Context, name string, oldRef *BlobRef) {
+	var err error
+	if oldRef == nil {
+		err = b.index.RemoveBlobRef(ctx, b.Name(), name)
+	} else {
+		err = b.index.SetBlobRef(ctx, b.Name(), name, oldRef.Hash)
+	}
+	if err != nil {
+		zap.S().Warn("Could not restore blob reference: ", err)
+	}
+}
+
+// RemoveFile only removes the reference, the blob is removed during garbage collection if it's not used anymore
+func (b *contentBucket) RemoveFile(name string) error {
+	if err := b.index.RemoveBlobRef(context.Background(), b.Name(), name); err != nil {
+		return err
+	}
+	return b.Bucket.RemoveFile(name)
+}
+
+// FileList returns the referenced files, along with the ones written before deduplication
+func (b *contentBucket) FileList() ([]fs.DirEntry, error) {
+	entries, err := b.Bucket.FileList()
+	if err != nil {
+		return nil, err
+	}
+	refs, err := b.index.BlobRefs(context.Background(), b.Name())
+	if err != nil {
+		return nil, err
+	}
+	blobs := make(map[string]fs.FileInfo)
+	files := make([]fs.DirEntry, 0, len(entries))
+	for _, entry := range entries {
+		if !blobNameRegex.MatchString(entry.Name()) {
+			files = append(files, entry)
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		hash, _, _ := strings.Cut(entry.Name(), ".")
+		blobs[hash] = info
+	}
+	for _, ref := range refs {
+		info, ok := blobs[ref.Hash]
+		if !ok {
+			zap.S().Warnf("Missing blob %s for file %q in bucket %q", ref.Hash, ref.Name, b.Name())
+			continue
+		}
+		files = append(files, fs.FileInfoToDirEntry(&blobInfo{FileInfo: info, name: ref.Name, modTime: ref.UpdatedAt}))
+	}
+	slices.SortFunc(files, func(a, b fs.DirEntry) int { return strings.Compare(a.Name(), b.Name()) })
+	return files, nil
+}
+
+// Statistics counts the stored blobs, so files with identical contents are counted once
+func (b *contentBucket) Statistics(refresh bool) *BucketStats {
+	stats := b.Bucket.Statistics(refresh)
+	if stats == nil {
+		return nil
+	}
+	ret := *stats
+	ret.Deduplicated = true
+	return &ret
+}
+
+// BlobReader returns the contents with the given hash, if the bucket is content-addressed
+func BlobReader(b Bucket, hash string) (io.ReadCloser, error) {
+	cb, ok := b.(*contentBucket)
+	if !ok {
+		return nil, errors.New("bucket is not content-addressed")
+	}
+	return cb.Bucket.Reader(hash)
+}
+
+// Eviction works on the stored blobs, so it's not supported. Content-addressed buckets are meant for persistent data
+func (b *contentBucket) Evictable() bool {
+	return false
+}
+
+func (b *contentBucket) RunEvictionPolicy(*slog.Logger) (int, error) {
+	return -1, errors.New("Content-addressed buckets don't support eviction")
+}
+
+func (b *contentBucket) ResetCache() error {
+	return resetCache(b)
+}
+
+// CollectGarbage removes the blobs that are no longer referenced by any file and returns their number
+func (b *contentBucket) CollectGarbage(ctx context.Context, logger *slog.Logger) (int, error) {
+	entries, err := b.Bucket.FileList()
+	if err != nil {
+		return -1, err
+	}
+	refs, err := b.index.BlobRefs(ctx, b.Name())
+	if err != nil {
+		return -1, err
+	}
+	referenced := make(map[string]bool, len(refs))
+	for _, ref := range refs {
+		referenced[ref.Hash] = true
+	}
+
+	var numDeleted int
+	for _, entry := range entries {
+		hash, _, _ := strings.Cut(entry.Name(), ".")
+		if !blobNameRegex.MatchString(entry.Name()) || referenced[hash] {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return numDeleted, err
+		}
+		if time.Since(info.ModTime()) < blobGracePeriod {
+			continue
+		}
+		deleted, err := b.removeUnreferenced(ctx, hash)
+		if err != nil {
+			return numDeleted, err
+		}
+		if deleted {
+			numDeleted++
+		}
+	}
+	if logger != nil {
+		logger.Info("Collected unreferenced blobs", slog.Any("bucket", b), slog.Int("count", numDeleted))
+	}
+	return numDeleted, nil
+}
+
+// removeUnreferenced removes the blob if no file uses it. The blob might have been reused since the references were fetched
+func (b *contentBucket) removeUnreferenced(ctx context.Context, hash string) (bool, error) {
+	b.blobMu.Lock()
+	defer b.blobMu.Unlock()
+	ok, err := b.index.BlobReferenced(ctx, b.Name(), hash)
+	if err != nil || ok {
+		return false, err
+	}
+	if err := b.Bucket.RemoveFile(hash); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// MigrateFiles moves the files written before deduplication into blobs and returns their number
+func (b *contentBucket) MigrateFiles(ctx context.Context) (int, error) {
+	entries, err := b.Bucket.FileList()
+	if err != nil {
+		return -1, err
+	}
+	var numMigrated int
+	for _, entry := range entries {
+		if blobNameRegex.MatchString(entry.Name()) {
+			continue
+		}
+		name := TrimCompressionExt(entry.Name())
+		if err := ctx.Err(); err != nil {
+			return numMigrated, err
+		}
+		r, err := b.Bucket.Reader(name)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return numMigrated, err
+		}
+		err = b.WriteFile(name, r, 0644)
+		r.Close()
+		if err != nil {
+			return numMigrated, err
+		}
+		numMigrated++
+	}
+	return numMigrated, nil
+}
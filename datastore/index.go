@@ -0,0 +1,189 @@
+package datastore
+
+import (
+	"cmp"
+	"container/list"
+	"io/fs"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// objectIndex keeps the sizes and access times of the bucket files in memory, so statistics and eviction don't have to list the bucket.
+// Files are kept ordered from the most to the least recently used
+type objectIndex struct {
+	mu      sync.Mutex
+	loaded  bool
+	objects map[string]*list.Element
+	lru     list.List
+	size    int64
+
+	// pins counts the users of the files that must not be evicted
+	pins map[string]int
+
+	loadedAt time.Time
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+type indexedObject struct {
+	name       string
+	size       int64
+	modTime    time.Time
+	accessedAt time.Time
+}
+
+// load rebuilds the index from the bucket's file list, using the modification times as access times
+func (idx *objectIndex) load(fileList func() ([]fs.DirEntry, error)) error {
+	entries, err := fileList()
+	if err != nil {
+		return err
+	}
+	objects := make([]*indexedObject, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, &indexedObject{
+			name:       TrimCompressionExt(entry.Name()),
+			size:       info.Size(),
+			modTime:    info.ModTime(),
+			accessedAt: info.ModTime(),
+		})
+	}
+	slices.SortFunc(objects, func(a, b *indexedObject) int { return cmp.Compare(a.modTime.UnixMicro(), b.modTime.UnixMicro()) })
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.objects = make(map[string]*list.Element, len(objects))
+	idx.lru.Init()
+	idx.size = 0
+	for _, obj := range objects {
+		if el, ok := idx.objects[obj.name]; ok {
+			// Another variant of the same file
+			idx.size -= el.Value.(*indexedObject).size
+			idx.lru.Remove(el)
+		}
+		idx.objects[obj.name] = idx.lru.PushFront(obj)
+		idx.size += obj.size
+	}
+	idx.loaded = true
+	idx.loadedAt = time.Now()
+	return nil
+}
+
+func (idx *objectIndex) isLoaded() bool {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return idx.loaded
+}
+
+// put records a newly written file
+func (idx *objectIndex) put(name string, size int64) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.objects == nil {
+		idx.objects = make(map[string]*list.Element)
+	}
+	now := time.Now()
+	if el, ok := idx.objects[name]; ok {
+		obj := el.Value.(*indexedObject)
+		idx.size += size - obj.size
+		obj.size, obj.modTime, obj.accessedAt = size, now, now
+		idx.lru.MoveToFront(el)
+		return
+	}
+	idx.objects[name] = idx.lru.PushFront(&indexedObject{name: name, size: size, modTime: now, accessedAt: now})
+	idx.size += size
+}
+
+// access records a read of the file, counting it as a cache hit or miss
+func (idx *objectIndex) access(name string, found bool) {
+	if !found {
+		idx.misses.Add(1)
+		return
+	}
+	idx.hits.Add(1)
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if el, ok := idx.objects[name]; ok {
+		el.Value.(*indexedObject).accessedAt = time.Now()
+		idx.lru.MoveToFront(el)
+	}
+}
+
+func (idx *objectIndex) remove(name string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if el, ok := idx.objects[name]; ok {
+		idx.size -= el.Value.(*indexedObject).size
+		idx.lru.Remove(el)
+		delete(idx.objects, name)
+	}
+}
+
+// pin protects the file from eviction until the returned function is called
+func (idx *objectIndex) pin(name string) func() {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.pins == nil {
+		idx.pins = make(map[string]int)
+	}
+	idx.pins[name]++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			idx.mu.Lock()
+			defer idx.mu.Unlock()
+			if idx.pins[name]--; idx.pins[name] <= 0 {
+				delete(idx.pins, name)
+			}
+		})
+	}
+}
+
+// evict returns the files written before the maximum TTL, followed by the least recently used ones until the bucket fits its maximum size.
+// The kept and pinned files are never evicted. The files stay in the index until they are actually removed, so failed removals are still accounted for
+func (idx *objectIndex) evict(maxSize int64, maxTTL time.Duration, keep string) []string {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	var names []string
+	size := idx.size
+	evicted := make(map[string]bool)
+	evictable := func(obj *indexedObject) bool {
+		return obj.name != keep && idx.pins[obj.name] == 0 && !evicted[obj.name]
+	}
+	if maxTTL > time.Second {
+		for el := idx.lru.Back(); el != nil; el = el.Prev() {
+			if obj := el.Value.(*indexedObject); evictable(obj) && time.Since(obj.modTime) > maxTTL {
+				names = append(names, obj.name)
+				evicted[obj.name] = true
+				size -= obj.size
+			}
+		}
+	}
+	if maxSize > 1024 {
+		for el := idx.lru.Back(); el != nil && size > maxSize; el = el.Prev() {
+			if obj := el.Value.(*indexedObject); evictable(obj) {
+				names = append(names, obj.name)
+				evicted[obj.name] = true
+				size -= obj.size
+			}
+		}
+	}
+	return names
+}
+
+// fillStats sets the item count, size and access counters of the statistics
+func (idx *objectIndex) fillStats(stats *BucketStats) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	stats.NumItems = len(idx.objects)
+	stats.OnDiskSize = idx.size
+	stats.CreatedAt = idx.loadedAt
+	stats.Hits = idx.hits.Load()
+	stats.Misses = idx.misses.Load()
+}
@@ -0,0 +1,33 @@
+package datastore
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestIndexEviction(t *testing.T) {
+	var idx objectIndex
+	for _, name := range []string{"1.bin", "2.bin", "3.bin"} {
+		idx.put(name, 1000)
+	}
+
+	unpin := idx.pin("1.bin")
+	if names := idx.evict(2000, 0, "3.bin"); !slices.Equal(names, []string{"2.bin"}) {
+		t.Errorf("Expected only 2.bin to be evicted, got %v", names)
+	}
+	// The files are only removed from the index once they are deleted
+	if names := idx.evict(2000, 0, "3.bin"); !slices.Equal(names, []string{"2.bin"}) {
+		t.Errorf("Expected 2.bin to be evicted again, got %v", names)
+	}
+
+	idx.remove("2.bin")
+	idx.put("4.bin", 1000)
+	unpin()
+	unpin()
+	if names := idx.evict(2000, 0, "4.bin"); !slices.Equal(names, []string{"1.bin"}) {
+		t.Errorf("Expected 1.bin to be evicted after unpinning, got %v", names)
+	}
+	if idx.size != 3000 {
+		t.Errorf("Expected size 3000, got %d", idx.size)
+	}
+}
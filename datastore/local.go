@@ -0,0 +1,171 @@
+package datastore
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/KiloProjects/kilonova"
+	"go.uber.org/zap"
+)
+
+var _ Bucket = &localBucket{}
+
+// localBucket stores the files in a directory on the local disk
+type localBucket struct {
+	bucketSettings
+
+	rootPath string
+}
+
+func (b *localBucket) Statistics(refresh bool) *BucketStats {
+	return statistics(&b.bucketSettings, b.FileList, refresh)
+}
+
+func (b *localBucket) Init() error {
+	return os.MkdirAll(path.Join(b.rootPath, b.name), 0755)
+}
+
+func (b *localBucket) Stat(name string) (fs.FileInfo, error) {
+	for _, codec := range readCodecs {
+		stat, err := os.Stat(b.filePath(name) + codec.Ext())
+		if err == nil || !errors.Is(err, fs.ErrNotExist) {
+			return stat, err
+		}
+	}
+	return nil, kilonova.ErrNotExist
+}
+
+// WriteFile writes the file under a temporary name first, so readers never see a partially written file.
+// The variants stored with other codecs are removed afterwards.
+func (b *localBucket) WriteFile(name string, r io.Reader, mode fs.FileMode) error {
+	f, err := os.CreateTemp(path.Join(b.rootPath, b.name), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	zw, err := newCompressWriter(f, b.compression)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	_, err = io.Copy(zw, r)
+	if err1 := zw.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	size, err1 := f.Seek(0, io.SeekCurrent)
+	if err1 != nil && err == nil {
+		err = err1
+	}
+	if err1 := f.Chmod(mode); err1 != nil && err == nil {
+		err = err1
+	}
+	if err1 := f.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), b.filePath(name)+b.compression.Codec.Ext()); err != nil {
+		return err
+	}
+	for _, codec := range readCodecs {
+		if codec == b.compression.Codec {
+			continue
+		}
+		if err := os.Remove(b.filePath(name) + codec.Ext()); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	b.written(name, size, b.RemoveFile)
+	return nil
+}
+
+func (b *localBucket) Reader(name string) (io.ReadCloser, error) {
+	for _, codec := range readCodecs {
+		f, err := os.Open(b.filePath(name) + codec.Ext())
+		if err == nil {
+			b.index.access(name, true)
+			return newDecompressReader(codec, f)
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	b.index.access(name, false)
+	return nil, kilonova.ErrNotExist
+}
+
+// ReadSeeker tries to open the given file using the normal reader function. If the output implements ReadSeekCloser,
+// then it is used directly. Otherwise, we decompress on the fly into a temp file and return that instead (it will be deleted on Close()).
+// TODO: Better caching, maybe some kind of sub-bucket concept?
+func (b *localBucket) ReadSeeker(name string) (io.ReadSeekCloser, error) {
+	rc, err := b.Reader(name)
+	if err != nil {
+		return nil, err
+	}
+	return seekableReader(rc)
+}
+
+func (b *localBucket) RemoveFile(name string) error {
+	for _, codec := range readCodecs {
+		if err := os.Remove(b.filePath(name) + codec.Ext()); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	b.index.remove(name)
+	return nil
+}
+
+// FileList skips the hidden files, which are the ones still being written
+func (b *localBucket) FileList() ([]fs.DirEntry, error) {
+	entries, err := os.ReadDir(path.Join(b.rootPath, b.name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return slices.DeleteFunc(entries, func(entry fs.DirEntry) bool {
+		return strings.HasPrefix(entry.Name(), ".")
+	}), err
+}
+
+func (b *localBucket) RunEvictionPolicy(logger *slog.Logger) (int, error) {
+	return runEvictionPolicy(&b.bucketSettings, b.FileList, b.RemoveFile, logger)
+}
+
+func (b *localBucket) ResetCache() error {
+	return resetCache(b)
+}
+
+// NewLocalBucket creates a bucket stored in the given directory
+func NewLocalBucket(path string, name string, compression Compression, cache bool, persistent bool, maxSize int64, maxTTL time.Duration) (Bucket, error) {
+	b := &localBucket{
+		bucketSettings: bucketSettings{
+			name:       name,
+			persistent: persistent,
+			cache:      cache,
+			maxSize:    maxSize,
+			maxTTL:     maxTTL,
+
+			compression: compression,
+		},
+		rootPath: path,
+	}
+	if err := b.Init(); err != nil {
+		return nil, err
+	}
+	if err := b.index.load(b.FileList); err != nil {
+		zap.S().Warnf("Couldn't index bucket %q: %v", name, err)
+	}
+	return b, nil
+}
+
+func (b *localBucket) filePath(name string) string {
+	return path.Join(b.rootPath, b.name, name)
+}
@@ -2,9 +2,11 @@ package datastore
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"time"
 
+	"github.com/KiloProjects/kilonova/internal/config"
 	"go.uber.org/zap"
 )
 
@@ -34,11 +36,14 @@ type bucketDef struct {
 	MaxSize      int64
 	MaxTTL       time.Duration
 
-	CompressionLevel int
+	Compression Compression
+
+	// Deduplicated buckets store the files by their content hash, see NewContentBucket
+	Deduplicated bool
 }
 
 var (
-	buckets     = make(map[BucketType]*Bucket)
+	buckets     = make(map[BucketType]Bucket)
 	initialized = false
 
 	// TODO: Do better...
@@ -49,44 +54,48 @@ var (
 
 			MaxSize: 2 * 1024 * 1024 * 1024, // 2GB
 
-			IsPersistent:     false,
-			CompressionLevel: NoCompression,
+			IsPersistent: false,
+			Compression:  NoCompression,
 		},
 		{
 			Name:    BucketTypeTests,
 			IsCache: false,
 
-			IsPersistent:     true,
-			CompressionLevel: DefaultCompression,
+			IsPersistent: true,
+			Compression:  DefaultCompression,
+			Deduplicated: true,
 		},
 		{
 			Name:    BucketTypeAttachments,
 			IsCache: true,
 
-			IsPersistent:     false,
-			CompressionLevel: NoCompression,
+			IsPersistent: false,
+			Compression:  NoCompression,
 		},
 		{
 			Name:    BucketTypeAvatars,
 			IsCache: true,
 
-			MaxTTL:           31 * 24 * time.Hour, // 31d
-			IsPersistent:     false,
-			CompressionLevel: NoCompression,
+			MaxTTL:       31 * 24 * time.Hour, // 31d
+			IsPersistent: false,
+			Compression:  NoCompression,
 		},
 		{
 			Name:    BucketTypeCheckers,
 			IsCache: true,
 
-			IsPersistent:     false,
-			CompressionLevel: NoCompression,
+			IsPersistent: false,
+			Compression:  NoCompression,
 		},
 		{
 			Name:    BucketTypeCompiles,
 			IsCache: false, // Well it kind of is but not really since it's cleaned up in the grader
 
-			IsPersistent:     false,
-			CompressionLevel: NoCompression,
+			// Binaries may be kept for selective reevaluations
+			MaxSize: 1024 * 1024 * 1024, // 1GB
+
+			IsPersistent: false,
+			Compression:  NoCompression,
 		},
 	}
 )
@@ -97,7 +106,14 @@ func init() {
 	}
 }
 
-func InitBuckets(p string) error {
+const (
+	BackendLocal = "local"
+	BackendS3    = "s3"
+)
+
+// InitBuckets creates the buckets in the data directory, or in the object store if selected in the storage config.
+// The index holds the file references of deduplicated buckets. If it's nil, they store files under their names.
+func InitBuckets(p string, storage config.StorageConf, index BlobIndex) error {
 	if initialized {
 		return errors.New("buckets already initialized")
 	}
@@ -105,10 +121,50 @@ func InitBuckets(p string) error {
 	if err := os.MkdirAll(p, 0777); err != nil {
 		return err
 	}
+	for name, backend := range storage.Backends {
+		if !BucketType(name).Valid() {
+			return fmt.Errorf("unknown bucket %q in storage config", name)
+		}
+		if backend != BackendLocal && backend != BackendS3 {
+			return fmt.Errorf("unknown storage backend %q for bucket %q", backend, name)
+		}
+	}
+	compression := make(map[string]Compression)
+	for name, val := range storage.Compression {
+		if !BucketType(name).Valid() {
+			return fmt.Errorf("unknown bucket %q in storage config", name)
+		}
+		c, err := ParseCompression(val)
+		if err != nil {
+			return fmt.Errorf("invalid compression for bucket %q: %w", name, err)
+		}
+		compression[name] = c
+	}
 	for _, b := range bucketData {
-		bucket, err := NewBucket(p, string(b.Name), b.CompressionLevel, b.IsCache, b.IsPersistent, b.MaxSize, b.MaxTTL)
+		if c, ok := compression[string(b.Name)]; ok {
+			b.Compression = c
+		}
+		var bucket Bucket
+		var err error
+		switch storage.Backends[string(b.Name)] {
+		case BackendS3:
+			bucket, err = NewS3Bucket(S3Options{
+				Endpoint:  storage.S3.Endpoint,
+				Region:    storage.S3.Region,
+				Bucket:    storage.S3.Bucket,
+				AccessKey: storage.S3.AccessKey,
+				SecretKey: storage.S3.SecretKey,
+				PathStyle: storage.S3.PathStyle,
+				Prefix:    storage.S3.Prefix,
+			}, string(b.Name), b.Compression, b.IsCache, b.IsPersistent, b.MaxSize, b.MaxTTL)
+		default:
+			bucket, err = NewLocalBucket(p, string(b.Name), b.Compression, b.IsCache, b.IsPersistent, b.MaxSize, b.MaxTTL)
+		}
 		if err != nil {
-			return err
+			return fmt.Errorf("couldn't initialize bucket %q: %w", b.Name, err)
+		}
+		if b.Deduplicated && index != nil {
+			bucket = NewContentBucket(bucket, index)
 		}
 		buckets[b.Name] = bucket
 	}
@@ -121,7 +177,7 @@ func IsBucket(name BucketType) bool {
 }
 
 // GetBucket panics if there is no bucket with that name
-func GetBucket(name BucketType) *Bucket {
+func GetBucket(name BucketType) Bucket {
 	b, ok := buckets[name]
 	if !ok {
 		zap.S().Fatalf("No bucket found with name %q", name)
@@ -129,8 +185,8 @@ func GetBucket(name BucketType) *Bucket {
 	return b
 }
 
-func GetBuckets() []*Bucket {
-	ret := make([]*Bucket, 0, len(buckets))
+func GetBuckets() []Bucket {
+	ret := make([]Bucket, 0, len(buckets))
 	for _, val := range buckets {
 		ret = append(ret, val)
 	}
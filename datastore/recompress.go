@@ -0,0 +1,42 @@
+package datastore
+
+import "time"
+
+// PendingRecompression returns the names of the stored files whose codec differs from the bucket's one,
+// or that were written before since. The level can't be told from the stored file, so since should be when
+// the bucket started using its compression. Recompressed files are newer than that and are not returned again,
+// so an interrupted recompression continues from where it stopped
+func PendingRecompression(b Bucket, since time.Time) ([]string, error) {
+	raw := rawBucket(b)
+	entries, err := raw.FileList()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if codecOf(entry.Name()) != raw.Compression().Codec {
+			names = append(names, TrimCompressionExt(entry.Name()))
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		if info.ModTime().Before(since) {
+			names = append(names, TrimCompressionExt(entry.Name()))
+		}
+	}
+	return names, nil
+}
+
+// RecompressFile rewrites the file using the bucket's codec.
+// The old variant is only removed after the new one is written, so readers are not affected
+func RecompressFile(b Bucket, name string) error {
+	raw := rawBucket(b)
+	r, err := raw.Reader(name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return raw.WriteFile(name, r, 0644)
+}
@@ -0,0 +1,95 @@
+package datastore
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestRecompression(t *testing.T) {
+	dir := t.TempDir()
+	data := bytes.Repeat([]byte("kilonova "), 1000)
+
+	gzBucket, err := NewLocalBucket(dir, "tests", Compression{Codec: CodecGzip}, false, true, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"1.in", "1.out"} {
+		if err := gzBucket.WriteFile(name, bytes.NewReader(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	bucket, err := NewLocalBucket(dir, "tests", Compression{Codec: CodecZstd, Level: 19}, false, true, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	since := time.Now()
+	pending, err := PendingRecompression(bucket, since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 2 {
+		t.Fatalf("Expected 2 pending files, got %v", pending)
+	}
+	for _, name := range pending {
+		if err := RecompressFile(bucket, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if pending, _ := PendingRecompression(bucket, since.Add(-time.Minute)); len(pending) != 0 {
+		t.Errorf("Files left after recompression: %v", pending)
+	}
+	if _, err := os.Stat(path.Join(dir, "tests", "1.in.gz")); err == nil {
+		t.Error("Old variant was not removed")
+	}
+	r, err := bucket.Reader("1.in")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if got, err := io.ReadAll(r); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("Recompressed file has different contents (err: %v)", err)
+	}
+}
+
+func TestRecompressionLevel(t *testing.T) {
+	dir := t.TempDir()
+	data := bytes.Repeat([]byte("kilonova "), 1000)
+
+	oldBucket, err := NewLocalBucket(dir, "tests", Compression{Codec: CodecZstd, Level: 3}, false, true, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := oldBucket.WriteFile("1.in", bytes.NewReader(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(path.Join(dir, "tests", "1.in.zst"), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the level differs, so the file is pending because it was written before the change
+	bucket, err := NewLocalBucket(dir, "tests", Compression{Codec: CodecZstd, Level: 19}, false, true, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	since := time.Now().Add(-time.Minute)
+	pending, err := PendingRecompression(bucket, since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0] != "1.in" {
+		t.Fatalf("Expected 1.in to be pending, got %v", pending)
+	}
+	if err := RecompressFile(bucket, "1.in"); err != nil {
+		t.Fatal(err)
+	}
+	if pending, _ := PendingRecompression(bucket, since); len(pending) != 0 {
+		t.Errorf("Files left after recompression: %v", pending)
+	}
+}
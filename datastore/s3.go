@@ -0,0 +1,315 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"go.uber.org/zap"
+)
+
+// S3Options configures the connection to an S3-compatible object store
+type S3Options struct {
+	// Endpoint is the base URL of the server, such as https://s3.eu-central-1.amazonaws.com or http://localhost:9000
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+
+	// PathStyle addresses the bucket in the URL path instead of the host name, as required by most self-hosted servers
+	PathStyle bool
+	// Prefix is prepended to the keys of all objects, such that the store can be shared with other applications
+	Prefix string
+
+	// Transport is used for the requests, http.DefaultTransport if nil
+	Transport http.RoundTripper
+}
+
+func newS3Client(opts S3Options) (*minio.Client, error) {
+	if opts.Bucket == "" {
+		return nil, errors.New("no S3 bucket specified")
+	}
+	endpoint, err := url.Parse(opts.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3 endpoint: %w", err)
+	}
+	if endpoint.Scheme != "http" && endpoint.Scheme != "https" {
+		return nil, fmt.Errorf("invalid S3 endpoint scheme %q", endpoint.Scheme)
+	}
+	if strings.Trim(endpoint.Path, "/") != "" {
+		return nil, errors.New("S3 endpoint must not have a path")
+	}
+	if opts.Region == "" {
+		// Setting the region avoids looking up the bucket location before the first request
+		opts.Region = "us-east-1"
+	}
+	lookup := minio.BucketLookupDNS
+	if opts.PathStyle {
+		lookup = minio.BucketLookupPath
+	}
+	return minio.New(endpoint.Host, &minio.Options{
+		Creds:        credentials.NewStaticV4(opts.AccessKey, opts.SecretKey, ""),
+		Secure:       endpoint.Scheme == "https",
+		Transport:    opts.Transport,
+		Region:       opts.Region,
+		BucketLookup: lookup,
+	})
+}
+
+// isS3NotFound returns true if the error is the response to a request for a missing object
+func isS3NotFound(err error) bool {
+	return minio.ToErrorResponse(err).StatusCode == http.StatusNotFound
+}
+
+type s3ObjectInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	etag    string
+}
+
+var _ fs.FileInfo = &s3ObjectInfo{}
+
+func newS3ObjectInfo(name string, obj minio.ObjectInfo) *s3ObjectInfo {
+	return &s3ObjectInfo{name: name, size: obj.Size, modTime: obj.LastModified, etag: obj.ETag}
+}
+
+func (i *s3ObjectInfo) Name() string       { return i.name }
+func (i *s3ObjectInfo) Size() int64        { return i.size }
+func (i *s3ObjectInfo) Mode() fs.FileMode  { return 0644 }
+func (i *s3ObjectInfo) ModTime() time.Time { return i.modTime }
+func (i *s3ObjectInfo) IsDir() bool        { return false }
+func (i *s3ObjectInfo) Sys() any           { return nil }
+
+// Version returns the ETag of the object, since the modification time only has a 1 second resolution
+func (i *s3ObjectInfo) Version() string { return i.etag }
+
+var _ Bucket = &s3Bucket{}
+
+// s3Bucket stores the files as objects in an S3-compatible store, under the bucket name as prefix.
+// Compressed files are stored with the codec's extension, like in local buckets.
+type s3Bucket struct {
+	bucketSettings
+
+	client *minio.Client
+	opts   S3Options
+	// timeout bounds every request to the store
+	timeout time.Duration
+}
+
+func (b *s3Bucket) key(name string) string {
+	return b.opts.Prefix + b.name + "/" + name
+}
+
+// stat returns the info of the object with the given key
+func (b *s3Bucket) stat(ctx context.Context, key string) (*s3ObjectInfo, error) {
+	obj, err := b.client.StatObject(ctx, b.opts.Bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		if isS3NotFound(err) {
+			return nil, kilonova.ErrNotExist
+		}
+		return nil, err
+	}
+	return newS3ObjectInfo(key[strings.LastIndexByte(key, '/')+1:], obj), nil
+}
+
+// removeObject removes the object. Deleting missing objects is not an error
+func (b *s3Bucket) removeObject(ctx context.Context, key string) error {
+	if err := b.client.RemoveObject(ctx, b.opts.Bucket, key, minio.RemoveObjectOptions{}); err != nil && !isS3NotFound(err) {
+		return err
+	}
+	return nil
+}
+
+func (b *s3Bucket) context() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), b.timeout)
+}
+
+// keys returns the possible keys of the file, with the one matching the bucket's compression setting first
+func (b *s3Bucket) keys(name string) []string {
+	keys := []string{b.key(name) + b.compression.Codec.Ext()}
+	for _, codec := range readCodecs {
+		if codec != b.compression.Codec {
+			keys = append(keys, b.key(name)+codec.Ext())
+		}
+	}
+	return keys
+}
+
+func (b *s3Bucket) Statistics(refresh bool) *BucketStats {
+	return statistics(&b.bucketSettings, b.FileList, refresh)
+}
+
+func (b *s3Bucket) Stat(name string) (fs.FileInfo, error) {
+	ctx, cancel := b.context()
+	defer cancel()
+	var err error
+	for _, key := range b.keys(name) {
+		var info *s3ObjectInfo
+		info, err = b.stat(ctx, key)
+		if err == nil {
+			return info, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return nil, err
+}
+
+func (b *s3Bucket) Reader(name string) (io.ReadCloser, error) {
+	// The timeout is not used for downloads, since big files may take a while
+	ctx := context.Background()
+	for _, key := range b.keys(name) {
+		obj, err := b.client.GetObject(ctx, b.opts.Bucket, key, minio.GetObjectOptions{})
+		if err != nil {
+			return nil, err
+		}
+		// The request is only sent on the first operation on the object
+		if _, err := obj.Stat(); err != nil {
+			obj.Close()
+			if isS3NotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		b.index.access(name, true)
+		return newDecompressReader(codecOf(key), obj)
+	}
+	b.index.access(name, false)
+	return nil, kilonova.ErrNotExist
+}
+
+func (b *s3Bucket) ReadSeeker(name string) (io.ReadSeekCloser, error) {
+	rc, err := b.Reader(name)
+	if err != nil {
+		return nil, err
+	}
+	return seekableReader(rc)
+}
+
+// WriteFile spools the (compressed) file on disk before uploading, such that the object is uploaded in a single request
+func (b *s3Bucket) WriteFile(name string, r io.Reader, _ fs.FileMode) error {
+	f, err := os.CreateTemp("", "bucket-upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	keys := b.keys(name)
+	zw, err := newCompressWriter(f, b.compression)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(zw, r)
+	if err1 := zw.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	if err != nil {
+		return err
+	}
+	size, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if _, err := b.client.PutObject(ctx, b.opts.Bucket, keys[0], f, size, minio.PutObjectOptions{}); err != nil {
+		return err
+	}
+	// Remove the files stored with the other codecs, so they don't shadow the new one
+	var errs []error
+	for _, key := range keys[1:] {
+		errs = append(errs, b.removeObject(ctx, key))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	b.written(name, size, b.RemoveFile)
+	return nil
+}
+
+func (b *s3Bucket) RemoveFile(name string) error {
+	ctx, cancel := b.context()
+	defer cancel()
+	var errs []error
+	for _, key := range b.keys(name) {
+		errs = append(errs, b.removeObject(ctx, key))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	b.index.remove(name)
+	return nil
+}
+
+func (b *s3Bucket) FileList() ([]fs.DirEntry, error) {
+	ctx, cancel := b.context()
+	defer cancel()
+	prefix := b.key("")
+	var entries []fs.DirEntry
+	for obj := range b.client.ListObjects(ctx, b.opts.Bucket, minio.ListObjectsOptions{Prefix: prefix}) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		// Objects in "subdirectories" don't belong to the bucket
+		name := strings.TrimPrefix(obj.Key, prefix)
+		if strings.Contains(name, "/") {
+			continue
+		}
+		entries = append(entries, fs.FileInfoToDirEntry(newS3ObjectInfo(name, obj)))
+	}
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int { return strings.Compare(a.Name(), b.Name()) })
+	return entries, nil
+}
+
+func (b *s3Bucket) RunEvictionPolicy(logger *slog.Logger) (int, error) {
+	return runEvictionPolicy(&b.bucketSettings, b.FileList, b.RemoveFile, logger)
+}
+
+func (b *s3Bucket) ResetCache() error {
+	return resetCache(b)
+}
+
+// NewS3Bucket creates a bucket stored in the given S3-compatible object store
+func NewS3Bucket(opts S3Options, name string, compression Compression, cache bool, persistent bool, maxSize int64, maxTTL time.Duration) (Bucket, error) {
+	client, err := newS3Client(opts)
+	if err != nil {
+		return nil, err
+	}
+	b := &s3Bucket{
+		bucketSettings: bucketSettings{
+			name:       name,
+			persistent: persistent,
+			cache:      cache,
+			maxSize:    maxSize,
+			maxTTL:     maxTTL,
+
+			compression: compression,
+		},
+		client:  client,
+		opts:    opts,
+		timeout: 30 * time.Second,
+	}
+	if err := b.index.load(b.FileList); err != nil {
+		zap.S().Warnf("Couldn't index bucket %q: %v", name, err)
+	}
+	return b, nil
+}
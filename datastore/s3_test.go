@@ -0,0 +1,296 @@
+package datastore
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 is an in-memory stand-in for an S3-compatible server, using path-style addressing
+type fakeS3 struct {
+	t      *testing.T
+	bucket string
+
+	mu      sync.Mutex
+	objects map[string][]byte
+	times   map[string]time.Time
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=access/") {
+		s.t.Errorf("Missing signature on %s %s", r.Method, r.URL)
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	key, ok := strings.CutPrefix(r.URL.Path, "/"+s.bucket+"/")
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case r.Method == http.MethodGet && key == "":
+		s.list(w, r)
+	case r.Method == http.MethodPut:
+		data, err := io.ReadAll(r.Body)
+		if err == nil && strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+			data, err = decodeAWSChunked(data)
+		}
+		if err != nil {
+			s.t.Errorf("Invalid payload for %q: %v", key, err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		s.objects[key] = data
+		s.times[key] = time.Now()
+		w.Header().Set("ETag", etag(data))
+	case r.Method == http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		data, ok := s.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			if r.Method == http.MethodGet {
+				io.WriteString(w, "<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>")
+			}
+			return
+		}
+		w.Header().Set("ETag", etag(data))
+		w.Header().Set("Last-Modified", s.times[key].UTC().Format(http.TimeFormat))
+		status := http.StatusOK
+		if start, ok := strings.CutPrefix(r.Header.Get("Range"), "bytes="); ok {
+			start, _, _ = strings.Cut(start, "-")
+			offset, _ := strconv.Atoi(start)
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, len(data)-1, len(data)))
+			data = data[min(offset, len(data)):]
+			status = http.StatusPartialContent
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.WriteHeader(status)
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func etag(data []byte) string {
+	hash := md5.Sum(data)
+	return `"` + hex.EncodeToString(hash[:]) + `"`
+}
+
+// decodeAWSChunked returns the payload of a body uploaded with a streaming signature,
+// which is made of chunks like "<hex size>;chunk-signature=<signature>\r\n<data>\r\n"
+func decodeAWSChunked(body []byte) ([]byte, error) {
+	var data []byte
+	for {
+		header, rest, ok := bytes.Cut(body, []byte("\r\n"))
+		if !ok {
+			return nil, errors.New("missing chunk header")
+		}
+		sizeHex, _, _ := bytes.Cut(header, []byte(";"))
+		size, err := strconv.ParseInt(string(sizeHex), 16, 64)
+		if err != nil || int64(len(rest)) < size {
+			return nil, fmt.Errorf("invalid chunk header %q", header)
+		}
+		if size == 0 {
+			return data, nil
+		}
+		data = append(data, rest[:size]...)
+		body = bytes.TrimPrefix(rest[size:], []byte("\r\n"))
+	}
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		LastModified time.Time `xml:"LastModified"`
+		ETag         string    `xml:"ETag"`
+		Size         int64     `xml:"Size"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// list returns at most 2 keys per page, to exercise pagination
+func (s *fakeS3) list(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+	var keys []string
+	for key := range s.objects {
+		if strings.HasPrefix(key, prefix) && key > r.URL.Query().Get("continuation-token") {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+
+	var result listBucketResult
+	if len(keys) > 2 {
+		keys = keys[:2]
+		result.IsTruncated = true
+		result.NextContinuationToken = keys[1]
+	}
+	for _, key := range keys {
+		result.Contents = append(result.Contents, struct {
+			Key          string    `xml:"Key"`
+			LastModified time.Time `xml:"LastModified"`
+			ETag         string    `xml:"ETag"`
+			Size         int64     `xml:"Size"`
+		}{key, s.times[key], etag(s.objects[key]), int64(len(s.objects[key]))})
+	}
+	xml.NewEncoder(w).Encode(result)
+}
+
+func newTestS3Bucket(t *testing.T, name string, compression Compression, maxSize int64) (Bucket, *fakeS3) {
+	fake := &fakeS3{t: t, bucket: "kilonova", objects: make(map[string][]byte), times: make(map[string]time.Time)}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	bucket, err := NewS3Bucket(S3Options{
+		Endpoint:  srv.URL,
+		Bucket:    "kilonova",
+		AccessKey: "access",
+		SecretKey: "secret",
+		PathStyle: true,
+		Prefix:    "data/",
+	}, name, compression, true, false, maxSize, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bucket, fake
+}
+
+func TestS3Bucket(t *testing.T) {
+	for _, level := range []Compression{NoCompression, DefaultCompression, {Codec: CodecGzip, Level: 9}} {
+		bucket, fake := newTestS3Bucket(t, "tests", level, 0)
+		data := bytes.Repeat([]byte("kilonova "), 1000)
+
+		for _, name := range []string{"1.in", "1.out", "2.in"} {
+			if err := bucket.WriteFile(name, bytes.NewReader(data), 0644); err != nil {
+				t.Fatalf("WriteFile(%q): %v", name, err)
+			}
+		}
+		if _, ok := fake.objects["data/tests/1.in"+level.Codec.Ext()]; !ok {
+			t.Errorf("Compression %s: object stored with the wrong extension", level)
+		}
+
+		r, err := bucket.Reader("1.in")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(r)
+		r.Close()
+		if err != nil || !bytes.Equal(got, data) {
+			t.Errorf("Reader returned different data (err: %v)", err)
+		}
+
+		rs, err := bucket.ReadSeeker("1.out")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := rs.Seek(9, io.SeekStart); err != nil {
+			t.Error(err)
+		}
+		got, _ = io.ReadAll(rs)
+		rs.Close()
+		if !bytes.Equal(got, data[9:]) {
+			t.Error("ReadSeeker returned different data")
+		}
+
+		if _, err := bucket.Stat("1.in"); err != nil {
+			t.Errorf("Stat: %v", err)
+		}
+		// Rewrites within the same second must still be noticed by the caches keyed on the file version
+		before, err := bucket.Stat("2.in")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := bucket.WriteFile("2.in", bytes.NewReader(data[1:]), 0644); err != nil {
+			t.Fatal(err)
+		}
+		after, err := bucket.Stat("2.in")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if FileVersion(before) == FileVersion(after) {
+			t.Errorf("File version didn't change after rewriting the file")
+		}
+		if _, err := bucket.Stat("3.in"); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("Stat of missing file should return fs.ErrNotExist, got %v", err)
+		}
+		if _, err := bucket.Reader("3.in"); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("Reader of missing file should return fs.ErrNotExist, got %v", err)
+		}
+
+		entries, err := bucket.FileList()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 3 {
+			t.Errorf("Expected 3 files, got %d", len(entries))
+		}
+
+		if err := bucket.RemoveFile("1.in"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := bucket.Stat("1.in"); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("File still exists after removal: %v", err)
+		}
+		if stats := bucket.Statistics(true); stats == nil || stats.NumItems != 2 {
+			t.Errorf("Unexpected statistics: %#v", stats)
+		}
+	}
+}
+
+func TestS3BucketEviction(t *testing.T) {
+	bucket, _ := newTestS3Bucket(t, "attachments", NoCompression, 2048)
+	write := func(name string) {
+		if err := bucket.WriteFile(name, bytes.NewReader(make([]byte, 1000)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("0")
+	write("1")
+	// Reading the first file makes the second one the least recently used
+	r, err := bucket.Reader("0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+	write("2")
+
+	for i, exists := range []bool{true, false, true} {
+		if _, err := bucket.Stat(strconv.Itoa(i)); (err == nil) != exists {
+			t.Errorf("File %d: expected existence %t, got error %v", i, exists, err)
+		}
+	}
+	if _, err := bucket.Reader("1"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Evicted file can still be read: %v", err)
+	}
+
+	stats := bucket.Statistics(false)
+	if stats.NumItems != 2 || stats.OnDiskSize != 2000 {
+		t.Errorf("Unexpected bucket size: %d files, %d bytes", stats.NumItems, stats.OnDiskSize)
+	}
+	if stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("Expected 1 hit and 1 miss, got %d and %d", stats.Hits, stats.Misses)
+	}
+	if numDeleted, err := bucket.RunEvictionPolicy(nil); err != nil || numDeleted != 0 {
+		t.Errorf("Eviction policy deleted %d files (err: %v), but the bucket was within its limits", numDeleted, err)
+	}
+}
@@ -0,0 +1,157 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/minio/minio-go/v7"
+)
+
+// StoredFile is a bucket file as it is stored, before decompression, such that it can be sent as is to clients accepting its codec
+type StoredFile struct {
+	io.ReadSeekCloser
+
+	Codec   Codec
+	ModTime time.Time
+	// Hash is the SHA-256 of the decompressed contents. It's only known for the files of content-addressed buckets
+	Hash string
+	// Version changes whenever the stored file is rewritten, see FileVersion. It identifies the contents when Hash is unknown
+	Version string
+}
+
+// storedOpener is implemented by the buckets that can open their files without decompressing them
+type storedOpener interface {
+	openStored(name string) (io.ReadSeekCloser, Codec, fs.FileInfo, error)
+}
+
+// OpenStored opens the file without decompressing it. The file must be closed, unless Decompressed is called
+func OpenStored(b Bucket, name string) (*StoredFile, error) {
+	key := name
+	var ref *BlobRef
+	if cb, ok := b.(*contentBucket); ok {
+		var err error
+		ref, err = cb.ref(name)
+		if err != nil {
+			return nil, err
+		}
+		if ref != nil {
+			key = ref.Hash
+		}
+	}
+	opener, ok := rawBucket(b).(storedOpener)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	rsc, codec, info, err := opener.openStored(key)
+	if err != nil {
+		return nil, err
+	}
+	f := &StoredFile{ReadSeekCloser: rsc, Codec: codec, ModTime: info.ModTime(), Version: FileVersion(info)}
+	if ref != nil {
+		// Blobs may be older than the files pointing to them
+		f.Hash, f.ModTime = ref.Hash, ref.UpdatedAt
+	}
+	return f, nil
+}
+
+// Decompressed returns the seekable decompressed contents. Compressed files are only decompressed on the first read or seek,
+// so requests answered from the headers alone don't pay for it. The returned reader takes ownership of the stored file
+func (f *StoredFile) Decompressed() io.ReadSeekCloser {
+	if f.Codec == CodecNone {
+		return f.ReadSeekCloser
+	}
+	return &lazyDecompressed{stored: f}
+}
+
+type lazyDecompressed struct {
+	stored *StoredFile
+	rsc    io.ReadSeekCloser
+	err    error
+}
+
+func (l *lazyDecompressed) open() error {
+	if l.rsc != nil || l.err != nil {
+		return l.err
+	}
+	rc, err := newDecompressReader(l.stored.Codec, l.stored.ReadSeekCloser)
+	if err != nil {
+		l.err = err
+		return err
+	}
+	l.rsc, l.err = seekableReader(rc)
+	return l.err
+}
+
+func (l *lazyDecompressed) Read(p []byte) (int, error) {
+	if err := l.open(); err != nil {
+		return 0, err
+	}
+	return l.rsc.Read(p)
+}
+
+func (l *lazyDecompressed) Seek(offset int64, whence int) (int64, error) {
+	if err := l.open(); err != nil {
+		return 0, err
+	}
+	return l.rsc.Seek(offset, whence)
+}
+
+func (l *lazyDecompressed) Close() error {
+	if l.rsc != nil {
+		return l.rsc.Close()
+	}
+	if l.err != nil {
+		// The stored file was already closed while failing to decompress it
+		return nil
+	}
+	return l.stored.Close()
+}
+
+func (b *localBucket) openStored(name string) (io.ReadSeekCloser, Codec, fs.FileInfo, error) {
+	for _, codec := range readCodecs {
+		f, err := os.Open(b.filePath(name) + codec.Ext())
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, CodecNone, nil, err
+		}
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, CodecNone, nil, err
+		}
+		b.index.access(name, true)
+		return f, codec, info, nil
+	}
+	b.index.access(name, false)
+	return nil, CodecNone, nil, kilonova.ErrNotExist
+}
+
+// openStored returns the object as is. Objects are downloaded lazily and seeked with range requests,
+// so requests answered from the headers alone only cost a HEAD request
+func (b *s3Bucket) openStored(name string) (io.ReadSeekCloser, Codec, fs.FileInfo, error) {
+	ctx := context.Background()
+	for _, key := range b.keys(name) {
+		info, err := b.stat(ctx, key)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, CodecNone, nil, err
+		}
+		obj, err := b.client.GetObject(ctx, b.opts.Bucket, key, minio.GetObjectOptions{})
+		if err != nil {
+			return nil, CodecNone, nil, err
+		}
+		b.index.access(name, true)
+		return obj, codecOf(key), info, nil
+	}
+	b.index.access(name, false)
+	return nil, CodecNone, nil, kilonova.ErrNotExist
+}
@@ -69,7 +69,7 @@ func (a *DB) CreateBlogPostAttachment(ctx context.Context, att *kilonova.Attachm
 	return nil
 }
 
-const selectedAttFields = "id, created_at, last_updated_at, last_updated_by, visible, private, execable, name, data_size" // Make sure to keep this in sync
+const selectedAttFields = "id, created_at, last_updated_at, last_updated_by, visible, private, execable, name, data_size, data_hash" // Make sure to keep this in sync
 
 func (a *DB) Attachment(ctx context.Context, filter *kilonova.AttachmentFilter) (*kilonova.Attachment, error) {
 	if filter == nil {
@@ -192,6 +192,7 @@ type dbAttachment struct {
 
 	Name string `db:"name"`
 	Size int    `db:"data_size"`
+	Hash string `db:"data_hash"`
 	//Data []byte `db:"data"`
 }
 
@@ -211,5 +212,12 @@ func internalToAttachment(att *dbAttachment) *kilonova.Attachment {
 
 		Name: att.Name,
 		Size: att.Size,
+		Hash: att.Hash,
 	}
 }
+
+// ExistingAttachmentIDs returns which of the given attachment IDs still exist
+func (a *DB) ExistingAttachmentIDs(ctx context.Context, ids []int) ([]int, error) {
+	rows, _ := a.conn.Query(ctx, "SELECT id FROM attachments WHERE id = ANY($1)", ids)
+	return pgx.CollectRows(rows, pgx.RowTo[int])
+}
@@ -0,0 +1,61 @@
+package db
+
+import (
+	"context"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/datastore"
+	"github.com/jackc/pgx/v5"
+)
+
+// Snapshot is a read-only transaction whose snapshot is exported, such that pg_dump sees the same data as the backup
+type Snapshot struct {
+	tx pgx.Tx
+	ID string
+}
+
+func (s *DB) ExportSnapshot(ctx context.Context) (*Snapshot, error) {
+	tx, err := s.conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, err
+	}
+	snap := &Snapshot{tx: tx}
+	if err := tx.QueryRow(ctx, "SELECT pg_export_snapshot()").Scan(&snap.ID); err != nil {
+		tx.Rollback(ctx)
+		return nil, err
+	}
+	return snap, nil
+}
+
+func (s *Snapshot) SchemaVersion(ctx context.Context) (int, error) {
+	var version int
+	err := s.tx.QueryRow(ctx, "SELECT version FROM kn_schema_version").Scan(&version)
+	return version, err
+}
+
+func (s *Snapshot) Tests(ctx context.Context) ([]*kilonova.Test, error) {
+	rows, _ := s.tx.Query(ctx, "SELECT * FROM tests ORDER BY id")
+	return pgx.CollectRows(rows, pgx.RowToAddrOfStructByNameLax[kilonova.Test])
+}
+
+func (s *Snapshot) JudgingHacks(ctx context.Context) ([]*kilonova.Hack, error) {
+	rows, _ := s.tx.Query(ctx, judgingHacksQuery)
+	return collectHacks(rows)
+}
+
+func (s *Snapshot) BlobRefs(ctx context.Context, bucket string) ([]*datastore.BlobRef, error) {
+	rows, _ := s.tx.Query(ctx, "SELECT name, hash, updated_at FROM bucket_blob_refs WHERE bucket = $1 ORDER BY name", bucket)
+	return pgx.CollectRows(rows, pgx.RowToAddrOfStructByNameLax[datastore.BlobRef])
+}
+
+// Close ends the transaction. The snapshot can't be used by other sessions afterwards
+func (s *Snapshot) Close(ctx context.Context) error {
+	return s.tx.Rollback(ctx)
+}
+
+// IsEmpty returns whether the database has no tables, such that a backup can be restored into it
+func (s *DB) IsEmpty(ctx context.Context) (bool, error) {
+	var hasTables bool
+	err := s.conn.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_schema = 'public')").Scan(&hasTables)
+	return !hasTables, err
+}
@@ -0,0 +1,64 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/KiloProjects/kilonova/datastore"
+	"github.com/jackc/pgx/v5"
+)
+
+var _ datastore.BlobIndex = &DB{}
+
+func (s *DB) BlobRef(ctx context.Context, bucket string, name string) (*datastore.BlobRef, error) {
+	var ref datastore.BlobRef
+	err := Get(s.conn, ctx, &ref, "SELECT name, hash, updated_at FROM bucket_blob_refs WHERE bucket = $1 AND name = $2", bucket, name)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ref, nil
+}
+
+func (s *DB) SetBlobRef(ctx context.Context, bucket string, name string, hash string) error {
+	_, err := s.conn.Exec(ctx, `INSERT INTO bucket_blob_refs (bucket, name, hash) VALUES ($1, $2, $3) 
+		ON CONFLICT (bucket, name) DO UPDATE SET hash = EXCLUDED.hash, updated_at = NOW()`, bucket, name, hash)
+	return err
+}
+
+func (s *DB) RemoveBlobRef(ctx context.Context, bucket string, name string) error {
+	_, err := s.conn.Exec(ctx, "DELETE FROM bucket_blob_refs WHERE bucket = $1 AND name = $2", bucket, name)
+	return err
+}
+
+func (s *DB) BlobRefs(ctx context.Context, bucket string) ([]*datastore.BlobRef, error) {
+	var refs []*datastore.BlobRef
+	err := Select(s.conn, ctx, &refs, "SELECT name, hash, updated_at FROM bucket_blob_refs WHERE bucket = $1 ORDER BY name", bucket)
+	if errors.Is(err, pgx.ErrNoRows) || len(refs) == 0 {
+		return []*datastore.BlobRef{}, nil
+	}
+	return refs, err
+}
+
+// BlobReferenced returns whether any file of the bucket uses the blob with the given hash
+func (s *DB) BlobReferenced(ctx context.Context, bucket string, hash string) (bool, error) {
+	var ok bool
+	err := s.conn.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM bucket_blob_refs WHERE bucket = $1 AND hash = $2)", bucket, hash).Scan(&ok)
+	return ok, err
+}
+
+// StartBucketRecompression records that the bucket is being converted to the given compression and returns when the conversion started.
+// If the last recompression used the same compression, its start time is kept, so an interrupted recompression can be continued
+func (s *DB) StartBucketRecompression(ctx context.Context, bucket string, compression string) (time.Time, error) {
+	if _, err := s.conn.Exec(ctx, `INSERT INTO bucket_recompressions (bucket, compression) VALUES ($1, $2)
+		ON CONFLICT (bucket) DO UPDATE SET compression = EXCLUDED.compression, started_at = NOW()
+		WHERE bucket_recompressions.compression <> EXCLUDED.compression`, bucket, compression); err != nil {
+		return time.Time{}, err
+	}
+	var startedAt time.Time
+	err := s.conn.QueryRow(ctx, "SELECT started_at FROM bucket_recompressions WHERE bucket = $1", bucket).Scan(&startedAt)
+	return startedAt, err
+}
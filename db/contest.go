@@ -42,6 +42,14 @@ type dbContest struct {
 	SubmissionCooldown int `db:"submission_cooldown_ms"`
 	QuestionCooldown   int `db:"question_cooldown_ms"`
 
+	AllowCustomRuns bool `db:"allow_custom_runs"`
+
+	SystemTesting  *kilonova.SystemTestMode `db:"system_testing"`
+	SystemTestedAt *time.Time               `db:"system_tested_at"`
+
+	AllowHacking bool            `db:"allow_hacking"`
+	HackScore    decimal.Decimal `db:"hack_score"`
+
 	Type kilonova.ContestType `db:"type"`
 }
 
@@ -184,6 +192,59 @@ func (s *DB) DeleteContest(ctx context.Context, id int) error {
 	return err
 }
 
+// PendingSystemTests returns the IDs of the ended contests that still need system testing.
+// Contests with submissions that are still being judged are skipped until the queue settles.
+func (s *DB) PendingSystemTests(ctx context.Context) ([]int, error) {
+	rows, _ := s.conn.Query(ctx, `
+		SELECT id FROM contests
+		WHERE system_testing IS NOT NULL AND system_tested_at IS NULL AND end_time < NOW()
+			AND NOT EXISTS (SELECT 1 FROM submissions WHERE contest_id = contests.id AND status IN ('creating', 'waiting', 'working'))
+		ORDER BY end_time ASC`)
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return []int{}, nil
+	}
+	return ids, err
+}
+
+// StartSystemTesting marks the contest as system tested and queues the selected pretest-only submissions for reevaluation.
+// It returns the number of queued submissions, or -1 if the contest was already system tested.
+func (s *DB) StartSystemTesting(ctx context.Context, contestID int, mode kilonova.SystemTestMode) (int, error) {
+	var ordering string
+	switch mode {
+	case kilonova.SystemTestFinal:
+		ordering = "created_at DESC"
+	case kilonova.SystemTestBest:
+		ordering = "score DESC, created_at ASC"
+	default:
+		return -1, kilonova.ErrUnknownError
+	}
+
+	var numSubs = -1
+	err := pgx.BeginFunc(ctx, s.conn, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, "UPDATE contests SET system_tested_at = NOW() WHERE id = $1 AND system_tested_at IS NULL", contestID)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return nil
+		}
+
+		tag, err = tx.Exec(ctx, `
+			UPDATE submissions SET status = 'reevaling' WHERE id IN (
+				SELECT DISTINCT ON (user_id, problem_id) id FROM submissions
+				WHERE contest_id = $1 AND pretests_only AND status = 'finished' AND compile_error IS NOT TRUE
+				ORDER BY user_id, problem_id, `+ordering+`
+			)`, contestID)
+		if err != nil {
+			return err
+		}
+		numSubs = int(tag.RowsAffected())
+		return nil
+	})
+	return numSubs, err
+}
+
 // Contest leaderboard
 
 type databaseClassicEntry struct {
@@ -191,6 +252,7 @@ type databaseClassicEntry struct {
 	ContestID int             `db:"contest_id"`
 	Total     decimal.Decimal `db:"total_score"`
 	LastTime  *time.Time      `db:"last_time"`
+	NumHacks  int             `db:"num_hacks"`
 
 	FreezeTime *time.Time `db:"freeze_time"`
 }
@@ -223,6 +285,7 @@ func (s *DB) classicToLeaderboardEntry(ctx context.Context, entry *databaseClass
 		User:          user.ToBrief(),
 		TotalScore:    entry.Total,
 		ProblemScores: scores,
+		NumHacks:      entry.NumHacks,
 
 		ProblemAttempts: make(map[int]int),
 		Penalty:         0,
@@ -435,6 +498,22 @@ func contestUpdateQuery(upd *kilonova.ContestUpdate, ub *updateBuilder) {
 	if v := upd.MaxSubs; v != nil {
 		ub.AddUpdate("max_sub_count = %s", v)
 	}
+	if v := upd.AllowCustomRuns; v != nil {
+		ub.AddUpdate("allow_custom_runs = %s", v)
+	}
+	if v := upd.SystemTesting; v != nil {
+		if *v == kilonova.SystemTestNone {
+			ub.AddUpdate("system_testing = NULL")
+		} else {
+			ub.AddUpdate("system_testing = %s", *v)
+		}
+	}
+	if v := upd.AllowHacking; v != nil {
+		ub.AddUpdate("allow_hacking = %s", v)
+	}
+	if v := upd.HackScore; v != nil {
+		ub.AddUpdate("hack_score = %s", v)
+	}
 	if v := upd.PublicLeaderboard; v != nil {
 		ub.AddUpdate("public_leaderboard = %s", v)
 	}
@@ -483,6 +562,10 @@ func getContestOrdering(ordering string, ascending bool) string {
 }
 
 func (s *DB) internalToContest(ctx context.Context, contest *dbContest) (*kilonova.Contest, error) {
+	systemTesting := kilonova.SystemTestNone
+	if contest.SystemTesting != nil {
+		systemTesting = *contest.SystemTesting
+	}
 
 	editors, err := s.contestEditors(ctx, contest.ID)
 	if err != nil {
@@ -511,6 +594,14 @@ func (s *DB) internalToContest(ctx context.Context, contest *dbContest) (*kilono
 		EndTime:    contest.EndTime,
 		MaxSubs:    contest.MaxSubCount,
 
+		AllowCustomRuns: contest.AllowCustomRuns,
+
+		SystemTesting:  systemTesting,
+		SystemTestedAt: contest.SystemTestedAt,
+
+		AllowHacking: contest.AllowHacking,
+		HackScore:    contest.HackScore,
+
 		Description: contest.Desc,
 
 		PerUserTime: contest.PerUserTime,
@@ -0,0 +1,40 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/jackc/pgx/v5"
+)
+
+type graderHost struct {
+	Hostname     string    `db:"hostname"`
+	SpeedFactor  float64   `db:"speed_factor"`
+	CalibratedAt time.Time `db:"calibrated_at"`
+}
+
+func (s *DB) UpdateGraderHost(ctx context.Context, hostname string, speedFactor float64) error {
+	_, err := s.conn.Exec(ctx, `
+		INSERT INTO grader_hosts (hostname, speed_factor, calibrated_at) VALUES ($1, $2, NOW())
+			ON CONFLICT (hostname) DO UPDATE SET speed_factor = EXCLUDED.speed_factor, calibrated_at = EXCLUDED.calibrated_at
+	`, hostname, speedFactor)
+	return err
+}
+
+func (s *DB) GraderHosts(ctx context.Context) ([]*kilonova.GraderHost, error) {
+	rows, _ := s.conn.Query(ctx, "SELECT * FROM grader_hosts ORDER BY calibrated_at DESC")
+	hosts, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[graderHost])
+	if err != nil {
+		return nil, err
+	}
+	return mapper(hosts, internalToGraderHost), nil
+}
+
+func internalToGraderHost(host *graderHost) *kilonova.GraderHost {
+	return &kilonova.GraderHost{
+		Hostname:     host.Hostname,
+		SpeedFactor:  host.SpeedFactor,
+		CalibratedAt: host.CalibratedAt,
+	}
+}
@@ -0,0 +1,183 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/jackc/pgx/v5"
+)
+
+type dbHack struct {
+	ID           int       `db:"id"`
+	CreatedAt    time.Time `db:"created_at"`
+	ContestID    int       `db:"contest_id"`
+	ProblemID    int       `db:"problem_id"`
+	SubmissionID int       `db:"submission_id"`
+	HackerID     int       `db:"hacker_id"`
+	TargetID     int       `db:"target_id"`
+
+	Status  kilonova.HackStatus `db:"status"`
+	Verdict string              `db:"verdict"`
+
+	TestID *int `db:"test_id"`
+}
+
+func (s *DB) CreateHack(ctx context.Context, contestID, problemID, submissionID, hackerID, targetID int) (int, error) {
+	var id int
+	err := s.conn.QueryRow(ctx, `INSERT INTO hacks (contest_id, problem_id, submission_id, hacker_id, target_id) VALUES ($1, $2, $3, $4, $5) RETURNING id`, contestID, problemID, submissionID, hackerID, targetID).Scan(&id)
+	if err != nil {
+		return -1, err
+	}
+	return id, nil
+}
+
+func (s *DB) Hacks(ctx context.Context, filter kilonova.HackFilter) ([]*kilonova.Hack, error) {
+	fb := newFilterBuilder()
+	hackFilterQuery(&filter, fb)
+
+	rows, _ := s.conn.Query(
+		ctx,
+		"SELECT * FROM hacks WHERE "+fb.Where()+" ORDER BY created_at DESC "+FormatLimitOffset(filter.Limit, filter.Offset),
+		fb.Args()...,
+	)
+	hacks, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[dbHack])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return []*kilonova.Hack{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return mapper(hacks, internalToHack), nil
+}
+
+func (s *DB) Hack(ctx context.Context, id int) (*kilonova.Hack, error) {
+	return toSingular(ctx, kilonova.HackFilter{ID: &id, Limit: 1}, s.Hacks)
+}
+
+func (s *DB) UpdateHack(ctx context.Context, id int, upd kilonova.HackUpdate) error {
+	ub := newUpdateBuilder()
+	if v := upd.Status; v != kilonova.HackStatusNone {
+		ub.AddUpdate("status = %s", v)
+	}
+	if v := upd.Verdict; v != nil {
+		ub.AddUpdate("verdict = %s", v)
+	}
+	if v := upd.TestID; v != nil {
+		ub.AddUpdate("test_id = %s", v)
+	}
+	if ub.CheckUpdates() != nil {
+		return ub.CheckUpdates()
+	}
+	fb := ub.MakeFilter()
+	fb.AddConstraint("id = %s", id)
+	_, err := s.conn.Exec(ctx, "UPDATE hacks SET "+fb.WithUpdate(), fb.Args()...)
+	return err
+}
+
+// ClaimPendingHacks atomically marks at most `limit` pending hacks as running and returns them
+func (s *DB) ClaimPendingHacks(ctx context.Context, limit int) ([]*kilonova.Hack, error) {
+	rows, _ := s.conn.Query(ctx, `
+		UPDATE hacks SET status = 'running' WHERE id IN (
+			SELECT id FROM hacks WHERE status = 'pending' ORDER BY created_at ASC LIMIT $1 FOR UPDATE SKIP LOCKED
+		) RETURNING *`, limit)
+	hacks, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[dbHack])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return []*kilonova.Hack{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return mapper(hacks, internalToHack), nil
+}
+
+// ResetRunningHacks puts back in the queue the hacks that were interrupted (ie. by a restart)
+func (s *DB) ResetRunningHacks(ctx context.Context) error {
+	_, err := s.conn.Exec(ctx, "UPDATE hacks SET status = 'pending' WHERE status = 'running'")
+	return err
+}
+
+// judgingHacksQuery selects the hacks that are not judged yet, whose data is still stored in the tests bucket
+const judgingHacksQuery = "SELECT * FROM hacks WHERE status = 'pending' OR status = 'running' ORDER BY id"
+
+// JudgingHacks returns the pending and running hacks, used for checking the test data in the bucket
+func (s *DB) JudgingHacks(ctx context.Context) ([]*kilonova.Hack, error) {
+	rows, _ := s.conn.Query(ctx, judgingHacksQuery)
+	return collectHacks(rows)
+}
+
+func collectHacks(rows pgx.Rows) ([]*kilonova.Hack, error) {
+	hacks, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[dbHack])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return []*kilonova.Hack{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return mapper(hacks, internalToHack), nil
+}
+
+func (s *DB) LockContestProblem(ctx context.Context, contestID, userID, problemID int) error {
+	_, err := s.conn.Exec(ctx, "INSERT INTO contest_problem_locks (contest_id, user_id, problem_id) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING", contestID, userID, problemID)
+	return err
+}
+
+func (s *DB) ContestProblemLocked(ctx context.Context, contestID, userID, problemID int) (bool, error) {
+	var locked bool
+	err := s.conn.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM contest_problem_locks WHERE contest_id = $1 AND user_id = $2 AND problem_id = $3)", contestID, userID, problemID).Scan(&locked)
+	return locked, err
+}
+
+func (s *DB) ContestLockedProblems(ctx context.Context, contestID, userID int) ([]int, error) {
+	rows, _ := s.conn.Query(ctx, "SELECT problem_id FROM contest_problem_locks WHERE contest_id = $1 AND user_id = $2", contestID, userID)
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return []int{}, nil
+	}
+	return ids, err
+}
+
+func hackFilterQuery(filter *kilonova.HackFilter, fb *filterBuilder) {
+	if v := filter.ID; v != nil {
+		fb.AddConstraint("id = %s", v)
+	}
+	if v := filter.ContestID; v != nil {
+		fb.AddConstraint("contest_id = %s", v)
+	}
+	if v := filter.ProblemID; v != nil {
+		fb.AddConstraint("problem_id = %s", v)
+	}
+	if v := filter.SubmissionID; v != nil {
+		fb.AddConstraint("submission_id = %s", v)
+	}
+	if v := filter.HackerID; v != nil {
+		fb.AddConstraint("hacker_id = %s", v)
+	}
+	if v := filter.TargetID; v != nil {
+		fb.AddConstraint("target_id = %s", v)
+	}
+	if v := filter.InvolvedUserID; v != nil {
+		fb.AddConstraint("(hacker_id = %s OR target_id = %s)", v, v)
+	}
+	if v := filter.Status; v != kilonova.HackStatusNone {
+		fb.AddConstraint("status = %s", v)
+	}
+}
+
+func internalToHack(hack *dbHack) *kilonova.Hack {
+	return &kilonova.Hack{
+		ID:           hack.ID,
+		CreatedAt:    hack.CreatedAt,
+		ContestID:    hack.ContestID,
+		ProblemID:    hack.ProblemID,
+		SubmissionID: hack.SubmissionID,
+		HackerID:     hack.HackerID,
+		TargetID:     hack.TargetID,
+
+		Status:  hack.Status,
+		Verdict: hack.Verdict,
+
+		TestID: hack.TestID,
+	}
+}
@@ -38,6 +38,86 @@ var migrations = []migration{
 		name:    "Discord Avatar as main",
 		handler: runFile("003.use_discord_avatar.sql"),
 	},
+	{
+		id:      4,
+		name:    "Subtask aggregation",
+		handler: runFile("004.subtask_aggregation.sql"),
+	},
+	{
+		id:      5,
+		name:    "Relative scoring",
+		handler: runFile("005.relative_scoring.sql"),
+	},
+	{
+		id:      6,
+		name:    "Custom runs",
+		handler: runFile("006.custom_runs.sql"),
+	},
+	{
+		id:      7,
+		name:    "Pretests",
+		handler: runFile("007.pretests.sql"),
+	},
+	{
+		id:      8,
+		name:    "Hacks",
+		handler: runFile("008.hacks.sql"),
+	},
+	{
+		id:      9,
+		name:    "Submission files",
+		handler: runFile("009.submission_files.sql"),
+	},
+	{
+		id:      10,
+		name:    "Grader calibration",
+		handler: runFile("010.grader_calibration.sql"),
+	},
+	{
+		id:      11,
+		name:    "Submission cancellation",
+		handler: runFile("011.submission_cancellation.sql"),
+	},
+	{
+		id:      12,
+		name:    "Compile options",
+		handler: runFile("012.compile_options.sql"),
+	},
+	{
+		id:      13,
+		name:    "Test limits",
+		handler: runFile("013.test_limits.sql"),
+	},
+	{
+		id:      14,
+		name:    "Submission toolchain",
+		handler: runFile("014.submission_toolchain.sql"),
+	},
+	{
+		id:      15,
+		name:    "Selective reevaluation",
+		handler: runFile("015.selective_reevaluation.sql"),
+	},
+	{
+		id:      16,
+		name:    "Content-addressed storage",
+		handler: runFile("016.content_addressed_storage.sql"),
+	},
+	{
+		id:      17,
+		name:    "Bucket recompressions",
+		handler: runFile("017.bucket_recompressions.sql"),
+	},
+	{
+		id:      18,
+		name:    "Attachment hash",
+		handler: runFile("018.attachment_hash.sql"),
+	},
+	{
+		id:      19,
+		name:    "Expected verdicts",
+		handler: runFile("019.expected_verdicts.sql"),
+	},
 }
 
 var specialMigrations = []migration{
@@ -48,6 +128,11 @@ var specialMigrations = []migration{
 	},
 }
 
+// LatestSchemaVersion returns the version of the database schema after all migrations are run
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].id
+}
+
 func (s *DB) RunMigrations(ctx context.Context) error {
 	if err := s.checkLegacy(ctx); err != nil {
 		return err
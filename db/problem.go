@@ -31,6 +31,13 @@ type dbProblem struct {
 	MemoryLimit int     `db:"memory_limit"`
 	SourceSize  int     `db:"source_size"`
 
+	TimeLimitFactor *float64 `db:"time_limit_factor"`
+
+	StackLimit          int    `db:"stack_limit"`
+	CompileFlags        string `db:"compile_flags"`
+	GraderCompileFlags  string `db:"grader_compile_flags"`
+	CheckerCompileFlags string `db:"checker_compile_flags"`
+
 	SourceCredits string `db:"source_credits"`
 
 	ScoreScale decimal.Decimal `db:"leaderboard_score_scale"`
@@ -40,6 +47,9 @@ type dbProblem struct {
 	DigitPrecision int32 `db:"digit_precision"`
 
 	ScoringStrategy kilonova.ScoringType `db:"scoring_strategy"`
+
+	ObjectiveType     *kilonova.ObjectiveType `db:"objective_type"`
+	ObjectiveExponent decimal.Decimal         `db:"objective_exponent"`
 }
 
 type dbScoredProblem struct {
@@ -133,9 +143,9 @@ ORDER BY cpbs.position ASC`, contestID, userID, freezeTime)
 }
 
 const problemCreateQuery = `INSERT INTO problems (
-	name, console_input, test_name, memory_limit, source_size, time_limit, visible, source_credits, default_points
+	name, console_input, test_name, memory_limit, source_size, time_limit, visible, source_credits, default_points, time_limit_factor, stack_limit, compile_flags, grader_compile_flags, checker_compile_flags
 ) VALUES (
-	$1, $2, $3, $4, $5, $6, $7, $8, $9
+	$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14
 ) RETURNING id;`
 
 func (s *DB) CreateProblem(ctx context.Context, p *kilonova.Problem, authorID int) error {
@@ -155,7 +165,7 @@ func (s *DB) CreateProblem(ctx context.Context, p *kilonova.Problem, authorID in
 		p.SourceSize = kilonova.DefaultSourceSize.Value()
 	}
 	var id int
-	err := s.conn.QueryRow(ctx, problemCreateQuery, p.Name, p.ConsoleInput, p.TestName, p.MemoryLimit, p.SourceSize, p.TimeLimit, p.Visible, p.SourceCredits, p.DefaultPoints).Scan(&id)
+	err := s.conn.QueryRow(ctx, problemCreateQuery, p.Name, p.ConsoleInput, p.TestName, p.MemoryLimit, p.SourceSize, p.TimeLimit, p.Visible, p.SourceCredits, p.DefaultPoints, p.TimeLimitFactor, p.StackLimit, p.CompileFlags, p.GraderCompileFlags, p.CheckerCompileFlags).Scan(&id)
 	if err == nil {
 		p.ID = id
 	}
@@ -277,9 +287,24 @@ func problemUpdateQuery(upd *kilonova.ProblemUpdate, ub *updateBuilder) {
 	if v := upd.TimeLimit; v != nil && !math.IsNaN(*v) {
 		ub.AddUpdate("time_limit = %s", v)
 	}
+	if v := upd.TimeLimitFactor; v != nil {
+		ub.AddUpdate("time_limit_factor = %s", v)
+	}
 	if v := upd.MemoryLimit; v != nil {
 		ub.AddUpdate("memory_limit = %s", v)
 	}
+	if v := upd.StackLimit; v != nil {
+		ub.AddUpdate("stack_limit = %s", v)
+	}
+	if v := upd.CompileFlags; v != nil {
+		ub.AddUpdate("compile_flags = %s", strings.TrimSpace(*v))
+	}
+	if v := upd.GraderCompileFlags; v != nil {
+		ub.AddUpdate("grader_compile_flags = %s", strings.TrimSpace(*v))
+	}
+	if v := upd.CheckerCompileFlags; v != nil {
+		ub.AddUpdate("checker_compile_flags = %s", strings.TrimSpace(*v))
+	}
 
 	if v := upd.DefaultPoints; v != nil {
 		ub.AddUpdate("default_points = %s", v)
@@ -316,6 +341,16 @@ func problemUpdateQuery(upd *kilonova.ProblemUpdate, ub *updateBuilder) {
 	if v := upd.ScorePrecision; v != nil {
 		ub.AddUpdate("digit_precision = %s", v)
 	}
+	if v := upd.ObjectiveType; v != nil {
+		if *v == kilonova.ObjectiveNone {
+			ub.AddUpdate("objective_type = NULL")
+		} else {
+			ub.AddUpdate("objective_type = %s", *v)
+		}
+	}
+	if v := upd.ObjectiveExponent; v != nil {
+		ub.AddUpdate("objective_exponent = %s", v)
+	}
 }
 
 // Access rights
@@ -345,6 +380,11 @@ func (s *DB) internalToProblem(pb *dbProblem) *kilonova.Problem {
 		return nil
 	}
 
+	objectiveType := kilonova.ObjectiveNone
+	if pb.ObjectiveType != nil {
+		objectiveType = *pb.ObjectiveType
+	}
+
 	return &kilonova.Problem{
 		ID:        pb.ID,
 		CreatedAt: pb.CreatedAt,
@@ -360,6 +400,13 @@ func (s *DB) internalToProblem(pb *dbProblem) *kilonova.Problem {
 		MemoryLimit: pb.MemoryLimit,
 		SourceSize:  pb.SourceSize,
 
+		TimeLimitFactor: pb.TimeLimitFactor,
+
+		StackLimit:          pb.StackLimit,
+		CompileFlags:        pb.CompileFlags,
+		GraderCompileFlags:  pb.GraderCompileFlags,
+		CheckerCompileFlags: pb.CheckerCompileFlags,
+
 		SourceCredits: pb.SourceCredits,
 
 		ScoreScale: pb.ScoreScale,
@@ -369,6 +416,9 @@ func (s *DB) internalToProblem(pb *dbProblem) *kilonova.Problem {
 
 		PublishedAt:     pb.PublishedAt,
 		ScoringStrategy: pb.ScoringStrategy,
+
+		ObjectiveType:     objectiveType,
+		ObjectiveExponent: pb.ObjectiveExponent,
 	}
 }
 
@@ -0,0 +1,13 @@
+CREATE TYPE subtask_aggregation AS enum (
+    'min',
+    'sum',
+    'average',
+    'threshold'
+);
+
+ALTER TABLE subtasks ADD COLUMN aggregation subtask_aggregation NOT NULL DEFAULT 'min';
+ALTER TABLE subtasks ADD COLUMN threshold numeric NOT NULL DEFAULT 100;
+
+-- Copied from subtask, like the score
+ALTER TABLE submission_subtasks ADD COLUMN aggregation subtask_aggregation NOT NULL DEFAULT 'min';
+ALTER TABLE submission_subtasks ADD COLUMN threshold numeric NOT NULL DEFAULT 100;
@@ -0,0 +1,14 @@
+CREATE TYPE objective_type AS enum (
+    'maximize',
+    'minimize'
+);
+
+-- NULL objective_type means that the problem is not an optimization problem
+ALTER TABLE problems ADD COLUMN objective_type objective_type;
+ALTER TABLE problems ADD COLUMN objective_exponent numeric NOT NULL DEFAULT 1;
+
+-- Best known objective value for every test, NULL if no value was reported yet
+ALTER TABLE tests ADD COLUMN best_objective numeric;
+
+-- Raw objective value reported by the checker, kept for rescoring when the best value improves
+ALTER TABLE submission_tests ADD COLUMN objective numeric;
@@ -0,0 +1,2 @@
+-- Contest organizers may disable custom runs (running code on custom input) while the contest is running
+ALTER TABLE contests ADD COLUMN allow_custom_runs boolean NOT NULL DEFAULT true;
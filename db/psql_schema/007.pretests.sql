@@ -0,0 +1,13 @@
+CREATE TYPE system_test_mode AS enum (
+    'final',
+    'best'
+);
+
+-- Pretests are the only tests judged while a contest with system testing is running
+ALTER TABLE tests ADD COLUMN pretest boolean NOT NULL DEFAULT false;
+
+-- NULL system_testing means that the contest has no pretests phase
+ALTER TABLE contests ADD COLUMN system_testing system_test_mode;
+ALTER TABLE contests ADD COLUMN system_tested_at timestamptz;
+
+ALTER TABLE submissions ADD COLUMN pretests_only boolean NOT NULL DEFAULT false;
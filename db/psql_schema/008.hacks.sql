@@ -0,0 +1,39 @@
+CREATE TYPE hack_status AS enum (
+    'pending',
+    'running',
+    'successful',
+    'unsuccessful',
+    'invalid'
+);
+
+ALTER TABLE contests ADD COLUMN allow_hacking boolean NOT NULL DEFAULT false;
+-- Points added to the leaderboard total for every successful hack
+ALTER TABLE contests ADD COLUMN hack_score numeric NOT NULL DEFAULT 0;
+
+-- Contestants that locked a problem may no longer submit to it, but may view and hack others' accepted submissions
+CREATE TABLE IF NOT EXISTS contest_problem_locks (
+    contest_id  bigint      NOT NULL REFERENCES contests(id) ON DELETE CASCADE,
+    user_id     bigint      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    problem_id  bigint      NOT NULL REFERENCES problems(id) ON DELETE CASCADE,
+    locked_at   timestamptz NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (contest_id, user_id, problem_id)
+);
+
+-- The hack input is stored in the tests bucket, under the negated hack ID
+CREATE TABLE IF NOT EXISTS hacks (
+    id              bigint      GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    created_at      timestamptz NOT NULL DEFAULT NOW(),
+    contest_id      bigint      NOT NULL REFERENCES contests(id) ON DELETE CASCADE,
+    problem_id      bigint      NOT NULL REFERENCES problems(id) ON DELETE CASCADE,
+    submission_id   bigint      NOT NULL REFERENCES submissions(id) ON DELETE CASCADE,
+    hacker_id       bigint      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    target_id       bigint      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+
+    status          hack_status NOT NULL DEFAULT 'pending',
+    verdict         text        NOT NULL DEFAULT '',
+
+    test_id         bigint      REFERENCES tests(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS hacks_contest_idx ON hacks (contest_id, status);
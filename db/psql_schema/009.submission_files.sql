@@ -0,0 +1,9 @@
+-- Additional source files of multi-file submissions. The entrypoint is still stored in submissions.code
+CREATE TABLE IF NOT EXISTS submission_files (
+    submission_id   bigint  NOT NULL REFERENCES submissions(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    name            text    NOT NULL,
+    data            bytea   NOT NULL,
+    PRIMARY KEY (submission_id, name)
+);
+
+ALTER TABLE submissions ADD COLUMN num_files integer NOT NULL DEFAULT 0;
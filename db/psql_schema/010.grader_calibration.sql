@@ -0,0 +1,13 @@
+-- Speed factor of every grader host, measured by the calibration benchmark at startup.
+-- A factor of 2 means that the host is twice as slow as the reference machine
+CREATE TABLE IF NOT EXISTS grader_hosts (
+    hostname        text                        PRIMARY KEY,
+    speed_factor    double precision            NOT NULL,
+    calibrated_at   timestamptz                 NOT NULL DEFAULT NOW()
+);
+
+-- Speed factor of the grader at the time the problem's time limit was set. NULL means that time limits are not scaled
+ALTER TABLE problems ADD COLUMN time_limit_factor double precision;
+
+-- Speed factor of the grader that evaluated the submission
+ALTER TABLE submissions ADD COLUMN speed_factor double precision;
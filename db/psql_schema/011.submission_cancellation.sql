@@ -0,0 +1,13 @@
+-- Submissions whose evaluation was cancelled, either manually or because it exceeded the grader's time budget
+ALTER TABLE submissions ADD COLUMN cancelled boolean NOT NULL DEFAULT false;
+
+-- Results of the submissions being reevaluated, restored if the reevaluation is cancelled.
+-- The rows of the submission's subtests, subtasks and links between them are kept as they were before the reset
+CREATE TABLE IF NOT EXISTS submission_snapshots (
+    submission_id       bigint      NOT NULL PRIMARY KEY REFERENCES submissions(id) ON DELETE CASCADE,
+    created_at          timestamptz NOT NULL DEFAULT NOW(),
+    submission          jsonb       NOT NULL,
+    subtests            jsonb       NOT NULL,
+    subtasks            jsonb       NOT NULL,
+    subtask_subtests    jsonb       NOT NULL
+);
@@ -0,0 +1,8 @@
+-- Extra flags appended to the compile command of submissions and of the checker, for languages that accept them
+ALTER TABLE problems ADD COLUMN compile_flags text NOT NULL DEFAULT '';
+ALTER TABLE problems ADD COLUMN checker_compile_flags text NOT NULL DEFAULT '';
+-- Extra flags appended to the compile command of submissions built together with the problem's graders
+ALTER TABLE problems ADD COLUMN grader_compile_flags text NOT NULL DEFAULT '';
+
+-- Stack limit in kilobytes. 0 means the sandbox's default
+ALTER TABLE problems ADD COLUMN stack_limit integer NOT NULL DEFAULT 0;
@@ -0,0 +1,10 @@
+-- Per-test overrides of the problem's time (seconds) and memory (KB) limits. NULL means the problem's limit
+ALTER TABLE tests ADD COLUMN time_limit double precision;
+ALTER TABLE tests ADD COLUMN memory_limit integer;
+
+-- Names of the additional input files of the test, copied into the box alongside the regular input
+ALTER TABLE tests ADD COLUMN input_files text[] NOT NULL DEFAULT '{}';
+
+-- Limit overrides applied to all tests of the subtask that don't have their own override
+ALTER TABLE subtasks ADD COLUMN time_limit double precision;
+ALTER TABLE subtasks ADD COLUMN memory_limit integer;
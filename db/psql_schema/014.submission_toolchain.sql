@@ -0,0 +1,17 @@
+-- Versions of the language toolchain and of the sandbox used for the last evaluation of the submission
+ALTER TABLE submissions ADD COLUMN language_version text;
+ALTER TABLE submissions ADD COLUMN sandbox_version text;
+
+-- Versions of the toolchain each subtest was evaluated with, since selective reevaluations keep the results of older evaluations
+ALTER TABLE submission_tests ADD COLUMN language_version text;
+ALTER TABLE submission_tests ADD COLUMN sandbox_version text;
+
+-- Toolchains of every evaluation of the submission, the submission itself only holds the last one
+CREATE TABLE IF NOT EXISTS submission_toolchains (
+    id                  bigint      GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    submission_id       bigint      NOT NULL REFERENCES submissions(id) ON DELETE CASCADE,
+    language_version    text,
+    sandbox_version     text,
+    evaluated_at        timestamptz NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS submission_toolchains_submission ON submission_toolchains (submission_id);
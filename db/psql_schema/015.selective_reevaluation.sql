@@ -0,0 +1,13 @@
+-- Hash of the test's input, output and additional input files. NULL for tests created before hashes were tracked
+ALTER TABLE tests ADD COLUMN data_hash text;
+-- Hash of the test data the subtest was evaluated on, compared against the test's hash to find outdated results
+ALTER TABLE submission_tests ADD COLUMN test_hash text;
+
+-- Scores of the submissions before the problem's last selective reevaluation, used for reporting score changes
+CREATE TABLE IF NOT EXISTS submission_score_changes (
+    submission_id   bigint      NOT NULL PRIMARY KEY REFERENCES submissions(id) ON DELETE CASCADE,
+    problem_id      bigint      NOT NULL REFERENCES problems(id) ON DELETE CASCADE,
+    old_score       numeric     NOT NULL,
+    reevaluated_at  timestamptz NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS submission_score_changes_problem ON submission_score_changes (problem_id);
@@ -0,0 +1,11 @@
+-- Content-addressed buckets store files under the hash of their contents.
+-- The references link the file names (such as the test data of test IDs) to the stored blobs, which may be shared
+CREATE TABLE IF NOT EXISTS bucket_blob_refs (
+    bucket      text        NOT NULL,
+    name        text        NOT NULL,
+    hash        text        NOT NULL,
+    updated_at  timestamptz NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (bucket, name)
+);
+CREATE INDEX IF NOT EXISTS bucket_blob_refs_hash ON bucket_blob_refs (bucket, hash);
@@ -0,0 +1,7 @@
+-- The compression each bucket is being converted to, since the level of the stored files can't be told from their names.
+-- Files written before started_at may still use another level
+CREATE TABLE IF NOT EXISTS bucket_recompressions (
+    bucket      text        PRIMARY KEY,
+    compression text        NOT NULL,
+    started_at  timestamptz NOT NULL DEFAULT NOW()
+);
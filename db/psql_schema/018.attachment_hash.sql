@@ -0,0 +1,2 @@
+-- The hash identifies the attachment contents, so conditional requests can be answered without loading the data
+ALTER TABLE attachments ADD COLUMN IF NOT EXISTS data_hash text GENERATED ALWAYS AS (encode(sha256(data), 'hex')) STORED;
@@ -0,0 +1,2 @@
+-- Outcome the problem authors expect from the submission, for the reference solutions of imported problem packages
+ALTER TABLE submissions ADD COLUMN expected_verdict text;
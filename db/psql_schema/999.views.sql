@@ -142,15 +142,20 @@ $$ LANGUAGE SQL STABLE;
 DROP FUNCTION IF EXISTS contest_max_scores(bigint);
 DROP FUNCTION IF EXISTS contest_max_scores(bigint, timestamptz);
 CREATE OR REPLACE FUNCTION contest_max_scores(contest_id bigint, freeze_time timestamptz) RETURNS TABLE(user_id bigint, problem_id bigint, score decimal, mintime timestamptz) AS $$
-    WITH max_submission_strat AS (
+    -- After system testing, only submissions judged on the full test set count
+    WITH system_tested AS (
+        SELECT 1 FROM contests WHERE id = $1 AND system_tested_at IS NOT NULL
+    ), max_submission_strat AS (
         SELECT DISTINCT user_id, problem_id, FIRST_VALUE(score * (leaderboard_score_scale / 100)) OVER w AS max_score, FIRST_VALUE(created_at) OVER w AS mintime
             FROM submissions WHERE contest_id = $1 AND created_at <= COALESCE(freeze_time, NOW()) AND (status = 'finished' OR status = 'reevaling')
+                AND NOT (pretests_only AND EXISTS (SELECT 1 FROM system_tested))
             WINDOW w AS (PARTITION BY user_id, problem_id ORDER BY score DESC, created_at ASC)
     ), subtask_max_scores AS (
         SELECT DISTINCT user_id, subtask_id, problem_id, FIRST_VALUE(computed_score * (leaderboard_score_scale / 100)) OVER w AS max_score, FIRST_VALUE(created_at) OVER w AS mintime
         FROM submission_subtasks stks
         WHERE subtask_id IS NOT NULL AND contest_id = $1
             AND created_at <= COALESCE(freeze_time, NOW())
+            AND NOT EXISTS (SELECT 1 FROM submissions subs, system_tested WHERE subs.id = stks.submission_id AND subs.pretests_only)
             WINDOW w AS (PARTITION BY user_id, subtask_id, problem_id ORDER BY computed_score DESC, created_at ASC)
     ), sum_subtasks_strat AS (
         SELECT DISTINCT user_id, problem_id, coalesce(SUM(max_score), -1) AS max_score, MAX(mintime) AS mintime FROM subtask_max_scores GROUP BY user_id, problem_id
@@ -174,18 +179,25 @@ DROP VIEW IF EXISTS contest_top_view CASCADE;
 DROP FUNCTION IF EXISTS contest_top_view;
 -- Since we now return -1 on no attempt, we must filter it when computing the top view
 -- also, exclude contest editors/testers since they didn't get that score legit
-CREATE OR REPLACE FUNCTION contest_top_view(contest_id bigint, freeze_time timestamptz, include_editors boolean) RETURNS TABLE (user_id bigint, contest_id bigint, total_score decimal, last_time timestamptz) AS $$
+CREATE OR REPLACE FUNCTION contest_top_view(contest_id bigint, freeze_time timestamptz, include_editors boolean) RETURNS TABLE (user_id bigint, contest_id bigint, total_score decimal, last_time timestamptz, num_hacks integer) AS $$
     -- both contest_scores and legit_contestants will contain results only for that contest id, so it's safe to simply join them 
     WITH contest_scores AS (
         SELECT user_id, SUM(score) AS total_score, MAX(mintime) FILTER (WHERE score > 0) AS last_time FROM contest_max_scores($1, $2) WHERE score >= 0 GROUP BY user_id
+    ), hack_counts AS (
+        SELECT hacker_id AS user_id, COUNT(*) AS num_hacks FROM hacks 
+        WHERE contest_id = $1 AND status = 'successful' AND created_at <= COALESCE(freeze_time, NOW()) 
+        GROUP BY hacker_id
     ), legit_contestants AS (
         SELECT regs.* FROM contest_registrations regs WHERE regs.contest_id = $1 AND (NOT EXISTS (SELECT 1 FROM contest_user_access acc WHERE acc.user_id = regs.user_id AND acc.contest_id = regs.contest_id) OR $3 = true)
     )
-    SELECT users.user_id, $1 AS contest_id, COALESCE(scores.total_score, 0) AS total_score, last_time
+    SELECT users.user_id, $1 AS contest_id, 
+        COALESCE(scores.total_score, 0) + COALESCE(hacks.num_hacks, 0) * (SELECT hack_score FROM contests WHERE id = $1 LIMIT 1) AS total_score, 
+        last_time, COALESCE(hacks.num_hacks, 0) AS num_hacks
     FROM 
         legit_contestants users 
         LEFT JOIN contest_scores scores ON users.user_id = scores.user_id 
-        ORDER BY COALESCE(total_score, 0) DESC, last_time ASC NULLS LAST, user_id;
+        LEFT JOIN hack_counts hacks ON users.user_id = hacks.user_id
+        ORDER BY total_score DESC, last_time ASC NULLS LAST, user_id;
 $$ LANGUAGE SQL STABLE;
 
 DROP FUNCTION IF EXISTS contest_icpc_view;
@@ -231,6 +243,17 @@ RETURNS TABLE (user_id bigint, contest_id bigint, last_time timestamptz, num_sol
     ORDER BY COALESCE(num_problems, 0) DESC, penalty ASC NULLS LAST, last_time ASC NULLS LAST, user_id;
 $$ LANGUAGE SQL STABLE;
 
+-- accepted_submission returns true if the submission passed every judged test.
+-- Submissions judged only on pretests may be accepted without getting the full score
+CREATE OR REPLACE FUNCTION accepted_submission(sub_id bigint) RETURNS boolean AS $$
+    SELECT EXISTS (SELECT 1 FROM submissions subs
+        WHERE subs.id = $1 AND subs.status = 'finished' AND subs.compile_error IS NOT TRUE AND (CASE
+            WHEN subs.submission_type = 'icpc' THEN subs.icpc_verdict = 'test_verdict.accepted'
+            ELSE EXISTS (SELECT 1 FROM submission_tests WHERE submission_id = $1)
+                AND NOT EXISTS (SELECT 1 FROM submission_tests WHERE submission_id = $1 AND (skipped OR percentage < 100))
+        END))
+$$ LANGUAGE SQL STABLE;
+
 DROP FUNCTION IF EXISTS visible_submissions;
 CREATE OR REPLACE FUNCTION visible_submissions(user_id bigint) RETURNS TABLE (sub_id bigint) AS $$
     WITH v_pbs AS (SELECT * FROM visible_pbs($1))
@@ -251,6 +274,12 @@ CREATE OR REPLACE FUNCTION visible_submissions(user_id bigint) RETURNS TABLE (su
         WHERE EXISTS (SELECT 1 FROM visible_contests($1) viz WHERE contests.id = viz.contest_id)
         AND contests.id = subs.contest_id AND v_pbs.problem_id = subs.problem_id
         AND contests.end_time <= NOW()) -- if the contest ended and the problem is visible, show the submission
+    UNION ALL
+    (SELECT subs.id as sub_id
+        FROM submissions subs, contest_problem_locks locks, contests
+        WHERE locks.user_id = $1 AND locks.contest_id = subs.contest_id AND locks.problem_id = subs.problem_id
+        AND contests.id = subs.contest_id AND contests.allow_hacking AND contests.end_time > NOW()
+        AND accepted_submission(subs.id)) -- accepted submissions to problems locked by the user may be hacked while the contest is running
 $$ LANGUAGE SQL STABLE;
 
 DROP FUNCTION IF EXISTS visible_submissions_ex;
@@ -273,6 +302,12 @@ CREATE OR REPLACE FUNCTION visible_submissions_ex(user_id bigint, problem_id big
         WHERE EXISTS (SELECT 1 FROM visible_contests($1) viz WHERE contests.id = viz.contest_id)
         AND contests.id = subs.contest_id AND v_pbs.problem_id = subs.problem_id AND ($3 IS NULL OR subs.user_id = $3)
         AND contests.end_time <= NOW()) -- if the contest ended and the problem is visible, show the submission
+    UNION ALL
+    (SELECT subs.id as sub_id
+        FROM submissions subs, contest_problem_locks locks, contests
+        WHERE locks.user_id = $1 AND locks.contest_id = subs.contest_id AND locks.problem_id = subs.problem_id
+        AND contests.id = subs.contest_id AND contests.allow_hacking AND contests.end_time > NOW()
+        AND accepted_submission(subs.id) AND ($2 IS NULL OR subs.problem_id = $2) AND ($3 IS NULL OR subs.user_id = $3)) -- accepted submissions to problems locked by the user may be hacked while the contest is running
 $$ LANGUAGE SQL STABLE;
 
 DROP VIEW IF EXISTS problem_list_deep_problems CASCADE;
@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/KiloProjects/kilonova"
@@ -31,10 +32,13 @@ func (s *DB) InitSubmission(ctx context.Context, subID int) error {
 func clearSubs(ctx context.Context, tx pgx.Tx, filter kilonova.SubmissionFilter) error {
 	fb := newFilterBuilder()
 	subFilterQuery(&filter, fb)
+	if err := snapshotSubs(ctx, tx, fb.Where(), fb.Args()...); err != nil {
+		return err
+	}
 	// Reset submission data:
 	if _, err := tx.Exec(ctx, `
 		UPDATE submissions 
-			SET status = 'creating', score = 0, max_time = -1, max_memory = -1, compile_error = false, compile_message = '', icpc_verdict = NULL, compile_duration = NULL, leaderboard_score_scale = 100
+			SET status = 'creating', score = 0, max_time = -1, max_memory = -1, compile_error = false, compile_message = '', icpc_verdict = NULL, compile_duration = NULL, speed_factor = NULL, cancelled = false, leaderboard_score_scale = 100
 			WHERE `+fb.Where(), fb.Args()...); err != nil {
 		return err
 	}
@@ -50,6 +54,70 @@ func clearSubs(ctx context.Context, tx pgx.Tx, filter kilonova.SubmissionFilter)
 	return err
 }
 
+// snapshotSubs saves the results of the finished submissions matching the condition, so they can be restored if their reevaluation is cancelled.
+// Submissions that are already being reevaluated keep the snapshot of their last finished evaluation
+func snapshotSubs(ctx context.Context, tx pgx.Tx, where string, args ...any) error {
+	_, err := tx.Exec(ctx, fmt.Sprintf(`
+	INSERT INTO submission_snapshots (submission_id, submission, subtests, subtasks, subtask_subtests)
+		WITH snap_subs AS (SELECT * FROM submissions WHERE %s)
+		SELECT subs.id, to_jsonb(subs) - 'code',
+			COALESCE((SELECT jsonb_agg(to_jsonb(st)) FROM submission_tests st WHERE st.submission_id = subs.id), '[]'),
+			COALESCE((SELECT jsonb_agg(to_jsonb(sstk)) FROM submission_subtasks sstk WHERE sstk.submission_id = subs.id), '[]'),
+			COALESCE((SELECT jsonb_agg(to_jsonb(links)) FROM submission_subtask_subtests links 
+				INNER JOIN submission_tests st ON links.submission_test_id = st.id WHERE st.submission_id = subs.id), '[]')
+		FROM snap_subs subs WHERE subs.status = 'finished' OR subs.status = 'reevaling'
+	ON CONFLICT (submission_id) DO UPDATE SET created_at = NOW(), submission = EXCLUDED.submission, subtests = EXCLUDED.subtests, 
+		subtasks = EXCLUDED.subtasks, subtask_subtests = EXCLUDED.subtask_subtests`, where), args...)
+	return err
+}
+
+// restoreSub puts back the result saved by snapshotSubs and removes the snapshot. It returns false if the submission has no snapshot
+func restoreSub(ctx context.Context, tx pgx.Tx, id int) (bool, error) {
+	tag, err := tx.Exec(ctx, `
+	UPDATE submissions subs SET status = 'finished', cancelled = old.cancelled, score = old.score, max_time = old.max_time, max_memory = old.max_memory, 
+		compile_error = old.compile_error, compile_message = old.compile_message, compile_duration = old.compile_duration, icpc_verdict = old.icpc_verdict, 
+		speed_factor = old.speed_factor, language_version = old.language_version, sandbox_version = old.sandbox_version, 
+		digit_precision = old.digit_precision, submission_type = old.submission_type, leaderboard_score_scale = old.leaderboard_score_scale, pretests_only = old.pretests_only
+	FROM submission_snapshots snap, jsonb_populate_record(NULL::submissions, snap.submission) old
+	WHERE snap.submission_id = $1 AND subs.id = $1`, id)
+	if err != nil || tag.RowsAffected() == 0 {
+		return false, err
+	}
+
+	// Removing the subtests and subtasks also removes the links between them
+	if _, err := tx.Exec(ctx, "DELETE FROM submission_tests WHERE submission_id = $1", id); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(ctx, "DELETE FROM submission_subtasks WHERE submission_id = $1", id); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(ctx, `
+	INSERT INTO submission_tests 
+		SELECT old.* FROM submission_snapshots snap, jsonb_populate_recordset(NULL::submission_tests, snap.subtests) old WHERE snap.submission_id = $1`, id); err != nil {
+		return false, err
+	}
+	// computed_score is generated, so the columns are listed
+	if _, err := tx.Exec(ctx, `
+	INSERT INTO submission_subtasks (id, created_at, submission_id, contest_id, final_percentage, leaderboard_score_scale, user_id, subtask_id, problem_id, visible_id, score, digit_precision, aggregation, threshold) 
+		SELECT old.id, old.created_at, old.submission_id, old.contest_id, old.final_percentage, old.leaderboard_score_scale, old.user_id, old.subtask_id, old.problem_id, old.visible_id, old.score, old.digit_precision, old.aggregation, old.threshold 
+		FROM submission_snapshots snap, jsonb_populate_recordset(NULL::submission_subtasks, snap.subtasks) old WHERE snap.submission_id = $1`, id); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(ctx, `
+	INSERT INTO submission_subtask_subtests 
+		SELECT old.* FROM submission_snapshots snap, jsonb_populate_recordset(NULL::submission_subtask_subtests, snap.subtask_subtests) old WHERE snap.submission_id = $1`, id); err != nil {
+		return false, err
+	}
+	_, err = tx.Exec(ctx, "DELETE FROM submission_snapshots WHERE submission_id = $1", id)
+	return true, err
+}
+
+// DeleteSubmissionSnapshot removes the saved result of the submission, once its reevaluation finished
+func (s *DB) DeleteSubmissionSnapshot(ctx context.Context, id int) error {
+	_, err := s.conn.Exec(ctx, "DELETE FROM submission_snapshots WHERE submission_id = $1", id)
+	return err
+}
+
 func initSubs(ctx context.Context, tx pgx.Tx, filter kilonova.SubmissionFilter) error {
 	fb := newFilterBuilder()
 	subFilterQuery(&filter, fb)
@@ -62,7 +130,9 @@ func initSubs(ctx context.Context, tx pgx.Tx, filter kilonova.SubmissionFilter)
 				WHEN 'acm-icpc' THEN 'acm-icpc'::eval_type
 				ELSE 'classic'::eval_type
 			END,
-		leaderboard_score_scale = COALESCE((SELECT leaderboard_score_scale FROM problems WHERE problems.id = problem_id), leaderboard_score_scale)
+		leaderboard_score_scale = COALESCE((SELECT leaderboard_score_scale FROM problems WHERE problems.id = problem_id), leaderboard_score_scale),
+		pretests_only = COALESCE((SELECT system_testing IS NOT NULL AND system_tested_at IS NULL FROM contests WHERE contests.id = contest_id), false) 
+			AND EXISTS (SELECT 1 FROM tests WHERE tests.problem_id = submissions.problem_id AND tests.pretest)
 	WHERE `+fb.Where(), fb.Args()...); err != nil {
 		return err
 	}
@@ -73,18 +143,31 @@ func initSubs(ctx context.Context, tx pgx.Tx, filter kilonova.SubmissionFilter)
 		WITH subs_to_add AS (SELECT * FROM submissions WHERE %s)
 		SELECT subs.created_at AS created_at, subs.id AS submission_id, tests.id AS test_id, tests.visible_id, tests.score AS score 
 		FROM subs_to_add subs, tests 
-		WHERE subs.problem_id = tests.problem_id`, fb.Where()), fb.Args()...); err != nil {
+		WHERE subs.problem_id = tests.problem_id AND (NOT subs.pretests_only OR tests.pretest)`, fb.Where()), fb.Args()...); err != nil {
 		return err
 	}
 
+	if err := initSubTasks(ctx, tx, fb.Where(), fb.Args()...); err != nil {
+		return err
+	}
+
+	// Update to waiting
+	_, err := tx.Exec(ctx, "UPDATE submissions SET status = 'waiting' WHERE "+fb.Where(), fb.Args()...)
+	return err
+}
+
+// initSubTasks creates the subtasks of the submissions matching the condition and links them to their subtests
+func initSubTasks(ctx context.Context, tx pgx.Tx, where string, args ...any) error {
 	// Init subtasks
 	if _, err := tx.Exec(ctx, fmt.Sprintf(`
 	INSERT INTO submission_subtasks 
-	(user_id, created_at, submission_id, contest_id, subtask_id, problem_id, visible_id, digit_precision, score, leaderboard_score_scale) 
+	(user_id, created_at, submission_id, contest_id, subtask_id, problem_id, visible_id, digit_precision, score, leaderboard_score_scale, aggregation, threshold) 
 		WITH subs_to_add AS (SELECT * FROM submissions WHERE %s)
-	SELECT subs.user_id, subs.created_at AS created_at, subs.id AS submission_id, subs.contest_id, stks.id AS subtask_id, stks.problem_id AS problem_id, stks.visible_id, subs.digit_precision AS digit_precision, stks.score AS score, subs.leaderboard_score_scale AS leaderboard_score_scale
+	SELECT subs.user_id, subs.created_at AS created_at, subs.id AS submission_id, subs.contest_id, stks.id AS subtask_id, stks.problem_id AS problem_id, stks.visible_id, subs.digit_precision AS digit_precision, stks.score AS score, subs.leaderboard_score_scale AS leaderboard_score_scale, stks.aggregation AS aggregation, stks.threshold AS threshold
 	FROM subs_to_add subs, subtasks stks 
-	WHERE subs.problem_id = stks.problem_id`, fb.Where()), fb.Args()...); err != nil {
+	WHERE subs.problem_id = stks.problem_id AND (NOT subs.pretests_only OR EXISTS (
+		SELECT 1 FROM subtask_tests stt INNER JOIN tests ON stt.test_id = tests.id WHERE stt.subtask_id = stks.id AND tests.pretest
+	))`, where), args...); err != nil {
 		return err
 	}
 
@@ -96,12 +179,116 @@ func initSubs(ctx context.Context, tx pgx.Tx, filter kilonova.SubmissionFilter)
 	INNER JOIN submission_subtasks sstk ON stks.subtask_id = sstk.subtask_id
 	INNER JOIN submission_tests st ON stks.test_id = st.test_id AND sstk.submission_id = st.submission_id
 	WHERE EXISTS (SELECT 1 FROM submissions WHERE id = st.submission_id AND %s)`,
-		fb.Where()), fb.Args()...)
+		where), args...)
+	return err
+}
 
-	// Update to waiting
-	if _, err := tx.Exec(ctx, "UPDATE submissions SET status = 'waiting' WHERE "+fb.Where(), fb.Args()...); err != nil {
+// outdatedSubTestsQuery selects the subtests of the problem's finished submissions that were evaluated on different test data than the current one.
+// Submissions that failed to compile or were cancelled are ignored, since their subtests were not actually run.
+const outdatedSubTestsQuery = `
+SELECT st.id, st.submission_id, subs.submission_type
+	FROM submission_tests st
+	INNER JOIN tests ON st.test_id = tests.id
+	INNER JOIN submissions subs ON st.submission_id = subs.id
+	WHERE subs.problem_id = $1 AND subs.status = 'finished' AND subs.compile_error IS NOT TRUE AND NOT subs.cancelled
+		AND st.done AND NOT st.skipped AND st.test_hash IS DISTINCT FROM tests.data_hash`
+
+// changedTestSetQuery selects the problem's finished submissions whose test set changed since they were evaluated,
+// either because tests were added after that or because some of their tests were removed.
+const changedTestSetQuery = `
+SELECT subs.id AS submission_id, subs.submission_type
+	FROM submissions subs
+	WHERE subs.problem_id = $1 AND subs.status = 'finished' AND subs.compile_error IS NOT TRUE AND NOT subs.cancelled
+		AND (EXISTS (
+			SELECT 1 FROM tests WHERE tests.problem_id = subs.problem_id AND (NOT subs.pretests_only OR tests.pretest)
+				AND NOT EXISTS (SELECT 1 FROM submission_tests st WHERE st.submission_id = subs.id AND st.test_id = tests.id)
+		) OR EXISTS (SELECT 1 FROM submission_tests st WHERE st.submission_id = subs.id AND st.test_id IS NULL))`
+
+// ResetOutdatedSubTests queues the reevaluation of the subtests evaluated on outdated test data and returns the number of affected submissions.
+// Only the outdated subtests of classic submissions are reset, the rest of their results are kept.
+// Classic submissions whose test set changed get subtests for the new tests, lose the ones of the removed tests and have their subtasks rebuilt.
+// ICPC verdicts depend on the order of the tests, so those submissions are fully reevaluated.
+// The current scores are saved, replacing the ones from the previous selective reevaluation of the problem.
+func (s *DB) ResetOutdatedSubTests(ctx context.Context, problemID int) (int, error) {
+	var count int
+	err := pgx.BeginFunc(ctx, s.conn, func(tx pgx.Tx) error {
+		affectedQuery := "SELECT submission_id FROM (" + outdatedSubTestsQuery + ") outdated UNION SELECT submission_id FROM (" + changedTestSetQuery + ") changed"
+		// Keep the report of the previous reevaluation if there is nothing to reevaluate
+		if err := tx.QueryRow(ctx, "SELECT COUNT(*) FROM ("+affectedQuery+") affected", problemID).Scan(&count); err != nil || count == 0 {
+			return err
+		}
+
+		rows, _ := tx.Query(ctx, "SELECT submission_id FROM ("+changedTestSetQuery+") changed WHERE submission_type = 'classic'", problemID)
+		changedIDs, err := pgx.CollectRows(rows, pgx.RowTo[int])
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(ctx, "DELETE FROM submission_score_changes WHERE problem_id = $1", problemID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `
+		INSERT INTO submission_score_changes (submission_id, problem_id, old_score)
+			SELECT id, problem_id, score FROM submissions 
+			WHERE id IN (`+affectedQuery+`)`, problemID); err != nil {
+			return err
+		}
+
+		if err := snapshotSubs(ctx, tx, "id IN (SELECT submission_id FROM submission_score_changes WHERE problem_id = $1)", problemID); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(ctx, `
+		UPDATE submission_tests 
+			SET done = false, skipped = false, verdict = '', time = 0, memory = 0, percentage = 0, objective = NULL, test_hash = NULL
+			WHERE id IN (SELECT id FROM (`+outdatedSubTestsQuery+`) outdated WHERE outdated.submission_type = 'classic')`, problemID); err != nil {
+			return err
+		}
+
+		if len(changedIDs) > 0 {
+			// The subtests of removed tests had their test set to NULL when the test was deleted
+			if _, err := tx.Exec(ctx, "DELETE FROM submission_tests WHERE submission_id = ANY($1) AND test_id IS NULL", changedIDs); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(ctx, `
+			INSERT INTO submission_tests (created_at, submission_id, test_id, visible_id, score) 
+				SELECT subs.created_at, subs.id, tests.id, tests.visible_id, tests.score 
+				FROM submissions subs, tests 
+				WHERE subs.id = ANY($1) AND subs.problem_id = tests.problem_id AND (NOT subs.pretests_only OR tests.pretest)
+					AND NOT EXISTS (SELECT 1 FROM submission_tests st WHERE st.submission_id = subs.id AND st.test_id = tests.id)`, changedIDs); err != nil {
+				return err
+			}
+			// Subtasks may have gained or lost tests as well, so they are recreated along with their links
+			if _, err := tx.Exec(ctx, "DELETE FROM submission_subtasks WHERE submission_id = ANY($1)", changedIDs); err != nil {
+				return err
+			}
+			if err := initSubTasks(ctx, tx, "id = ANY($1)", changedIDs); err != nil {
+				return err
+			}
+		}
+
+		// Classic submissions go straight to the queue, since the reevaluation queue resets all subtests.
+		// Their scores are recomputed from all subtests once the pending ones are run
+		_, err = tx.Exec(ctx, `
+		UPDATE submissions 
+			SET status = CASE submission_type WHEN 'acm-icpc' THEN 'reevaling'::status ELSE 'waiting'::status END
+			WHERE id IN (SELECT submission_id FROM submission_score_changes WHERE problem_id = $1)`, problemID)
 		return err
-	}
+	})
+	return count, err
+}
 
-	return err
+// SubmissionScoreChanges returns the score changes of the submissions affected by the problem's last selective reevaluation
+func (s *DB) SubmissionScoreChanges(ctx context.Context, problemID int) ([]*kilonova.SubmissionScoreChange, error) {
+	var changes []*kilonova.SubmissionScoreChange
+	err := Select(s.conn, ctx, &changes, `
+	SELECT ssc.submission_id, subs.user_id, subs.status, ssc.old_score, subs.score AS new_score, ssc.reevaluated_at 
+		FROM submission_score_changes ssc 
+		INNER JOIN submissions subs ON ssc.submission_id = subs.id 
+		WHERE ssc.problem_id = $1 
+		ORDER BY ssc.submission_id DESC`, problemID)
+	if errors.Is(err, pgx.ErrNoRows) || len(changes) == 0 {
+		return []*kilonova.SubmissionScoreChange{}, nil
+	}
+	return changes, err
 }
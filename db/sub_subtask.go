@@ -28,6 +28,9 @@ type subSubtask struct {
 	ScoreScale *decimal.Decimal `db:"leaderboard_score_scale"`
 
 	ComputedScore decimal.Decimal `db:"computed_score"`
+
+	Aggregation kilonova.SubtaskAggregation `db:"aggregation"`
+	Threshold   decimal.Decimal             `db:"threshold"`
 }
 
 func (s *DB) UpdateSubmissionSubtaskPercentage(ctx context.Context, id int, percentage decimal.Decimal) (err error) {
@@ -115,5 +118,8 @@ func (s *DB) internalToSubmissionSubTask(ctx context.Context, st *subSubtask) (*
 
 		FinalPercentage: st.FinalPercentage,
 		ScorePrecision:  st.ScorePrecision,
+
+		Aggregation: st.Aggregation,
+		Threshold:   st.Threshold,
 	}, nil
 }
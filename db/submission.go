@@ -40,6 +40,19 @@ type dbSubmission struct {
 
 	SubmissionType kilonova.EvalType `db:"submission_type"`
 	ICPCVerdict    *string           `db:"icpc_verdict"`
+
+	PretestsOnly bool `db:"pretests_only"`
+
+	NumFiles int `db:"num_files"`
+
+	SpeedFactor *float64 `db:"speed_factor"`
+
+	Cancelled bool `db:"cancelled"`
+
+	LanguageVersion *string `db:"language_version"`
+	SandboxVersion  *string `db:"sandbox_version"`
+
+	ExpectedVerdict *string `db:"expected_verdict"`
 }
 
 func (s *DB) Submission(ctx context.Context, id int) (*kilonova.Submission, error) {
@@ -90,6 +103,33 @@ func (s *DB) SubmissionCode(ctx context.Context, subID int) ([]byte, error) {
 	return code, err
 }
 
+// SubmissionFiles returns the additional source files of the submission, ordered by name
+func (s *DB) SubmissionFiles(ctx context.Context, subID int) ([]*kilonova.SubmissionFile, error) {
+	var files []*kilonova.SubmissionFile
+	err := Select(s.conn, ctx, &files, "SELECT name, data FROM submission_files WHERE submission_id = $1 ORDER BY name ASC", subID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return []*kilonova.SubmissionFile{}, nil
+	}
+	return files, err
+}
+
+func (s *DB) CreateSubmissionFiles(ctx context.Context, subID int, files []*kilonova.SubmissionFile) error {
+	if len(files) == 0 {
+		return nil
+	}
+	return pgx.BeginFunc(ctx, s.conn, func(tx pgx.Tx) error {
+		rows := make([][]any, 0, len(files))
+		for _, file := range files {
+			rows = append(rows, []any{subID, file.Name, file.Data})
+		}
+		if _, err := tx.CopyFrom(ctx, pgx.Identifier{"submission_files"}, []string{"submission_id", "name", "data"}, pgx.CopyFromRows(rows)); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, "UPDATE submissions SET num_files = $2 WHERE id = $1", subID, len(files))
+		return err
+	})
+}
+
 func (s *DB) SubmissionCount(ctx context.Context, filter kilonova.SubmissionFilter, limit int) (int, error) {
 	fb := newFilterBuilder()
 	subFilterQuery(&filter, fb)
@@ -151,6 +191,53 @@ func (s *DB) BulkUpdateSubmissions(ctx context.Context, filter kilonova.Submissi
 	return err
 }
 
+// RecordSubmissionToolchain saves the toolchain of the submission's current evaluation, keeping the ones of the previous evaluations
+func (s *DB) RecordSubmissionToolchain(ctx context.Context, id int, languageVersion *string, sandboxVersion string) error {
+	return pgx.BeginFunc(ctx, s.conn, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "UPDATE submissions SET language_version = $2, sandbox_version = $3 WHERE id = $1", id, languageVersion, sandboxVersion); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, "INSERT INTO submission_toolchains (submission_id, language_version, sandbox_version) VALUES ($1, $2, $3)", id, languageVersion, sandboxVersion)
+		return err
+	})
+}
+
+// SubmissionToolchains returns the toolchains of all evaluations of the submission, oldest first
+func (s *DB) SubmissionToolchains(ctx context.Context, id int) ([]*kilonova.SubmissionToolchain, error) {
+	var toolchains []*kilonova.SubmissionToolchain
+	err := Select(s.conn, ctx, &toolchains, "SELECT language_version, sandbox_version, evaluated_at FROM submission_toolchains WHERE submission_id = $1 ORDER BY id ASC", id)
+	if errors.Is(err, pgx.ErrNoRows) || len(toolchains) == 0 {
+		return []*kilonova.SubmissionToolchain{}, nil
+	}
+	return toolchains, err
+}
+
+// CancelSubmission stops the evaluation of the submission. Reevaluated submissions get back their previous result,
+// the others are marked as finished with no score and skip the tests that were not evaluated yet.
+// If onlyQueued is true, the submission is cancelled only if the grader didn't pick it up, otherwise it may also be in evaluation.
+// Finished submissions are never cancelled, so an evaluation that finished in the meantime keeps its result.
+// It returns false if the submission was not cancelled.
+func (s *DB) CancelSubmission(ctx context.Context, id int, verdict string, onlyQueued bool) (bool, error) {
+	var cancelled bool
+	err := pgx.BeginFunc(ctx, s.conn, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, `UPDATE submissions SET status = 'finished', score = 0, cancelled = true 
+			WHERE id = $1 AND (status = 'creating' OR status = 'waiting' OR ($2 = false AND status = 'working'))`, id, onlyQueued)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return nil
+		}
+		cancelled = true
+		if restored, err := restoreSub(ctx, tx, id); err != nil || restored {
+			return err
+		}
+		_, err = tx.Exec(ctx, "UPDATE submission_tests SET done = true, skipped = true, verdict = $2 WHERE submission_id = $1 AND done = false", id, verdict)
+		return err
+	})
+	return cancelled, err
+}
+
 func (s *DB) ClearUserContestSubmissions(ctx context.Context, contestID, userID int) error {
 	_, err := s.conn.Exec(ctx, "UPDATE submissions SET contest_id = NULL WHERE contest_id = $1 AND user_id = $2", contestID, userID)
 	return err
@@ -266,6 +353,9 @@ func subFilterQuery(filter *kilonova.SubmissionFilter, fb *filterBuilder) {
 	if v := filter.Score; v != nil {
 		fb.AddConstraint("score = %s", v)
 	}
+	if filter.Accepted {
+		fb.AddConstraint("accepted_submission(id)")
+	}
 
 	if v := filter.Since; v != nil {
 		fb.AddConstraint("created_at > %s", v)
@@ -274,6 +364,13 @@ func subFilterQuery(filter *kilonova.SubmissionFilter, fb *filterBuilder) {
 	if v := filter.CompileError; v != nil {
 		fb.AddConstraint("compile_error = %s", v)
 	}
+
+	if v := filter.LanguageVersion; v != nil {
+		fb.AddConstraint("(language_version = %s OR EXISTS (SELECT 1 FROM submission_tests st WHERE st.submission_id = submissions.id AND st.done AND st.language_version = %s))", v, v)
+	}
+	if v := filter.SandboxVersion; v != nil {
+		fb.AddConstraint("(sandbox_version = %s OR EXISTS (SELECT 1 FROM submission_tests st WHERE st.submission_id = submissions.id AND st.done AND st.sandbox_version = %s))", v, v)
+	}
 }
 
 func subUpdateQuery(upd *kilonova.SubmissionUpdate, b *updateBuilder) {
@@ -297,6 +394,18 @@ func subUpdateQuery(upd *kilonova.SubmissionUpdate, b *updateBuilder) {
 	if v := upd.CompileTime; v != nil {
 		b.AddUpdate("compile_duration = %s", v)
 	}
+	if v := upd.SpeedFactor; v != nil {
+		b.AddUpdate("speed_factor = %s", v)
+	}
+	if v := upd.LanguageVersion; v != nil {
+		b.AddUpdate("language_version = %s", v)
+	}
+	if v := upd.SandboxVersion; v != nil {
+		b.AddUpdate("sandbox_version = %s", v)
+	}
+	if v := upd.ExpectedVerdict; v != nil {
+		b.AddUpdate("expected_verdict = %s", v)
+	}
 
 	if v := upd.MaxTime; v != nil {
 		b.AddUpdate("max_time = %s", v)
@@ -352,5 +461,18 @@ func (s *DB) internalToSubmission(sub *dbSubmission) *kilonova.Submission {
 
 		SubmissionType: sub.SubmissionType,
 		ICPCVerdict:    sub.ICPCVerdict,
+
+		PretestsOnly: sub.PretestsOnly,
+
+		NumFiles: sub.NumFiles,
+
+		SpeedFactor: sub.SpeedFactor,
+
+		Cancelled: sub.Cancelled,
+
+		LanguageVersion: sub.LanguageVersion,
+		SandboxVersion:  sub.SandboxVersion,
+
+		ExpectedVerdict: sub.ExpectedVerdict,
 	}
 }
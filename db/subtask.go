@@ -15,9 +15,15 @@ func (s *DB) CreateSubTask(ctx context.Context, subtask *kilonova.SubTask) error
 	if subtask.ProblemID == 0 || subtask.Tests == nil {
 		return kilonova.ErrMissingRequired
 	}
+	if subtask.Aggregation == kilonova.AggregationNone {
+		subtask.Aggregation = kilonova.AggregationMin
+	}
+	if subtask.Aggregation != kilonova.AggregationThreshold {
+		subtask.Threshold = decimal.NewFromInt(100)
+	}
 	var id int
 	// Do insertion
-	err := s.conn.QueryRow(ctx, "INSERT INTO subtasks (problem_id, visible_id, score) VALUES ($1, $2, $3) RETURNING id", subtask.ProblemID, subtask.VisibleID, subtask.Score).Scan(&id)
+	err := s.conn.QueryRow(ctx, "INSERT INTO subtasks (problem_id, visible_id, score, aggregation, threshold, time_limit, memory_limit) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id", subtask.ProblemID, subtask.VisibleID, subtask.Score, subtask.Aggregation, subtask.Threshold, subtask.TimeLimit, subtask.MemoryLimit).Scan(&id)
 	if err != nil {
 		return err
 	}
@@ -98,6 +104,26 @@ func (s *DB) UpdateSubTask(ctx context.Context, id int, upd kilonova.SubTaskUpda
 	if v := upd.Score; v != nil {
 		ub.AddUpdate("score = %s", v)
 	}
+	if v := upd.Aggregation; v != kilonova.AggregationNone {
+		ub.AddUpdate("aggregation = %s", v)
+	}
+	if v := upd.Threshold; v != nil {
+		ub.AddUpdate("threshold = %s", v)
+	}
+	if v := upd.TimeLimit; v != nil {
+		if *v > 0 {
+			ub.AddUpdate("time_limit = %s", v)
+		} else {
+			ub.AddUpdate("time_limit = NULL")
+		}
+	}
+	if v := upd.MemoryLimit; v != nil {
+		if *v > 0 {
+			ub.AddUpdate("memory_limit = %s", v)
+		} else {
+			ub.AddUpdate("memory_limit = NULL")
+		}
+	}
 
 	if ub.CheckUpdates() != nil {
 		return kilonova.ErrNoUpdates
@@ -148,6 +174,12 @@ type subtask struct {
 	VisibleID int       `db:"visible_id"`
 
 	Score decimal.Decimal
+
+	Aggregation kilonova.SubtaskAggregation
+	Threshold   decimal.Decimal
+
+	TimeLimit   *float64 `db:"time_limit"`
+	MemoryLimit *int     `db:"memory_limit"`
 }
 
 func (s *DB) internalToSubTask(ctx context.Context, st *subtask) (*kilonova.SubTask, error) {
@@ -181,5 +213,11 @@ ORDER BY tests.visible_id ASC
 		VisibleID: st.VisibleID,
 		Score:     st.Score,
 		Tests:     ids,
+
+		Aggregation: st.Aggregation,
+		Threshold:   st.Threshold,
+
+		TimeLimit:   st.TimeLimit,
+		MemoryLimit: st.MemoryLimit,
 	}, nil
 }
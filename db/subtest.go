@@ -47,6 +47,16 @@ WITH subtask_ids AS (
 	return subtests, err
 }
 
+// RelativeSubTests returns the finished subtests of the given tests which have an objective value
+func (s *DB) RelativeSubTests(ctx context.Context, testIDs []int) ([]*kilonova.SubTest, error) {
+	var subtests []*kilonova.SubTest
+	err := Select(s.conn, ctx, &subtests, "SELECT * FROM submission_tests WHERE test_id = ANY($1) AND objective IS NOT NULL AND done = true ORDER BY id ASC", testIDs)
+	if errors.Is(err, pgx.ErrNoRows) || len(subtests) == 0 {
+		return []*kilonova.SubTest{}, nil
+	}
+	return subtests, err
+}
+
 func (s *DB) SubTest(ctx context.Context, id int) (*kilonova.SubTest, error) {
 	var subtest kilonova.SubTest
 	err := Get(s.conn, ctx, &subtest, "SELECT * FROM submission_tests WHERE id = $1", id)
@@ -87,4 +97,22 @@ func subtestUpdateQuery(upd *kilonova.SubTestUpdate, ub *updateBuilder) {
 	if v := upd.Skipped; v != nil {
 		ub.AddUpdate("skipped = %s", v)
 	}
+	if v := upd.Objective; v != nil {
+		ub.AddUpdate("objective = %s", v)
+	}
+	if v := upd.TestHash; v != nil {
+		ub.AddUpdate("test_hash = %s", v)
+	}
+	if v := upd.LanguageVersion; v != nil {
+		ub.AddUpdate("language_version = %s", v)
+	}
+	if v := upd.SandboxVersion; v != nil {
+		ub.AddUpdate("sandbox_version = %s", v)
+	}
+}
+
+// ExistingSubTestIDs returns which of the given subtest IDs still exist
+func (s *DB) ExistingSubTestIDs(ctx context.Context, ids []int) ([]int, error) {
+	rows, _ := s.conn.Query(ctx, "SELECT id FROM submission_tests WHERE id = ANY($1)", ids)
+	return pgx.CollectRows(rows, pgx.RowTo[int])
 }
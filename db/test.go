@@ -6,6 +6,7 @@ import (
 
 	"github.com/KiloProjects/kilonova"
 	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
 )
 
 func (s *DB) CreateTest(ctx context.Context, test *kilonova.Test) error {
@@ -14,7 +15,10 @@ func (s *DB) CreateTest(ctx context.Context, test *kilonova.Test) error {
 	}
 
 	var id int
-	err := s.conn.QueryRow(ctx, "INSERT INTO tests (score, problem_id, visible_id) VALUES ($1, $2, $3) RETURNING id", test.Score, test.ProblemID, test.VisibleID).Scan(&id)
+	if test.InputFiles == nil {
+		test.InputFiles = []string{}
+	}
+	err := s.conn.QueryRow(ctx, "INSERT INTO tests (score, problem_id, visible_id, pretest, time_limit, memory_limit, input_files) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id", test.Score, test.ProblemID, test.VisibleID, test.Pretest, test.TimeLimit, test.MemoryLimit, test.InputFiles).Scan(&id)
 	if err == nil {
 		test.ID = id
 	}
@@ -39,6 +43,16 @@ func (s *DB) Tests(ctx context.Context, pbID int) ([]*kilonova.Test, error) {
 	return tests, err
 }
 
+// AllTests returns the tests of all problems, used for checking the test data in the bucket
+func (s *DB) AllTests(ctx context.Context) ([]*kilonova.Test, error) {
+	var tests []*kilonova.Test
+	err := Select(s.conn, ctx, &tests, "SELECT * FROM tests ORDER BY id")
+	if errors.Is(err, pgx.ErrNoRows) {
+		return []*kilonova.Test{}, nil
+	}
+	return tests, err
+}
+
 func (s *DB) UpdateTest(ctx context.Context, id int, upd kilonova.TestUpdate) error {
 	ub := newUpdateBuilder()
 	if v := upd.Score; v != nil {
@@ -47,6 +61,29 @@ func (s *DB) UpdateTest(ctx context.Context, id int, upd kilonova.TestUpdate) er
 	if v := upd.VisibleID; v != nil {
 		ub.AddUpdate("visible_id = %s", v)
 	}
+	if v := upd.Pretest; v != nil {
+		ub.AddUpdate("pretest = %s", v)
+	}
+	if v := upd.TimeLimit; v != nil {
+		if *v > 0 {
+			ub.AddUpdate("time_limit = %s", v)
+		} else {
+			ub.AddUpdate("time_limit = NULL")
+		}
+	}
+	if v := upd.MemoryLimit; v != nil {
+		if *v > 0 {
+			ub.AddUpdate("memory_limit = %s", v)
+		} else {
+			ub.AddUpdate("memory_limit = NULL")
+		}
+	}
+	if v := upd.InputFiles; v != nil {
+		ub.AddUpdate("input_files = %s", v)
+	}
+	if v := upd.DataHash; v != nil {
+		ub.AddUpdate("data_hash = %s", v)
+	}
 	if ub.CheckUpdates() != nil {
 		return ub.CheckUpdates()
 	}
@@ -57,24 +94,69 @@ func (s *DB) UpdateTest(ctx context.Context, id int, upd kilonova.TestUpdate) er
 	return err
 }
 
-func (s *DB) DeleteProblemTests(ctx context.Context, problemID int) ([]int, error) {
-	rows, _ := s.conn.Query(ctx, "DELETE FROM tests WHERE problem_id = $1 RETURNING id", problemID)
-	vals, err := pgx.CollectRows(rows, pgx.RowTo[int])
+// ImproveTestObjective atomically sets the best objective value of the test, if the value is better than the existing one.
+// It returns true if the best value was updated.
+func (s *DB) ImproveTestObjective(ctx context.Context, id int, value decimal.Decimal, minimize bool) (bool, error) {
+	cmp := ">"
+	if minimize {
+		cmp = "<"
+	}
+	tag, err := s.conn.Exec(ctx, "UPDATE tests SET best_objective = $2 WHERE id = $1 AND (best_objective IS NULL OR $2 "+cmp+" best_objective)", id, value)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return []int{}, nil
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// TestBestObjective returns the best known objective value of the test, or nil if there is none
+func (s *DB) TestBestObjective(ctx context.Context, id int) (*decimal.Decimal, error) {
+	var best *decimal.Decimal
+	err := s.conn.QueryRow(ctx, "SELECT best_objective FROM tests WHERE id = $1", id).Scan(&best)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return best, err
+}
+
+// ResetTestObjective clears the best objective value, used when the test data is changed.
+// The objective values of the test's subtests were computed on the old data, so they are cleared as well and no longer affect rescoring
+func (s *DB) ResetTestObjective(ctx context.Context, id int) error {
+	return pgx.BeginFunc(ctx, s.conn, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "UPDATE tests SET best_objective = NULL WHERE id = $1", id); err != nil {
+			return err
 		}
-		return nil, err
+		_, err := tx.Exec(ctx, "UPDATE submission_tests SET objective = NULL WHERE test_id = $1 AND objective IS NOT NULL", id)
+		return err
+	})
+}
+
+// ResetProblemObjectives clears the best objective values of all tests of the problem
+func (s *DB) ResetProblemObjectives(ctx context.Context, problemID int) error {
+	_, err := s.conn.Exec(ctx, "UPDATE tests SET best_objective = NULL WHERE problem_id = $1", problemID)
+	return err
+}
+
+// DeleteProblemTests removes all tests of the problem and returns them, such that their data can be purged
+func (s *DB) DeleteProblemTests(ctx context.Context, problemID int) ([]*kilonova.Test, error) {
+	var tests []*kilonova.Test
+	err := Select(s.conn, ctx, &tests, "DELETE FROM tests WHERE problem_id = $1 RETURNING *", problemID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return []*kilonova.Test{}, nil
 	}
-	return vals, err
+	return tests, err
 }
 
-func (s *DB) DeleteTest(ctx context.Context, id int) error {
-	_, err := s.conn.Exec(ctx, "DELETE FROM tests WHERE id = $1", id)
+// DeleteTest removes the test and returns it, or nil if it didn't exist
+func (s *DB) DeleteTest(ctx context.Context, id int) (*kilonova.Test, error) {
+	var test kilonova.Test
+	err := Get(s.conn, ctx, &test, "DELETE FROM tests WHERE id = $1 RETURNING *", id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return err
+	return &test, nil
 }
 
 func (s *DB) BiggestVID(ctx context.Context, problemID int) (int, error) {
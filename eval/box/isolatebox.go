@@ -103,6 +103,9 @@ func (b *IsolateBox) buildRunFlags(c *eval.RunConfig, metaFilePath string) (res
 		// Just a sanity check to ensure resources aren't exhausted.
 		res = append(res, "--cg-mem="+strconv.FormatInt(b.memoryQuota, 10))
 	}
+	if c.StackLimit != 0 {
+		res = append(res, "--stack="+strconv.Itoa(c.StackLimit))
+	}
 
 	if c.InputPath == "" {
 		c.InputPath = "/dev/null"
@@ -138,6 +141,12 @@ func (b *IsolateBox) WriteFile(fpath string, r io.Reader, mode fs.FileMode) erro
 	return writeFile(b.getFilePath(fpath), r, mode)
 }
 
+func (b *IsolateBox) LinkFile(fpath string, src string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return linkFile(b.getFilePath(fpath), src)
+}
+
 func (b *IsolateBox) ReadFile(fpath string, w io.Writer) error {
 	b.mu.Lock()
 	defer b.mu.Unlock()
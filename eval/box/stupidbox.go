@@ -99,6 +99,12 @@ func (b *StupidSandbox) WriteFile(fpath string, r io.Reader, mode fs.FileMode) e
 	return writeFile(b.getFilePath(fpath), r, mode)
 }
 
+func (b *StupidSandbox) LinkFile(fpath string, src string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return linkFile(b.getFilePath(fpath), src)
+}
+
 func (b *StupidSandbox) FileExists(fpath string) bool {
 	b.mu.Lock()
 	defer b.mu.Unlock()
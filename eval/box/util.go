@@ -5,6 +5,7 @@ import (
 	"io"
 	"io/fs"
 	"os"
+	"path"
 
 	"github.com/KiloProjects/kilonova/eval"
 	"go.uber.org/zap"
@@ -32,6 +33,10 @@ func saveFile(p string, bucket eval.Bucket, filename string, mode fs.FileMode) e
 }
 
 func writeFile(p string, r io.Reader, mode fs.FileMode) error {
+	// Files of multi-file submissions may be nested in directories
+	if err := os.MkdirAll(path.Dir(p), 0777); err != nil {
+		return err
+	}
 	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC|os.O_SYNC, mode)
 	if err != nil {
 		return err
@@ -46,6 +51,31 @@ func writeFile(p string, r io.Reader, mode fs.FileMode) error {
 	return err
 }
 
+// linkFile hard links src at p, falling back to copying it if they are on different filesystems
+func linkFile(p string, src string) error {
+	if err := os.MkdirAll(path.Dir(p), 0777); err != nil {
+		return err
+	}
+	// The file might be left over from a previous run
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := os.Link(src, p); err == nil {
+		return nil
+	}
+
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return writeFile(p, f, stat.Mode())
+}
+
 func checkFile(p string) bool {
 	_, err := os.Stat(p)
 	if err != nil {
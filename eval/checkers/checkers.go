@@ -11,6 +11,7 @@ type Checker interface {
 	Prepare(context.Context) (string, error)
 	Cleanup(context.Context) error
 
-	// RunChecker returns a comment and a decimal number [0, 100] signifying the percentage of correctness of the subtest
-	RunChecker(ctx context.Context, subtestID int, testID int) (string, decimal.Decimal)
+	// RunChecker returns a comment and a decimal number [0, 100] signifying the percentage of correctness of the subtest.
+	// For optimization problems, it may instead return a raw objective value, in which case the percentage should be ignored.
+	RunChecker(ctx context.Context, subtestID int, testID int) (string, decimal.Decimal, *decimal.Decimal)
 }
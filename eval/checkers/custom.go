@@ -7,6 +7,7 @@ import (
 	"io/fs"
 	"log/slog"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -31,6 +32,11 @@ var _ Checker = &customChecker{}
 //go:embed checkerdata/testlib.h
 var testlibFile []byte
 
+// TestlibHeader returns the bundled testlib.h, which is also made available to validators
+func TestlibHeader() []byte {
+	return testlibFile
+}
+
 type customCheckerInput struct {
 	c *customChecker
 
@@ -41,6 +47,9 @@ type customCheckerInput struct {
 type checkerResult struct {
 	Percentage decimal.Decimal
 	Output     string
+
+	// Objective is set if the checker reported a raw objective value instead of a percentage
+	Objective *decimal.Decimal
 }
 
 // note that customChecker should not be used between submissions
@@ -89,7 +98,8 @@ func (c *customChecker) Prepare(ctx context.Context) (string, error) {
 		}, HeaderFiles: map[string][]byte{
 			"/box/testlib.h": testlibFile,
 		},
-		Lang: eval.GetLangByFilename(c.filename),
+		Lang:       eval.GetLangByFilename(c.filename),
+		ExtraFlags: strings.Fields(c.pb.CheckerCompileFlags),
 	}, c.Logger)
 	if err != nil {
 		return "Couldn't compile checker", err
@@ -104,7 +114,7 @@ func (c *customChecker) Prepare(ctx context.Context) (string, error) {
 	return "", nil
 }
 
-func (c *customChecker) RunChecker(ctx context.Context, subtestID int, testID int) (string, decimal.Decimal) {
+func (c *customChecker) RunChecker(ctx context.Context, subtestID int, testID int) (string, decimal.Decimal, *decimal.Decimal) {
 	checkerPrepareMu.RLock()
 	defer checkerPrepareMu.RUnlock()
 	var out checkerResult
@@ -121,12 +131,12 @@ func (c *customChecker) RunChecker(ctx context.Context, subtestID int, testID in
 		testID:    testID,
 	}, slog.Default())
 	if err != nil || resp == nil {
-		return ErrOut, decimal.Zero
+		return ErrOut, decimal.Zero, nil
 	}
 
 	out = *resp
 
-	return out.Output, out.Percentage
+	return out.Output, out.Percentage, out.Objective
 }
 
 func (c *customChecker) Cleanup(_ context.Context) error {
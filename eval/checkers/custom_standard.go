@@ -42,12 +42,22 @@ func standardCheckerTask(ctx context.Context, mgr eval.BoxScheduler, job *custom
 		stderr = []byte{}
 	}
 
-	floatScore, err := strconv.ParseFloat(strings.TrimSpace(string(stdout)), 64)
-	if err != nil || math.IsInf(floatScore, 0) || math.IsNaN(floatScore) {
-		rez.Output = "Invalid checker score"
-		return rez, nil
+	// Optimization problems may report the raw objective value as "objective <value>"
+	if val, ok := strings.CutPrefix(strings.TrimSpace(string(stdout)), "objective"); ok {
+		objective, err := decimal.NewFromString(strings.TrimSpace(val))
+		if err != nil {
+			rez.Output = "Invalid checker objective"
+			return rez, nil
+		}
+		rez.Objective = &objective
+	} else {
+		floatScore, err := strconv.ParseFloat(strings.TrimSpace(string(stdout)), 64)
+		if err != nil || math.IsInf(floatScore, 0) || math.IsNaN(floatScore) {
+			rez.Output = "Invalid checker score"
+			return rez, nil
+		}
+		rez.Percentage = decimal.NewFromFloat(floatScore).Shift(2)
 	}
-	rez.Percentage = decimal.NewFromFloat(floatScore).Shift(2)
 
 	rez.Output = strings.TrimSpace(string(stderr))
 	if rez.Output == "" {
@@ -26,45 +26,45 @@ func (d *DiffChecker) Prepare(_ context.Context) (string, error) { return "", ni
 
 func (d *DiffChecker) Cleanup(_ context.Context) error { return nil }
 
-func (d *DiffChecker) RunChecker(ctx context.Context, subtestID int, testID int) (string, decimal.Decimal) {
+func (d *DiffChecker) RunChecker(ctx context.Context, subtestID int, testID int) (string, decimal.Decimal, *decimal.Decimal) {
 	tf, err := os.CreateTemp("", "prog-out-*")
 	if err != nil {
-		return ErrOut, decimal.Zero
+		return ErrOut, decimal.Zero, nil
 	}
 	defer tf.Close()
 	defer os.Remove(tf.Name())
 
 	cf, err := os.CreateTemp("", "correct-out-*")
 	if err != nil {
-		return ErrOut, decimal.Zero
+		return ErrOut, decimal.Zero, nil
 	}
 	defer cf.Close()
 	defer os.Remove(cf.Name())
 
 	if err := redirBucketFile(tf, datastore.GetBucket(datastore.BucketTypeSubtests), strconv.Itoa(subtestID)); err != nil {
-		return ErrOut, decimal.Zero
+		return ErrOut, decimal.Zero, nil
 	}
 	if err := redirBucketFile(cf, datastore.GetBucket(datastore.BucketTypeTests), strconv.Itoa(testID)+".out"); err != nil {
-		return ErrOut, decimal.Zero
+		return ErrOut, decimal.Zero, nil
 	}
 
 	if err := exec.CommandContext(ctx, "diff", "-qBbEa", tf.Name(), cf.Name()).Run(); err != nil {
 		var exitErr *exec.ExitError
 		if errors.As(err, &exitErr) {
 			if exitErr.ExitCode() == 0 {
-				return CorrectOut, decimal.NewFromInt(100)
+				return CorrectOut, decimal.NewFromInt(100), nil
 			}
 
-			return WrongOut, decimal.Zero
+			return WrongOut, decimal.Zero, nil
 		}
 
-		return WrongOut, decimal.Zero
+		return WrongOut, decimal.Zero, nil
 	}
 
-	return CorrectOut, decimal.NewFromInt(100)
+	return CorrectOut, decimal.NewFromInt(100), nil
 }
 
-func redirBucketFile(w io.Writer, bucket *datastore.Bucket, filename string) error {
+func redirBucketFile(w io.Writer, bucket datastore.Bucket, filename string) error {
 	f, err := bucket.Reader(filename)
 	if err != nil {
 		return err
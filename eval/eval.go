@@ -21,6 +21,9 @@ type Sandbox interface {
 	// SaveFile reads contents of path from sandbox and saves them in the given bucket by calling WriteFile
 	SaveFile(path string, bucket Bucket, filename string, mode fs.FileMode) error
 	WriteFile(path string, r io.Reader, mode fs.FileMode) error
+	// LinkFile makes the file at src (on the host) available at path inside the sandbox, hard linking it if possible.
+	// The file must not be modified afterwards, since it may be shared between sandboxes.
+	LinkFile(path string, src string) error
 	// RemoveFile(path string) error
 	FileExists(path string) bool
 
@@ -52,6 +55,8 @@ type RunConfig struct {
 	StderrPath string
 
 	MemoryLimit int
+	// StackLimit is in kilobytes. If 0, the sandbox's default is used
+	StackLimit int
 
 	TimeLimit     float64
 	WallTimeLimit float64
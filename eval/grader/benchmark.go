@@ -0,0 +1,115 @@
+package grader
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/datastore"
+	"github.com/KiloProjects/kilonova/eval/tasks"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// BenchmarkSubmission compiles the submission and runs it the given number of times on all of the problem's tests, within the given time limit.
+// Tests with their own time limit are run with it scaled by the same factor, and their times are reported relative to the problem's time limit.
+// Outputs are not checked, only the running time is of interest. Nothing about the submission is updated.
+func (h *Handler) BenchmarkSubmission(ctx context.Context, pb *kilonova.Problem, sub *kilonova.Submission, runs int, timeLimit float64) (*kilonova.SolutionTiming, *kilonova.StatusError) {
+	if h.runner == nil {
+		return nil, kilonova.Statusf(503, "Grader is not running")
+	}
+
+	settings, err := h.base.ProblemSettings(ctx, pb.ID)
+	if err != nil {
+		return nil, kilonova.WrapError(err, "Couldn't get problem settings")
+	}
+	tests, err := h.base.Tests(ctx, pb.ID)
+	if err != nil {
+		return nil, err
+	}
+	speedFactor := h.base.GraderSpeedFactor()
+	setups, err := getTestSetups(ctx, h.base, pb, speedFactor)
+	if err != nil {
+		return nil, err
+	}
+	// The given time limit is relative to the problem's time limit, scaled to the grader's speed
+	var factor float64
+	if speedFactor != nil {
+		factor = *speedFactor
+	}
+	problemLimit := pb.ScaledTimeLimit(factor)
+	limitFactor := timeLimit / problemLimit
+
+	req, err := genSubCompileRequest(ctx, h.base, sub, pb, settings)
+	if err != nil {
+		return nil, err
+	}
+	req.OutputName = "bench-" + uuid.NewString() + ".bin"
+
+	runner, err1 := h.runner.SubRunner(ctx, 1)
+	if err1 != nil {
+		return nil, kilonova.WrapError(err1, "Couldn't acquire runner")
+	}
+	defer runner.Close(ctx)
+
+	defer func() {
+		if err := datastore.GetBucket(datastore.BucketTypeCompiles).RemoveFile(req.OutputName); err != nil {
+			zap.S().Warn("Couldn't remove benchmark artifact: ", err)
+		}
+	}()
+
+	graderLogger.Info("Benchmarking submission", slog.Int("id", sub.ID), slog.Int("runs", runs))
+	compileResp, err1 := tasks.CompileTask(ctx, runner, req, graderLogger)
+	if err1 != nil {
+		return nil, kilonova.WrapError(err1, "Error from eval")
+	}
+
+	timing := &kilonova.SolutionTiming{
+		SubmissionID: sub.ID,
+		Language:     sub.Language,
+		Measured:     true,
+	}
+	if !compileResp.Success {
+		timing.Verdict = "translate:compile_error"
+		return timing, nil
+	}
+
+runs:
+	for range runs {
+		for _, test := range tests {
+			setup := setups.get(pb, test.ID)
+			execReq := &tasks.ExecRequest{
+				SubID:         sub.ID,
+				Filename:      pb.TestName,
+				MemoryLimit:   setup.memoryLimit,
+				StackLimit:    pb.StackLimit,
+				TimeLimit:     setup.timeLimit * limitFactor,
+				Lang:          sub.Language,
+				TestID:        test.ID,
+				InputFiles:    setup.inputFiles,
+				Executable:    req.OutputName,
+				DiscardOutput: true,
+			}
+			if pb.ConsoleInput {
+				execReq.Filename = "stdin"
+			}
+			resp, err := tasks.ExecuteTask(ctx, runner, int64(setup.memoryLimit), execReq, graderLogger)
+			if err != nil {
+				return nil, kilonova.WrapError(err, "Couldn't execute test")
+			}
+			if resp.Time > execReq.TimeLimit || strings.Contains(resp.Comments, "timeout") {
+				// Once a solution times out there is nothing more to measure
+				timing.TimedOut = true
+				timing.MaxTime = timeLimit
+				break runs
+			}
+			timing.MaxTime = max(timing.MaxTime, resp.Time*problemLimit/setup.timeLimit)
+			if resp.Comments != "" && timing.Verdict == "" {
+				timing.Verdict = resp.Comments
+			}
+		}
+	}
+
+	return timing, nil
+}
@@ -0,0 +1,46 @@
+package grader
+
+import (
+	"log/slog"
+	"os"
+
+	"github.com/KiloProjects/kilonova/eval"
+	"github.com/KiloProjects/kilonova/eval/tasks"
+	"github.com/KiloProjects/kilonova/internal/config"
+	"go.uber.org/zap"
+)
+
+var (
+	CalibrationBaseline = config.GenFlag[int]("feature.grader.calibration_baseline", 500, "Running time (in milliseconds) of the calibration benchmark on the reference grader")
+)
+
+// SpeedFactor returns how many times slower this grader is than the reference grader, or 0 if calibration failed
+func (h *Handler) SpeedFactor() float64 {
+	return h.speedFactor
+}
+
+// calibrate runs the calibration benchmark and records the speed factor of this host.
+// If it fails, time limits are applied as they are.
+func (h *Handler) calibrate(runner eval.BoxScheduler) {
+	baseline := float64(CalibrationBaseline.Value()) / 1000
+	if baseline <= 0 {
+		return
+	}
+	benchTime, err := tasks.CalibrationTask(h.ctx, runner, graderLogger)
+	if err != nil {
+		zap.S().Warn("Couldn't calibrate grader, time limits will not be scaled: ", err)
+		return
+	}
+	h.speedFactor = benchTime / baseline
+	zap.S().Infof("Grader speed factor: %.3f", h.speedFactor)
+	graderLogger.Info("Calibrated grader", slog.Float64("time", benchTime), slog.Float64("speed_factor", h.speedFactor))
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		zap.S().Warn("Couldn't get hostname: ", err)
+		return
+	}
+	if err := h.base.UpdateGraderHost(h.ctx, hostname, h.speedFactor); err != nil {
+		zap.S().Warn(err)
+	}
+}
@@ -0,0 +1,72 @@
+package grader
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/internal/config"
+	"go.uber.org/zap"
+)
+
+var SubmissionTimeBudget = config.GenFlag[int]("behavior.grader.submission_time_budget", 0, "Maximum wall-clock time (in seconds) spent evaluating a submission, after which the evaluation is cancelled. 0 means no limit")
+
+var (
+	errEvalCancelled  = errors.New("evaluation cancelled")
+	errEvalTimeBudget = errors.New("evaluation time budget exceeded")
+)
+
+// submissionContext returns the context of the submission's evaluation, which is cancelled
+// when the time budget runs out or when the evaluation is cancelled from outside the grader.
+// The returned function must be called once the evaluation is done.
+func (h *Handler) submissionContext(subID int) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(h.ctx)
+	stop := context.CancelFunc(func() {})
+	var evalCtx context.Context = ctx
+	if budget := SubmissionTimeBudget.Value(); budget > 0 {
+		evalCtx, stop = context.WithTimeoutCause(ctx, time.Duration(budget)*time.Second, errEvalTimeBudget)
+	}
+
+	h.runningMu.Lock()
+	h.running[subID] = cancel
+	h.runningMu.Unlock()
+
+	return evalCtx, func() {
+		h.runningMu.Lock()
+		delete(h.running, subID)
+		h.runningMu.Unlock()
+		stop()
+		cancel(nil)
+	}
+}
+
+// CancelSubmission kills the boxes of the submission, if it's being evaluated by this grader
+func (h *Handler) CancelSubmission(subID int) bool {
+	h.runningMu.Lock()
+	defer h.runningMu.Unlock()
+	cancel, ok := h.running[subID]
+	if ok {
+		cancel(errEvalCancelled)
+	}
+	return ok
+}
+
+// finishCancelledSubmission marks the submission as cancelled if its evaluation was stopped.
+// Evaluations interrupted by the grader shutting down are left as is, to be picked up again.
+func (h *Handler) finishCancelledSubmission(ctx context.Context, sub *kilonova.Submission) {
+	var verdict string
+	switch context.Cause(ctx) {
+	case errEvalCancelled:
+		verdict = "translate:cancelled"
+	case errEvalTimeBudget:
+		verdict = "translate:eval_time_budget"
+	default:
+		return
+	}
+	graderLogger.Info("Submission evaluation stopped", slog.Int("id", sub.ID), slog.Any("reason", context.Cause(ctx)))
+	if err := h.base.FinishCancelledSubmission(h.ctx, sub.ID, verdict); err != nil {
+		zap.S().Warn("Couldn't mark submission as cancelled: ", err)
+	}
+}
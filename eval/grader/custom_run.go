@@ -0,0 +1,109 @@
+package grader
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/datastore"
+	"github.com/KiloProjects/kilonova/eval"
+	"github.com/KiloProjects/kilonova/eval/tasks"
+	"github.com/KiloProjects/kilonova/sudoapi"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const pendingCustomRunsLimit = 4
+
+// scheduleCustomRuns runs the queued custom runs, each in its own box
+func (h *Handler) scheduleCustomRuns(runner eval.BoxScheduler) {
+	jobs := h.base.ClaimPendingCustomRuns(pendingCustomRunsLimit)
+	if len(jobs) == 0 {
+		return
+	}
+	graderLogger.Info("Found pending custom runs", slog.Int("count", len(jobs)))
+	for i, job := range jobs {
+		r, err := runner.SubRunner(h.ctx, 1)
+		if err != nil {
+			zap.S().Warn(err)
+			for _, job := range jobs[i:] {
+				h.base.FinishCustomRun(job, nil, kilonova.WrapError(err, "Couldn't acquire runner"))
+			}
+			return
+		}
+		go func(job *sudoapi.CustomRunJob, r eval.BoxScheduler) {
+			defer r.Close(h.ctx)
+			rez, err := h.customRun(h.ctx, r, job.Problem, job.Language, job.Code, job.Files, job.Input)
+			h.base.FinishCustomRun(job, rez, err)
+		}(job, r)
+	}
+}
+
+// customRun compiles the code together with the problem's graders and headers and runs it once on the given input, within the problem limits.
+// Nothing is persisted, the compiled executable is removed as soon as the run finishes.
+func (h *Handler) customRun(ctx context.Context, runner eval.BoxScheduler, pb *kilonova.Problem, lang string, code []byte, files []*kilonova.SubmissionFile, input []byte) (*kilonova.CustomRun, *kilonova.StatusError) {
+	settings, err := h.base.ProblemSettings(ctx, pb.ID)
+	if err != nil {
+		return nil, kilonova.WrapError(err, "Couldn't get problem settings")
+	}
+
+	req, err := genCompileRequest(ctx, h.base, 0, lang, code, files, pb, settings)
+	if err != nil {
+		return nil, err
+	}
+	req.OutputName = "run-" + uuid.NewString() + ".bin"
+
+	defer func() {
+		if err := datastore.GetBucket(datastore.BucketTypeCompiles).RemoveFile(req.OutputName); err != nil {
+			zap.S().Warn("Couldn't remove custom run artifact: ", err)
+		}
+	}()
+
+	graderLogger.Info("Compiling custom run", slog.Int("problem_id", pb.ID), slog.String("lang", lang))
+	compileResp, err1 := tasks.CompileTask(ctx, runner, req, graderLogger)
+	if err1 != nil {
+		return nil, kilonova.WrapError(err1, "Error from eval")
+	}
+
+	rez := &kilonova.CustomRun{
+		CompileError:   !compileResp.Success,
+		CompileMessage: compileResp.Output,
+	}
+	if !compileResp.Success {
+		rez.Verdict = "translate:compile_error"
+		return rez, nil
+	}
+
+	timeLimit := pb.ScaledTimeLimit(h.speedFactor)
+	runReq := &tasks.CustomRunRequest{
+		Executable:  req.OutputName,
+		Filename:    pb.TestName,
+		Input:       input,
+		MemoryLimit: pb.MemoryLimit,
+		StackLimit:  pb.StackLimit,
+		TimeLimit:   timeLimit,
+		Lang:        lang,
+	}
+	if pb.ConsoleInput {
+		runReq.Filename = "stdin"
+	}
+
+	resp, err1 := tasks.CustomRunTask(ctx, runner, int64(pb.MemoryLimit), runReq, graderLogger)
+	if err1 != nil {
+		return nil, kilonova.WrapError(err1, "Couldn't execute custom run")
+	}
+
+	// Make sure TLEs are fully handled
+	if resp.Time > timeLimit {
+		resp.Time = timeLimit
+		resp.Comments = "translate:timeout"
+	}
+
+	rez.Stdout = resp.Stdout
+	rez.Stderr = resp.Stderr
+	rez.Time = resp.Time
+	rez.Memory = resp.Memory
+	rez.ExitCode = resp.ExitStatus
+	rez.Verdict = resp.Comments
+	return rez, nil
+}
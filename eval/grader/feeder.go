@@ -37,6 +37,14 @@ type Handler struct {
 	wakeChan chan struct{}
 
 	runner eval.BoxScheduler
+	// sandboxVersion is the version of the sandbox used by runner
+	sandboxVersion string
+
+	speedFactor float64
+
+	// running holds the cancel functions of the submissions being evaluated
+	runningMu sync.Mutex
+	running   map[int]context.CancelCauseFunc
 }
 
 func NewHandler(ctx context.Context, base *sudoapi.BaseAPI) (*Handler, *kilonova.StatusError) {
@@ -59,7 +67,13 @@ func NewHandler(ctx context.Context, base *sudoapi.BaseAPI) (*Handler, *kilonova
 		}))
 	})
 
-	return &Handler{ctx, ch, base, wCh, nil}, nil
+	return &Handler{
+		ctx:      ctx,
+		sChan:    ch,
+		base:     base,
+		wakeChan: wCh,
+		running:  make(map[int]context.CancelCauseFunc),
+	}, nil
 }
 
 func (h *Handler) Wake() {
@@ -73,6 +87,8 @@ func (h *Handler) LanguageVersions(ctx context.Context) map[string]string {
 	return h.runner.LanguageVersions(ctx)
 }
 
+var ICPCParallelTests = config.GenFlag[int]("behavior.grader.icpc_parallel_tests", 4, "Number of tests of an ICPC submission evaluated in parallel. Set to 1 to evaluate them one after another")
+
 func (h *Handler) ScheduleSubmission(runner eval.BoxScheduler, sub *kilonova.Submission) error {
 	var subRunner eval.BoxScheduler
 	if sub.SubmissionType == kilonova.EvalTypeClassic {
@@ -83,7 +99,9 @@ func (h *Handler) ScheduleSubmission(runner eval.BoxScheduler, sub *kilonova.Sub
 			subRunner = r
 		}
 	} else {
-		r, err := runner.SubRunner(h.ctx, 1)
+		// ICPC submissions run a few tests ahead of time, see handleICPCSubmission
+		numConc := min(max(int64(ICPCParallelTests.Value()), 1), runner.NumConcurrent())
+		r, err := runner.SubRunner(h.ctx, numConc)
 		if err != nil {
 			return err
 		} else {
@@ -95,13 +113,29 @@ func (h *Handler) ScheduleSubmission(runner eval.BoxScheduler, sub *kilonova.Sub
 	}
 	go func(sub *kilonova.Submission, r eval.BoxScheduler) {
 		defer r.Close(h.ctx)
-		if err := executeSubmission(h.ctx, h.base, r, sub); err != nil {
+		ctx, done := h.submissionContext(sub.ID)
+		defer done()
+		h.recordToolchain(ctx, sub)
+		if err := executeSubmission(ctx, h.base, r, sub); err != nil && ctx.Err() == nil {
 			zap.S().Warn("Couldn't run submission: ", err)
 		}
+		h.finishCancelledSubmission(ctx, sub)
 	}(sub, subRunner)
 	return nil
 }
 
+// recordToolchain saves the language and sandbox versions the submission is evaluated with.
+// They are also kept on sub, so the evaluated subtests are marked with them
+func (h *Handler) recordToolchain(ctx context.Context, sub *kilonova.Submission) {
+	sub.LanguageVersion, sub.SandboxVersion = nil, &h.sandboxVersion
+	if ver, ok := h.LanguageVersions(ctx)[sub.Language]; ok {
+		sub.LanguageVersion = &ver
+	}
+	if err := h.base.RecordSubmissionToolchain(ctx, sub.ID, sub.LanguageVersion, h.sandboxVersion); err != nil {
+		zap.S().Warn("Couldn't save submission toolchain: ", err)
+	}
+}
+
 func (h *Handler) handle(runner eval.BoxScheduler) error {
 	for {
 		select {
@@ -157,6 +191,9 @@ func (h *Handler) handle(runner eval.BoxScheduler) error {
 				}
 			}
 
+			h.scheduleHacks(runner)
+			h.scheduleCustomRuns(runner)
+
 			if rewake {
 				// Try to instantly continue working on the queue
 				h.Wake()
@@ -166,12 +203,15 @@ func (h *Handler) handle(runner eval.BoxScheduler) error {
 }
 
 func (h *Handler) Start() error {
-	runner, err := getAppropriateRunner()
+	runner, sandboxVersion, err := getAppropriateRunner()
 	if err != nil {
 		return err
 	}
 
-	h.runner = runner
+	h.runner, h.sandboxVersion = runner, sandboxVersion
+	h.calibrate(runner)
+	// Load the language versions before any submission is evaluated, since they are recorded for each one
+	runner.LanguageVersions(h.ctx)
 	h.base.RegisterGrader(h) // To allow waking from outside grader
 
 	go func() {
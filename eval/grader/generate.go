@@ -0,0 +1,155 @@
+package grader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"strings"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/datastore"
+	"github.com/KiloProjects/kilonova/eval"
+	"github.com/KiloProjects/kilonova/eval/checkers"
+	"github.com/KiloProjects/kilonova/eval/tasks"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	// generatorTimeLimit (seconds) and generatorMemoryLimit (kbytes) apply to every generator and solution run
+	generatorTimeLimit   = 10
+	generatorMemoryLimit = 512 * 1024
+)
+
+// GenerateTests compiles the generators and the solution, then fills in the missing inputs and outputs of the tests.
+// Every input and output is written to the tests bucket as soon as it is produced, instead of being kept in memory.
+// Programs are compiled only once, the first failure aborts the whole generation.
+func (h *Handler) GenerateTests(ctx context.Context, gen *kilonova.TestGeneration) *kilonova.StatusError {
+	if h.runner == nil {
+		return kilonova.Statusf(503, "Grader is not running")
+	}
+
+	runner, err := h.runner.SubRunner(ctx, 1)
+	if err != nil {
+		return kilonova.WrapError(err, "Couldn't acquire runner")
+	}
+	defer runner.Close(ctx)
+
+	executables := make(map[string]string)
+	defer func() {
+		for _, name := range executables {
+			if err := datastore.GetBucket(datastore.BucketTypeCompiles).RemoveFile(name); err != nil {
+				zap.S().Warn("Couldn't remove generator artifact: ", err)
+			}
+		}
+	}()
+	compile := func(name string, prog *kilonova.GeneratorProgram) *kilonova.StatusError {
+		if _, ok := executables[name]; ok {
+			return nil
+		}
+		lang, ok := eval.Langs[prog.Lang]
+		if !ok {
+			return kilonova.Statusf(400, "Unknown language for %q", name)
+		}
+		outName := "gen-" + uuid.NewString() + ".bin"
+		graderLogger.Info("Compiling generator", slog.String("name", name), slog.String("lang", prog.Lang))
+		resp, err := tasks.CompileTask(ctx, runner, &tasks.CompileRequest{
+			Lang:       prog.Lang,
+			CodeFiles:  map[string][]byte{lang.SourceName: prog.Code},
+			OutputName: outName,
+			HeaderFiles: map[string][]byte{
+				"/box/testlib.h": checkers.TestlibHeader(),
+			},
+		}, graderLogger)
+		if err != nil {
+			return kilonova.WrapError(err, "Error from eval")
+		}
+		executables[name] = outName
+		if !resp.Success {
+			return kilonova.Statusf(400, "Couldn't compile %q: %s", name, resp.Output)
+		}
+		return nil
+	}
+	tests := datastore.GetBucket(datastore.BucketTypeTests)
+	// run writes the whole output of the program to a new file in the tests bucket, returning its name
+	run := func(name string, lang string, req *tasks.CustomRunRequest, ext string) (string, *kilonova.StatusError) {
+		req.Executable = executables[name]
+		req.Lang = lang
+		req.TimeLimit = generatorTimeLimit
+		req.MemoryLimit = generatorMemoryLimit
+		outName := "gen-" + uuid.NewString() + ext
+		req.OutputBucketFile = &eval.BucketFile{Bucket: datastore.BucketTypeTests, Filename: outName, Mode: 0644}
+		resp, err := tasks.CustomRunTask(ctx, runner, generatorMemoryLimit, req, graderLogger)
+		if err != nil {
+			return "", kilonova.WrapError(err, "Couldn't run program")
+		}
+		if resp.Comments != "translate:success" || resp.ExitStatus != 0 {
+			return outName, kilonova.Statusf(400, "%q failed (%s, exit code %d): %s", name, resp.Comments, resp.ExitStatus, resp.Stderr)
+		}
+		// The box only saves the files that exist, but an empty output is still valid
+		if _, err := tests.Stat(outName); errors.Is(err, fs.ErrNotExist) {
+			if err := tests.WriteFile(outName, bytes.NewReader(nil), 0644); err != nil {
+				return "", kilonova.WrapError(err, "Couldn't save empty output")
+			}
+		}
+		return outName, nil
+	}
+
+	for i, test := range gen.Tests {
+		if test.Input != nil {
+			// The solution reads the given input from the tests bucket as well
+			f, err := test.Input()
+			if err != nil {
+				return kilonova.WrapError(err, fmt.Sprintf("Couldn't open input of test #%d", i+1))
+			}
+			test.InputFile = "gen-" + uuid.NewString() + ".in"
+			err = tests.WriteFile(test.InputFile, f, 0644)
+			f.Close()
+			if err != nil {
+				return kilonova.WrapError(err, fmt.Sprintf("Couldn't save input of test #%d", i+1))
+			}
+			continue
+		}
+		if len(test.Command) == 0 {
+			return kilonova.Statusf(400, "Test #%d has neither an input nor a generator command", i+1)
+		}
+		prog, ok := gen.Generators[test.Command[0]]
+		if !ok {
+			return kilonova.Statusf(400, "Unknown generator %q", test.Command[0])
+		}
+		if err := compile(test.Command[0], prog); err != nil {
+			return err
+		}
+		input, err := run(test.Command[0], prog.Lang, &tasks.CustomRunRequest{
+			Filename: "stdin",
+			Args:     test.Command[1:],
+		}, ".in")
+		test.InputFile = input
+		if err != nil {
+			return kilonova.WrapError(err, fmt.Sprintf("Couldn't generate input of test #%d (%s)", i+1, strings.Join(test.Command, " ")))
+		}
+	}
+
+	for i, test := range gen.Tests {
+		if gen.Solution == nil {
+			return kilonova.Statusf(400, "There is no solution to generate the output of test #%d", i+1)
+		}
+		// Generators and the solution share the executables map, so the solution's key is not a valid generator name
+		if err := compile(" solution", gen.Solution); err != nil {
+			return err
+		}
+		output, err := run(" solution", gen.Solution.Lang, &tasks.CustomRunRequest{
+			Filename:        gen.SolutionFilename,
+			InputBucketFile: &eval.BucketFile{Bucket: datastore.BucketTypeTests, Filename: test.InputFile, Mode: 0666},
+		}, ".out")
+		test.OutputFile = output
+		if err != nil {
+			return kilonova.WrapError(err, fmt.Sprintf("Couldn't generate output of test #%d", i+1))
+		}
+	}
+
+	return nil
+}
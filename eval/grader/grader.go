@@ -2,7 +2,10 @@ package grader
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"log/slog"
 	"path"
 	"slices"
@@ -30,19 +33,55 @@ var (
 )
 
 func genSubCompileRequest(ctx context.Context, base *sudoapi.BaseAPI, sub *kilonova.Submission, pb *kilonova.Problem, settings *kilonova.ProblemEvalSettings) (*tasks.CompileRequest, *kilonova.StatusError) {
+	subCode, err := base.RawSubmissionCode(ctx, sub.ID)
+	if err != nil {
+		return nil, err
+	}
+	var files []*kilonova.SubmissionFile
+	if sub.NumFiles > 0 {
+		files, err = base.SubmissionFiles(ctx, sub.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return genCompileRequest(ctx, base, sub.ID, sub.Language, subCode, files, pb, settings)
+}
+
+// genCompileRequest builds the compile request for the given code and additional files, including the problem's graders and headers
+func genCompileRequest(ctx context.Context, base *sudoapi.BaseAPI, id int, language string, subCode []byte, files []*kilonova.SubmissionFile, pb *kilonova.Problem, settings *kilonova.ProblemEvalSettings) (*tasks.CompileRequest, *kilonova.StatusError) {
 	req := &tasks.CompileRequest{
-		ID:          sub.ID,
-		Lang:        sub.Language,
+		ID:          id,
+		Lang:        language,
 		CodeFiles:   make(map[string][]byte),
 		HeaderFiles: make(map[string][]byte),
+		ExtraFlags:  strings.Fields(pb.CompileFlags),
 	}
 	atts, err := base.ProblemAttachments(ctx, pb.ID)
 	if err != nil {
 		return nil, err
 	}
+	if len(settings.GraderFiles) > 0 && language == "pascal" {
+		// In interactive problems, include the source code as header
+		// Apparently the fpc compiler allows only one file as parameter, this should solve it
+		req.HeaderFiles[eval.Langs[language].SourceName] = subCode
+	} else {
+		// But by default it should be a code file
+		req.CodeFiles[eval.Langs[language].SourceName] = subCode
+	}
+	// Additional files are compiled only if they are sources of the same language, the others are just made available
+	for _, file := range files {
+		fPath := path.Join(path.Dir(eval.Langs[language].SourceName), file.Name)
+		if slices.Contains(eval.Langs[language].Extensions, path.Ext(file.Name)) {
+			req.CodeFiles[fPath] = file.Data
+		} else {
+			req.HeaderFiles[fPath] = file.Data
+		}
+	}
+	// The problem's graders and headers are added last, so submission files with the same name can't replace them
+	var hasGrader bool
 	for _, codeFile := range settings.GraderFiles {
 		lang := eval.GetLangByFilename(codeFile)
-		if lang != sub.Language && !slices.Contains(eval.Langs[sub.Language].SimilarLangs, lang) {
+		if lang != language && !slices.Contains(eval.Langs[language].SimilarLangs, lang) {
 			continue
 		}
 		for _, att := range atts {
@@ -54,21 +93,11 @@ func genSubCompileRequest(ctx context.Context, base *sudoapi.BaseAPI, sub *kilon
 				}
 				name := strings.Replace(path.Base(att.Name), path.Ext(att.Name), eval.Langs[lang].Extensions[0], 1)
 				req.CodeFiles[path.Join("/box", name)] = data
+				delete(req.HeaderFiles, path.Join("/box", name))
+				hasGrader = true
 			}
 		}
 	}
-	subCode, err := base.RawSubmissionCode(ctx, sub.ID)
-	if err != nil {
-		return nil, err
-	}
-	if len(settings.GraderFiles) > 0 && sub.Language == "pascal" {
-		// In interactive problems, include the source code as header
-		// Apparently the fpc compiler allows only one file as parameter, this should solve it
-		req.HeaderFiles[eval.Langs[sub.Language].SourceName] = subCode
-	} else {
-		// But by default it should be a code file
-		req.CodeFiles[eval.Langs[sub.Language].SourceName] = subCode
-	}
 	for _, headerFile := range settings.HeaderFiles {
 		for _, att := range atts {
 			if att.Name == headerFile {
@@ -78,9 +107,14 @@ func genSubCompileRequest(ctx context.Context, base *sudoapi.BaseAPI, sub *kilon
 					return nil, kilonova.Statusf(500, "Couldn't get grader data")
 				}
 				req.HeaderFiles[path.Join("/box", path.Base(att.Name))] = data
+				delete(req.CodeFiles, path.Join("/box", path.Base(att.Name)))
 			}
 		}
 	}
+	// The grader is built in the same compiler invocation, so its flags come after the problem's ones to override them
+	if hasGrader {
+		req.ExtraFlags = append(req.ExtraFlags, strings.Fields(pb.GraderCompileFlags)...)
+	}
 	return req, nil
 }
 
@@ -90,6 +124,11 @@ func executeSubmission(ctx context.Context, base *sudoapi.BaseAPI, runner eval.B
 		// In case anything ever happens, make sure it is at least marked as finished
 		if err := base.UpdateSubmission(ctx, sub.ID, kilonova.SubmissionUpdate{Status: kilonova.StatusFinished}); err != nil {
 			zap.S().Warn("Couldn't finish submission:", err)
+			return
+		}
+		// The previous result is no longer needed, since the evaluation wasn't cancelled
+		if err := base.DeleteSubmissionSnapshot(ctx, sub.ID); err != nil {
+			zap.S().Warn("Couldn't remove submission snapshot:", err)
 		}
 	}()
 
@@ -110,7 +149,51 @@ func executeSubmission(ctx context.Context, base *sudoapi.BaseAPI, runner eval.B
 		return kilonova.WrapError(err1, "Couldn't get problem settings")
 	}
 
-	if err := compileSubmission(ctx, base, runner, sub, problem, problemSettings); err != nil {
+	// Time limits are scaled to the speed of this grader, relative to the one they were set on
+	speedFactor := base.GraderSpeedFactor()
+	setups, err1 := getTestSetups(ctx, base, problem, speedFactor)
+	if err1 != nil {
+		return kilonova.WrapError(err1, "Couldn't get test limits")
+	}
+	if speedFactor != nil {
+		problem.TimeLimit = problem.ScaledTimeLimit(*speedFactor)
+		if err := base.UpdateSubmission(ctx, sub.ID, kilonova.SubmissionUpdate{SpeedFactor: speedFactor}); err != nil {
+			zap.S().Warn("Couldn't save speed factor: ", err)
+		}
+	}
+
+	subTests, err1 := base.SubTests(ctx, sub.ID)
+	if err1 != nil {
+		internalErr := "test_verdict.internal_error"
+		if err := base.UpdateSubmission(ctx, sub.ID, kilonova.SubmissionUpdate{
+			Status: kilonova.StatusFinished, Score: &problem.DefaultPoints,
+			ChangeVerdict: true, ICPCVerdict: &internalErr,
+		}); err != nil {
+			return kilonova.WrapError(err, "Could not update submission after subtest fetch fail")
+		}
+		return kilonova.WrapError(err1, "Could not fetch subtests")
+	}
+
+	// Selective reevaluations keep the results of the subtests whose tests didn't change, so only the rest are run
+	pendingTests := slices.DeleteFunc(slices.Clone(subTests), func(st *kilonova.SubTest) bool { return st.Done })
+	partial := sub.SubmissionType == kilonova.EvalTypeClassic && len(pendingTests) < len(subTests)
+	if partial {
+		subTests = pendingTests
+	}
+
+	req, err1 := genSubCompileRequest(ctx, base, sub, problem, problemSettings)
+	if err1 != nil {
+		zap.S().Warn(err1)
+		return kilonova.WrapError(err1, "Couldn't generate compilation request")
+	}
+	// The kept binary is only reused if it was built from the same sources, flags and compiler version
+	binaryName, keyName := fmt.Sprintf("%d.bin", sub.ID), fmt.Sprintf("%d.key", sub.ID)
+	buildKey := compileKey(req, runner.LanguageVersions(ctx)[sub.Language])
+	// The compiles bucket evicts files when it gets full, the binary must stay until all tests are run
+	defer datastore.Pin(datastore.GetBucket(datastore.BucketTypeCompiles), binaryName)()
+	if partial && KeepBinaries.Value() && cachedBuildKey(binaryName, keyName) == buildKey {
+		graderLogger.Info("Reusing compiled submission", slog.Int("id", sub.ID), slog.Int("subtests", len(subTests)))
+	} else if err := compileSubmission(ctx, base, runner, sub, problem, req); err != nil {
 		if err.Code != 204 { // Skip
 			zap.S().Warn(err)
 			return err
@@ -137,28 +220,16 @@ func executeSubmission(ctx context.Context, base *sudoapi.BaseAPI, runner eval.B
 		return kilonova.WrapError(err, "Could not prepare checker")
 	}
 
-	subTests, err1 := base.SubTests(ctx, sub.ID)
-	if err1 != nil {
-		internalErr := "test_verdict.internal_error"
-		if err := base.UpdateSubmission(ctx, sub.ID, kilonova.SubmissionUpdate{
-			Status: kilonova.StatusFinished, Score: &problem.DefaultPoints,
-			ChangeVerdict: true, ICPCVerdict: &internalErr,
-		}); err != nil {
-			return kilonova.WrapError(err, "Could not update submission after subtest fetch fail")
-		}
-		return kilonova.WrapError(err1, "Could not fetch subtests")
-	}
-
 	// TODO: This is shit.
 	// It is basically 2 implementations for ~ the same thing. It could be merged neater
 	switch sub.SubmissionType {
 	case kilonova.EvalTypeClassic:
-		if err := handleClassicSubmission(ctx, base, runner, sub, problem, checker, subTests); err != nil {
+		if err := handleClassicSubmission(ctx, base, runner, sub, problem, setups, checker, subTests); err != nil {
 			zap.S().Warn(err)
 			return err
 		}
 	case kilonova.EvalTypeICPC:
-		if err := handleICPCSubmission(ctx, base, runner, sub, problem, checker, subTests); err != nil {
+		if err := handleICPCSubmission(ctx, base, runner, sub, problem, setups, checker, subTests); err != nil {
 			zap.S().Warn(err)
 			return err
 		}
@@ -166,8 +237,15 @@ func executeSubmission(ctx context.Context, base *sudoapi.BaseAPI, runner eval.B
 		return kilonova.Statusf(500, "Invalid eval type")
 	}
 
-	if err := datastore.GetBucket(datastore.BucketTypeCompiles).RemoveFile(fmt.Sprintf("%d.bin", sub.ID)); err != nil {
-		zap.S().Warn("Couldn't remove compilation artifact: ", err)
+	compiles := datastore.GetBucket(datastore.BucketTypeCompiles)
+	if KeepBinaries.Value() {
+		if err := compiles.WriteFile(keyName, strings.NewReader(buildKey), 0644); err != nil {
+			zap.S().Warn("Couldn't save build key: ", err)
+		}
+	} else {
+		if err := compiles.RemoveFile(binaryName); err != nil {
+			zap.S().Warn("Couldn't remove compilation artifact: ", err)
+		}
 	}
 
 	if err := checker.Cleanup(ctx); err != nil {
@@ -176,8 +254,10 @@ func executeSubmission(ctx context.Context, base *sudoapi.BaseAPI, runner eval.B
 	return nil
 }
 
-func handleClassicSubmission(ctx context.Context, base *sudoapi.BaseAPI, runner eval.BoxScheduler, sub *kilonova.Submission, problem *kilonova.Problem, checker checkers.Checker, subTests []*kilonova.SubTest) *kilonova.StatusError {
+func handleClassicSubmission(ctx context.Context, base *sudoapi.BaseAPI, runner eval.BoxScheduler, sub *kilonova.Submission, problem *kilonova.Problem, setups testSetups, checker checkers.Checker, subTests []*kilonova.SubTest) *kilonova.StatusError {
 	var wg sync.WaitGroup
+	var improvedMu sync.Mutex
+	var improvedTests []int
 
 	for _, subTest := range subTests {
 		subTest := subTest
@@ -185,10 +265,15 @@ func handleClassicSubmission(ctx context.Context, base *sudoapi.BaseAPI, runner
 
 		go func() {
 			defer wg.Done()
-			_, _, err := handleSubTest(ctx, base, runner, checker, sub, problem, subTest)
+			_, _, improved, err := handleSubTest(ctx, base, runner, checker, sub, problem, setups, subTest)
 			if err != nil {
 				zap.S().Warn("Error handling subtest:", err)
 			}
+			if improved {
+				improvedMu.Lock()
+				improvedTests = append(improvedTests, *subTest.TestID)
+				improvedMu.Unlock()
+			}
 		}()
 	}
 
@@ -198,16 +283,48 @@ func handleClassicSubmission(ctx context.Context, base *sudoapi.BaseAPI, runner
 		zap.S().Warn("Couldn't score test: ", err)
 	}
 
+	scheduleRelativeRescore(ctx, base, problem, improvedTests)
 	return nil
 }
 
-func handleICPCSubmission(ctx context.Context, base *sudoapi.BaseAPI, runner eval.BoxScheduler, sub *kilonova.Submission, problem *kilonova.Problem, checker checkers.Checker, subTests []*kilonova.SubTest) *kilonova.StatusError {
+// handleICPCSubmission evaluates the tests speculatively in a sliding window, but saves their results in order.
+// This way, the progress and the verdict are the same as when running the tests one after another,
+// while idle boxes are used to run the following tests ahead of time.
+func handleICPCSubmission(ctx context.Context, base *sudoapi.BaseAPI, runner eval.BoxScheduler, sub *kilonova.Submission, problem *kilonova.Problem, setups testSetups, checker checkers.Checker, subTests []*kilonova.SubTest) *kilonova.StatusError {
 	var failed bool
+	var improvedTests []int
 	var upd kilonova.SubmissionUpdate
 	upd.Status = kilonova.StatusFinished
 
-	for _, subTest := range subTests {
+	window := max(int(runner.NumConcurrent()), 1)
+	results := make([]chan *subTestResult, len(subTests))
+	cancels := make([]context.CancelFunc, len(subTests))
+	var wg sync.WaitGroup
+	start := func(i int) {
+		if i >= len(subTests) {
+			return
+		}
+		tctx, cancel := context.WithCancel(ctx)
+		results[i], cancels[i] = make(chan *subTestResult, 1), cancel
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] <- runSubTest(tctx, runner, checker, sub, problem, setups, subTests[i])
+		}()
+	}
+	// Speculative runs must be done before the executable is removed
+	defer wg.Wait()
+
+	for i := range window {
+		start(i)
+	}
+
+	for i, subTest := range subTests {
 		if failed {
+			if cancels[i] != nil {
+				// Speculative run of a test after the failing one
+				cancels[i]()
+			}
 			if err := base.UpdateSubTest(ctx, subTest.ID, kilonova.SubTestUpdate{
 				Done: &True, Skipped: &True,
 				Verdict: &skippedVerdict,
@@ -216,20 +333,28 @@ func handleICPCSubmission(ctx context.Context, base *sudoapi.BaseAPI, runner eva
 			}
 			continue
 		}
-		score, verdict, err := handleSubTest(ctx, base, runner, checker, sub, problem, subTest)
+		rez := <-results[i]
+		cancels[i]()
+		score, verdict, improved, err := finishSubTest(ctx, base, sub, problem, subTest, rez)
 		if err != nil {
 			zap.S().Warn("Error handling subtest:", err)
+			start(i + window)
 			continue
 		}
+		if improved {
+			improvedTests = append(improvedTests, *subTest.TestID)
+		}
 		if !score.Equal(decimal.NewFromInt(100)) {
 			upd.Score = &problem.DefaultPoints
 			upd.ChangeVerdict = true
 
-			verdict = fmt.Sprintf("%s (test_verdict.test_x #%d)", strings.ReplaceAll(verdict, "translate:", "test_verdict."), subTest.VisibleID)
+			verdict = icpcFailedVerdict(verdict, subTest.VisibleID)
 			upd.ICPCVerdict = &verdict
 
 			failed = true
+			continue
 		}
+		start(i + window)
 	}
 
 	if !failed {
@@ -254,16 +379,56 @@ func handleICPCSubmission(ctx context.Context, base *sudoapi.BaseAPI, runner eva
 	upd.MaxTime = &time
 	upd.MaxMemory = &memory
 
-	return base.UpdateSubmission(ctx, sub.ID, upd)
+	if err := base.UpdateSubmission(ctx, sub.ID, upd); err != nil {
+		return err
+	}
+	scheduleRelativeRescore(ctx, base, problem, improvedTests)
+	return nil
+}
+
+// icpcFailedVerdict returns the verdict of an ICPC submission failing the test with the given verdict
+func icpcFailedVerdict(verdict string, visibleID int) string {
+	return fmt.Sprintf("%s (test_verdict.test_x #%d)", strings.ReplaceAll(verdict, "translate:", "test_verdict."), visibleID)
+}
+
+// compileKey identifies the build of the compile request with the given compiler version
+func compileKey(req *tasks.CompileRequest, langVersion string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00", req.Lang, langVersion, strings.Join(req.ExtraFlags, " "))
+	for _, files := range []map[string][]byte{req.CodeFiles, req.HeaderFiles} {
+		names := make([]string, 0, len(files))
+		for name := range files {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			fmt.Fprintf(h, "%s\x00%d\x00", name, len(files[name]))
+			h.Write(files[name])
+		}
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
 }
 
-func compileSubmission(ctx context.Context, base *sudoapi.BaseAPI, runner eval.BoxScheduler, sub *kilonova.Submission, problem *kilonova.Problem, problemSettings *kilonova.ProblemEvalSettings) *kilonova.StatusError {
-	req, err := genSubCompileRequest(ctx, base, sub, problem, problemSettings)
+// cachedBuildKey returns the build key of the kept binary, or an empty string if it's not available
+func cachedBuildKey(binaryName, keyName string) string {
+	compiles := datastore.GetBucket(datastore.BucketTypeCompiles)
+	if _, err := compiles.Stat(binaryName); err != nil {
+		return ""
+	}
+	r, err := compiles.Reader(keyName)
+	if err != nil {
+		return ""
+	}
+	defer r.Close()
+	key, err := io.ReadAll(r)
 	if err != nil {
-		zap.S().Warn(err)
-		return kilonova.WrapError(err, "Couldn't generate compilation request")
+		return ""
 	}
+	return string(key)
+}
 
+func compileSubmission(ctx context.Context, base *sudoapi.BaseAPI, runner eval.BoxScheduler, sub *kilonova.Submission, problem *kilonova.Problem, req *tasks.CompileRequest) *kilonova.StatusError {
 	resp, err1 := tasks.CompileTask(ctx, runner, req, graderLogger)
 	if err1 != nil {
 		return kilonova.WrapError(err1, "Error from eval")
@@ -307,39 +472,119 @@ func compileSubmission(ctx context.Context, base *sudoapi.BaseAPI, runner eval.B
 	return nil
 }
 
-func handleSubTest(ctx context.Context, base *sudoapi.BaseAPI, runner eval.BoxScheduler, checker checkers.Checker, sub *kilonova.Submission, problem *kilonova.Problem, subTest *kilonova.SubTest) (decimal.Decimal, string, error) {
+// testSetup holds the limits and the additional input files a test is evaluated with
+type testSetup struct {
+	timeLimit   float64
+	memoryLimit int
+	inputFiles  []string
+	// dataHash is saved on the subtests, to find them when the test data changes
+	dataHash *string
+}
+
+// testSetups maps test IDs to their setup. Time limits are already scaled to the grader's speed
+type testSetups map[int]*testSetup
+
+// get returns the setup of the test, falling back to the problem's limits for tests created during evaluation
+func (s testSetups) get(problem *kilonova.Problem, testID int) *testSetup {
+	if setup, ok := s[testID]; ok {
+		return setup
+	}
+	return &testSetup{timeLimit: problem.TimeLimit, memoryLimit: problem.MemoryLimit}
+}
+
+// getTestSetups resolves the limits of all of the problem's tests. It must be called before the problem's time limit is scaled
+func getTestSetups(ctx context.Context, base *sudoapi.BaseAPI, problem *kilonova.Problem, speedFactor *float64) (testSetups, *kilonova.StatusError) {
+	tests, err := base.Tests(ctx, problem.ID)
+	if err != nil {
+		return nil, err
+	}
+	subtasks, err := base.SubTasks(ctx, problem.ID)
+	if err != nil {
+		return nil, err
+	}
+	var factor float64
+	if speedFactor != nil {
+		factor = *speedFactor
+	}
+	setups := make(testSetups, len(tests))
+	for _, test := range tests {
+		timeLimit, memoryLimit := problem.TestLimits(test, subtasks, factor)
+		setups[test.ID] = &testSetup{timeLimit: timeLimit, memoryLimit: memoryLimit, inputFiles: test.InputFiles, dataHash: test.DataHash}
+	}
+	return setups, nil
+}
+
+// subTestResult holds the outcome of running a subtest, before it is saved
+type subTestResult struct {
+	resp      *tasks.ExecResponse
+	testScore decimal.Decimal
+	objective *decimal.Decimal
+	testHash  *string
+	err       *kilonova.StatusError
+}
+
+// handleSubTest evaluates the subtest and returns its percentage, verdict and whether it improved the best objective value of the test
+func handleSubTest(ctx context.Context, base *sudoapi.BaseAPI, runner eval.BoxScheduler, checker checkers.Checker, sub *kilonova.Submission, problem *kilonova.Problem, setups testSetups, subTest *kilonova.SubTest) (decimal.Decimal, string, bool, error) {
+	return finishSubTest(ctx, base, sub, problem, subTest, runSubTest(ctx, runner, checker, sub, problem, setups, subTest))
+}
+
+// runSubTest executes the subtest and runs the checker on its output. Nothing is saved, so it is safe to discard the result
+func runSubTest(ctx context.Context, runner eval.BoxScheduler, checker checkers.Checker, sub *kilonova.Submission, problem *kilonova.Problem, setups testSetups, subTest *kilonova.SubTest) *subTestResult {
 	if subTest.TestID == nil {
 		zap.S().Error("A subtest whose test was purged was detected.", spew.Sdump(subTest))
-		return decimal.Zero, "", kilonova.Statusf(400, "Trying to handle subtest whose test was purged. This should never happen")
+		return &subTestResult{err: kilonova.Statusf(400, "Trying to handle subtest whose test was purged. This should never happen")}
 	}
+	setup := setups.get(problem, *subTest.TestID)
 
 	execRequest := &tasks.ExecRequest{
 		SubID:       sub.ID,
 		SubtestID:   subTest.ID,
 		Filename:    problem.TestName,
-		MemoryLimit: problem.MemoryLimit,
-		TimeLimit:   problem.TimeLimit,
+		MemoryLimit: setup.memoryLimit,
+		StackLimit:  problem.StackLimit,
+		TimeLimit:   setup.timeLimit,
 		Lang:        sub.Language,
 		TestID:      *subTest.TestID,
+		InputFiles:  setup.inputFiles,
 	}
 	if problem.ConsoleInput {
 		execRequest.Filename = "stdin"
 	}
 
-	resp, err := tasks.ExecuteTask(ctx, runner, int64(problem.MemoryLimit), execRequest, graderLogger)
+	resp, err := tasks.ExecuteTask(ctx, runner, int64(setup.memoryLimit), execRequest, graderLogger)
 	if err != nil {
-		return decimal.Zero, "", kilonova.WrapError(err, "Couldn't execute subtest")
+		return &subTestResult{err: kilonova.WrapError(err, "Couldn't execute subtest")}
 	}
-	var testScore decimal.Decimal
+	rez := &subTestResult{resp: resp, testHash: setup.dataHash}
 
 	// Make sure TLEs are fully handled
-	if resp.Time > problem.TimeLimit {
-		resp.Time = problem.TimeLimit
+	if resp.Time > setup.timeLimit {
+		resp.Time = setup.timeLimit
 		resp.Comments = "translate:timeout"
 	}
 
 	if resp.Comments == "" {
-		resp.Comments, testScore = checker.RunChecker(ctx, subTest.ID, *subTest.TestID)
+		resp.Comments, rez.testScore, rez.objective = checker.RunChecker(ctx, subTest.ID, *subTest.TestID)
+	}
+	return rez
+}
+
+// finishSubTest scores the result of runSubTest and saves it
+func finishSubTest(ctx context.Context, base *sudoapi.BaseAPI, sub *kilonova.Submission, problem *kilonova.Problem, subTest *kilonova.SubTest, rez *subTestResult) (decimal.Decimal, string, bool, error) {
+	if rez.err != nil {
+		return decimal.Zero, "", false, rez.err
+	}
+	resp, testScore, objective := rez.resp, rez.testScore, rez.objective
+	var improved bool
+
+	if objective != nil {
+		if problem.IsRelative() {
+			testScore, improved = relativeTestScore(ctx, base, problem, *subTest.TestID, *objective)
+		} else {
+			// The checker shouldn't report objective values for regular problems
+			objective = nil
+			testScore = decimal.Zero
+		}
 	}
 
 	// Hide fatal signals for ICPC submissions
@@ -352,10 +597,120 @@ func handleSubTest(ctx context.Context, base *sudoapi.BaseAPI, runner eval.BoxSc
 		}
 	}
 
-	if err := base.UpdateSubTest(ctx, subTest.ID, kilonova.SubTestUpdate{Memory: &resp.Memory, Percentage: &testScore, Time: &resp.Time, Verdict: &resp.Comments, Done: &True}); err != nil {
-		return decimal.Zero, "", kilonova.WrapError(err, "Error during evaltest updating")
+	if err := base.UpdateSubTest(ctx, subTest.ID, kilonova.SubTestUpdate{
+		Memory: &resp.Memory, Percentage: &testScore, Time: &resp.Time, Verdict: &resp.Comments, Done: &True, Objective: objective, TestHash: rez.testHash,
+		LanguageVersion: sub.LanguageVersion, SandboxVersion: sub.SandboxVersion,
+	}); err != nil {
+		return decimal.Zero, "", false, kilonova.WrapError(err, "Error during evaltest updating")
+	}
+	return testScore, resp.Comments, improved, nil
+}
+
+// relativeTestScore updates the best objective value of the test and returns the percentage relative to it
+func relativeTestScore(ctx context.Context, base *sudoapi.BaseAPI, problem *kilonova.Problem, testID int, objective decimal.Decimal) (decimal.Decimal, bool) {
+	improved, err := base.ImproveTestObjective(ctx, testID, objective, problem.ObjectiveType)
+	if err != nil {
+		return decimal.Zero, false
+	}
+	best, err := base.TestBestObjective(ctx, testID)
+	if err != nil || best == nil {
+		return decimal.Zero, improved
+	}
+	return problem.RelativePercentage(objective, *best), improved
+}
+
+// relativeRescoreMu serializes the rescorings, so the percentages are always computed against the latest best values
+var relativeRescoreMu sync.Mutex
+
+// scheduleRelativeRescore rescores the subtests of the tests whose best objective values improved in the background,
+// so the evaluation of the submission doesn't wait for the other submissions to be rescored
+func scheduleRelativeRescore(ctx context.Context, base *sudoapi.BaseAPI, problem *kilonova.Problem, testIDs []int) {
+	if len(testIDs) == 0 {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		relativeRescoreMu.Lock()
+		defer relativeRescoreMu.Unlock()
+		if err := rescoreRelativeTests(ctx, base, problem, testIDs); err != nil {
+			zap.S().Warn("Couldn't rescore relative tests: ", err)
+		}
+	}()
+}
+
+// rescoreRelativeTests recomputes the percentages of all finished subtests of the given tests after their best objective values improved.
+// Affected submissions are then rescored without being reevaluated.
+func rescoreRelativeTests(ctx context.Context, base *sudoapi.BaseAPI, problem *kilonova.Problem, testIDs []int) *kilonova.StatusError {
+	bests := make(map[int]decimal.Decimal)
+	for _, testID := range testIDs {
+		best, err := base.TestBestObjective(ctx, testID)
+		if err != nil {
+			return err
+		}
+		if best != nil {
+			bests[testID] = *best
+		}
+	}
+
+	subtests, err := base.RelativeSubTests(ctx, testIDs)
+	if err != nil {
+		return err
+	}
+
+	changedSubs := make(map[int]bool)
+	for _, st := range subtests {
+		best, ok := bests[*st.TestID]
+		if !ok {
+			continue
+		}
+		percentage := problem.RelativePercentage(*st.Objective, best)
+		if percentage.Equal(st.Percentage) {
+			continue
+		}
+		if err := base.UpdateSubTest(ctx, st.ID, kilonova.SubTestUpdate{Percentage: &percentage}); err != nil {
+			return err
+		}
+		changedSubs[st.SubmissionID] = true
+	}
+
+	for subID := range changedSubs {
+		sub, err := base.RawSubmission(ctx, subID)
+		if err != nil {
+			zap.S().Warn(err)
+			continue
+		}
+		// Submissions still being evaluated will be scored with the new percentages once they finish
+		if sub.Status != kilonova.StatusFinished {
+			continue
+		}
+		switch sub.SubmissionType {
+		case kilonova.EvalTypeClassic:
+			err = scoreTests(ctx, base, sub, problem)
+		case kilonova.EvalTypeICPC:
+			err = rescoreICPCSubmission(ctx, base, sub, problem)
+		}
+		if err != nil {
+			zap.S().Warn("Couldn't rescore submission: ", err)
+		}
+	}
+	return nil
+}
+
+// rescoreICPCSubmission recomputes the verdict of a finished ICPC submission from its subtests' percentages.
+// Percentages only decrease when rescoring, so the tests after a new failure were already evaluated
+func rescoreICPCSubmission(ctx context.Context, base *sudoapi.BaseAPI, sub *kilonova.Submission, problem *kilonova.Problem) *kilonova.StatusError {
+	subTests, err := base.SubTests(ctx, sub.ID)
+	if err != nil {
+		return err
 	}
-	return testScore, resp.Comments, nil
+	score, verdict := decimal.NewFromInt(100), acceptedVerdict
+	for _, st := range subTests {
+		if st.Done && !st.Skipped && !st.Percentage.Equal(decimal.NewFromInt(100)) {
+			score, verdict = problem.DefaultPoints, icpcFailedVerdict(st.Verdict, st.VisibleID)
+			break
+		}
+	}
+	return base.UpdateSubmission(ctx, sub.ID, kilonova.SubmissionUpdate{Score: &score, ChangeVerdict: true, ICPCVerdict: &verdict})
 }
 
 func markSubtestsDone(ctx context.Context, base *sudoapi.BaseAPI, sub *kilonova.Submission) error {
@@ -388,6 +743,15 @@ func scoreTests(ctx context.Context, base *sudoapi.BaseAPI, sub *kilonova.Submis
 		subTasks = nil
 	}
 
+	hackTests, err1 := base.ProblemHackTests(ctx, problem.ID)
+	if err1 != nil {
+		return err1
+	}
+	// failedHack reports whether the subtest is a hack test that the submission didn't pass
+	failedHack := func(st *kilonova.SubTest) bool {
+		return st.TestID != nil && slices.Contains(hackTests, *st.TestID) && st.Percentage.LessThan(decimal.NewFromInt(100))
+	}
+
 	var score = problem.DefaultPoints
 
 	if len(subTasks) > 0 {
@@ -396,17 +760,12 @@ func scoreTests(ctx context.Context, base *sudoapi.BaseAPI, sub *kilonova.Submis
 			subMap[st.ID] = st
 		}
 		for _, stk := range subTasks {
-			percentage := decimal.NewFromInt(100)
-			if len(stk.Subtests) == 0 { // Empty subtasks should be invalidated
-				percentage = decimal.Zero
-			}
+			percentage := subtaskPercentage(stk, subMap)
+			// Hack tests have no score, so failing one invalidates the whole subtask
 			for _, id := range stk.Subtests {
-				st, ok := subMap[id]
-				if !ok {
-					zap.S().Warn("Couldn't find subtest. This should not really happen.")
-					continue
+				if st, ok := subMap[id]; ok && failedHack(st) {
+					percentage = decimal.Zero
 				}
-				percentage = decimal.Min(percentage, st.Percentage)
 			}
 			// subTaskScore = stk.Score * (percentage / 100) rounded to the precision
 			subTaskScore := stk.Score.Mul(percentage.Shift(-2)).Round(problem.ScorePrecision)
@@ -421,6 +780,10 @@ func scoreTests(ctx context.Context, base *sudoapi.BaseAPI, sub *kilonova.Submis
 			testScore := subtest.Score.Mul(subtest.Percentage.Shift(-2)).Round(problem.ScorePrecision)
 			score = score.Add(testScore)
 		}
+		// Hack tests have no score, so failing one invalidates the whole submission
+		if slices.ContainsFunc(subtests, failedHack) {
+			score = problem.DefaultPoints
+		}
 	}
 
 	var memory int
@@ -433,9 +796,64 @@ func scoreTests(ctx context.Context, base *sudoapi.BaseAPI, sub *kilonova.Submis
 	return base.UpdateSubmission(ctx, sub.ID, kilonova.SubmissionUpdate{Status: kilonova.StatusFinished, Score: &score, MaxTime: &time, MaxMemory: &memory})
 }
 
-var ForceSecureSandbox = config.GenFlag[bool]("feature.grader.force_secure_sandbox", true, "Force use of secure sandbox only. Should be always enabled in production environments")
+// subtaskPercentage aggregates the percentages of the subtask's subtests according to the subtask's aggregation mode
+func subtaskPercentage(stk *kilonova.SubmissionSubTask, subMap map[int]*kilonova.SubTest) decimal.Decimal {
+	hundred := decimal.NewFromInt(100)
+	subtests := make([]*kilonova.SubTest, 0, len(stk.Subtests))
+	for _, id := range stk.Subtests {
+		st, ok := subMap[id]
+		if !ok {
+			zap.S().Warn("Couldn't find subtest. This should not really happen.")
+			continue
+		}
+		subtests = append(subtests, st)
+	}
+	if len(subtests) == 0 { // Empty subtasks should be invalidated
+		return decimal.Zero
+	}
+
+	switch stk.Aggregation {
+	case kilonova.AggregationSum:
+		// Weigh each test percentage by the test's score
+		var total, weights decimal.Decimal
+		for _, st := range subtests {
+			total = total.Add(st.Score.Mul(st.Percentage))
+			weights = weights.Add(st.Score)
+		}
+		if weights.IsPositive() {
+			return decimal.Min(hundred, total.Div(weights))
+		}
+		// All tests are worth 0 points, so fall back to the average
+		fallthrough
+	case kilonova.AggregationAverage:
+		var total decimal.Decimal
+		for _, st := range subtests {
+			total = total.Add(st.Percentage)
+		}
+		return total.Div(decimal.NewFromInt(int64(len(subtests))))
+	case kilonova.AggregationThreshold:
+		for _, st := range subtests {
+			if st.Percentage.LessThan(stk.Threshold) {
+				return decimal.Zero
+			}
+		}
+		return hundred
+	default: // kilonova.AggregationMin
+		percentage := hundred
+		for _, st := range subtests {
+			percentage = decimal.Min(percentage, st.Percentage)
+		}
+		return percentage
+	}
+}
+
+var (
+	ForceSecureSandbox = config.GenFlag[bool]("feature.grader.force_secure_sandbox", true, "Force use of secure sandbox only. Should be always enabled in production environments")
+	KeepBinaries       = config.GenFlag[bool]("feature.grader.keep_binaries", false, "Keep compiled submissions after evaluation, so selective reevaluations don't compile them again if their sources, flags and compiler didn't change. Old binaries are evicted once the compiles bucket is full")
+)
 
-func getAppropriateRunner() (eval.BoxScheduler, error) {
+// getAppropriateRunner returns the box scheduler along with the version of the sandbox it uses
+func getAppropriateRunner() (eval.BoxScheduler, string, error) {
 	var boxFunc scheduler.BoxFunc
 	var boxVersion string = "NONE"
 	if scheduler.CheckCanRun(box.New) {
@@ -453,11 +871,11 @@ func getAppropriateRunner() (eval.BoxScheduler, error) {
 	zap.S().Info("Trying to spin up local grader")
 	bm, err := scheduler.New(config.Eval.StartingBox, config.Eval.NumConcurrent, config.Eval.GlobalMaxMem, graderLogger, boxFunc)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	zap.S().Infof("Running local grader (version: %s)", boxVersion)
 
-	return bm, nil
+	return bm, boxVersion, nil
 }
 
 func getAppropriateChecker(ctx context.Context, base *sudoapi.BaseAPI, runner eval.BoxScheduler, sub *kilonova.Submission, pb *kilonova.Problem, settings *kilonova.ProblemEvalSettings) (checkers.Checker, error) {
@@ -0,0 +1,116 @@
+package grader
+
+import (
+	"testing"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/eval/tasks"
+	"github.com/shopspring/decimal"
+)
+
+type subtaskPercentageTest struct {
+	Aggregation kilonova.SubtaskAggregation
+	Threshold   int64
+	// Tests holds the score and percentage of each subtest
+	Tests      [][2]int64
+	Percentage string
+}
+
+var subtaskPercentageExamples = map[string]subtaskPercentageTest{
+	"min":               {Aggregation: kilonova.AggregationMin, Tests: [][2]int64{{10, 100}, {10, 40}, {10, 70}}, Percentage: "40"},
+	"default_min":       {Tests: [][2]int64{{10, 100}, {10, 0}}, Percentage: "0"},
+	"sum":               {Aggregation: kilonova.AggregationSum, Tests: [][2]int64{{30, 100}, {10, 0}}, Percentage: "75"},
+	"sum_zero_scores":   {Aggregation: kilonova.AggregationSum, Tests: [][2]int64{{0, 100}, {0, 50}}, Percentage: "75"},
+	"average":           {Aggregation: kilonova.AggregationAverage, Tests: [][2]int64{{30, 100}, {10, 0}}, Percentage: "50"},
+	"threshold_passed":  {Aggregation: kilonova.AggregationThreshold, Threshold: 50, Tests: [][2]int64{{10, 50}, {10, 80}}, Percentage: "100"},
+	"threshold_failed":  {Aggregation: kilonova.AggregationThreshold, Threshold: 50, Tests: [][2]int64{{10, 49}, {10, 100}}, Percentage: "0"},
+	"empty":             {Aggregation: kilonova.AggregationAverage, Percentage: "0"},
+	"missing_subtests":  {Aggregation: kilonova.AggregationMin, Tests: [][2]int64{{10, 60}, {-1, 0}}, Percentage: "60"},
+	"all_subtests_gone": {Aggregation: kilonova.AggregationSum, Tests: [][2]int64{{-1, 0}}, Percentage: "0"},
+}
+
+func TestSubtaskPercentage(t *testing.T) {
+	for k, v := range subtaskPercentageExamples {
+		v := v
+		t.Run(k, func(t *testing.T) {
+			t.Parallel()
+			stk := &kilonova.SubmissionSubTask{Aggregation: v.Aggregation, Threshold: decimal.NewFromInt(v.Threshold)}
+			subMap := make(map[int]*kilonova.SubTest)
+			for i, test := range v.Tests {
+				stk.Subtests = append(stk.Subtests, i)
+				// A negative score marks a subtest missing from the map
+				if test[0] < 0 {
+					continue
+				}
+				subMap[i] = &kilonova.SubTest{ID: i, Score: decimal.NewFromInt(test[0]), Percentage: decimal.NewFromInt(test[1])}
+			}
+			percentage := subtaskPercentage(stk, subMap)
+			if expected := decimal.RequireFromString(v.Percentage); !percentage.Equal(expected) {
+				t.Fatalf("Invalid percentage, expected %s, got %s", expected, percentage)
+			}
+		})
+	}
+}
+
+func compileKeyRequest() *tasks.CompileRequest {
+	return &tasks.CompileRequest{
+		ID:          1,
+		Lang:        "cpp17",
+		CodeFiles:   map[string][]byte{"/box/main.cpp": []byte("int main() {}"), "/box/util.h": []byte("#pragma once")},
+		HeaderFiles: map[string][]byte{"/box/grader.h": []byte("void f();")},
+		ExtraFlags:  []string{"-O2"},
+	}
+}
+
+type compileKeyTest struct {
+	Modify func(req *tasks.CompileRequest)
+	// LangVersion overrides the compiler version, if set
+	LangVersion string
+	// Same is set if the change must not affect the build key
+	Same bool
+}
+
+var compileKeyExamples = map[string]compileKeyTest{
+	"identical":    {Modify: func(req *tasks.CompileRequest) {}, Same: true},
+	"other_id":     {Modify: func(req *tasks.CompileRequest) { req.ID = 2 }, Same: true},
+	"other_output": {Modify: func(req *tasks.CompileRequest) { req.OutputName = "custom.bin" }, Same: true},
+	"lang":         {Modify: func(req *tasks.CompileRequest) { req.Lang = "cpp20" }},
+	"lang_version": {Modify: func(req *tasks.CompileRequest) {}, LangVersion: "g++ 14.1"},
+	"flags":        {Modify: func(req *tasks.CompileRequest) { req.ExtraFlags = []string{"-O3"} }},
+	"no_flags":     {Modify: func(req *tasks.CompileRequest) { req.ExtraFlags = nil }},
+	"code":         {Modify: func(req *tasks.CompileRequest) { req.CodeFiles["/box/main.cpp"] = []byte("int main() { }") }},
+	"code_name": {Modify: func(req *tasks.CompileRequest) {
+		req.CodeFiles["/box/util.hpp"] = req.CodeFiles["/box/util.h"]
+		delete(req.CodeFiles, "/box/util.h")
+	}},
+	"extra_code": {Modify: func(req *tasks.CompileRequest) { req.CodeFiles["/box/extra.cpp"] = nil }},
+	"header":     {Modify: func(req *tasks.CompileRequest) { req.HeaderFiles["/box/grader.h"] = []byte("void g();") }},
+	"header_as_code": {Modify: func(req *tasks.CompileRequest) {
+		req.CodeFiles["/box/grader.h"] = req.HeaderFiles["/box/grader.h"]
+		req.HeaderFiles = nil
+	}},
+}
+
+func TestCompileKey(t *testing.T) {
+	const langVersion = "g++ 13.2"
+	base := compileKey(compileKeyRequest(), langVersion)
+	for k, v := range compileKeyExamples {
+		v := v
+		t.Run(k, func(t *testing.T) {
+			t.Parallel()
+			req := compileKeyRequest()
+			v.Modify(req)
+			version := langVersion
+			if v.LangVersion != "" {
+				version = v.LangVersion
+			}
+			key := compileKey(req, version)
+			if v.Same && key != base {
+				t.Fatalf("Build key should not change")
+			}
+			if !v.Same && key == base {
+				t.Fatalf("Build key should change")
+			}
+		})
+	}
+}
@@ -0,0 +1,261 @@
+package grader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/datastore"
+	"github.com/KiloProjects/kilonova/eval"
+	"github.com/KiloProjects/kilonova/eval/checkers"
+	"github.com/KiloProjects/kilonova/eval/tasks"
+	"github.com/KiloProjects/kilonova/sudoapi"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+const pendingHacksLimit = 2
+
+func (h *Handler) scheduleHacks(runner eval.BoxScheduler) {
+	hacks, err := h.base.ClaimPendingHacks(h.ctx, pendingHacksLimit)
+	if err != nil {
+		zap.S().Warn(err)
+		return
+	}
+	if len(hacks) == 0 {
+		return
+	}
+	graderLogger.Info("Found pending hacks", slog.Int("count", len(hacks)))
+	for _, hack := range hacks {
+		r, err := runner.SubRunner(h.ctx, 1)
+		if err != nil {
+			zap.S().Warn(err)
+			continue
+		}
+		go func(hack *kilonova.Hack, r eval.BoxScheduler) {
+			defer r.Close(h.ctx)
+			status, verdict := evaluateHack(h.ctx, h.base, r, hack)
+			if err := h.base.FinishHack(h.ctx, hack, status, verdict); err != nil {
+				zap.S().Warn("Couldn't finish hack: ", err)
+			}
+		}(hack, r)
+	}
+}
+
+// evaluateHack validates the hack input, generates the correct output using the author solution
+// and then runs the target submission on it. The hack is successful if the submission fails the new test.
+func evaluateHack(ctx context.Context, base *sudoapi.BaseAPI, runner eval.BoxScheduler, hack *kilonova.Hack) (kilonova.HackStatus, string) {
+	graderLogger.Info("Evaluating hack", slog.Int("id", hack.ID), slog.Int("submission_id", hack.SubmissionID))
+	testID := sudoapi.HackTestID(hack.ID)
+
+	sub, err := base.RawSubmission(ctx, hack.SubmissionID)
+	if err != nil {
+		return kilonova.HackInvalid, "translate:internal_error"
+	}
+	problem, err := base.Problem(ctx, hack.ProblemID)
+	if err != nil {
+		return kilonova.HackInvalid, "translate:internal_error"
+	}
+	settings, err := base.ProblemSettings(ctx, hack.ProblemID)
+	if err != nil {
+		return kilonova.HackInvalid, "translate:internal_error"
+	}
+	if settings.SolutionName == "" {
+		return kilonova.HackInvalid, "Problem doesn't have an author solution"
+	}
+	// The programs run with the limits of the subtask the hack test would be added to
+	var speedFactor float64
+	if factor := base.GraderSpeedFactor(); factor != nil {
+		speedFactor = *factor
+	}
+	problem.TimeLimit, problem.MemoryLimit, err = base.HackTestLimits(ctx, problem, speedFactor)
+	if err != nil {
+		return kilonova.HackInvalid, "translate:internal_error"
+	}
+
+	compiles := datastore.GetBucket(datastore.BucketTypeCompiles)
+	for _, name := range []string{"validator", "solution", "target"} {
+		defer datastore.Pin(compiles, hackExecutable(hack, name))()
+	}
+	defer func() {
+		for _, name := range []string{"validator", "solution", "target"} {
+			if err := compiles.RemoveFile(hackExecutable(hack, name)); err != nil {
+				zap.S().Warn("Couldn't remove hack artifact: ", err)
+			}
+		}
+		if err := datastore.GetBucket(datastore.BucketTypeSubtests).RemoveFile(fmt.Sprintf("%d", testID)); err != nil {
+			zap.S().Warn("Couldn't remove hack output: ", err)
+		}
+	}()
+
+	if settings.ValidatorName != "" {
+		ok, verdict := validateHackInput(ctx, base, runner, hack, problem, settings)
+		if !ok {
+			return kilonova.HackInvalid, verdict
+		}
+	}
+
+	// Generate the correct output
+	solutionCode, err := base.ProblemAttDataByName(ctx, problem.ID, settings.SolutionName)
+	if err != nil {
+		return kilonova.HackInvalid, "Couldn't get author solution"
+	}
+	solutionLang := eval.GetLangByFilename(settings.SolutionName)
+	req, err := genCompileRequest(ctx, base, 0, solutionLang, solutionCode, nil, problem, settings)
+	if err != nil {
+		return kilonova.HackInvalid, "translate:internal_error"
+	}
+	req.OutputName = hackExecutable(hack, "solution")
+	if ok, verdict := compileHackProgram(ctx, runner, req); !ok {
+		return kilonova.HackInvalid, verdict
+	}
+	resp, err1 := runHackProgram(ctx, runner, hack, problem, solutionLang, req.OutputName)
+	if err1 != nil {
+		return kilonova.HackInvalid, "translate:internal_error"
+	}
+	if resp.Comments != "" {
+		return kilonova.HackInvalid, "Author solution failed: " + resp.Comments
+	}
+	if err := copyHackOutput(base, testID); err != nil {
+		zap.S().Warn("Couldn't save hack output: ", err)
+		return kilonova.HackInvalid, "translate:internal_error"
+	}
+
+	// Run the hacked submission
+	req, err = genSubCompileRequest(ctx, base, sub, problem, settings)
+	if err != nil {
+		return kilonova.HackInvalid, "translate:internal_error"
+	}
+	req.OutputName = hackExecutable(hack, "target")
+	if ok, _ := compileHackProgram(ctx, runner, req); !ok {
+		return kilonova.HackInvalid, "Couldn't compile hacked submission"
+	}
+	resp, err1 = runHackProgram(ctx, runner, hack, problem, sub.Language, req.OutputName)
+	if err1 != nil {
+		return kilonova.HackInvalid, "translate:internal_error"
+	}
+	if resp.Comments != "" {
+		return kilonova.HackSuccessful, resp.Comments
+	}
+
+	checker, err2 := getAppropriateChecker(ctx, base, runner, sub, problem, settings)
+	if err2 != nil {
+		return kilonova.HackInvalid, "translate:internal_error"
+	}
+	if _, err := checker.Prepare(ctx); err != nil {
+		return kilonova.HackInvalid, "translate:internal_error"
+	}
+	defer func() {
+		if err := checker.Cleanup(ctx); err != nil {
+			zap.S().Warn("Couldn't remove checker artifact: ", err)
+		}
+	}()
+
+	verdict, percentage, _ := checker.RunChecker(ctx, testID, testID)
+	if percentage.LessThan(decimal.NewFromInt(100)) {
+		return kilonova.HackSuccessful, verdict
+	}
+	return kilonova.HackUnsuccessful, verdict
+}
+
+func validateHackInput(ctx context.Context, base *sudoapi.BaseAPI, runner eval.BoxScheduler, hack *kilonova.Hack, problem *kilonova.Problem, settings *kilonova.ProblemEvalSettings) (bool, string) {
+	code, err := base.ProblemAttDataByName(ctx, problem.ID, settings.ValidatorName)
+	if err != nil {
+		return false, "Couldn't get validator"
+	}
+	lang := eval.GetLangByFilename(settings.ValidatorName)
+	if lang == "" {
+		return false, "Invalid validator language"
+	}
+	req := &tasks.CompileRequest{
+		Lang:       lang,
+		CodeFiles:  map[string][]byte{eval.Langs[lang].SourceName: code},
+		OutputName: hackExecutable(hack, "validator"),
+		HeaderFiles: map[string][]byte{
+			"/box/testlib.h": checkers.TestlibHeader(),
+		},
+	}
+	if ok, verdict := compileHackProgram(ctx, runner, req); !ok {
+		return false, "Validator compile error: " + verdict
+	}
+
+	in, err1 := base.TestInput(sudoapi.HackTestID(hack.ID))
+	if err1 != nil {
+		return false, "translate:internal_error"
+	}
+	defer in.Close()
+	input, err1 := io.ReadAll(in)
+	if err1 != nil {
+		return false, "translate:internal_error"
+	}
+
+	resp, err1 := tasks.CustomRunTask(ctx, runner, int64(problem.MemoryLimit), &tasks.CustomRunRequest{
+		Executable:  req.OutputName,
+		Filename:    "stdin",
+		Input:       input,
+		MemoryLimit: problem.MemoryLimit,
+		Lang:        lang,
+	}, graderLogger)
+	if err1 != nil {
+		return false, "translate:internal_error"
+	}
+	if resp.Comments != "translate:success" || resp.ExitStatus != 0 {
+		verdict := "Invalid input"
+		if resp.Stderr != "" {
+			verdict += ": " + resp.Stderr
+		}
+		return false, verdict
+	}
+	return true, ""
+}
+
+func compileHackProgram(ctx context.Context, runner eval.BoxScheduler, req *tasks.CompileRequest) (bool, string) {
+	resp, err := tasks.CompileTask(ctx, runner, req, graderLogger)
+	if err != nil {
+		zap.S().Warn(err)
+		return false, "translate:internal_error"
+	}
+	return resp.Success, resp.Output
+}
+
+func runHackProgram(ctx context.Context, runner eval.BoxScheduler, hack *kilonova.Hack, problem *kilonova.Problem, lang string, executable string) (*tasks.ExecResponse, error) {
+	testID := sudoapi.HackTestID(hack.ID)
+	req := &tasks.ExecRequest{
+		SubtestID:   testID,
+		Filename:    problem.TestName,
+		MemoryLimit: problem.MemoryLimit,
+		StackLimit:  problem.StackLimit,
+		TimeLimit:   problem.TimeLimit,
+		Lang:        lang,
+		TestID:      testID,
+		Executable:  executable,
+	}
+	if problem.ConsoleInput {
+		req.Filename = "stdin"
+	}
+	resp, err := tasks.ExecuteTask(ctx, runner, int64(problem.MemoryLimit), req, graderLogger)
+	if err != nil {
+		return nil, err
+	}
+	// Make sure TLEs are fully handled
+	if resp.Time > problem.TimeLimit {
+		resp.Comments = "translate:timeout"
+	}
+	return resp, nil
+}
+
+// copyHackOutput saves the output of the author solution as the reference output of the hack
+func copyHackOutput(base *sudoapi.BaseAPI, testID int) error {
+	out, err := base.SubtestReader(testID)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	return base.SaveTestOutput(testID, out)
+}
+
+func hackExecutable(hack *kilonova.Hack, name string) string {
+	return fmt.Sprintf("hack-%d-%s.bin", hack.ID, name)
+}
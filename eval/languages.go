@@ -43,6 +43,8 @@ var Langs = map[string]Language{
 		RunCommand:     []string{"/box/output"},
 		SourceName:     "/box/main.c",
 		CompiledName:   "/box/output",
+		MultiFile:      true,
+		AcceptsFlags:   true,
 		SimilarLangs:   []string{"c", "cpp", "cpp11", "cpp14", "cpp17", "cpp20"},
 
 		VersionCommand: []string{"gcc", "--version"},
@@ -61,6 +63,8 @@ var Langs = map[string]Language{
 		RunCommand:     []string{"/box/output"},
 		SourceName:     "/box/main.cpp",
 		CompiledName:   "/box/output",
+		MultiFile:      true,
+		AcceptsFlags:   true,
 		SimilarLangs:   []string{"c", "cpp", "cpp11", "cpp14", "cpp17", "cpp20"},
 
 		VersionCommand: []string{"g++", "--version"},
@@ -79,6 +83,8 @@ var Langs = map[string]Language{
 		RunCommand:     []string{"/box/output"},
 		SourceName:     "/box/main.cpp",
 		CompiledName:   "/box/output",
+		MultiFile:      true,
+		AcceptsFlags:   true,
 		SimilarLangs:   []string{"c", "cpp", "cpp11", "cpp14", "cpp17", "cpp20"},
 
 		VersionCommand: []string{"g++", "--version"},
@@ -97,6 +103,8 @@ var Langs = map[string]Language{
 		RunCommand:     []string{"/box/output"},
 		SourceName:     "/box/main.cpp",
 		CompiledName:   "/box/output",
+		MultiFile:      true,
+		AcceptsFlags:   true,
 		SimilarLangs:   []string{"c", "cpp", "cpp11", "cpp14", "cpp17", "cpp20"},
 
 		VersionCommand: []string{"g++", "--version"},
@@ -115,6 +123,8 @@ var Langs = map[string]Language{
 		RunCommand:     []string{"/box/output"},
 		SourceName:     "/box/main.cpp",
 		CompiledName:   "/box/output",
+		MultiFile:      true,
+		AcceptsFlags:   true,
 		SimilarLangs:   []string{"c", "cpp", "cpp11", "cpp14", "cpp17", "cpp20"},
 
 		VersionCommand: []string{"g++", "--version"},
@@ -154,6 +164,7 @@ var Langs = map[string]Language{
 		RunCommand:     []string{"/box/main"},
 		SourceName:     "/box/main.go",
 		CompiledName:   "/box/main",
+		MultiFile:      true,
 
 		VersionCommand: []string{"/usr/bin/go", "version"},
 		VersionParser:  nil,
@@ -176,6 +187,7 @@ var Langs = map[string]Language{
 		RunCommand:     []string{"/box/output"},
 		SourceName:     "/box/main.hs",
 		CompiledName:   "/box/output",
+		MultiFile:      true,
 
 		VersionCommand: []string{"ghc", "--numeric-version"},
 		VersionParser:  nil,
@@ -188,10 +200,12 @@ var Langs = map[string]Language{
 		InternalName:  "java",
 		MOSSName:      "java",
 
-		CompileCommand: []string{"javac", MagicReplace},
-		RunCommand:     []string{"java", "Main"},
-		SourceName:     "/Main.java",
-		CompiledName:   "/Main.class",
+		// All classes, including the ones in packages, are bundled in a jar with Main as the entrypoint
+		CompileCommand: []string{"/bin/sh", "-c", `javac -d /box/classes "$@" && jar cfe /box/output.jar Main -C /box/classes .`, "javac", MagicReplace},
+		RunCommand:     []string{"java", "-Xmx" + MemoryReplace + "K", "-DKNOVA", "-DONLINE_JUDGE", "-jar", "/box/output.jar"},
+		SourceName:     "/box/Main.java",
+		CompiledName:   "/box/output.jar",
+		MultiFile:      true,
 
 		VersionCommand: []string{"javac", "--version"},
 		VersionParser:  nil,
@@ -209,6 +223,7 @@ var Langs = map[string]Language{
 		RunCommand:     []string{"java", "-Xmx" + MemoryReplace + "K", "-DKNOVA", "-DONLINE_JUDGE", "-jar", "/box/output.jar"},
 		SourceName:     "/box/main.kt",
 		CompiledName:   "/box/output.jar",
+		MultiFile:      true,
 
 		VersionCommand: []string{"kotlinc", "-version"},
 		VersionParser:  func(s string) string { return strings.TrimPrefix(s, "info:") },
@@ -225,6 +240,7 @@ var Langs = map[string]Language{
 		RunCommand:   []string{"python3", "/box/main.py"},
 		SourceName:   "/box/main.py",
 		CompiledName: "/box/main.py",
+		MultiFile:    true,
 
 		VersionCommand: []string{"python3", "--version"},
 		VersionParser:  nil,
@@ -275,8 +291,17 @@ type Language struct {
 	RunEnv   map[string]string `json:"-"`
 
 	// Mounts represents all directories to be mounted
-	Mounts     []Directory `json:"-"`
-	SourceName string      `json:"-"`
+	Mounts []Directory `json:"-"`
+	// SourceName is the path of the entrypoint of the submission
+	SourceName string `json:"-"`
+
+	// MultiFile is true if submissions may consist of multiple source files.
+	// Additional files are placed relative to the directory of SourceName
+	MultiFile bool `json:"multi_file"`
+
+	// AcceptsFlags is true if the compiler takes gcc-style flags.
+	// Problems may append extra flags (such as -DEVAL) to the compile command of such languages
+	AcceptsFlags bool `json:"-"`
 
 	CompiledName string `json:"compiled_name"`
 }
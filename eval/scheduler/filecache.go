@@ -0,0 +1,185 @@
+package scheduler
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sync"
+
+	"github.com/KiloProjects/kilonova/datastore"
+	"github.com/KiloProjects/kilonova/eval"
+	"github.com/KiloProjects/kilonova/internal/config"
+	"go.uber.org/zap"
+)
+
+var (
+	FileCacheSize = config.GenFlag[int]("feature.grader.file_cache_size", 2048, "Maximum size (in MB) of the local cache of decompressed tests and executables. Set to 0 to copy files into sandboxes every time")
+	FileCacheDir  = config.GenFlag[string]("feature.grader.file_cache_dir", "", "Directory of the sandbox file cache. Files are hard linked into sandboxes only if it's on the same filesystem as the isolate boxes. Defaults to box_cache in the data directory")
+)
+
+// fileCache keeps decompressed, read-only copies of bucket files on local disk.
+// They are linked into the boxes, instead of being decompressed and copied for every run.
+// The least recently used files are evicted once the cache exceeds its maximum size.
+type fileCache struct {
+	mu      sync.Mutex
+	root    string
+	maxSize int64
+	size    int64
+
+	entries map[string]*cacheEntry
+	// Front is the most recently used entry
+	lru *list.List
+}
+
+type cacheEntry struct {
+	key  string
+	path string
+	size int64
+
+	// refs counts the boxes currently linking the file, such entries are not evicted
+	refs int
+	elem *list.Element
+
+	ready chan struct{}
+	err   error
+}
+
+// newFileCache creates the cache in the given directory. Any files left from previous runs are removed, since their index is lost
+func newFileCache(root string, maxSize int64) (*fileCache, error) {
+	if err := os.RemoveAll(root); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, err
+	}
+	return &fileCache{
+		root:    root,
+		maxSize: maxSize,
+		entries: make(map[string]*cacheEntry),
+		lru:     list.New(),
+	}, nil
+}
+
+// acquire returns the cache entry of the bucket file, filling it if necessary. The entry must be released after it's linked.
+// Entries are keyed by the version of the bucket file (its ETag or content hash, if known), so updated files are never served stale.
+func (c *fileCache) acquire(bucketType datastore.BucketType, filename string, mode fs.FileMode) (*cacheEntry, error) {
+	bucket := datastore.GetBucket(bucketType)
+	stat, err := bucket.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+	if mode == 0 {
+		mode = stat.Mode()
+	}
+	// Files in the cache are shared, so they must not be writable from the boxes
+	mode = mode.Perm() &^ 0222
+	key := fmt.Sprintf("%s/%s@%s-%d-%o", bucketType, filename, datastore.FileVersion(stat), stat.Size(), mode)
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	if ok {
+		entry.refs++
+		c.lru.MoveToFront(entry.elem)
+		c.mu.Unlock()
+		<-entry.ready
+		if entry.err != nil {
+			c.release(entry)
+			return nil, entry.err
+		}
+		return entry, nil
+	}
+	hash := sha256.Sum256([]byte(key))
+	entry = &cacheEntry{
+		key:   key,
+		path:  path.Join(c.root, hex.EncodeToString(hash[:16])),
+		refs:  1,
+		ready: make(chan struct{}),
+	}
+	entry.elem = c.lru.PushFront(entry)
+	c.entries[key] = entry
+	c.mu.Unlock()
+
+	entry.size, entry.err = c.fill(bucket, filename, entry.path, mode)
+	close(entry.ready)
+	if entry.err != nil {
+		c.mu.Lock()
+		c.remove(entry)
+		c.mu.Unlock()
+		c.release(entry)
+		return nil, entry.err
+	}
+
+	c.mu.Lock()
+	c.size += entry.size
+	c.evict()
+	c.mu.Unlock()
+	return entry, nil
+}
+
+func (c *fileCache) release(entry *cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry.refs--
+	c.evict()
+}
+
+func (c *fileCache) fill(bucket eval.Bucket, filename string, p string, mode fs.FileMode) (int64, error) {
+	r, err := bucket.Reader(filename)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	f, err := os.CreateTemp(c.root, "tmp-*")
+	if err != nil {
+		return 0, err
+	}
+	size, err := io.Copy(f, r)
+	if err1 := f.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), mode)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), p)
+	}
+	if err != nil {
+		if err := os.Remove(f.Name()); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			zap.S().Warn("Couldn't remove temporary cache file: ", err)
+		}
+		return 0, err
+	}
+	return size, nil
+}
+
+// evict removes the least recently used entries until the cache fits its size. Must be called with the lock held
+func (c *fileCache) evict() {
+	for elem := c.lru.Back(); elem != nil && c.size > c.maxSize; {
+		entry := elem.Value.(*cacheEntry)
+		elem = elem.Prev()
+		if entry.refs > 0 {
+			continue
+		}
+		c.remove(entry)
+		c.size -= entry.size
+		// Boxes that already linked the file keep their link
+		if err := os.Remove(entry.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			zap.S().Warn("Couldn't remove cached file: ", err)
+		}
+	}
+}
+
+// remove deletes the entry from the index. Must be called with the lock held
+func (c *fileCache) remove(entry *cacheEntry) {
+	if c.entries[entry.key] == entry {
+		delete(c.entries, entry.key)
+	}
+	c.lru.Remove(entry.elem)
+}
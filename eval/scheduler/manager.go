@@ -50,7 +50,8 @@ type BoxManager struct {
 	languageVersionsMu sync.RWMutex
 	languageVersions   map[string]string
 
-	// TODO: Datastore manager here
+	// files is nil if the file cache is disabled
+	files *fileCache
 }
 
 func (b *BoxManager) SubRunner(ctx context.Context, numConc int64) (eval.BoxScheduler, error) {
@@ -75,6 +76,8 @@ func (b *BoxManager) SubRunner(ctx context.Context, numConc int64) (eval.BoxSche
 		parentMgr: b,
 
 		boxGenerator: b.boxGenerator,
+
+		files: b.files,
 	}, nil
 }
 
@@ -151,6 +154,19 @@ func New(startingNumber int, count int, maxMemory int64, logger *slog.Logger, bo
 
 		boxGenerator: boxGenerator,
 	}
+
+	if size := FileCacheSize.Value(); size > 0 {
+		root := FileCacheDir.Value()
+		if root == "" {
+			root = path.Join(config.Common.DataDir, "box_cache")
+		}
+		files, err := newFileCache(root, int64(size)*1024*1024)
+		if err != nil {
+			zap.S().Warn("Couldn't create sandbox file cache, files will be copied instead: ", err)
+		} else {
+			bm.files = files
+		}
+	}
 	return bm, nil
 }
 
@@ -231,7 +247,9 @@ func (mgr *BoxManager) RunBox2(ctx context.Context, req *eval.Box2Request, memQu
 	}
 
 	for path, val := range req.InputBucketFiles {
-		// TODO: Use datastore manager
+		if mgr.linkInBox(box, val, path) {
+			continue
+		}
 		// Do not reset val.Mode here, since CopyInBox stats and sets the proper mode
 		if err := copyInBox(box, datastore.GetBucket(val.Bucket), val.Filename, path, val.Mode); err != nil {
 			if errors.Is(err, kilonova.ErrNotExist) {
@@ -295,6 +313,27 @@ func (mgr *BoxManager) RunBox2(ctx context.Context, req *eval.Box2Request, memQu
 	return resp, nil
 }
 
+// linkInBox links the bucket file from the file cache into the box.
+// It returns false if the file cache is disabled or couldn't provide the file, in which case it should be copied.
+func (mgr *BoxManager) linkInBox(b eval.Sandbox, file *eval.BucketFile, p2 string) bool {
+	if mgr.files == nil {
+		return false
+	}
+	entry, err := mgr.files.acquire(file.Bucket, file.Filename, file.Mode)
+	if err != nil {
+		if !errors.Is(err, kilonova.ErrNotExist) {
+			zap.S().Warn("Couldn't get file from sandbox file cache: ", err)
+		}
+		return false
+	}
+	defer mgr.files.release(entry)
+	if err := b.LinkFile(p2, entry.path); err != nil {
+		zap.S().Warn("Couldn't link file in sandbox: ", err)
+		return false
+	}
+	return true
+}
+
 // Copies in box an object from a bucket
 func copyInBox(b eval.Sandbox, bucket eval.Bucket, filename string, p2 string, mode fs.FileMode) error {
 	file, err := bucket.Reader(filename)
@@ -0,0 +1,69 @@
+package tasks
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"log/slog"
+
+	"github.com/KiloProjects/kilonova/datastore"
+	"github.com/KiloProjects/kilonova/eval"
+	"go.uber.org/zap"
+)
+
+const (
+	calibrationLang = "cpp17"
+	calibrationName = "calibration.bin"
+	calibrationRuns = 3
+
+	calibrationMemory = 256 * 1024 // KB
+)
+
+//go:embed taskdata/calibration.cpp
+var calibrationSource []byte
+
+// CalibrationTask compiles and runs the calibration benchmark through the sandbox, returning the best running time (in seconds) out of a few runs
+func CalibrationTask(ctx context.Context, mgr eval.BoxScheduler, logger *slog.Logger) (float64, error) {
+	lang, ok := eval.Langs[calibrationLang]
+	if !ok || lang.Disabled {
+		return -1, errors.New("calibration language is not available")
+	}
+
+	compileResp, err := CompileTask(ctx, mgr, &CompileRequest{
+		Lang:       calibrationLang,
+		CodeFiles:  map[string][]byte{lang.SourceName: calibrationSource},
+		OutputName: calibrationName,
+	}, logger)
+	if err != nil {
+		return -1, err
+	}
+	defer func() {
+		if err := datastore.GetBucket(datastore.BucketTypeCompiles).RemoveFile(calibrationName); err != nil {
+			zap.S().Warn("Couldn't remove calibration artifact: ", err)
+		}
+	}()
+	if !compileResp.Success {
+		return -1, errors.New("couldn't compile calibration benchmark: " + compileResp.Output)
+	}
+
+	best := -1.0
+	for range calibrationRuns {
+		resp, err := CustomRunTask(ctx, mgr, calibrationMemory, &CustomRunRequest{
+			Executable:  calibrationName,
+			Filename:    "stdin",
+			MemoryLimit: calibrationMemory,
+			TimeLimit:   20,
+			Lang:        calibrationLang,
+		}, logger)
+		if err != nil {
+			return -1, err
+		}
+		if resp.Comments != "translate:success" || resp.ExitStatus != 0 {
+			return -1, errors.New("calibration benchmark failed: " + resp.Comments)
+		}
+		if best < 0 || resp.Time < best {
+			best = resp.Time
+		}
+	}
+	return best, nil
+}
@@ -1,12 +1,16 @@
 package tasks
 
 import (
+	"archive/zip"
 	"bytes"
 	"context"
 	"fmt"
 	"log/slog"
 	"maps"
+	"path"
+	"path/filepath"
 	"slices"
+	"strings"
 
 	"github.com/KiloProjects/kilonova"
 	"github.com/KiloProjects/kilonova/datastore"
@@ -22,6 +26,13 @@ type CompileRequest struct {
 	CodeFiles   map[string][]byte
 	HeaderFiles map[string][]byte
 	Lang        string
+
+	// OutputName, if not empty, overrides the ID-based filename of the compiled file in the compiles bucket.
+	// It is used for custom runs, which are not bound to a submission.
+	OutputName string
+
+	// ExtraFlags are appended to the compile command, if the language accepts them
+	ExtraFlags []string
 }
 
 type CompileResponse struct {
@@ -53,10 +64,27 @@ func CompileTask(ctx context.Context, mgr eval.BoxScheduler, req *CompileRequest
 	}
 
 	bucket, outName := bucketFromIDExec(req.ID)
+	if req.OutputName != "" {
+		bucket, outName = datastore.BucketTypeCompiles, req.OutputName
+	}
 	resp.Success = true
 
 	// If the language is interpreted, just save the code and leave
 	if !lang.Compiled {
+		if len(req.CodeFiles) > 1 && lang.MultiFile {
+			// Multi-file programs are bundled in a zip archive, which the interpreter runs as is
+			data, err := bundleSources(lang, req)
+			if err != nil {
+				resp.Output = "Could not bundle source files: " + err.Error()
+				resp.Success = false
+				return resp, nil
+			}
+			if err := datastore.GetBucket(bucket).WriteFile(outName, bytes.NewReader(data), 0644); err != nil {
+				resp.Other = err.Error()
+				resp.Success = false
+			}
+			return resp, nil
+		}
 		// It should only be one file here anyway
 		if len(req.CodeFiles) > 1 {
 			zap.S().Warn("More than one file specified for non-compiled language. This is not properly supported")
@@ -105,6 +133,13 @@ func CompileTask(ctx context.Context, mgr eval.BoxScheduler, req *CompileRequest
 		}
 		sourceFiles = append(sourceFiles, fName)
 	}
+	// Keep the order deterministic, with the entrypoint first, since some compilers name the output after the first file
+	slices.SortFunc(sourceFiles, func(a, b string) int {
+		if a == lang.SourceName || b == lang.SourceName {
+			return bool2int(b == lang.SourceName) - bool2int(a == lang.SourceName)
+		}
+		return strings.Compare(a, b)
+	})
 	for fName, fData := range req.HeaderFiles {
 		bReq.InputByteFiles[fName] = &eval.ByteFile{
 			Data: fData,
@@ -113,7 +148,11 @@ func CompileTask(ctx context.Context, mgr eval.BoxScheduler, req *CompileRequest
 	}
 
 	// Init compilation command
-	goodCmd, err := makeGoodCompileCommand(lang.CompileCommand, sourceFiles)
+	var flags []string
+	if lang.AcceptsFlags {
+		flags = req.ExtraFlags
+	}
+	goodCmd, err := makeGoodCompileCommand(lang.CompileCommand, flags, sourceFiles)
 	if err != nil {
 		zap.S().Warnf("MakeGoodCompileCommand returned an error: %q. This is not good, so we'll use the command from the config file. The supplied command was %#v", err, lang.CompileCommand)
 		goodCmd = lang.CompileCommand
@@ -159,12 +198,50 @@ func compilationOutput(resp *eval.Box2Response) string {
 	return string(combinedOutRunes)
 }
 
-func makeGoodCompileCommand(command []string, files []string) ([]string, error) {
+// bundleSources creates a zip archive with all the files of the request, having the entrypoint as __main__.py
+func bundleSources(lang eval.Language, req *CompileRequest) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	root := path.Dir(lang.SourceName)
+	for _, files := range []map[string][]byte{req.CodeFiles, req.HeaderFiles} {
+		for fName, fData := range files {
+			name, err := filepath.Rel(root, fName)
+			if err != nil || strings.HasPrefix(name, "..") {
+				return nil, fmt.Errorf("file %q is outside of the source directory", fName)
+			}
+			if fName == lang.SourceName {
+				name = "__main__.py"
+			}
+			w, err := zw.Create(name)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := w.Write(fData); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func bool2int(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// makeGoodCompileCommand replaces the magic field with the source files. The flags are placed right before them, so they override the default ones
+func makeGoodCompileCommand(command []string, flags []string, files []string) ([]string, error) {
 	cmd := slices.Clone(command)
 	for i := range cmd {
 		if cmd[i] == eval.MagicReplace {
 			x := []string{}
 			x = append(x, cmd[:i]...)
+			x = append(x, flags...)
 			x = append(x, files...)
 			x = append(x, cmd[i+1:]...)
 			return x, nil
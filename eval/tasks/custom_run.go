@@ -0,0 +1,149 @@
+package tasks
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/KiloProjects/kilonova/datastore"
+	"github.com/KiloProjects/kilonova/eval"
+	"go.uber.org/zap"
+)
+
+const customRunOutputLimit = 64 * 1024 // bytes
+
+type CustomRunRequest struct {
+	// Executable is the name of the compiled file in the compiles bucket
+	Executable string
+	Filename   string
+	Input      []byte
+
+	// TimeLimit is in seconds, MemoryLimit and StackLimit are in kilobytes
+	MemoryLimit int
+	StackLimit  int
+	TimeLimit   float64
+
+	Lang string
+
+	// Args are appended to the run command
+	Args []string
+	// InputBucketFile, if set, is read instead of Input.
+	// OutputBucketFile, if set, receives the whole output, which is then not returned in Stdout
+	InputBucketFile  *eval.BucketFile
+	OutputBucketFile *eval.BucketFile
+}
+
+type CustomRunResponse struct {
+	Stdout     string
+	Stderr     string
+	Time       float64
+	Memory     int
+	ExitStatus int
+	Comments   string
+}
+
+// CustomRunTask runs an already compiled executable once on user-provided input, returning its output directly
+func CustomRunTask(ctx context.Context, mgr eval.BoxScheduler, memQuota int64, req *CustomRunRequest, logger *slog.Logger) (*CustomRunResponse, error) {
+	logger.Info("Executing custom run", slog.String("executable", req.Executable))
+
+	lang := eval.Langs[req.Lang]
+
+	inPath, outPath := "/box/"+req.Filename+".in", "/box/"+req.Filename+".out"
+	const errPath = "/box/custom_run.err"
+
+	bReq := &eval.Box2Request{
+		InputByteFiles: map[string]*eval.ByteFile{
+			inPath: {
+				Data: req.Input,
+				Mode: 0666,
+			},
+		},
+		InputBucketFiles: map[string]*eval.BucketFile{
+			lang.CompiledName: {
+				Bucket:   datastore.BucketTypeCompiles,
+				Filename: req.Executable,
+				Mode:     0777,
+			},
+		},
+
+		RunConfig: &eval.RunConfig{
+			EnvToSet:      maps.Clone(lang.RunEnv),
+			MemoryLimit:   req.MemoryLimit,
+			StackLimit:    req.StackLimit,
+			TimeLimit:     req.TimeLimit,
+			WallTimeLimit: 2*req.TimeLimit + 1,
+
+			StderrPath: errPath,
+		},
+
+		OutputByteFiles: []string{outPath, errPath},
+
+		Command: append(slices.Clone(lang.RunCommand), req.Args...),
+	}
+
+	if !lang.Compiled {
+		bReq.RunConfig.Directories = slices.Clone(lang.Mounts)
+	}
+
+	if req.TimeLimit == 0 {
+		bReq.RunConfig.WallTimeLimit = 30
+	}
+
+	if req.InputBucketFile != nil {
+		delete(bReq.InputByteFiles, inPath)
+		bReq.InputBucketFiles[inPath] = req.InputBucketFile
+	}
+	if req.OutputBucketFile != nil {
+		bReq.OutputByteFiles = []string{errPath}
+		bReq.OutputBucketFiles = map[string]*eval.BucketFile{outPath: req.OutputBucketFile}
+	}
+
+	if req.Filename == "stdin" {
+		bReq.RunConfig.InputPath = inPath
+		bReq.RunConfig.OutputPath = outPath
+	}
+
+	resp := &CustomRunResponse{}
+
+	bResp, err := mgr.RunBox2(ctx, bReq, memQuota)
+	if bResp == nil || err != nil {
+		resp.Comments = "translate:internal_error"
+		if err != nil {
+			resp.Comments += "(" + err.Error() + ")"
+		}
+		return resp, nil
+	}
+
+	resp.Time, resp.Memory = bResp.Stats.Time, bResp.Stats.Memory
+	resp.ExitStatus = bResp.Stats.ExitCode
+	resp.Stdout = truncateOutput(bResp.ByteFiles[outPath])
+	resp.Stderr = truncateOutput(bResp.ByteFiles[errPath])
+
+	switch msg, status := bResp.Stats.Message, bResp.Stats.Status; status {
+	case "TO":
+		if strings.Contains(msg, "wall") {
+			resp.Comments = "translate:walltimeout"
+		} else {
+			resp.Comments = "translate:timeout"
+		}
+	case "RE", "SG":
+		resp.Comments = msg
+	case "XX":
+		resp.Comments = "Sandbox Error: " + msg
+		zap.S().Warn("Sandbox error detected during custom run, check grader.log for more details")
+		logger.Warn("Sandbox error", slog.String("executable", req.Executable), slog.Any("metadata", bResp.Stats))
+	default:
+		resp.Comments = "translate:success"
+	}
+
+	return resp, nil
+}
+
+func truncateOutput(data []byte) string {
+	if len(data) > customRunOutputLimit {
+		return strings.ToValidUTF8(string(data[:customRunOutputLimit]), "") + "\n... (output trimmed)"
+	}
+	return string(data)
+}
@@ -9,6 +9,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/KiloProjects/kilonova"
 	"github.com/KiloProjects/kilonova/datastore"
 	"github.com/KiloProjects/kilonova/eval"
 	"go.uber.org/zap"
@@ -19,12 +20,23 @@ type ExecRequest struct {
 	SubtestID int
 	Filename  string
 
-	// TimeLimit is in seconds, MemoryLimit is in kilobytes
+	// TimeLimit is in seconds, MemoryLimit and StackLimit are in kilobytes
 	MemoryLimit int
+	StackLimit  int
 	TimeLimit   float64
 
 	Lang   string
 	TestID int
+
+	// InputFiles are the names of the test's additional input files, placed in the box next to the regular input
+	InputFiles []string
+
+	// Executable, if not empty, overrides the SubID-based executable in the compiles bucket.
+	// It is used for hacks, which run helper programs that are not bound to a submission.
+	Executable string
+
+	// DiscardOutput skips saving the output, for runs where only the resource usage matters
+	DiscardOutput bool
 }
 
 type ExecResponse struct {
@@ -38,6 +50,9 @@ func ExecuteTask(ctx context.Context, mgr eval.BoxScheduler, memQuota int64, req
 	logger.Info("Executing subtest", slog.Int("subtest_id", req.SubtestID), slog.Int("sub_id", req.SubID))
 
 	bucket, fileName := bucketFromIDExec(req.SubID)
+	if req.Executable != "" {
+		bucket, fileName = datastore.BucketTypeCompiles, req.Executable
+	}
 	lang := eval.Langs[req.Lang]
 
 	boxOut := fmt.Sprintf("/box/%s.out", req.Filename)
@@ -61,6 +76,7 @@ func ExecuteTask(ctx context.Context, mgr eval.BoxScheduler, memQuota int64, req
 		RunConfig: &eval.RunConfig{
 			EnvToSet:      maps.Clone(lang.RunEnv),
 			MemoryLimit:   req.MemoryLimit,
+			StackLimit:    req.StackLimit,
 			TimeLimit:     req.TimeLimit,
 			WallTimeLimit: 2*req.TimeLimit + 1,
 		},
@@ -76,6 +92,19 @@ func ExecuteTask(ctx context.Context, mgr eval.BoxScheduler, memQuota int64, req
 		Command: slices.Clone(lang.RunCommand),
 	}
 
+	for _, name := range req.InputFiles {
+		p := "/box/" + name
+		if _, ok := bReq.InputBucketFiles[p]; ok || p == boxOut {
+			logger.Warn("Skipping input file that would overwrite a box file", slog.String("name", name), slog.Int("test_id", req.TestID))
+			continue
+		}
+		bReq.InputBucketFiles[p] = &eval.BucketFile{
+			Bucket:   datastore.BucketTypeTests,
+			Filename: kilonova.TestInputFilename(req.TestID, name),
+			Mode:     0666,
+		}
+	}
+
 	// if our specified language is not compiled, then it means that
 	// the mounts specified should be added at runtime
 	if !lang.Compiled {
@@ -91,6 +120,10 @@ func ExecuteTask(ctx context.Context, mgr eval.BoxScheduler, memQuota int64, req
 		bReq.RunConfig.OutputPath = "/box/stdin.out"
 	}
 
+	if req.DiscardOutput {
+		bReq.OutputBucketFiles = nil
+	}
+
 	resp := &ExecResponse{}
 
 	bResp, err := mgr.RunBox2(ctx, bReq, memQuota)
@@ -123,7 +156,7 @@ func ExecuteTask(ctx context.Context, mgr eval.BoxScheduler, memQuota int64, req
 	default:
 		okExit = true
 	}
-	if !okExit {
+	if !okExit || req.DiscardOutput {
 		return resp, nil
 	}
 
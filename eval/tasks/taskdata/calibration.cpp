@@ -0,0 +1,54 @@
+// Calibration benchmark, used to measure the speed of a grader host.
+// It mixes integer arithmetic, branching and cache-unfriendly memory accesses,
+// which is roughly what most submissions spend their time on.
+#include <cstdint>
+#include <cstdio>
+#include <vector>
+
+int main() {
+    const int N = 2000000;
+
+    // Sieve of Eratosthenes
+    std::vector<bool> composite(N + 1, false);
+    int primes = 0;
+    for (int i = 2; i <= N; i++) {
+        if (composite[i]) {
+            continue;
+        }
+        primes++;
+        for (int64_t j = (int64_t)i * i; j <= N; j += i) {
+            composite[j] = true;
+        }
+    }
+
+    // Random walk through a large permutation
+    std::vector<uint32_t> perm(N);
+    uint64_t state = 88172645463325252ULL;
+    for (int i = 0; i < N; i++) {
+        perm[i] = i;
+    }
+    for (int i = N - 1; i > 0; i--) {
+        state ^= state << 13;
+        state ^= state >> 7;
+        state ^= state << 17;
+        int j = state % (i + 1);
+        uint32_t tmp = perm[i];
+        perm[i] = perm[j];
+        perm[j] = tmp;
+    }
+    uint64_t checksum = 0;
+    uint32_t pos = 0;
+    for (int i = 0; i < 3 * N; i++) {
+        pos = perm[pos];
+        checksum = checksum * 31 + pos;
+    }
+
+    // Integer arithmetic
+    uint64_t acc = 1;
+    for (int i = 1; i <= 5 * N; i++) {
+        acc = (acc * 1000003 + i) % 998244353;
+    }
+
+    printf("%d %llu %llu\n", primes, (unsigned long long)checksum, (unsigned long long)acc);
+    return 0;
+}
@@ -0,0 +1,59 @@
+package kilonova
+
+import "time"
+
+type HackStatus string
+
+const (
+	HackStatusNone HackStatus = ""
+	// HackPending hacks are waiting to be picked up by the grader
+	HackPending HackStatus = "pending"
+	HackRunning HackStatus = "running"
+	// HackSuccessful means the target submission failed on the hack input
+	HackSuccessful HackStatus = "successful"
+	// HackUnsuccessful means the target submission passed the hack input
+	HackUnsuccessful HackStatus = "unsuccessful"
+	// HackInvalid means the input was rejected by the validator or the author solution could not produce an output
+	HackInvalid HackStatus = "invalid"
+)
+
+// Hack is an attempt of a contestant to break another contestant's accepted submission with a custom input
+type Hack struct {
+	ID        int       `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	ContestID    int `json:"contest_id"`
+	ProblemID    int `json:"problem_id"`
+	SubmissionID int `json:"submission_id"`
+	HackerID     int `json:"hacker_id"`
+	// TargetID is the author of the hacked submission
+	TargetID int `json:"target_id"`
+
+	Status  HackStatus `json:"status"`
+	Verdict string     `json:"verdict"`
+
+	// TestID is the test that was added to the problem after a successful hack
+	TestID *int `json:"test_id"`
+}
+
+type HackFilter struct {
+	ID           *int       `json:"id"`
+	ContestID    *int       `json:"contest_id"`
+	ProblemID    *int       `json:"problem_id"`
+	SubmissionID *int       `json:"submission_id"`
+	HackerID     *int       `json:"hacker_id"`
+	TargetID     *int       `json:"target_id"`
+	Status       HackStatus `json:"status"`
+
+	// InvolvedUserID filters the hacks where the user is either the hacker or the target
+	InvolvedUserID *int `json:"involved_user_id"`
+
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+type HackUpdate struct {
+	Status  HackStatus `json:"status"`
+	Verdict *string    `json:"verdict"`
+	TestID  *int       `json:"test_id"`
+}
@@ -17,6 +17,7 @@ var (
 	Email      EmailConf
 	Frontend   FrontendConf
 	Donations  DonationConf
+	Storage    StorageConf
 )
 
 // configStruct is the glue for all configuration sections when unmarshaling
@@ -27,6 +28,7 @@ type configStruct struct {
 	Email     EmailConf    `toml:"email"`
 	Frontend  FrontendConf `toml:"frontend"`
 	Donations DonationConf `toml:"donations"`
+	Storage   StorageConf  `toml:"storage"`
 }
 
 // EmailConf is the data required for the email part
@@ -61,6 +63,30 @@ type CommonConf struct {
 	TestMaxMemKB int `toml:"test_max_mem_kb"`
 }
 
+// StorageConf selects where the data buckets are stored
+type StorageConf struct {
+	// Backends maps bucket names (tests, attachments, etc.) to their storage backend, either "local" or "s3".
+	// Buckets not specified here are stored locally, in the data directory
+	Backends map[string]string `toml:"backends"`
+	// Compression maps bucket names to the codec used for new files, in the form of "none", "gzip", "zstd" or "codec:level".
+	// Existing files are converted by running the recompression job from the admin panel
+	Compression map[string]string `toml:"compression"`
+
+	S3 S3Conf `toml:"s3"`
+}
+
+// S3Conf is the data required for connecting to an S3-compatible object store
+type S3Conf struct {
+	Endpoint  string `toml:"endpoint"`
+	Region    string `toml:"region"`
+	Bucket    string `toml:"bucket"`
+	AccessKey string `toml:"access_key"`
+	SecretKey string `toml:"secret_key"`
+	// PathStyle must be enabled for most self-hosted servers, such as MinIO
+	PathStyle bool   `toml:"path_style"`
+	Prefix    string `toml:"prefix"`
+}
+
 type DonationConf struct {
 	BuyMeACoffeeName  string `toml:"buy_coffee_name"`
 	BMACWebhookSecret string `toml:"bmac_webhook_secret"`
@@ -84,6 +110,7 @@ func spread() {
 	Eval = c.Eval
 	Frontend = c.Frontend
 	Donations = c.Donations
+	Storage = c.Storage
 }
 
 func compactify() {
@@ -92,6 +119,7 @@ func compactify() {
 	c.Eval = Eval
 	c.Frontend = Frontend
 	c.Donations = Donations
+	c.Storage = Storage
 }
 
 func SetConfigPath(path string) {
@@ -148,8 +148,9 @@ func AttachmentContext(ctx context.Context) *kilonova.Attachment {
 	return getValueContext[kilonova.Attachment](ctx, AttachmentKey)
 }
 
-func BucketContext(ctx context.Context) *datastore.Bucket {
-	return getValueContext[datastore.Bucket](ctx, BucketKey)
+func BucketContext(ctx context.Context) datastore.Bucket {
+	b, _ := ctx.Value(BucketKey).(datastore.Bucket)
+	return b
 }
 
 func Tag(r *http.Request) *kilonova.Tag {
@@ -1,6 +1,10 @@
 package kilonova
 
-import "github.com/gosimple/slug"
+import (
+	"time"
+
+	"github.com/gosimple/slug"
+)
 
 type Mailer interface {
 	SendEmail(msg *MailerMessage) error
@@ -24,6 +28,14 @@ type MarkdownRenderer interface {
 	Render(src []byte, ctx *RenderContext) ([]byte, error)
 }
 
+// GraderHost holds the result of the calibration benchmark ran by a grader at startup.
+// A SpeedFactor of 2 means that the host is twice as slow as the reference machine.
+type GraderHost struct {
+	Hostname     string    `json:"hostname"`
+	SpeedFactor  float64   `json:"speed_factor"`
+	CalibratedAt time.Time `json:"calibrated_at"`
+}
+
 func MakeSlug(org string) string {
 	return slug.MakeLang(org, "ro")
 }
@@ -2,6 +2,8 @@ package kilonova
 
 import (
 	"log/slog"
+	"math"
+	"slices"
 	"time"
 
 	"github.com/KiloProjects/kilonova/internal/config"
@@ -22,6 +24,16 @@ const (
 	ScoringTypeICPC        ScoringType = "acm-icpc"
 )
 
+// ObjectiveType marks a problem as an optimization problem, where the checker reports a raw objective value
+// and test scores are relative to the best known value
+type ObjectiveType string
+
+const (
+	ObjectiveNone     ObjectiveType = ""
+	ObjectiveMaximize ObjectiveType = "maximize"
+	ObjectiveMinimize ObjectiveType = "minimize"
+)
+
 type Problem struct {
 	ID        int       `json:"id"`
 	CreatedAt time.Time `json:"created_at"`
@@ -38,6 +50,20 @@ type Problem struct {
 	MemoryLimit int     `json:"memory_limit"`
 	SourceSize  int     `json:"source_size"`
 
+	// TimeLimitFactor is the speed factor of the grader when the time limit was last set.
+	// If nil, the time limit is applied as-is on every grader.
+	TimeLimitFactor *float64 `json:"time_limit_factor"`
+
+	// StackLimit is in kilobytes. If 0, the sandbox's default is used
+	StackLimit int `json:"stack_limit"`
+
+	// CompileFlags are appended to the compile command of submissions (together with the graders), if the language accepts them.
+	// GraderCompileFlags are appended after them when the submission is built with the problem's graders.
+	// CheckerCompileFlags are appended to the compile command of the checker. All are space-separated
+	CompileFlags        string `json:"compile_flags"`
+	GraderCompileFlags  string `json:"grader_compile_flags"`
+	CheckerCompileFlags string `json:"checker_compile_flags"`
+
 	SourceCredits string `json:"source_credits"`
 
 	// Used only for leaderboard scoring right now
@@ -49,12 +75,118 @@ type Problem struct {
 
 	PublishedAt     *time.Time  `json:"published_at"`
 	ScoringStrategy ScoringType `json:"scoring_strategy"`
+
+	// Relative scoring stuff, only used if ObjectiveType is set
+	ObjectiveType     ObjectiveType   `json:"objective_type"`
+	ObjectiveExponent decimal.Decimal `json:"objective_exponent"`
 }
 
 func (pb *Problem) LogValue() slog.Value {
 	return slog.GroupValue(slog.Int("id", pb.ID), slog.String("name", pb.Name))
 }
 
+// ScaledTimeLimit returns the time limit adjusted for a grader with the given speed factor,
+// such that a solution takes up the same fraction of the limit as on the reference grader.
+func (pb *Problem) ScaledTimeLimit(speedFactor float64) float64 {
+	return pb.scaleTime(pb.TimeLimit, speedFactor)
+}
+
+func (pb *Problem) scaleTime(timeLimit float64, speedFactor float64) float64 {
+	if pb.TimeLimitFactor == nil || *pb.TimeLimitFactor <= 0 || speedFactor <= 0 {
+		return timeLimit
+	}
+	return timeLimit * speedFactor / *pb.TimeLimitFactor
+}
+
+// TestLimits returns the time and memory limits the test is evaluated with.
+// The test's own overrides take precedence, followed by the most permissive override of the subtasks containing it.
+// Time limits are scaled to the given speed factor, just like ScaledTimeLimit.
+func (pb *Problem) TestLimits(test *Test, subtasks []*SubTask, speedFactor float64) (float64, int) {
+	var timeLimit *float64
+	var memoryLimit *int
+	for _, st := range subtasks {
+		if !slices.Contains(st.Tests, test.ID) {
+			continue
+		}
+		if st.TimeLimit != nil && (timeLimit == nil || *st.TimeLimit > *timeLimit) {
+			timeLimit = st.TimeLimit
+		}
+		if st.MemoryLimit != nil && (memoryLimit == nil || *st.MemoryLimit > *memoryLimit) {
+			memoryLimit = st.MemoryLimit
+		}
+	}
+	if test.TimeLimit != nil {
+		timeLimit = test.TimeLimit
+	}
+	if test.MemoryLimit != nil {
+		memoryLimit = test.MemoryLimit
+	}
+
+	tl, ml := pb.TimeLimit, pb.MemoryLimit
+	if timeLimit != nil {
+		tl = *timeLimit
+	}
+	if memoryLimit != nil {
+		ml = *memoryLimit
+	}
+	return pb.scaleTime(tl, speedFactor), ml
+}
+
+// SpeedMismatch returns true if the speed factor deviates from the problem's reference factor by more than the given tolerance (ie. 0.2 for 20%)
+func (pb *Problem) SpeedMismatch(speedFactor float64, tolerance float64) bool {
+	if pb.TimeLimitFactor == nil || *pb.TimeLimitFactor <= 0 || speedFactor <= 0 {
+		return false
+	}
+	return math.Abs(speedFactor / *pb.TimeLimitFactor - 1) > tolerance
+}
+
+// IsRelative returns true if test scores are computed relative to the best known objective value
+func (pb *Problem) IsRelative() bool {
+	return pb.ObjectiveType == ObjectiveMaximize || pb.ObjectiveType == ObjectiveMinimize
+}
+
+// Improves reports whether the objective value is strictly better than the current best.
+// A nil best means that no value is known yet.
+func (pb *Problem) Improves(value decimal.Decimal, best *decimal.Decimal) bool {
+	if best == nil {
+		return true
+	}
+	if pb.ObjectiveType == ObjectiveMinimize {
+		return value.LessThan(*best)
+	}
+	return value.GreaterThan(*best)
+}
+
+// RelativePercentage computes the percentage [0, 100] of a test given the objective value and the best known value.
+// The formula is 100 * ratio^ObjectiveExponent, where ratio is value/best when maximizing and best/value when minimizing.
+// If the ratio can't be computed (non-positive values), only the best value gets the full percentage.
+func (pb *Problem) RelativePercentage(value, best decimal.Decimal) decimal.Decimal {
+	hundred := decimal.NewFromInt(100)
+	if !pb.Improves(best, &value) {
+		// best is not better than value, so value is (one of) the best
+		return hundred
+	}
+	if !value.IsPositive() || !best.IsPositive() {
+		return decimal.Zero
+	}
+	var ratio decimal.Decimal
+	if pb.ObjectiveType == ObjectiveMinimize {
+		ratio = best.Div(value)
+	} else {
+		ratio = value.Div(best)
+	}
+	exp := pb.ObjectiveExponent
+	if !exp.IsPositive() {
+		exp = decimal.NewFromInt(1)
+	}
+	// The ratio is in (0, 1), so the power is well defined
+	pow, err := ratio.PowWithPrecision(exp, 16)
+	if err != nil {
+		return decimal.Zero
+	}
+	return pow.Mul(hundred).Round(4)
+}
+
 type StatementVariant struct {
 	// Language, ie. ro/en
 	Language string `json:"lang"`
@@ -146,6 +278,14 @@ type ProblemUpdate struct {
 	MemoryLimit *int     `json:"memory_limit"`
 	SourceSize  *int     `json:"source_size"`
 
+	// TimeLimitFactor is set automatically when the time limit changes
+	TimeLimitFactor *float64 `json:"-"`
+
+	StackLimit          *int    `json:"stack_limit"`
+	CompileFlags        *string `json:"compile_flags"`
+	GraderCompileFlags  *string `json:"grader_compile_flags"`
+	CheckerCompileFlags *string `json:"checker_compile_flags"`
+
 	SourceCredits *string `json:"source_credits"`
 
 	ConsoleInput *bool `json:"console_input"`
@@ -154,6 +294,10 @@ type ProblemUpdate struct {
 
 	ScorePrecision  *int32      `json:"score_precision"`
 	ScoringStrategy ScoringType `json:"scoring_strategy"`
+
+	// ObjectiveType is a pointer, since ObjectiveNone is a valid value to update to
+	ObjectiveType     *ObjectiveType   `json:"objective_type"`
+	ObjectiveExponent *decimal.Decimal `json:"objective_exponent"`
 }
 
 type Attachment struct {
@@ -169,6 +313,8 @@ type Attachment struct {
 	Name string `json:"name"`
 	// Data []byte `json:"data,omitempty"`
 	Size int `json:"data_size"`
+	// Hash is the hex encoded SHA-256 of the data
+	Hash string `json:"data_hash"`
 }
 
 // Should be used only for interacting with db from sudoapi
@@ -204,6 +350,11 @@ type ProblemEvalSettings struct {
 	// If problem has custom checker that is marked as legacy
 	LegacyChecker bool `json:"legacy_checker"`
 
+	// If problem has an input validator (used for hacks), this is non-empty
+	ValidatorName string `json:"validator_name"`
+	// If problem has an author solution (used to generate hack outputs), this is non-empty
+	SolutionName string `json:"solution_name"`
+
 	// Stores the list of languages that are allowed to be submitted based on existing attachments
 	LanguageWhitelist []string `json:"lang_whitelist"`
 }
@@ -223,3 +374,33 @@ type ProblemChecklist struct {
 
 	NumSolutions int `json:"num_sols" db:"num_sols"`
 }
+
+// SolutionTiming is the running time of a solution, as used for time limit recommendations
+type SolutionTiming struct {
+	SubmissionID int    `json:"submission_id"`
+	Language     string `json:"language"`
+
+	// MaxTime is the maximum running time over all tests (and runs), in seconds
+	MaxTime float64 `json:"max_time"`
+	// TimedOut is true if the solution exceeded the time limit of the runs, so MaxTime is only a lower bound
+	TimedOut bool `json:"timed_out"`
+	// Verdict is set if the solution failed for reasons other than timing out
+	Verdict string `json:"verdict,omitempty"`
+
+	// Slow is true if the solution was marked as one that should not pass the time limit
+	Slow bool `json:"slow"`
+	// Measured is true if the time comes from fresh runs, rather than from the existing submission results
+	Measured bool `json:"measured"`
+}
+
+type TimeLimitRecommendation struct {
+	Solutions []*SolutionTiming `json:"solutions"`
+	// Languages holds the maximum time of the intended solutions for every language
+	Languages map[string]float64 `json:"languages"`
+
+	SlowestIntended float64 `json:"slowest_intended"`
+	Recommended     float64 `json:"recommended"`
+
+	// Warnings holds english messages about the intended or slow solutions that conflict with the recommendation
+	Warnings []string `json:"warnings"`
+}
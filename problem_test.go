@@ -0,0 +1,76 @@
+package kilonova
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestRelativePercentage(t *testing.T) {
+	tests := []struct {
+		name      string
+		objective ObjectiveType
+		exponent  string
+		value     string
+		best      string
+		expected  string
+	}{
+		{"best maximize", ObjectiveMaximize, "1", "10", "10", "100"},
+		{"better than best", ObjectiveMaximize, "1", "12", "10", "100"},
+		{"half maximize", ObjectiveMaximize, "1", "5", "10", "50"},
+		{"half minimize", ObjectiveMinimize, "1", "20", "10", "50"},
+		{"squared", ObjectiveMaximize, "2", "5", "10", "25"},
+		{"square root", ObjectiveMaximize, "0.5", "1", "4", "50"},
+		{"default exponent", ObjectiveMaximize, "0", "1", "4", "25"},
+		{"precise ratio", ObjectiveMinimize, "1", "3", "1", "33.3333"},
+		{"small difference", ObjectiveMaximize, "3", "0.999999", "1", "99.9997"},
+		{"non-positive value", ObjectiveMaximize, "1", "-1", "10", "0"},
+		{"non-positive best", ObjectiveMinimize, "1", "5", "-1", "0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pb := &Problem{ObjectiveType: tt.objective, ObjectiveExponent: decimal.RequireFromString(tt.exponent)}
+			got := pb.RelativePercentage(decimal.RequireFromString(tt.value), decimal.RequireFromString(tt.best))
+			if !got.Equal(decimal.RequireFromString(tt.expected)) {
+				t.Errorf("Expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestTestLimits(t *testing.T) {
+	ptr := func(v float64) *float64 { return &v }
+	iptr := func(v int) *int { return &v }
+	subtasks := []*SubTask{
+		{Tests: []int{1, 2}, TimeLimit: ptr(2), MemoryLimit: iptr(1024)},
+		{Tests: []int{2, 3}, TimeLimit: ptr(3)},
+		{Tests: []int{3}, TimeLimit: ptr(0.5), MemoryLimit: iptr(512)},
+	}
+	tests := []struct {
+		name        string
+		test        *Test
+		factor      *float64
+		speedFactor float64
+		time        float64
+		memory      int
+	}{
+		{"problem limits", &Test{ID: 4}, nil, 1, 1, 65536},
+		{"subtask limits", &Test{ID: 1}, nil, 1, 2, 1024},
+		{"most permissive subtask", &Test{ID: 2}, nil, 1, 3, 1024},
+		{"partial subtask limits", &Test{ID: 3}, nil, 1, 3, 512},
+		{"test limits", &Test{ID: 2, TimeLimit: ptr(0.25), MemoryLimit: iptr(256)}, nil, 1, 0.25, 256},
+		{"partial test limits", &Test{ID: 1, MemoryLimit: iptr(2048)}, nil, 1, 2, 2048},
+		{"scaled problem limit", &Test{ID: 4}, ptr(2), 3, 1.5, 65536},
+		{"scaled test limit", &Test{ID: 4, TimeLimit: ptr(2)}, ptr(2), 1, 1, 65536},
+		{"unscaled without factor", &Test{ID: 1}, nil, 4, 2, 1024},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pb := &Problem{TimeLimit: 1, MemoryLimit: 65536, TimeLimitFactor: tt.factor}
+			tl, ml := pb.TestLimits(tt.test, subtasks, tt.speedFactor)
+			if tl != tt.time || ml != tt.memory {
+				t.Errorf("Expected limits %v s and %d KB, got %v s and %d KB", tt.time, tt.memory, tl, ml)
+			}
+		})
+	}
+}
@@ -27,6 +27,26 @@ const (
 	EvalTypeICPC    EvalType = "acm-icpc"
 )
 
+// Expected verdicts of the reference solutions written by the problem authors.
+// They're named after the submission directories of Kattis problem packages.
+const (
+	ExpectedAccepted          = "accepted"
+	ExpectedWrongAnswer       = "wrong_answer"
+	ExpectedTimeLimitExceeded = "time_limit_exceeded"
+	ExpectedRunTimeError      = "run_time_error"
+	// ExpectedRejected is for solutions that must fail, without a specific verdict
+	ExpectedRejected = "rejected"
+)
+
+// ValidExpectedVerdict returns true if the verdict is one of the expected verdicts
+func ValidExpectedVerdict(verdict string) bool {
+	switch verdict {
+	case ExpectedAccepted, ExpectedWrongAnswer, ExpectedTimeLimitExceeded, ExpectedRunTimeError, ExpectedRejected:
+		return true
+	}
+	return false
+}
+
 type Submission struct {
 	ID        int       `json:"id"`
 	CreatedAt time.Time `json:"created_at"`
@@ -54,6 +74,35 @@ type Submission struct {
 
 	SubmissionType EvalType `json:"submission_type"`
 	ICPCVerdict    *string  `json:"icpc_verdict"`
+
+	// PretestsOnly is true if the submission was judged only on the problem's pretests
+	PretestsOnly bool `json:"pretests_only"`
+
+	// NumFiles is the number of source files besides the entrypoint, for multi-file submissions
+	NumFiles int `json:"num_files"`
+
+	// SpeedFactor is the speed factor of the grader that evaluated the submission
+	SpeedFactor *float64 `json:"speed_factor"`
+
+	// Cancelled is true if the evaluation was stopped before finishing. The tests that were not evaluated are marked as skipped
+	Cancelled bool `json:"cancelled"`
+
+	// LanguageVersion and SandboxVersion identify the toolchain the submission was last evaluated with.
+	// Subtests kept from earlier evaluations may have been judged with another one, see SubTest.
+	// They are shown only to problem editors.
+	LanguageVersion *string `json:"language_version"`
+	SandboxVersion  *string `json:"sandbox_version"`
+
+	// ExpectedVerdict is set for the reference solutions of imported problems. It's shown only to problem editors
+	ExpectedVerdict *string `json:"expected_verdict"`
+}
+
+// SubmissionFile is an additional source file of a multi-file submission.
+// The entrypoint is stored as the submission code.
+type SubmissionFile struct {
+	// Name is the path of the file, relative to the entrypoint's directory
+	Name string `json:"name"`
+	Data []byte `json:"data"`
 }
 
 type SubmissionUpdate struct {
@@ -69,6 +118,13 @@ type SubmissionUpdate struct {
 
 	ChangeVerdict bool
 	ICPCVerdict   *string
+
+	SpeedFactor *float64
+
+	LanguageVersion *string
+	SandboxVersion  *string
+
+	ExpectedVerdict *string
 }
 
 type SubmissionFilter struct {
@@ -88,7 +144,14 @@ type SubmissionFilter struct {
 	Lang         *string `json:"lang"`
 	CompileError *bool   `json:"compile_error"`
 
+	// LanguageVersion and SandboxVersion match submissions that have results from the given toolchain,
+	// either from their last evaluation or in the subtests kept from an earlier one. Only problem editors may use them
+	LanguageVersion *string `json:"language_version"`
+	SandboxVersion  *string `json:"sandbox_version"`
+
 	Score *decimal.Decimal `json:"score"`
+	// Accepted matches the submissions that passed every judged test
+	Accepted bool `json:"-"`
 
 	Look        bool       `json:"-"`
 	LookingUser *UserBrief `json:"-"`
@@ -119,6 +182,16 @@ type SubTest struct {
 	VisibleID int `db:"visible_id" json:"visible_id"`
 
 	Score decimal.Decimal `json:"score"`
+
+	// Objective is the raw value reported by the checker for relative scoring problems
+	Objective *decimal.Decimal `json:"objective"`
+
+	// TestHash is the data hash of the test at the time the subtest was evaluated
+	TestHash *string `db:"test_hash" json:"-"`
+
+	// LanguageVersion and SandboxVersion identify the toolchain the subtest was evaluated with
+	LanguageVersion *string `db:"language_version" json:"-"`
+	SandboxVersion  *string `db:"sandbox_version" json:"-"`
 }
 
 type SubTestUpdate struct {
@@ -128,6 +201,11 @@ type SubTestUpdate struct {
 	Verdict    *string
 	Done       *bool
 	Skipped    *bool
+	Objective  *decimal.Decimal
+	TestHash   *string
+
+	LanguageVersion *string
+	SandboxVersion  *string
 }
 
 type SubmissionSubTask struct {
@@ -149,9 +227,25 @@ type SubmissionSubTask struct {
 
 	ScorePrecision int `json:"score_precision"`
 
+	Aggregation SubtaskAggregation `json:"aggregation"`
+	Threshold   decimal.Decimal    `json:"threshold"`
+
 	Subtests []int `json:"subtests"`
 }
 
+// SubmissionScoreChange compares the score of a submission before and after the problem's last selective reevaluation
+type SubmissionScoreChange struct {
+	SubmissionID int    `db:"submission_id" json:"submission_id"`
+	UserID       int    `db:"user_id" json:"user_id"`
+	Status       Status `json:"status"`
+
+	OldScore decimal.Decimal `db:"old_score" json:"old_score"`
+	// NewScore is only final once the submission is finished
+	NewScore decimal.Decimal `db:"new_score" json:"new_score"`
+
+	ReevaluatedAt time.Time `db:"reevaluated_at" json:"reevaluated_at"`
+}
+
 type SubmissionPaste struct {
 	ID         string      `json:"id"`
 	Submission *Submission `json:"sub"`
@@ -168,9 +262,35 @@ type FullSubmission struct {
 
 	// TODO: maybe remove?
 	Code []byte `json:"code"`
+	// Files holds the additional source files, for multi-file submissions
+	Files []*SubmissionFile `json:"files"`
 
 	// ProblemEditor returns whether the looking user is a problem editor
 	ProblemEditor bool `json:"problem_editor"`
+	// SpeedMismatch is true if the submission was evaluated on a grader far from the problem's reference speed. Only set for problem editors
+	SpeedMismatch bool `json:"speed_mismatch"`
+	// Toolchains holds the toolchains of all evaluations of the submission, oldest first. Only set for problem editors
+	Toolchains []*SubmissionToolchain `json:"toolchains,omitempty"`
 
 	CodeTrulyVisible bool `json:"truly_visible"`
 }
+
+// SubmissionToolchain records the toolchain used for one evaluation of a submission
+type SubmissionToolchain struct {
+	LanguageVersion *string   `db:"language_version" json:"language_version"`
+	SandboxVersion  *string   `db:"sandbox_version" json:"sandbox_version"`
+	EvaluatedAt     time.Time `db:"evaluated_at" json:"evaluated_at"`
+}
+
+// CustomRun is the result of running code once on user-provided input, without creating a submission
+type CustomRun struct {
+	CompileError   bool   `json:"compile_error"`
+	CompileMessage string `json:"compile_message"`
+
+	Stdout   string  `json:"stdout"`
+	Stderr   string  `json:"stderr"`
+	Time     float64 `json:"time"`
+	Memory   int     `json:"memory"`
+	ExitCode int     `json:"exit_code"`
+	Verdict  string  `json:"verdict"`
+}
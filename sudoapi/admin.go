@@ -448,6 +448,16 @@ func (s *BaseAPI) cleanupBuckets() {
 		}
 		s.evictionLogger.Info("Deleted bucket objects", slog.Any("bucket", bucket), slog.Int("count", numDeleted))
 	}
+	for _, bucket := range datastore.GetBuckets() {
+		gc, ok := bucket.(datastore.ContentAddressed)
+		if !ok {
+			continue
+		}
+		if _, err := gc.CollectGarbage(context.Background(), s.evictionLogger); err != nil {
+			s.evictionLogger.Error(err.Error())
+			zap.S().Warn("Error collecting unreferenced blobs. Check eviction.log for details")
+		}
+	}
 }
 
 func (s *BaseAPI) refreshProblemStatsJob(ctx context.Context, interval time.Duration) error {
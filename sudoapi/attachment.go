@@ -391,6 +391,14 @@ func (s *BaseAPI) ProblemSettings(ctx context.Context, problemID int) (*kilonova
 			settings.LegacyChecker = false
 			continue
 		}
+		if filename == "validator" && eval.GetLangByFilename(att.Name) != "" {
+			settings.ValidatorName = att.Name
+			continue
+		}
+		if filename == "solution" && eval.GetLangByFilename(att.Name) != "" {
+			settings.SolutionName = att.Name
+			continue
+		}
 
 		if att.Name[0] == '_' {
 			continue
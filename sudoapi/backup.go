@@ -0,0 +1,531 @@
+package sudoapi
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/datastore"
+	"github.com/KiloProjects/kilonova/db"
+	"github.com/KiloProjects/kilonova/internal/config"
+	"github.com/klauspost/compress/zstd"
+	"go.uber.org/zap"
+)
+
+const (
+	backupManifestName = "manifest.json"
+	backupDatabaseName = "database.dump"
+	backupBlobPrefix   = "blobs/"
+)
+
+// BackupManifest describes the contents of a backup archive. It is the last file of the archive
+type BackupManifest struct {
+	ID            string    `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	Version       string    `json:"version"`
+	SchemaVersion int       `json:"schema_version"`
+
+	// BaseID is set for incremental backups, to the ID of the backup holding the contents not stored in this one
+	BaseID string `json:"base_id,omitempty"`
+
+	// DatabaseHash is the SHA256 hash of the pg_dump archive
+	DatabaseHash string `json:"database_hash"`
+	// Buckets maps the bucket names to their files, which map to the SHA256 hash of their contents
+	Buckets map[string]map[string]string `json:"buckets"`
+	// Blobs are the hashes of the contents stored in this archive, compressed with zstd
+	Blobs []string `json:"blobs"`
+}
+
+// contentHashes returns the hashes of the contents available in the backup or in its base backups
+func (m *BackupManifest) contentHashes() map[string]bool {
+	hashes := make(map[string]bool)
+	for _, files := range m.Buckets {
+		for _, hash := range files {
+			hashes[hash] = true
+		}
+	}
+	return hashes
+}
+
+// backupBuckets are the buckets whose files can't be regenerated. Attachments are stored in the database
+var backupBuckets = []datastore.BucketType{datastore.BucketTypeTests}
+
+// Backup writes a tar archive holding the database dump and the persistent buckets.
+// The files are chosen using the same database snapshot as the dump, so the archive is consistent.
+// If the base backup is given, contents already stored in it (or in its own base backups) are skipped.
+func (s *BaseAPI) Backup(ctx context.Context, w io.Writer, base *BackupManifest) (*BackupManifest, *StatusError) {
+	snap, err := s.db.ExportSnapshot(ctx)
+	if err != nil {
+		return nil, WrapError(err, "Couldn't export database snapshot")
+	}
+	defer snap.Close(context.WithoutCancel(ctx))
+
+	manifest := &BackupManifest{
+		ID:        time.Now().UTC().Format("20060102-150405") + "-" + kilonova.RandomString(6),
+		CreatedAt: time.Now(),
+		Version:   kilonova.Version,
+
+		Buckets: make(map[string]map[string]string),
+		Blobs:   []string{},
+	}
+	// known holds the hashes of the contents stored in the archive or in the base backup
+	known := make(map[string]bool)
+	if base != nil {
+		manifest.BaseID = base.ID
+		known = base.contentHashes()
+	}
+	manifest.SchemaVersion, err = snap.SchemaVersion(ctx)
+	if err != nil {
+		return nil, WrapError(err, "Couldn't get schema version")
+	}
+
+	tw := tar.NewWriter(w)
+	manifest.DatabaseHash, err = s.backupDatabase(ctx, tw, snap.ID)
+	if err != nil {
+		return nil, WrapError(err, "Couldn't dump database")
+	}
+
+	tests, err := snap.Tests(ctx)
+	if err != nil {
+		return nil, WrapError(err, "Couldn't get tests")
+	}
+	// The data of the hacks being judged is kept as well, since they are judged again after a restore
+	hacks, err := snap.JudgingHacks(ctx)
+	if err != nil {
+		return nil, WrapError(err, "Couldn't get hacks")
+	}
+	expected, optional := testFileNames(tests, hacks)
+	for _, bucketType := range backupBuckets {
+		bucket := datastore.GetBucket(bucketType)
+		refs, err := snap.BlobRefs(ctx, bucket.Name())
+		if err != nil {
+			return nil, WrapError(err, "Couldn't get bucket references")
+		}
+		refHashes := make(map[string]string, len(refs))
+		for _, ref := range refs {
+			refHashes[ref.Name] = ref.Hash
+		}
+
+		files := make(map[string]string)
+		manifest.Buckets[bucket.Name()] = files
+		names := make([]string, 0, len(expected))
+		for name := range expected {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			if err := ctx.Err(); err != nil {
+				return nil, WrapError(err, "Backup interrupted")
+			}
+			hash, err := s.backupFile(tw, bucket, name, refHashes[name], manifest, known)
+			if errors.Is(err, kilonova.ErrNotExist) {
+				if !optional[name] {
+					zap.S().Warnf("Missing file %q in bucket %q, skipping", name, bucket.Name())
+				}
+				continue
+			}
+			if err != nil {
+				return nil, WrapError(err, fmt.Sprintf("Couldn't back up file %q", name))
+			}
+			files[name] = hash
+		}
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "\t")
+	if err != nil {
+		return nil, WrapError(err, "Couldn't encode manifest")
+	}
+	if err := writeTarFile(tw, backupManifestName, int64(len(data)), strings.NewReader(string(data))); err != nil {
+		return nil, WrapError(err, "Couldn't write manifest")
+	}
+	if err := tw.Close(); err != nil {
+		return nil, WrapError(err, "Couldn't finish archive")
+	}
+	return manifest, nil
+}
+
+// backupDatabase runs pg_dump on the exported snapshot and returns the hash of the dump
+func (s *BaseAPI) backupDatabase(ctx context.Context, tw *tar.Writer, snapshotID string) (string, error) {
+	f, err := os.CreateTemp("", "kn-backup-db-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	h := sha256.New()
+	var stderr strings.Builder
+	cmd, err := postgresCommand(ctx, "pg_dump", "--format=custom", "--snapshot="+snapshotID)
+	if err != nil {
+		return "", err
+	}
+	cmd.Stdout = io.MultiWriter(f, h)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("pg_dump: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	size, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), writeTarFile(tw, backupDatabaseName, size, f)
+}
+
+// backupFile stores the file's contents in the archive, unless they are already known.
+// refHash is set if the bucket is content-addressed and the file is in the snapshot's references
+func (s *BaseAPI) backupFile(tw *tar.Writer, bucket datastore.Bucket, name string, refHash string, manifest *BackupManifest, known map[string]bool) (string, error) {
+	if refHash != "" && known[refHash] {
+		return refHash, nil
+	}
+
+	var r io.ReadCloser
+	var err error
+	if refHash != "" {
+		// The blob of the snapshot's reference is read, even if the file was changed since
+		r, err = datastore.BlobReader(bucket, refHash)
+	} else {
+		r, err = bucket.Reader(name)
+	}
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	f, err := os.CreateTemp("", "kn-backup-blob-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	h := sha256.New()
+	zw, err := zstd.NewWriter(f, zstd.WithEncoderConcurrency(1))
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(io.MultiWriter(zw, h), r)
+	if err1 := zw.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	if err != nil {
+		return "", err
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+	if refHash != "" && hash != refHash {
+		return "", fmt.Errorf("blob %s is corrupt", refHash)
+	}
+	if known[hash] {
+		return hash, nil
+	}
+
+	size, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	if err := writeTarFile(tw, backupBlobPrefix+hash+".zst", size, f); err != nil {
+		return "", err
+	}
+	manifest.Blobs = append(manifest.Blobs, hash)
+	known[hash] = true
+	return hash, nil
+}
+
+// postgresCommand prepares the PostgreSQL client tool to connect to the database.
+// The password is passed through PGPASSWORD, since the command line is visible in the process list
+func postgresCommand(ctx context.Context, name string, args ...string) (*exec.Cmd, error) {
+	dsn, password, err := splitDSNPassword(config.Common.DBDSN)
+	if err != nil {
+		return nil, fmt.Errorf("invalid database connection string: %w", err)
+	}
+	cmd := exec.CommandContext(ctx, name, append(args, "--dbname="+dsn)...)
+	if password != "" {
+		cmd.Env = append(os.Environ(), "PGPASSWORD="+password)
+	}
+	return cmd, nil
+}
+
+// splitDSNPassword removes the password from the connection string and returns it separately.
+// Both connection URIs and keyword/value connection strings are supported
+func splitDSNPassword(dsn string) (string, string, error) {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return "", "", err
+		}
+		var password string
+		if u.User != nil {
+			password, _ = u.User.Password()
+			u.User = url.User(u.User.Username())
+		}
+		if q := u.Query(); q.Has("password") {
+			password = q.Get("password")
+			q.Del("password")
+			u.RawQuery = q.Encode()
+		}
+		return u.String(), password, nil
+	}
+
+	var kept []string
+	var password string
+	rest := strings.TrimSpace(dsn)
+	for rest != "" {
+		key, after, ok := strings.Cut(rest, "=")
+		if !ok {
+			return "", "", fmt.Errorf("missing value for %q", rest)
+		}
+		key = strings.TrimSpace(key)
+		after = strings.TrimLeft(after, " \t\n\r")
+		value, n, err := dsnValue(after)
+		if err != nil {
+			return "", "", err
+		}
+		if key == "password" {
+			password = value
+		} else {
+			kept = append(kept, key+"="+after[:n])
+		}
+		rest = strings.TrimSpace(after[n:])
+	}
+	return strings.Join(kept, " "), password, nil
+}
+
+// dsnValue unquotes the value at the start of s, returning it along with the length of its raw form
+func dsnValue(s string) (string, int, error) {
+	var value strings.Builder
+	quoted := strings.HasPrefix(s, "'")
+	i := 0
+	if quoted {
+		i = 1
+	}
+	for i < len(s) {
+		switch c := s[i]; {
+		case c == '\\' && i+1 < len(s):
+			value.WriteByte(s[i+1])
+			i += 2
+			continue
+		case quoted && c == '\'':
+			return value.String(), i + 1, nil
+		case !quoted && strings.ContainsRune(" \t\n\r", rune(c)):
+			return value.String(), i, nil
+		default:
+			value.WriteByte(c)
+		}
+		i++
+	}
+	if quoted {
+		return "", 0, errors.New("unterminated quoted value")
+	}
+	return value.String(), i, nil
+}
+
+func writeTarFile(tw *tar.Writer, name string, size int64, r io.Reader) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     size,
+		Mode:     0644,
+		ModTime:  time.Now(),
+	}); err != nil {
+		return err
+	}
+	_, err := io.Copy(tw, r)
+	return err
+}
+
+type backupEntry struct {
+	offset int64
+	size   int64
+}
+
+// backupArchive is an opened backup, whose entries are read directly from the file
+type backupArchive struct {
+	f        *os.File
+	entries  map[string]backupEntry
+	manifest *BackupManifest
+}
+
+func openBackupArchive(path string) (*backupArchive, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	archive := &backupArchive{f: f, entries: make(map[string]backupEntry)}
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		// The tar reader doesn't buffer, so the file is positioned at the start of the entry's data
+		offset, err := f.Seek(0, io.SeekCurrent)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		archive.entries[hdr.Name] = backupEntry{offset: offset, size: hdr.Size}
+	}
+
+	r, ok := archive.open(backupManifestName)
+	if !ok {
+		f.Close()
+		return nil, fmt.Errorf("%s is missing the backup manifest", path)
+	}
+	if err := json.NewDecoder(r).Decode(&archive.manifest); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("invalid backup manifest: %w", err)
+	}
+	return archive, nil
+}
+
+func (a *backupArchive) open(name string) (*io.SectionReader, bool) {
+	entry, ok := a.entries[name]
+	if !ok {
+		return nil, false
+	}
+	return io.NewSectionReader(a.f, entry.offset, entry.size), true
+}
+
+// ReadBackupManifest returns the manifest of the backup archive, such as for using it as the base of an incremental backup
+func ReadBackupManifest(path string) (*BackupManifest, error) {
+	archive, err := openBackupArchive(path)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.f.Close()
+	return archive.manifest, nil
+}
+
+// RestoreBackup restores the backup into an instance with an empty database and empty persistent buckets.
+// Incremental backups also need their base backups, from the most recent to the full backup.
+func RestoreBackup(ctx context.Context, archivePath string, basePaths []string) error {
+	var archives []*backupArchive
+	defer func() {
+		for _, archive := range archives {
+			archive.f.Close()
+		}
+	}()
+	for _, path := range append([]string{archivePath}, basePaths...) {
+		archive, err := openBackupArchive(path)
+		if err != nil {
+			return err
+		}
+		if len(archives) > 0 && archives[len(archives)-1].manifest.BaseID != archive.manifest.ID {
+			return fmt.Errorf("%s is not the base of the previous backup", path)
+		}
+		archives = append(archives, archive)
+	}
+	if baseID := archives[len(archives)-1].manifest.BaseID; baseID != "" {
+		return fmt.Errorf("missing base backup %s", baseID)
+	}
+	manifest := archives[0].manifest
+	if manifest.SchemaVersion > db.LatestSchemaVersion() {
+		return fmt.Errorf("backup has schema version %d, newer than the supported %d", manifest.SchemaVersion, db.LatestSchemaVersion())
+	}
+
+	dump, ok := archives[0].open(backupDatabaseName)
+	if !ok {
+		return errors.New("backup is missing the database dump")
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, dump); err != nil {
+		return err
+	}
+	if hex.EncodeToString(h.Sum(nil)) != manifest.DatabaseHash {
+		return errors.New("database dump checksum mismatch")
+	}
+
+	conn, err := db.NewPSQL(ctx, config.Common.DBDSN)
+	if err != nil {
+		return fmt.Errorf("couldn't connect to DB: %w", err)
+	}
+	defer conn.Close()
+	if empty, err := conn.IsEmpty(ctx); err != nil || !empty {
+		if err != nil {
+			return err
+		}
+		return errors.New("database is not empty, refusing to restore")
+	}
+	if err := datastore.InitBuckets(config.Common.DataDir, config.Storage, conn); err != nil {
+		return fmt.Errorf("couldn't initialize data store: %w", err)
+	}
+	for name := range manifest.Buckets {
+		if empty, err := datastore.IsEmpty(datastore.GetBucket(datastore.BucketType(name))); err != nil || !empty {
+			if err != nil {
+				return err
+			}
+			return fmt.Errorf("bucket %q is not empty, refusing to restore", name)
+		}
+	}
+
+	zap.S().Info("Restoring database")
+	var stderr strings.Builder
+	cmd, err := postgresCommand(ctx, "pg_restore", "--no-owner", "--exit-on-error")
+	if err != nil {
+		return err
+	}
+	cmd.Stdin = io.NewSectionReader(dump, 0, dump.Size())
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("pg_restore: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	for bucketName, files := range manifest.Buckets {
+		bucket := datastore.GetBucket(datastore.BucketType(bucketName))
+		zap.S().Infof("Restoring %d files of bucket %q", len(files), bucketName)
+		for name, hash := range files {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := restoreBackupFile(archives, bucket, name, hash); err != nil {
+				return fmt.Errorf("couldn't restore file %q: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func restoreBackupFile(archives []*backupArchive, bucket datastore.Bucket, name string, hash string) error {
+	for _, archive := range archives {
+		r, ok := archive.open(backupBlobPrefix + hash + ".zst")
+		if !ok {
+			continue
+		}
+		zr, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		h := sha256.New()
+		if err := bucket.WriteFile(name, io.TeeReader(zr, h), 0644); err != nil {
+			return err
+		}
+		if hex.EncodeToString(h.Sum(nil)) != hash {
+			return errors.Join(errors.New("checksum mismatch"), bucket.RemoveFile(name))
+		}
+		return nil
+	}
+	return fmt.Errorf("contents %s not found in any backup", hash)
+}
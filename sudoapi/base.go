@@ -37,6 +37,13 @@ type Submissions struct {
 type Grader interface {
 	Wake()
 	LanguageVersions(ctx context.Context) map[string]string
+	// SpeedFactor returns the result of the calibration benchmark relative to the reference machine, or 0 if the grader was not calibrated
+	SpeedFactor() float64
+	BenchmarkSubmission(ctx context.Context, pb *kilonova.Problem, sub *kilonova.Submission, runs int, timeLimit float64) (*kilonova.SolutionTiming, *kilonova.StatusError)
+	// GenerateTests fills in the missing test data by running the generators and the solution
+	GenerateTests(ctx context.Context, gen *kilonova.TestGeneration) *kilonova.StatusError
+	// CancelSubmission stops the evaluation of the submission, if it's running. The grader marks it as cancelled afterwards
+	CancelSubmission(subID int) bool
 }
 
 type BaseAPI struct {
@@ -46,17 +53,23 @@ type BaseAPI struct {
 
 	sessionUserCache *theine.LoadingCache[string, *kilonova.UserFull]
 
-	grader Grader
+	grader        Grader
+	customRuns    customRunLimiter
+	customRunJobs customRunJobs
 
 	logChan chan *logEntry
 
 	dSess *discordgo.Session
 
 	evictionLogger        *slog.Logger
-	testBucket            *datastore.Bucket
-	attachmentCacheBucket *datastore.Bucket
-	subtestBucket         *datastore.Bucket
-	avatarBucket          *datastore.Bucket
+	testBucket            datastore.Bucket
+	attachmentCacheBucket datastore.Bucket
+	subtestBucket         datastore.Bucket
+	avatarBucket          datastore.Bucket
+
+	scrubReports   scrubReports
+	recompressions recompressionJobs
+	timeLimitJobs  timeLimitJobs
 }
 
 func (s *BaseAPI) Start(ctx context.Context) {
@@ -67,6 +80,7 @@ func (s *BaseAPI) Start(ctx context.Context) {
 	go s.cleanupBucketsJob(ctx, 30*time.Minute)
 	go s.refreshProblemStatsJob(ctx, 5*time.Minute)
 	go s.refreshHotProblemsJob(ctx, 4*time.Hour)
+	go s.systemTestingJob(ctx, 1*time.Minute)
 }
 
 func (s *BaseAPI) Close() *StatusError {
@@ -126,10 +140,6 @@ func InitializeBaseAPI(ctx context.Context) (*BaseAPI, *StatusError) {
 		return nil, WrapError(err, "Couldn't create data dir")
 	}
 
-	if err := datastore.InitBuckets(config.Common.DataDir); err != nil {
-		return nil, WrapError(err, "Couldn't initialize data store")
-	}
-
 	var knMailer kilonova.Mailer
 	if config.Email.Enabled {
 		mailer, err := email.NewMailer()
@@ -153,5 +163,10 @@ func InitializeBaseAPI(ctx context.Context) (*BaseAPI, *StatusError) {
 		}
 	}
 
+	// The tests bucket stores its file references in the DB
+	if err := datastore.InitBuckets(config.Common.DataDir, config.Storage, db); err != nil {
+		return nil, WrapError(err, "Couldn't initialize data store")
+	}
+
 	return GetBaseAPI(db, knMailer)
 }
@@ -0,0 +1,53 @@
+package sudoapi
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/KiloProjects/kilonova/datastore"
+	"github.com/KiloProjects/kilonova/internal/config"
+	"go.uber.org/zap"
+)
+
+var (
+	AllowedCompileFlags = config.GenFlag[string]("behavior.problem.allowed_compile_flags", "-O0,-O1,-O2,-O3,-Ofast,-funroll-loops,-fno-stack-protector,-lm,-pthread,-lpthread", "Comma-separated list of flags that problems may add to the compile command. Defines (-DNAME or -DNAME=value) are always allowed")
+)
+
+var compileDefineRegex = regexp.MustCompile(`^-D[A-Za-z_][A-Za-z0-9_]*(=[A-Za-z0-9_.+-]*)?$`)
+
+// ValidateCompileFlags checks that all the space-separated flags are defines or are in the allowlist
+func ValidateCompileFlags(flags string) *StatusError {
+	allowed := strings.Split(AllowedCompileFlags.Value(), ",")
+	for _, flag := range strings.Fields(flags) {
+		if compileDefineRegex.MatchString(flag) || slices.Contains(allowed, flag) {
+			continue
+		}
+		return Statusf(400, "Compile flag %q is not allowed", flag)
+	}
+	return nil
+}
+
+// validateProblemLimits checks the stack limit and the compile flags of the submissions, graders and checker
+func (s *BaseAPI) validateProblemLimits(stackLimit *int, compileFlags ...*string) *StatusError {
+	if stackLimit != nil && (*stackLimit < 0 || *stackLimit > config.Common.TestMaxMemKB) {
+		return Statusf(400, "Stack limit must be between 0 and %d KB", config.Common.TestMaxMemKB)
+	}
+	for _, flags := range compileFlags {
+		if flags == nil {
+			continue
+		}
+		if err := ValidateCompileFlags(*flags); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// invalidateChecker removes the compiled checker of the problem, so that it's compiled again with the new flags
+func invalidateChecker(problemID int) {
+	if err := datastore.GetBucket(datastore.BucketTypeCheckers).RemoveFile(fmt.Sprintf("%d.bin", problemID)); err != nil {
+		zap.S().Warn("Couldn't remove compiled checker: ", err)
+	}
+}
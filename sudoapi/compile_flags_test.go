@@ -0,0 +1,37 @@
+package sudoapi
+
+import "testing"
+
+type compileFlagsTest struct {
+	Flags string
+	Error bool
+}
+
+var compileFlagsExamples = map[string]compileFlagsTest{
+	"empty":              {Flags: ""},
+	"allowed":            {Flags: "-O2 -lm"},
+	"extra_spaces":       {Flags: "  -O3\t-pthread  "},
+	"define":             {Flags: "-DONLINE_JUDGE"},
+	"define_value":       {Flags: "-DMAXN=100000 -DEPS=1e-9"},
+	"fail_unknown":       {Flags: "-O2 -fsanitize=address", Error: true},
+	"fail_include":       {Flags: "-include /etc/passwd", Error: true},
+	"fail_define_name":   {Flags: "-D1NAME", Error: true},
+	"fail_define_quotes": {Flags: `-DNAME="x"`, Error: true},
+	"fail_define_shell":  {Flags: "-DNAME=$(id)", Error: true},
+}
+
+func TestValidateCompileFlags(t *testing.T) {
+	for k, v := range compileFlagsExamples {
+		v := v
+		t.Run(k, func(t *testing.T) {
+			t.Parallel()
+			err := ValidateCompileFlags(v.Flags)
+			if err != nil && !v.Error {
+				t.Fatalf("Error validating compile flags: %#v", err)
+			}
+			if err == nil && v.Error {
+				t.Fatalf("Test should not succeed")
+			}
+		})
+	}
+}
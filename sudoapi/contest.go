@@ -2,6 +2,7 @@ package sudoapi
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"time"
@@ -62,6 +63,12 @@ func (s *BaseAPI) CreateContest(ctx context.Context, name string, cType kilonova
 }
 
 func (s *BaseAPI) UpdateContest(ctx context.Context, id int, upd kilonova.ContestUpdate) *kilonova.StatusError {
+	if upd.HackScore != nil && upd.HackScore.IsNegative() {
+		return Statusf(400, "Hack score must not be negative")
+	}
+	if v := upd.SystemTesting; v != nil && *v != kilonova.SystemTestNone && *v != kilonova.SystemTestFinal && *v != kilonova.SystemTestBest {
+		return Statusf(400, "Invalid system testing mode!")
+	}
 	if err := s.db.UpdateContest(ctx, id, upd); err != nil {
 		zap.S().Warn(err)
 		return WrapError(err, "Couldn't update contest")
@@ -339,7 +346,7 @@ func (s *BaseAPI) RunMOSS(ctx context.Context, contest *kilonova.Contest) *Statu
 				}
 				users[sub.UserID] = true
 
-				code, err := s.RawSubmissionCode(ctx, sub.ID)
+				code, err := s.RawSubmissionSource(ctx, sub)
 				if err != nil {
 					return err
 				}
@@ -367,3 +374,53 @@ func (s *BaseAPI) MOSSSubmissions(ctx context.Context, contestID int) ([]*kilono
 	}
 	return subs, nil
 }
+
+// SystemTestContest queues the submissions picked by the contest's system testing mode for reevaluation on all tests.
+func (s *BaseAPI) SystemTestContest(ctx context.Context, contest *kilonova.Contest) *StatusError {
+	if contest.SystemTesting == kilonova.SystemTestNone {
+		return Statusf(400, "Contest doesn't have system testing enabled")
+	}
+	numSubs, err := s.db.StartSystemTesting(ctx, contest.ID, contest.SystemTesting)
+	if err != nil {
+		zap.S().Warn(err)
+		return WrapError(err, "Couldn't start system testing")
+	}
+	if numSubs < 0 {
+		return Statusf(400, "Contest was already system tested")
+	}
+
+	s.LogVerbose(ctx, "Started system testing", slog.Any("contest", contest), slog.Int("num_subs", numSubs))
+
+	// Wake grader to start processing immediately
+	s.WakeGrader()
+	return nil
+}
+
+func (s *BaseAPI) systemTestingJob(ctx context.Context, interval time.Duration) error {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if !errors.Is(ctx.Err(), context.Canceled) {
+				return ctx.Err()
+			}
+			return nil
+		case <-t.C:
+			ids, err := s.db.PendingSystemTests(ctx)
+			if err != nil {
+				zap.S().Warn("Couldn't get contests pending system testing: ", err)
+				continue
+			}
+			for _, id := range ids {
+				contest, err := s.Contest(ctx, id)
+				if err != nil {
+					continue
+				}
+				if err := s.SystemTestContest(ctx, contest); err != nil {
+					zap.S().Warn(err)
+				}
+			}
+		}
+	}
+}
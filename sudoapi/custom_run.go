@@ -0,0 +1,219 @@
+package sudoapi
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/eval"
+	"github.com/KiloProjects/kilonova/internal/config"
+)
+
+var (
+	CustomRunsEnabled = config.GenFlag[bool]("feature.grader.custom_runs", true, "Allow users to run their code on custom input without submitting")
+	CustomRunLimit    = config.GenFlag[int]("behavior.custom_runs.user_max_minute", 10, "Maximum number of custom runs per minute (for a single user)")
+	CustomRunMaxInput = config.GenFlag[int]("behavior.custom_runs.max_input_size", 1024*1024, "Maximum size of the input for custom runs (in bytes)")
+)
+
+// customRunLimiter keeps track of recent custom runs for every user, separately from submissions.
+// Custom runs are not stored anywhere, so this can't be done by querying the DB.
+type customRunLimiter struct {
+	mu   sync.Mutex
+	runs map[int][]time.Time
+}
+
+// allow returns true and records the run if the user did less than limit runs in the last minute
+func (l *customRunLimiter) allow(userID int, limit int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.runs == nil {
+		l.runs = make(map[int][]time.Time)
+	}
+	since := time.Now().Add(-1 * time.Minute)
+	// Users that didn't run anything in the last minute are forgotten, so the map only holds recent runs
+	for id, runs := range l.runs {
+		if len(runs) == 0 || runs[len(runs)-1].Before(since) {
+			delete(l.runs, id)
+		}
+	}
+	runs := slices.DeleteFunc(l.runs[userID], func(t time.Time) bool { return t.Before(since) })
+	if limit > 0 && len(runs) >= limit {
+		l.runs[userID] = runs
+		return false
+	}
+	l.runs[userID] = append(runs, time.Now())
+	return true
+}
+
+// CustomRunJob is a custom run queued for the grader. Its result is polled by the user that started it.
+// Jobs are only kept in memory, so they are lost when the server restarts
+type CustomRunJob struct {
+	ID int `json:"id"`
+
+	Running bool                `json:"running"`
+	Error   string              `json:"error,omitempty"`
+	Result  *kilonova.CustomRun `json:"result"`
+
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+
+	UserID   int                        `json:"-"`
+	Problem  *kilonova.Problem          `json:"-"`
+	Language string                     `json:"-"`
+	Code     []byte                     `json:"-"`
+	Files    []*kilonova.SubmissionFile `json:"-"`
+	Input    []byte                     `json:"-"`
+}
+
+// Finished custom runs are kept for a while, so their results can still be fetched
+const customRunJobTTL = 10 * time.Minute
+
+type customRunJobs struct {
+	mu     sync.Mutex
+	lastID int
+	jobs   map[int]*CustomRunJob
+	queue  []*CustomRunJob
+}
+
+// prune removes the results that were not fetched in time. The lock must be held
+func (q *customRunJobs) prune() {
+	since := time.Now().Add(-customRunJobTTL)
+	for id, job := range q.jobs {
+		if job.FinishedAt != nil && job.FinishedAt.Before(since) {
+			delete(q.jobs, id)
+		}
+	}
+}
+
+// CustomRun queues the code to be compiled and run once on the given input. The grader picks it up like a submission and the result is polled with CustomRunJob.
+// It mirrors the permission checks of CreateSubmission, but it is rate limited separately and nothing is saved.
+func (s *BaseAPI) CustomRun(ctx context.Context, author *UserFull, problem *kilonova.Problem, code []byte, files []*kilonova.SubmissionFile, lang eval.Language, input []byte, contestID *int) (*CustomRunJob, *StatusError) {
+	if !CustomRunsEnabled.Value() {
+		return nil, Statusf(403, "Custom runs are disabled")
+	}
+	if s.grader == nil {
+		return nil, Statusf(503, "Grader is not running")
+	}
+	if author == nil {
+		return nil, Statusf(400, "Invalid author")
+	}
+	if problem == nil {
+		return nil, Statusf(400, "Invalid problem")
+	}
+	if len(code) == 0 {
+		return nil, Statusf(400, "Empty code")
+	}
+	if len(code) > problem.SourceSize {
+		return nil, Statusf(400, "Code exceeds %d characters", problem.SourceSize)
+	}
+	if err := validateSubmissionFiles(problem, lang, code, files); err != nil {
+		return nil, err
+	}
+	if len(input) > CustomRunMaxInput.Value() {
+		return nil, Statusf(400, "Input exceeds %d bytes", CustomRunMaxInput.Value())
+	}
+	if !s.IsProblemVisible(author.Brief(), problem) {
+		return nil, Statusf(400, "User can't see the problem!")
+	}
+
+	if contestID != nil {
+		contest, err := s.Contest(ctx, *contestID)
+		if err != nil || !s.IsContestVisible(author.Brief(), contest) {
+			return nil, Statusf(404, "Couldn't find contest")
+		}
+		if !s.CanSubmitInContest(author.Brief(), contest) {
+			return nil, Statusf(400, "User cannot submit to contest")
+		}
+		if pb, err := s.ContestProblem(ctx, contest, author.Brief(), problem.ID); err != nil || pb == nil {
+			return nil, Statusf(400, "Problem is not in contest")
+		}
+		if !contest.AllowCustomRuns && !s.IsContestTester(author.Brief(), contest) {
+			return nil, Statusf(403, "Custom runs are disabled in this contest")
+		}
+	} else if !s.IsProblemFullyVisible(author.Brief(), problem) {
+		return nil, Statusf(400, "You cannot run code for a problem outside a contest while it's running")
+	}
+
+	if lang.InternalName == "outputOnly" {
+		return nil, Statusf(400, "Output only submissions can't be run")
+	}
+	langs, err := s.ProblemLanguages(ctx, problem.ID)
+	if err != nil {
+		return nil, WrapError(err, "Could not get problem languages")
+	}
+	if !slices.ContainsFunc(langs, func(a eval.Language) bool { return a.InternalName == lang.InternalName }) {
+		return nil, Statusf(400, "Language not supported by problem")
+	}
+
+	if !author.IsAdmin() && !s.customRuns.allow(author.ID, CustomRunLimit.Value()) {
+		return nil, Statusf(http.StatusTooManyRequests, "You cannot do more than %d custom runs in a minute, please wait a bit", CustomRunLimit.Value())
+	}
+
+	s.customRunJobs.mu.Lock()
+	defer s.customRunJobs.mu.Unlock()
+	if s.customRunJobs.jobs == nil {
+		s.customRunJobs.jobs = make(map[int]*CustomRunJob)
+	}
+	s.customRunJobs.prune()
+	s.customRunJobs.lastID++
+	job := &CustomRunJob{
+		ID: s.customRunJobs.lastID,
+
+		Running: true,
+
+		CreatedAt: time.Now(),
+
+		UserID:   author.ID,
+		Problem:  problem,
+		Language: lang.InternalName,
+		Code:     code,
+		Files:    files,
+		Input:    input,
+	}
+	s.customRunJobs.jobs[job.ID] = job
+	s.customRunJobs.queue = append(s.customRunJobs.queue, job)
+
+	// Wake grader to start processing immediately
+	s.WakeGrader()
+
+	ret := *job
+	return &ret, nil
+}
+
+// CustomRunJob returns the status of the user's custom run
+func (s *BaseAPI) CustomRunJob(id int, user *kilonova.UserBrief) (*CustomRunJob, *StatusError) {
+	s.customRunJobs.mu.Lock()
+	defer s.customRunJobs.mu.Unlock()
+	job, ok := s.customRunJobs.jobs[id]
+	if !ok || user == nil || job.UserID != user.ID {
+		return nil, WrapError(ErrNotFound, "Custom run not found")
+	}
+	ret := *job
+	return &ret, nil
+}
+
+// ClaimPendingCustomRuns removes at most limit custom runs from the queue and returns them, to be run by the grader
+func (s *BaseAPI) ClaimPendingCustomRuns(limit int) []*CustomRunJob {
+	s.customRunJobs.mu.Lock()
+	defer s.customRunJobs.mu.Unlock()
+	jobs := s.customRunJobs.queue[:min(limit, len(s.customRunJobs.queue))]
+	s.customRunJobs.queue = slices.Clone(s.customRunJobs.queue[len(jobs):])
+	return jobs
+}
+
+// FinishCustomRun saves the result of the custom run and drops its sources, which are no longer needed
+func (s *BaseAPI) FinishCustomRun(job *CustomRunJob, rez *kilonova.CustomRun, err *StatusError) {
+	s.customRunJobs.mu.Lock()
+	defer s.customRunJobs.mu.Unlock()
+	if err != nil {
+		job.Error = err.Error()
+	}
+	job.Result = rez
+	job.Running = false
+	finishedAt := time.Now()
+	job.FinishedAt = &finishedAt
+	job.Code, job.Files, job.Input = nil, nil, nil
+}
@@ -2,20 +2,29 @@ package sudoapi
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"io"
 	"strconv"
 	"strings"
 
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/datastore"
 	"go.uber.org/zap"
 	"vimagination.zapto.org/dos2unix"
 )
 
-func (s *BaseAPI) PurgeTestData(testID int) error {
-	if err := errors.Join(
-		s.testBucket.RemoveFile(strconv.Itoa(testID)+".in"),
-		s.testBucket.RemoveFile(strconv.Itoa(testID)+".out"),
-	); err != nil {
+func (s *BaseAPI) PurgeTestData(testID int, inputFiles []string) error {
+	errs := []error{
+		s.testBucket.RemoveFile(strconv.Itoa(testID) + ".in"),
+		s.testBucket.RemoveFile(strconv.Itoa(testID) + ".out"),
+	}
+	for _, name := range inputFiles {
+		errs = append(errs, s.testBucket.RemoveFile(kilonova.TestInputFilename(testID, name)))
+	}
+	if err := errors.Join(errs...); err != nil {
 		return WrapError(err, "Could not purge test data")
 	}
 	return nil
@@ -32,6 +41,17 @@ func (s *BaseAPI) SubtestReader(subtest int) (io.ReadCloser, error) {
 	return s.subtestBucket.Reader(strconv.Itoa(subtest))
 }
 
+// StoredTestInput opens the test input as it's stored, so it can be served without decompressing it
+func (s *BaseAPI) StoredTestInput(testID int) (*datastore.StoredFile, error) {
+	return datastore.OpenStored(s.testBucket, strconv.Itoa(testID)+".in")
+}
+func (s *BaseAPI) StoredTestOutput(testID int) (*datastore.StoredFile, error) {
+	return datastore.OpenStored(s.testBucket, strconv.Itoa(testID)+".out")
+}
+func (s *BaseAPI) StoredTestInputFile(testID int, name string) (*datastore.StoredFile, error) {
+	return datastore.OpenStored(s.testBucket, kilonova.TestInputFilename(testID, name))
+}
+
 func (s *BaseAPI) SaveTestInput(testID int, input io.Reader) error {
 	if err := s.testBucket.WriteFile(strconv.Itoa(testID)+".in", dos2unix.DOS2Unix(input), 0644); err != nil {
 		return WrapError(err, "Could not save test input")
@@ -39,6 +59,18 @@ func (s *BaseAPI) SaveTestInput(testID int, input io.Reader) error {
 	return nil
 }
 
+// TestInputFile returns the additional input file of the test with the given name
+func (s *BaseAPI) TestInputFile(testID int, name string) (io.ReadCloser, error) {
+	return s.testBucket.Reader(kilonova.TestInputFilename(testID, name))
+}
+
+func (s *BaseAPI) SaveTestInputFile(testID int, name string, input io.Reader) error {
+	if err := s.testBucket.WriteFile(kilonova.TestInputFilename(testID, name), dos2unix.DOS2Unix(input), 0644); err != nil {
+		return WrapError(err, "Could not save test input file")
+	}
+	return nil
+}
+
 func (s *BaseAPI) SaveTestOutput(testID int, output io.Reader) error {
 	if err := s.testBucket.WriteFile(strconv.Itoa(testID)+".out", dos2unix.DOS2Unix(output), 0644); err != nil {
 		return WrapError(err, "Could not save test output")
@@ -46,6 +78,32 @@ func (s *BaseAPI) SaveTestOutput(testID int, output io.Reader) error {
 	return nil
 }
 
+// TestDataHash hashes the input, output and additional input files of the test. Missing files are hashed as empty
+func (s *BaseAPI) TestDataHash(test *kilonova.Test) (string, error) {
+	files := []string{strconv.Itoa(test.ID) + ".in", strconv.Itoa(test.ID) + ".out"}
+	for _, name := range test.InputFiles {
+		files = append(files, kilonova.TestInputFilename(test.ID, name))
+	}
+	h := sha256.New()
+	for _, name := range files {
+		// The file name is included, so renaming an input file also changes the hash
+		fh := sha256.New()
+		r, err := s.testBucket.Reader(name)
+		if err != nil && !errors.Is(err, kilonova.ErrNotExist) {
+			return "", err
+		}
+		if err == nil {
+			_, err = io.Copy(fh, r)
+			r.Close()
+			if err != nil {
+				return "", err
+			}
+		}
+		fmt.Fprintf(h, "%s:%x\n", name, fh.Sum(nil))
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
 func (s *BaseAPI) GetAttachmentRender(attID int, renderType string) (io.ReadSeekCloser, error) {
 	f, err := s.attachmentCacheBucket.ReadSeeker(attachmentCacheBucketName(attID, renderType))
 	if err != nil {
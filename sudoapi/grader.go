@@ -0,0 +1,49 @@
+package sudoapi
+
+import (
+	"context"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/internal/config"
+	"go.uber.org/zap"
+)
+
+var (
+	SpeedMismatchTolerance = config.GenFlag[int]("behavior.grader.speed_tolerance", 20, "Deviation (in percents) of the grader speed from a problem's reference speed after which editors are warned")
+)
+
+// GraderSpeedFactor returns the speed factor of the running grader, or nil if it is not running or was not calibrated
+func (s *BaseAPI) GraderSpeedFactor() *float64 {
+	if s.grader == nil {
+		return nil
+	}
+	factor := s.grader.SpeedFactor()
+	if factor <= 0 {
+		return nil
+	}
+	return &factor
+}
+
+// SpeedMismatch returns true if results produced on a grader with the given speed factor should be taken with a grain of salt by the problem's editors
+func (s *BaseAPI) SpeedMismatch(problem *kilonova.Problem, speedFactor *float64) bool {
+	if problem == nil || speedFactor == nil {
+		return false
+	}
+	return problem.SpeedMismatch(*speedFactor, float64(SpeedMismatchTolerance.Value())/100)
+}
+
+func (s *BaseAPI) UpdateGraderHost(ctx context.Context, hostname string, speedFactor float64) *StatusError {
+	if err := s.db.UpdateGraderHost(ctx, hostname, speedFactor); err != nil {
+		zap.S().Warn(err)
+		return WrapError(err, "Couldn't update grader host")
+	}
+	return nil
+}
+
+func (s *BaseAPI) GraderHosts(ctx context.Context) ([]*kilonova.GraderHost, *StatusError) {
+	hosts, err := s.db.GraderHosts(ctx)
+	if err != nil {
+		return nil, WrapError(err, "Couldn't get grader hosts")
+	}
+	return hosts, nil
+}
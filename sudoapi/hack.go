@@ -0,0 +1,341 @@
+package sudoapi
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/internal/config"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+var (
+	HackMaxInput = config.GenFlag[int]("behavior.hacks.max_input_size", 2*1024*1024, "Maximum size of a hack input (in bytes)")
+)
+
+// Hack inputs (and the outputs generated by the author solution) are stored in the tests bucket under the negated hack ID,
+// so they can be fed to the grader and checkers the same way as regular tests.
+func HackTestID(hackID int) int {
+	return -hackID
+}
+
+func (s *BaseAPI) Hack(ctx context.Context, id int) (*kilonova.Hack, *StatusError) {
+	hack, err := s.db.Hack(ctx, id)
+	if err != nil || hack == nil {
+		return nil, WrapError(ErrNotFound, "Hack not found")
+	}
+	return hack, nil
+}
+
+func (s *BaseAPI) Hacks(ctx context.Context, filter kilonova.HackFilter) ([]*kilonova.Hack, *StatusError) {
+	hacks, err := s.db.Hacks(ctx, filter)
+	if err != nil {
+		return nil, WrapError(err, "Couldn't get hacks")
+	}
+	return hacks, nil
+}
+
+func (s *BaseAPI) UpdateHack(ctx context.Context, id int, upd kilonova.HackUpdate) *StatusError {
+	if err := s.db.UpdateHack(ctx, id, upd); err != nil {
+		zap.S().Warn(err)
+		return WrapError(err, "Couldn't update hack")
+	}
+	return nil
+}
+
+// ClaimPendingHacks marks at most limit pending hacks as running and returns them, to be evaluated by the grader
+func (s *BaseAPI) ClaimPendingHacks(ctx context.Context, limit int) ([]*kilonova.Hack, *StatusError) {
+	hacks, err := s.db.ClaimPendingHacks(ctx, limit)
+	if err != nil {
+		return nil, WrapError(err, "Couldn't get pending hacks")
+	}
+	return hacks, nil
+}
+
+func (s *BaseAPI) ResetRunningHacks(ctx context.Context) *StatusError {
+	if err := s.db.ResetRunningHacks(ctx); err != nil {
+		return WrapError(err, "Couldn't reset running hacks")
+	}
+	return nil
+}
+
+func (s *BaseAPI) ContestProblemLocked(ctx context.Context, contestID, userID, problemID int) bool {
+	locked, err := s.db.ContestProblemLocked(ctx, contestID, userID, problemID)
+	if err != nil {
+		zap.S().Warn(err)
+		return false
+	}
+	return locked
+}
+
+func (s *BaseAPI) ContestLockedProblems(ctx context.Context, contest *kilonova.Contest, user *kilonova.UserBrief) []int {
+	if !user.IsAuthed() || !contest.AllowHacking {
+		return []int{}
+	}
+	ids, err := s.db.ContestLockedProblems(ctx, contest.ID, user.ID)
+	if err != nil {
+		zap.S().Warn(err)
+		return []int{}
+	}
+	return ids
+}
+
+// LockContestProblem prevents the user from submitting to the problem for the rest of the contest.
+// In exchange, the user may view and hack the other accepted submissions to that problem.
+func (s *BaseAPI) LockContestProblem(ctx context.Context, contest *kilonova.Contest, user *kilonova.UserBrief, problemID int) *StatusError {
+	if !contest.AllowHacking {
+		return Statusf(400, "Hacking is not enabled for this contest")
+	}
+	if !contest.Running() || !s.CanSubmitInContest(user, contest) {
+		return Statusf(400, "You must be registered and during a contest to do this")
+	}
+	pb, err := s.ContestProblem(ctx, contest, user, problemID)
+	if err != nil || pb == nil {
+		return Statusf(400, "Problem is not in contest")
+	}
+
+	// Under pretests, accepted submissions may not have the full score yet
+	cnt, err1 := s.db.SubmissionCount(ctx, kilonova.SubmissionFilter{
+		ContestID: &contest.ID,
+		UserID:    &user.ID,
+		ProblemID: &problemID,
+		Accepted:  true,
+	}, 1)
+	if err1 != nil {
+		return WrapError(err1, "Couldn't check accepted submissions")
+	}
+	if cnt == 0 {
+		return Statusf(400, "You can only lock problems for which you have an accepted submission")
+	}
+
+	if err := s.db.LockContestProblem(ctx, contest.ID, user.ID, problemID); err != nil {
+		zap.S().Warn(err)
+		return WrapError(err, "Couldn't lock problem")
+	}
+	return nil
+}
+
+// CanViewHackTarget returns true if the user has locked the submission's problem in the submission's contest
+// and the submission passed every judged test, meaning that the source code may be viewed for hacking purposes.
+func (s *BaseAPI) CanViewHackTarget(ctx context.Context, sub *kilonova.Submission, user *kilonova.UserBrief) bool {
+	if sub == nil || sub.ContestID == nil || !user.IsAuthed() {
+		return false
+	}
+	if sub.Status != kilonova.StatusFinished {
+		return false
+	}
+	contest, err := s.Contest(ctx, *sub.ContestID)
+	if err != nil || !contest.AllowHacking || !contest.Running() {
+		return false
+	}
+	if !s.ContestProblemLocked(ctx, contest.ID, user.ID, sub.ProblemID) {
+		return false
+	}
+	cnt, err1 := s.db.SubmissionCount(ctx, kilonova.SubmissionFilter{ID: &sub.ID, Accepted: true}, 1)
+	return err1 == nil && cnt > 0
+}
+
+// CreateHack queues a hack of the submission with the given input. The result is computed asynchronously by the grader.
+func (s *BaseAPI) CreateHack(ctx context.Context, sub *kilonova.Submission, hacker *kilonova.UserBrief, input []byte) (int, *StatusError) {
+	if s.grader == nil {
+		return -1, Statusf(503, "Grader is not running")
+	}
+	if sub == nil || sub.ContestID == nil {
+		return -1, Statusf(400, "Only contest submissions can be hacked")
+	}
+	if !hacker.IsAuthed() {
+		return -1, Statusf(400, "Invalid hacker")
+	}
+	if sub.UserID == hacker.ID {
+		return -1, Statusf(400, "You cannot hack your own submission")
+	}
+	if len(input) == 0 {
+		return -1, Statusf(400, "Empty input")
+	}
+	if len(input) > HackMaxInput.Value() {
+		return -1, Statusf(400, "Input must not exceed %d bytes", HackMaxInput.Value())
+	}
+
+	contest, err := s.Contest(ctx, *sub.ContestID)
+	if err != nil {
+		return -1, err
+	}
+	if !contest.AllowHacking {
+		return -1, Statusf(400, "Hacking is not enabled for this contest")
+	}
+	if !contest.Running() || !s.CanSubmitInContest(hacker, contest) {
+		return -1, Statusf(400, "You must be registered and during a contest to do this")
+	}
+	if !s.CanViewHackTarget(ctx, sub, hacker) {
+		return -1, Statusf(400, "You can only hack accepted submissions to problems you have locked")
+	}
+
+	problem, err := s.Problem(ctx, sub.ProblemID)
+	if err != nil {
+		return -1, err
+	}
+	if problem.IsRelative() {
+		return -1, Statusf(400, "Optimization problems cannot be hacked")
+	}
+	settings, err := s.ProblemSettings(ctx, problem.ID)
+	if err != nil {
+		return -1, err
+	}
+	if settings.SolutionName == "" {
+		return -1, Statusf(400, "Problem doesn't have an author solution, so it cannot be hacked")
+	}
+
+	hacks, err := s.Hacks(ctx, kilonova.HackFilter{SubmissionID: &sub.ID})
+	if err != nil {
+		return -1, err
+	}
+	for _, hack := range hacks {
+		if hack.Status == kilonova.HackSuccessful {
+			return -1, Statusf(400, "Submission was already hacked")
+		}
+		if hack.HackerID == hacker.ID && (hack.Status == kilonova.HackPending || hack.Status == kilonova.HackRunning) {
+			return -1, Statusf(400, "You already have a hack for this submission in the queue")
+		}
+	}
+
+	id, err1 := s.db.CreateHack(ctx, contest.ID, problem.ID, sub.ID, hacker.ID, sub.UserID)
+	if err1 != nil {
+		zap.S().Warn(err1)
+		return -1, WrapError(err1, "Couldn't create hack")
+	}
+
+	if err := s.SaveTestInput(HackTestID(id), bytes.NewReader(input)); err != nil {
+		zap.S().Warn(err)
+		verdict := "Couldn't save hack input"
+		if err := s.UpdateHack(ctx, id, kilonova.HackUpdate{Status: kilonova.HackInvalid, Verdict: &verdict}); err != nil {
+			zap.S().Warn(err)
+		}
+		return -1, WrapError(err, "Couldn't save hack input")
+	}
+
+	s.LogVerbose(ctx, "Created hack", slog.Int("hack_id", id), slog.Int("submission_id", sub.ID))
+
+	// Wake immediately to evaluate hack
+	s.WakeGrader()
+
+	return id, nil
+}
+
+// FinishHack saves the result of the hack. On success, the hack input is added to the problem's tests
+// and every finished contest submission to the problem is rejudged, so that the new test is taken into account.
+// A submission that fails a hack test gets no points for the subtask containing it (see ProblemHackTests).
+func (s *BaseAPI) FinishHack(ctx context.Context, hack *kilonova.Hack, status kilonova.HackStatus, verdict string) *StatusError {
+	upd := kilonova.HackUpdate{Status: status, Verdict: &verdict}
+	if status == kilonova.HackSuccessful {
+		testID, err := s.addHackTest(ctx, hack)
+		if err != nil {
+			zap.S().Warn("Couldn't add hack test: ", err)
+		} else {
+			upd.TestID = &testID
+		}
+	}
+	// The hack data is no longer needed once the hack is judged, successful hacks were copied to a regular test above
+	if err := s.PurgeTestData(HackTestID(hack.ID), nil); err != nil {
+		zap.S().Warn("Couldn't remove hack data: ", err)
+	}
+	if err := s.UpdateHack(ctx, hack.ID, upd); err != nil {
+		return err
+	}
+
+	if status != kilonova.HackSuccessful || upd.TestID == nil {
+		return nil
+	}
+
+	// The new test affects all submissions to the problem, not just the hacked one.
+	// Submissions judged only on pretests are rejudged on them again, the new test will be caught by system testing
+	if err := s.db.BulkUpdateSubmissions(ctx, kilonova.SubmissionFilter{
+		ProblemID: &hack.ProblemID,
+		ContestID: &hack.ContestID,
+		Status:    kilonova.StatusFinished,
+	}, kilonova.SubmissionUpdate{Status: kilonova.StatusReevaling}); err != nil {
+		zap.S().Warn(err)
+		return WrapError(err, "Couldn't mark submissions for reevaluation")
+	}
+	s.WakeGrader()
+	return nil
+}
+
+// ProblemHackTests returns the IDs of the tests added to the problem by successful hacks.
+// Hack tests are worth no points on their own, so failing one invalidates the subtask containing it,
+// or the whole submission if the problem has no subtasks.
+func (s *BaseAPI) ProblemHackTests(ctx context.Context, problemID int) ([]int, *StatusError) {
+	hacks, err := s.Hacks(ctx, kilonova.HackFilter{ProblemID: &problemID, Status: kilonova.HackSuccessful})
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int, 0, len(hacks))
+	for _, hack := range hacks {
+		if hack.TestID != nil {
+			ids = append(ids, *hack.TestID)
+		}
+	}
+	return ids, nil
+}
+
+// HackTestLimits returns the time and memory limits of a hack test of the problem, once addHackTest puts it in the last subtask
+func (s *BaseAPI) HackTestLimits(ctx context.Context, problem *kilonova.Problem, speedFactor float64) (float64, int, *StatusError) {
+	subtasks, err := s.SubTasks(ctx, problem.ID)
+	if err != nil {
+		return 0, 0, err
+	}
+	var test kilonova.Test
+	var stks []*kilonova.SubTask
+	if len(subtasks) > 0 {
+		stk := *subtasks[len(subtasks)-1]
+		stk.Tests = []int{test.ID}
+		stks = append(stks, &stk)
+	}
+	timeLimit, memoryLimit := problem.TestLimits(&test, stks, speedFactor)
+	return timeLimit, memoryLimit, nil
+}
+
+func (s *BaseAPI) addHackTest(ctx context.Context, hack *kilonova.Hack) (int, *StatusError) {
+	test := kilonova.Test{
+		ProblemID: hack.ProblemID,
+		VisibleID: s.NextVID(ctx, hack.ProblemID),
+		Score:     decimal.Zero,
+	}
+	if err := s.CreateTest(ctx, &test); err != nil {
+		return -1, err
+	}
+
+	in, err := s.TestInput(HackTestID(hack.ID))
+	if err != nil {
+		return -1, WrapError(err, "Couldn't open hack input")
+	}
+	defer in.Close()
+	if err := s.SaveTestInput(test.ID, in); err != nil {
+		return -1, WrapError(err, "Couldn't save hack test input")
+	}
+	out, err := s.TestOutput(HackTestID(hack.ID))
+	if err != nil {
+		return -1, WrapError(err, "Couldn't open hack output")
+	}
+	defer out.Close()
+	if err := s.SaveTestOutput(test.ID, out); err != nil {
+		return -1, WrapError(err, "Couldn't save hack test output")
+	}
+	if err := s.TestDataChanged(ctx, &test); err != nil {
+		return -1, err
+	}
+
+	// Hack tests go in the last subtask, which usually holds the full constraints
+	subtasks, err1 := s.SubTasks(ctx, hack.ProblemID)
+	if err1 != nil {
+		return -1, err1
+	}
+	if len(subtasks) > 0 {
+		stk := subtasks[len(subtasks)-1]
+		if err := s.UpdateSubTaskTests(ctx, stk.ID, append(stk.Tests, test.ID)); err != nil {
+			return -1, err
+		}
+	}
+	return test.ID, nil
+}
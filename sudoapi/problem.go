@@ -3,6 +3,7 @@ package sudoapi
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
 	"maps"
 	"math"
@@ -37,11 +38,42 @@ func (s *BaseAPI) UpdateProblem(ctx context.Context, id int, args kilonova.Probl
 	if args.ScoringStrategy != kilonova.ScoringTypeNone && args.ScoringStrategy != kilonova.ScoringTypeMaxSub && args.ScoringStrategy != kilonova.ScoringTypeSumSubtasks && args.ScoringStrategy != kilonova.ScoringTypeICPC {
 		return Statusf(400, "Invalid scoring strategy!")
 	}
+	if v := args.ObjectiveType; v != nil && *v != kilonova.ObjectiveNone && *v != kilonova.ObjectiveMaximize && *v != kilonova.ObjectiveMinimize {
+		return Statusf(400, "Invalid objective type!")
+	}
+	if v := args.ObjectiveExponent; v != nil && !v.IsPositive() {
+		return Statusf(400, "Objective exponent must be positive!")
+	}
+	if err := s.validateProblemLimits(args.StackLimit, args.CompileFlags, args.GraderCompileFlags, args.CheckerCompileFlags); err != nil {
+		return err
+	}
+	var objectiveChanged bool
+	if args.ObjectiveType != nil || (args.TimeLimit != nil && args.TimeLimitFactor == nil) {
+		pb, err := s.Problem(ctx, id)
+		if err != nil {
+			return err
+		}
+		// The time limit is relative to the speed of the grader it was chosen on.
+		// The editor always sends the time limit, so the reference speed is only changed when the limit itself is
+		if args.TimeLimit != nil && args.TimeLimitFactor == nil && *args.TimeLimit != pb.TimeLimit {
+			args.TimeLimitFactor = s.GraderSpeedFactor()
+		}
+		// Best objective values are meaningless once the optimization direction changes
+		objectiveChanged = args.ObjectiveType != nil && pb.ObjectiveType != *args.ObjectiveType
+	}
 
 	if err := s.db.UpdateProblem(ctx, id, args); err != nil {
 		zap.S().Warn(err)
 		return WrapError(err, "Couldn't update problem")
 	}
+	if objectiveChanged {
+		if err := s.ResetProblemObjectives(ctx, id); err != nil {
+			return err
+		}
+	}
+	if args.CheckerCompileFlags != nil {
+		invalidateChecker(id)
+	}
 
 	return nil
 }
@@ -260,6 +292,12 @@ func (s *BaseAPI) AttemptedProblems(ctx context.Context, user *kilonova.UserBrie
 }
 
 func (s *BaseAPI) insertProblem(ctx context.Context, problem *kilonova.Problem, authorID int) (int, *StatusError) {
+	if problem.TimeLimitFactor == nil {
+		problem.TimeLimitFactor = s.GraderSpeedFactor()
+	}
+	if err := s.validateProblemLimits(&problem.StackLimit, &problem.CompileFlags, &problem.GraderCompileFlags, &problem.CheckerCompileFlags); err != nil {
+		return -1, err
+	}
 	err := s.db.CreateProblem(ctx, problem, authorID)
 	if err != nil {
 		return -1, WrapError(err, "Couldn't create problem")
@@ -464,5 +502,13 @@ func (s *BaseAPI) ProblemDiagnostics(ctx context.Context, problem *kilonova.Prob
 		})
 	}
 
+	// Warn if the grader is far from the speed the time limit was set on, since results may not be representative
+	if speedFactor := s.GraderSpeedFactor(); s.SpeedMismatch(problem, speedFactor) {
+		diags = append(diags, &ProblemDiagnostic{
+			Level:   slog.LevelWarn,
+			Message: fmt.Sprintf("The grader's speed factor (%.2f) differs significantly from the one the time limit was set on (%.2f). Time limits are scaled accordingly, but results may vary.", *speedFactor, *problem.TimeLimitFactor),
+		})
+	}
+
 	return diags, nil
 }
@@ -0,0 +1,139 @@
+package sudoapi
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/KiloProjects/kilonova/datastore"
+	"github.com/KiloProjects/kilonova/internal/config"
+	"go.uber.org/zap"
+)
+
+var recompressionRate = config.GenFlag("behavior.storage.recompression_rate", 20, "Maximum number of bucket files recompressed per second. 0 disables the limit")
+
+// RecompressionStatus is the progress of converting the files of a bucket to its configured codec
+type RecompressionStatus struct {
+	Bucket      string `json:"bucket"`
+	Compression string `json:"compression"`
+
+	Running   bool   `json:"running"`
+	Total     int    `json:"total"`
+	Done      int    `json:"done"`
+	Failed    int    `json:"failed"`
+	LastError string `json:"last_error,omitempty"`
+
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+
+	cancel context.CancelFunc
+}
+
+type recompressionJobs struct {
+	mu   sync.Mutex
+	jobs map[string]*RecompressionStatus
+}
+
+// StartRecompression converts in the background the bucket files stored with another compression than the configured one.
+// Files are read transparently during the conversion. Stopped recompressions may be started again and skip the converted files.
+// The target compression is saved in the database, so this also works after a restart, but the job status is only kept in memory
+func (s *BaseAPI) StartRecompression(ctx context.Context, bucket datastore.Bucket) (*RecompressionStatus, *StatusError) {
+	s.recompressions.mu.Lock()
+	defer s.recompressions.mu.Unlock()
+	if s.recompressions.jobs == nil {
+		s.recompressions.jobs = make(map[string]*RecompressionStatus)
+	}
+	if job, ok := s.recompressions.jobs[bucket.Name()]; ok && job.Running {
+		return nil, Statusf(400, "Bucket is already being recompressed")
+	}
+
+	since, err := s.db.StartBucketRecompression(ctx, bucket.Name(), bucket.Compression().String())
+	if err != nil {
+		return nil, WrapError(err, "Couldn't save recompression")
+	}
+	pending, err := datastore.PendingRecompression(bucket, since)
+	if err != nil {
+		return nil, WrapError(err, "Couldn't list files to recompress")
+	}
+	jobCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	job := &RecompressionStatus{
+		Bucket:      bucket.Name(),
+		Compression: bucket.Compression().String(),
+
+		Running: true,
+		Total:   len(pending),
+
+		StartedAt: time.Now(),
+
+		cancel: cancel,
+	}
+	s.recompressions.jobs[bucket.Name()] = job
+	go s.recompress(jobCtx, bucket, pending, job)
+
+	ret := *job
+	return &ret, nil
+}
+
+func (s *BaseAPI) recompress(ctx context.Context, bucket datastore.Bucket, names []string, job *RecompressionStatus) {
+	defer job.cancel()
+
+	var tick <-chan time.Time
+	if rate := recompressionRate.Value(); rate > 0 {
+		t := time.NewTicker(time.Second / time.Duration(rate))
+		defer t.Stop()
+		tick = t.C
+	}
+	for _, name := range names {
+		if tick != nil {
+			select {
+			case <-ctx.Done():
+			case <-tick:
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		err := datastore.RecompressFile(bucket, name)
+		s.recompressions.mu.Lock()
+		if err != nil {
+			zap.S().Warnf("Couldn't recompress file %q: %v", name, err)
+			job.Failed++
+			job.LastError = err.Error()
+		} else {
+			job.Done++
+		}
+		s.recompressions.mu.Unlock()
+	}
+
+	s.recompressions.mu.Lock()
+	defer s.recompressions.mu.Unlock()
+	job.Running = false
+	finishedAt := time.Now()
+	job.FinishedAt = &finishedAt
+	slog.Info("Bucket recompression finished", slog.Any("bucket", bucket), slog.Int("done", job.Done), slog.Int("failed", job.Failed), slog.Int("total", job.Total))
+}
+
+// StopRecompression stops the running recompression of the bucket
+func (s *BaseAPI) StopRecompression(bucket datastore.Bucket) *StatusError {
+	s.recompressions.mu.Lock()
+	defer s.recompressions.mu.Unlock()
+	job, ok := s.recompressions.jobs[bucket.Name()]
+	if !ok || !job.Running {
+		return Statusf(400, "Bucket is not being recompressed")
+	}
+	job.cancel()
+	return nil
+}
+
+// Recompressions returns the status of the last recompression of each bucket since the server started
+func (s *BaseAPI) Recompressions() map[string]*RecompressionStatus {
+	s.recompressions.mu.Lock()
+	defer s.recompressions.mu.Unlock()
+	ret := make(map[string]*RecompressionStatus, len(s.recompressions.jobs))
+	for name, job := range s.recompressions.jobs {
+		status := *job
+		ret[name] = &status
+	}
+	return ret
+}
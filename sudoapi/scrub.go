@@ -0,0 +1,294 @@
+package sudoapi
+
+import (
+	"context"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/datastore"
+	"go.uber.org/zap"
+)
+
+// ScrubIssue is a bucket file that doesn't match the database
+type ScrubIssue struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason,omitempty"`
+	// ProblemID is only set for test data
+	ProblemID int `json:"problem_id,omitempty"`
+}
+
+// ScrubReport is the result of checking a bucket against the database.
+// Scrubs run in the background and only the last report of every bucket is kept in memory, so it's lost when the server restarts
+type ScrubReport struct {
+	Bucket string `json:"bucket"`
+
+	Running bool   `json:"running"`
+	Error   string `json:"error,omitempty"`
+	// TotalFiles and CheckedFiles track the progress of reading the files. Orphaned files are counted in TotalFiles, but not checked
+	TotalFiles   int `json:"total_files"`
+	CheckedFiles int `json:"checked_files"`
+
+	// The issues are filled in once the scrub finishes
+	Missing  []*ScrubIssue `json:"missing"`
+	Corrupt  []*ScrubIssue `json:"corrupt"`
+	Orphaned []*ScrubIssue `json:"orphaned"`
+
+	DeletedOrphans int `json:"deleted_orphans"`
+
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+}
+
+type scrubReports struct {
+	mu      sync.Mutex
+	reports map[string]*ScrubReport
+}
+
+// scrubGracePeriod skips the recently written files, since their rows might not have been committed yet
+const scrubGracePeriod = time.Hour
+
+// StartScrub runs ScrubBucket in the background. The report is polled with ScrubReport
+func (s *BaseAPI) StartScrub(ctx context.Context, bucket datastore.Bucket, deleteOrphans bool) (*ScrubReport, *StatusError) {
+	if !scrubSupported(bucket) {
+		return nil, Statusf(400, "Scrubbing is not supported for this bucket")
+	}
+
+	s.scrubReports.mu.Lock()
+	defer s.scrubReports.mu.Unlock()
+	if s.scrubReports.reports == nil {
+		s.scrubReports.reports = make(map[string]*ScrubReport)
+	}
+	if report, ok := s.scrubReports.reports[bucket.Name()]; ok && report.Running {
+		return nil, Statusf(400, "The bucket is already being scrubbed")
+	}
+	report := &ScrubReport{
+		Bucket:   bucket.Name(),
+		Running:  true,
+		Missing:  []*ScrubIssue{},
+		Corrupt:  []*ScrubIssue{},
+		Orphaned: []*ScrubIssue{},
+
+		StartedAt: time.Now(),
+	}
+	s.scrubReports.reports[bucket.Name()] = report
+
+	go func() {
+		result, err := s.scrubBucket(context.WithoutCancel(ctx), bucket, deleteOrphans, func(checked, total int) {
+			s.scrubReports.mu.Lock()
+			defer s.scrubReports.mu.Unlock()
+			report.CheckedFiles, report.TotalFiles = checked, total
+		})
+
+		s.scrubReports.mu.Lock()
+		defer s.scrubReports.mu.Unlock()
+		if err != nil {
+			report.Error = err.Error()
+			report.Running = false
+			finishedAt := time.Now()
+			report.FinishedAt = &finishedAt
+			return
+		}
+		*report = *result
+	}()
+
+	ret := *report
+	return &ret, nil
+}
+
+// ScrubReport returns the last scrub report of the bucket, or nil if the bucket was never scrubbed
+func (s *BaseAPI) ScrubReport(bucketName string) *ScrubReport {
+	s.scrubReports.mu.Lock()
+	defer s.scrubReports.mu.Unlock()
+	report, ok := s.scrubReports.reports[bucketName]
+	if !ok {
+		return nil
+	}
+	ret := *report
+	return &ret
+}
+
+// ScrubBucket cross-checks the files of the bucket with the rows they belong to.
+// Every file is read in full, so corrupt compressed files are also reported.
+// Missing files are only reported for the tests bucket, since the subtest outputs and attachment renders may be evicted.
+func (s *BaseAPI) ScrubBucket(ctx context.Context, bucket datastore.Bucket, deleteOrphans bool) (*ScrubReport, *StatusError) {
+	return s.scrubBucket(ctx, bucket, deleteOrphans, func(int, int) {})
+}
+
+func scrubSupported(bucket datastore.Bucket) bool {
+	switch datastore.BucketType(bucket.Name()) {
+	case datastore.BucketTypeTests, datastore.BucketTypeSubtests, datastore.BucketTypeAttachments:
+		return true
+	default:
+		return false
+	}
+}
+
+// scrubBucket does the checks of ScrubBucket, calling progress after every checked file
+func (s *BaseAPI) scrubBucket(ctx context.Context, bucket datastore.Bucket, deleteOrphans bool, progress func(checked, total int)) (*ScrubReport, *StatusError) {
+	if !scrubSupported(bucket) {
+		return nil, Statusf(400, "Scrubbing is not supported for this bucket")
+	}
+	report := &ScrubReport{
+		Bucket:   bucket.Name(),
+		Missing:  []*ScrubIssue{},
+		Corrupt:  []*ScrubIssue{},
+		Orphaned: []*ScrubIssue{},
+
+		StartedAt: time.Now(),
+	}
+
+	entries, err := bucket.FileList()
+	if err != nil {
+		return nil, WrapError(err, "Couldn't list bucket files")
+	}
+	files := make(map[string]time.Time, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return nil, WrapError(err, "Couldn't stat bucket file")
+		}
+		files[datastore.TrimCompressionExt(entry.Name())] = info.ModTime()
+	}
+
+	var expected map[string]int
+	var optional map[string]bool
+	switch datastore.BucketType(bucket.Name()) {
+	case datastore.BucketTypeTests:
+		expected, optional, err = s.expectedTestFiles(ctx)
+	case datastore.BucketTypeSubtests:
+		expected, err = ownedBucketFiles(ctx, files, s.db.ExistingSubTestIDs)
+	case datastore.BucketTypeAttachments:
+		expected, err = ownedBucketFiles(ctx, files, s.db.ExistingAttachmentIDs)
+	}
+	if err != nil {
+		return nil, WrapError(err, "Couldn't get bucket file owners")
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	report.TotalFiles = len(names)
+	for _, name := range names {
+		if err := ctx.Err(); err != nil {
+			return nil, WrapError(err, "Scrub interrupted")
+		}
+		pbID, ok := expected[name]
+		if !ok {
+			if time.Since(files[name]) < scrubGracePeriod {
+				continue
+			}
+			issue := &ScrubIssue{Name: name}
+			report.Orphaned = append(report.Orphaned, issue)
+			if !deleteOrphans {
+				continue
+			}
+			if err := bucket.RemoveFile(name); err != nil {
+				zap.S().Warn("Couldn't delete orphaned file: ", err)
+				issue.Reason = err.Error()
+				continue
+			}
+			report.DeletedOrphans++
+			continue
+		}
+		report.CheckedFiles++
+		if err := readBucketFile(bucket, name); err != nil {
+			report.Corrupt = append(report.Corrupt, &ScrubIssue{Name: name, Reason: err.Error(), ProblemID: pbID})
+		}
+		progress(report.CheckedFiles, report.TotalFiles)
+	}
+
+	if datastore.BucketType(bucket.Name()) == datastore.BucketTypeTests {
+		for name, pbID := range expected {
+			if _, ok := files[name]; !ok && !optional[name] {
+				report.Missing = append(report.Missing, &ScrubIssue{Name: name, ProblemID: pbID})
+			}
+		}
+		slices.SortFunc(report.Missing, func(a, b *ScrubIssue) int { return strings.Compare(a.Name, b.Name) })
+	}
+
+	finishedAt := time.Now()
+	report.FinishedAt = &finishedAt
+	return report, nil
+}
+
+// expectedTestFiles returns the test data file names, along with their problem IDs.
+// The optional files are the data of the hacks still being judged, which are not reported as missing,
+// since their outputs are only generated during judging. Successful hacks are regular tests by then.
+func (s *BaseAPI) expectedTestFiles(ctx context.Context) (expected map[string]int, optional map[string]bool, err error) {
+	tests, err := s.db.AllTests(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	hacks, err := s.db.JudgingHacks(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	expected, optional = testFileNames(tests, hacks)
+	return expected, optional, nil
+}
+
+// testFileNames maps the names of the files storing the tests' and hacks' data to their problem IDs.
+// The hack files are also returned separately, since they may not exist yet
+func testFileNames(tests []*kilonova.Test, hacks []*kilonova.Hack) (map[string]int, map[string]bool) {
+	expected := make(map[string]int, 2*len(tests)+2*len(hacks))
+	for _, test := range tests {
+		expected[strconv.Itoa(test.ID)+".in"] = test.ProblemID
+		expected[strconv.Itoa(test.ID)+".out"] = test.ProblemID
+		for _, name := range test.InputFiles {
+			expected[kilonova.TestInputFilename(test.ID, name)] = test.ProblemID
+		}
+	}
+	optional := make(map[string]bool, 2*len(hacks))
+	for _, hack := range hacks {
+		for _, ext := range []string{".in", ".out"} {
+			name := strconv.Itoa(HackTestID(hack.ID)) + ext
+			expected[name] = hack.ProblemID
+			optional[name] = true
+		}
+	}
+	return expected, optional
+}
+
+// ownedBucketFiles returns the files whose name starts with the ID of an existing row
+func ownedBucketFiles(ctx context.Context, files map[string]time.Time, existingIDs func(context.Context, []int) ([]int, error)) (map[string]int, error) {
+	ids := make([]int, 0, len(files))
+	for name := range files {
+		prefix, _, _ := strings.Cut(name, ".")
+		if id, err := strconv.Atoi(prefix); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	existingList, err := existingIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[int]bool, len(existingList))
+	for _, id := range existingList {
+		existing[id] = true
+	}
+	owned := make(map[string]int)
+	for name := range files {
+		prefix, _, _ := strings.Cut(name, ".")
+		if id, err := strconv.Atoi(prefix); err == nil && existing[id] {
+			owned[name] = 0
+		}
+	}
+	return owned, nil
+}
+
+func readBucketFile(bucket datastore.Bucket, name string) error {
+	r, err := bucket.Reader(name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	_, err = io.Copy(io.Discard, r)
+	return err
+}
@@ -3,12 +3,14 @@ package sudoapi
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"slices"
 	"time"
 
 	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/datastore"
 	"github.com/KiloProjects/kilonova/eval"
 	"github.com/KiloProjects/kilonova/internal/config"
 	"github.com/shopspring/decimal"
@@ -131,6 +133,10 @@ func (s *BaseAPI) Submissions(ctx context.Context, filter kilonova.SubmissionFil
 	if look {
 		filter.Look = true
 		filter.LookingUser = lookingUser
+
+		if (filter.LanguageVersion != nil || filter.SandboxVersion != nil) && !s.canFilterToolchain(ctx, filter, lookingUser) {
+			return nil, Statusf(403, "Only problem editors may filter submissions by toolchain version")
+		}
 	}
 
 	subs, err := s.db.Submissions(ctx, filter)
@@ -166,6 +172,22 @@ func (s *BaseAPI) Submissions(ctx context.Context, filter kilonova.SubmissionFil
 	return s.fillSubmissions(ctx, cnt, subs, look, lookingUser, truncated)
 }
 
+// canFilterToolchain returns true if the user may see the toolchain versions of the filtered submissions.
+// Since the versions are hidden from regular users, the filter must be restricted to a problem the user is editing.
+func (s *BaseAPI) canFilterToolchain(ctx context.Context, filter kilonova.SubmissionFilter, user *kilonova.UserBrief) bool {
+	if user.IsAdmin() {
+		return true
+	}
+	if filter.ProblemID == nil {
+		return false
+	}
+	pb, err := s.Problem(ctx, *filter.ProblemID)
+	if err != nil {
+		return false
+	}
+	return s.IsProblemEditor(user, pb)
+}
+
 // Remember to do proper authorization when using this
 func (s *BaseAPI) RawSubmission(ctx context.Context, id int) (*kilonova.Submission, *StatusError) {
 	sub, err := s.db.Submission(ctx, id)
@@ -276,9 +298,24 @@ func (s *BaseAPI) getSubmission(ctx context.Context, subid int, lookingUser *Use
 		return nil, err1
 	}
 	rez.Code = code
+	if len(code) > 0 && sub.NumFiles > 0 {
+		rez.Files, err1 = s.SubmissionFiles(ctx, sub.ID)
+		if err1 != nil {
+			return nil, err1
+		}
+	}
 
 	rez.Problem = problem
 	rez.ProblemEditor = s.IsProblemEditor(lookingUser, rez.Problem)
+	if rez.ProblemEditor {
+		rez.SpeedMismatch = s.SpeedMismatch(problem, sub.SpeedFactor)
+		toolchains, err := s.db.SubmissionToolchains(ctx, sub.ID)
+		if err != nil {
+			zap.S().Warn(err)
+			return nil, WrapError(err, "Couldn't fetch submission toolchains")
+		}
+		rez.Toolchains = toolchains
+	}
 
 	rez.SubTests, err1 = s.SubTests(ctx, subid)
 	if err1 != nil {
@@ -299,6 +336,15 @@ func (s *BaseAPI) getSubmission(ctx context.Context, subid int, lookingUser *Use
 	return rez, nil
 }
 
+// RecordSubmissionToolchain saves the toolchain the submission is evaluated with, along with the ones of its previous evaluations
+func (s *BaseAPI) RecordSubmissionToolchain(ctx context.Context, id int, languageVersion *string, sandboxVersion string) *StatusError {
+	if err := s.db.RecordSubmissionToolchain(ctx, id, languageVersion, sandboxVersion); err != nil {
+		zap.S().Warn(err, id)
+		return WrapError(err, "Couldn't save submission toolchain")
+	}
+	return nil
+}
+
 func (s *BaseAPI) UpdateSubmission(ctx context.Context, id int, status kilonova.SubmissionUpdate) *StatusError {
 	if err := s.db.UpdateSubmission(ctx, id, status); err != nil {
 		zap.S().Warn(err, id)
@@ -338,7 +384,8 @@ var (
 )
 
 // CreateSubmission produces a new submission and also creates the necessary subtests
-func (s *BaseAPI) CreateSubmission(ctx context.Context, author *UserFull, problem *kilonova.Problem, code []byte, lang eval.Language, contestID *int, bypassSubCount bool) (int, *StatusError) {
+// Multi-file submissions have the entrypoint as code, and the rest of the source files in files.
+func (s *BaseAPI) CreateSubmission(ctx context.Context, author *UserFull, problem *kilonova.Problem, code []byte, files []*kilonova.SubmissionFile, lang eval.Language, contestID *int, bypassSubCount bool) (int, *StatusError) {
 	if author == nil {
 		return -1, Statusf(400, "Invalid submission author")
 	}
@@ -348,6 +395,9 @@ func (s *BaseAPI) CreateSubmission(ctx context.Context, author *UserFull, proble
 	if len(code) > problem.SourceSize { // Maximum admitted by problem
 		return -1, Statusf(400, "Code exceeds %d characters", problem.SourceSize)
 	}
+	if err := validateSubmissionFiles(problem, lang, code, files); err != nil {
+		return -1, err
+	}
 	if !s.IsProblemVisible(author.Brief(), problem) {
 		return -1, Statusf(400, "Submitter can't see the problem!")
 	}
@@ -398,6 +448,9 @@ func (s *BaseAPI) CreateSubmission(ctx context.Context, author *UserFull, proble
 		if err != nil || pb == nil {
 			return -1, Statusf(400, "Problem is not in contest")
 		}
+		if contest.AllowHacking && s.ContestProblemLocked(ctx, contest.ID, author.ID, pb.ID) {
+			return -1, Statusf(400, "You locked this problem and cannot submit to it anymore")
+		}
 		cnt, err := s.RemainingSubmissionCount(ctx, contest, pb, author.Brief())
 		if err != nil {
 			return -1, err
@@ -446,6 +499,11 @@ func (s *BaseAPI) CreateSubmission(ctx context.Context, author *UserFull, proble
 		return -1, Statusf(500, "Couldn't create submission")
 	}
 
+	if err := s.db.CreateSubmissionFiles(ctx, id, files); err != nil {
+		zap.S().Warn("Couldn't save submission files:", err)
+		return -1, Statusf(500, "Couldn't save submission files")
+	}
+
 	if err := s.db.InitSubmission(ctx, id); err != nil {
 		zap.S().Warn("Couldn't initialize submission:", err)
 		return -1, Statusf(500, "Couldn't initialize submission")
@@ -462,6 +520,12 @@ func (s *BaseAPI) DeleteSubmission(ctx context.Context, subID int) *StatusError
 		zap.S().Warn("Couldn't delete submission:", err)
 		return Statusf(500, "Failed to delete submission")
 	}
+	// The compiled binary might have been kept for reevaluations
+	for _, name := range []string{fmt.Sprintf("%d.bin", subID), fmt.Sprintf("%d.key", subID)} {
+		if err := datastore.GetBucket(datastore.BucketTypeCompiles).RemoveFile(name); err != nil {
+			zap.S().Warn("Couldn't remove compilation artifact: ", err)
+		}
+	}
 	return nil
 }
 
@@ -480,6 +544,145 @@ func (s *BaseAPI) ResetProblemSubmissions(ctx context.Context, problem *kilonova
 	return nil
 }
 
+// ReevaluateChangedTests reevaluates only the subtests that were evaluated on outdated test data, instead of all submissions.
+// Submissions are also evaluated on the tests added since they were judged and rescored without the removed ones.
+// It returns the number of submissions queued for reevaluation.
+func (s *BaseAPI) ReevaluateChangedTests(ctx context.Context, problem *kilonova.Problem) (int, *StatusError) {
+	count, err := s.db.ResetOutdatedSubTests(ctx, problem.ID)
+	if err != nil {
+		zap.S().Warn(err)
+		return -1, WrapError(err, "Couldn't mark subtests for reevaluation")
+	}
+	if count == 0 {
+		return 0, nil
+	}
+
+	s.LogUserAction(ctx, "Reevaluated changed tests", slog.Any("problem", problem), slog.Int("count", count))
+
+	// Wake grader to start processing immediately
+	s.WakeGrader()
+	return count, nil
+}
+
+// ReevaluateToolchainSubmissions reevaluates the problem's finished submissions that have results from the given toolchain,
+// such that the results of a broken compiler or sandbox can be replaced. It returns the number of submissions queued for reevaluation.
+func (s *BaseAPI) ReevaluateToolchainSubmissions(ctx context.Context, problem *kilonova.Problem, languageVersion, sandboxVersion *string) (int, *StatusError) {
+	if languageVersion == nil && sandboxVersion == nil {
+		return -1, Statusf(400, "No toolchain version specified")
+	}
+	filter := kilonova.SubmissionFilter{
+		ProblemID:       &problem.ID,
+		Status:          kilonova.StatusFinished,
+		LanguageVersion: languageVersion,
+		SandboxVersion:  sandboxVersion,
+	}
+	count, err := s.db.SubmissionCount(ctx, filter, -1)
+	if err != nil {
+		zap.S().Warn(err)
+		return -1, WrapError(err, "Couldn't count submissions")
+	}
+	if count == 0 {
+		return 0, nil
+	}
+	if err := s.db.BulkUpdateSubmissions(ctx, filter, kilonova.SubmissionUpdate{Status: kilonova.StatusReevaling}); err != nil {
+		zap.S().Warn(err)
+		return -1, WrapError(err, "Couldn't mark submissions for reevaluation")
+	}
+
+	s.LogUserAction(ctx, "Reevaluated submissions by toolchain", slog.Any("problem", problem), slog.Any("language_version", languageVersion), slog.Any("sandbox_version", sandboxVersion), slog.Int("count", count))
+
+	// Wake grader to start processing immediately
+	s.WakeGrader()
+	return count, nil
+}
+
+// SubmissionScoreChanges returns the scores of the submissions before and after the problem's last selective reevaluation
+func (s *BaseAPI) SubmissionScoreChanges(ctx context.Context, problem *kilonova.Problem) ([]*kilonova.SubmissionScoreChange, *StatusError) {
+	changes, err := s.db.SubmissionScoreChanges(ctx, problem.ID)
+	if err != nil {
+		zap.S().Warn(err)
+		return nil, WrapError(err, "Couldn't get score changes")
+	}
+	return changes, nil
+}
+
+// CancelSubmission stops the evaluation of the submission.
+// Running evaluations are killed and marked as cancelled, while reevaluations keep their previous result, even if the grader already picked them up.
+func (s *BaseAPI) CancelSubmission(ctx context.Context, sub *kilonova.Submission) *StatusError {
+	switch sub.Status {
+	case kilonova.StatusFinished:
+		return Statusf(400, "Submission is not being evaluated")
+	case kilonova.StatusReevaling:
+		if err := s.db.BulkUpdateSubmissions(ctx, kilonova.SubmissionFilter{ID: &sub.ID, Status: kilonova.StatusReevaling}, kilonova.SubmissionUpdate{
+			Status: kilonova.StatusFinished,
+		}); err != nil {
+			zap.S().Warn(err)
+			return WrapError(err, "Couldn't cancel reevaluation")
+		}
+		s.LogUserAction(ctx, "Cancelled submission reevaluation", slog.Int("submission_id", sub.ID))
+		return nil
+	}
+
+	if s.grader != nil && s.grader.CancelSubmission(sub.ID) {
+		s.LogUserAction(ctx, "Cancelled submission evaluation", slog.Int("submission_id", sub.ID))
+		return nil
+	}
+	cancelled, err := s.db.CancelSubmission(ctx, sub.ID, "translate:cancelled", true)
+	if err != nil {
+		zap.S().Warn(err)
+		return WrapError(err, "Couldn't cancel submission")
+	}
+	// The grader might have picked up the submission in the meantime
+	if !cancelled && (s.grader == nil || !s.grader.CancelSubmission(sub.ID)) {
+		return Statusf(400, "Submission is not being evaluated")
+	}
+	s.LogUserAction(ctx, "Cancelled submission evaluation", slog.Int("submission_id", sub.ID))
+	return nil
+}
+
+// CancelProblemEvaluations stops all the pending evaluations of the problem's submissions.
+// It is meant for reevaluations triggered by mistake, so the submissions that were not fully reevaluated get back their previous result.
+func (s *BaseAPI) CancelProblemEvaluations(ctx context.Context, problem *kilonova.Problem) *StatusError {
+	if err := s.db.BulkUpdateSubmissions(ctx, kilonova.SubmissionFilter{ProblemID: &problem.ID, Status: kilonova.StatusReevaling}, kilonova.SubmissionUpdate{
+		Status: kilonova.StatusFinished,
+	}); err != nil {
+		zap.S().Warn(err)
+		return WrapError(err, "Couldn't cancel reevaluations")
+	}
+
+	subs, err := s.db.Submissions(ctx, kilonova.SubmissionFilter{ProblemID: &problem.ID, Waiting: true})
+	if err != nil {
+		zap.S().Warn(err)
+		return WrapError(err, "Couldn't get pending submissions")
+	}
+	for _, sub := range subs {
+		if err := s.CancelSubmission(ctx, sub); err != nil && err.Code != 400 {
+			return err
+		}
+	}
+
+	s.LogUserAction(ctx, "Cancelled problem evaluations", slog.Any("problem", problem))
+	return nil
+}
+
+// DeleteSubmissionSnapshot removes the result saved before the submission was reevaluated, which is restored if the reevaluation is cancelled
+func (s *BaseAPI) DeleteSubmissionSnapshot(ctx context.Context, subID int) *StatusError {
+	if err := s.db.DeleteSubmissionSnapshot(ctx, subID); err != nil {
+		zap.S().Warn(err)
+		return WrapError(err, "Couldn't remove submission snapshot")
+	}
+	return nil
+}
+
+// FinishCancelledSubmission marks the submission as cancelled, once the grader stopped evaluating it
+func (s *BaseAPI) FinishCancelledSubmission(ctx context.Context, subID int, verdict string) *StatusError {
+	if _, err := s.db.CancelSubmission(ctx, subID, verdict, false); err != nil {
+		zap.S().Warn(err)
+		return WrapError(err, "Couldn't cancel submission")
+	}
+	return nil
+}
+
 func (s *BaseAPI) subVisibleRegardless(ctx context.Context, sub *kilonova.Submission, user *kilonova.UserBrief, subProblem *kilonova.Problem) bool {
 	if sub == nil {
 		return false
@@ -530,6 +733,11 @@ func (s *BaseAPI) isSubmissionVisible(ctx context.Context, sub *kilonova.Submiss
 		}
 	}
 
+	// Contestants that locked the problem may view accepted submissions in order to hack them
+	if s.CanViewHackTarget(ctx, sub, user) {
+		return true
+	}
+
 	return s.subVisibleRegardless(ctx, sub, user, subProblem)
 }
 
@@ -544,6 +752,9 @@ func (s *BaseAPI) filterSubmission(ctx context.Context, sub *kilonova.Submission
 	}
 	if !s.IsProblemEditor(user, subProblem) {
 		sub.CompileTime = nil
+		sub.LanguageVersion = nil
+		sub.SandboxVersion = nil
+		sub.ExpectedVerdict = nil
 	}
 }
 
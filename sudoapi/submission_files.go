@@ -0,0 +1,178 @@
+package sudoapi
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/eval"
+	"github.com/KiloProjects/kilonova/internal/config"
+	"go.uber.org/zap"
+)
+
+var (
+	MaxSubmissionFiles = config.GenFlag[int]("behavior.submissions.max_files", 20, "Maximum number of source files in a multi-file submission")
+)
+
+var submissionFileRegex = regexp.MustCompile(`^[a-zA-Z0-9._-]+(/[a-zA-Z0-9._-]+)*$`)
+
+func (s *BaseAPI) SubmissionFiles(ctx context.Context, subID int) ([]*kilonova.SubmissionFile, *StatusError) {
+	files, err := s.db.SubmissionFiles(ctx, subID)
+	if err != nil {
+		zap.S().Warn(err)
+		return nil, WrapError(err, "Couldn't get submission files")
+	}
+	return files, nil
+}
+
+// RawSubmissionSource returns the entrypoint and all the other files of the submission concatenated, to be used for plagiarism checks
+func (s *BaseAPI) RawSubmissionSource(ctx context.Context, sub *kilonova.Submission) ([]byte, *StatusError) {
+	code, err := s.RawSubmissionCode(ctx, sub.ID)
+	if err != nil || sub.NumFiles == 0 {
+		return code, err
+	}
+	files, err := s.SubmissionFiles(ctx, sub.ID)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	buf.Write(code)
+	for _, file := range files {
+		buf.WriteString("\n\n")
+		buf.Write(file.Data)
+	}
+	return buf.Bytes(), nil
+}
+
+// validateSubmissionFiles checks the additional files of a submission. Their total size, together with the entrypoint, must fit in the problem's source size
+func validateSubmissionFiles(problem *kilonova.Problem, lang eval.Language, code []byte, files []*kilonova.SubmissionFile) *StatusError {
+	if len(files) == 0 {
+		return nil
+	}
+	if !lang.MultiFile {
+		return Statusf(400, "Language doesn't support multiple files")
+	}
+	if len(files) > MaxSubmissionFiles.Value() {
+		return Statusf(400, "Submission must not have more than %d files", MaxSubmissionFiles.Value())
+	}
+
+	entrypoint := path.Base(lang.SourceName)
+	size := len(code)
+	names := make(map[string]bool)
+	for _, file := range files {
+		if !submissionFileRegex.MatchString(file.Name) || slices.Contains(strings.Split(file.Name, "/"), "..") {
+			return Statusf(400, "Invalid file name %q", file.Name)
+		}
+		if file.Name == entrypoint {
+			return Statusf(400, "File name %q is reserved for the entrypoint", file.Name)
+		}
+		if names[file.Name] {
+			return Statusf(400, "Duplicate file name %q", file.Name)
+		}
+		names[file.Name] = true
+		size += len(file.Data)
+	}
+	if size > problem.SourceSize {
+		return Statusf(400, "Code exceeds %d characters", problem.SourceSize)
+	}
+	return nil
+}
+
+// ExpandSubmissionFiles returns the uploaded files, with the zip archives extracted
+func ExpandSubmissionFiles(uploads []*kilonova.SubmissionFile, maxSize int) ([]*kilonova.SubmissionFile, *StatusError) {
+	var files []*kilonova.SubmissionFile
+	for _, upload := range uploads {
+		if strings.HasSuffix(upload.Name, ".zip") {
+			extracted, err := extractSubmissionArchive(upload.Data, maxSize)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, extracted...)
+			continue
+		}
+		files = append(files, &kilonova.SubmissionFile{Name: path.Base(upload.Name), Data: upload.Data})
+	}
+	return files, nil
+}
+
+// SplitSubmissionFiles separates the entrypoint from the other files.
+// If entrypoint is empty, the file named like the language's default source file (ie. main.cpp) is chosen,
+// or the only file with one of the language's extensions.
+func SplitSubmissionFiles(lang eval.Language, files []*kilonova.SubmissionFile, entrypoint string) ([]byte, []*kilonova.SubmissionFile, *StatusError) {
+	if len(files) == 0 {
+		return nil, nil, Statusf(400, "No source files")
+	}
+
+	idx := -1
+	if entrypoint != "" {
+		idx = slices.IndexFunc(files, func(f *kilonova.SubmissionFile) bool { return f.Name == entrypoint })
+	} else {
+		idx = slices.IndexFunc(files, func(f *kilonova.SubmissionFile) bool { return f.Name == path.Base(lang.SourceName) })
+		if idx < 0 {
+			for i, file := range files {
+				if !slices.Contains(lang.Extensions, path.Ext(file.Name)) {
+					continue
+				}
+				if idx >= 0 {
+					return nil, nil, Statusf(400, "Couldn't determine entrypoint, please specify it")
+				}
+				idx = i
+			}
+		}
+	}
+	if idx < 0 {
+		return nil, nil, Statusf(400, "Couldn't find entrypoint")
+	}
+
+	code := files[idx].Data
+	return code, slices.Delete(files, idx, idx+1), nil
+}
+
+func extractSubmissionArchive(data []byte, maxSize int) ([]*kilonova.SubmissionFile, *StatusError) {
+	ar, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, Statusf(400, "Invalid zip archive")
+	}
+	var files []*kilonova.SubmissionFile
+	size := 0
+	for _, f := range ar.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if size+int(f.UncompressedSize64) > maxSize {
+			return nil, Statusf(400, "Code exceeds %d characters", maxSize)
+		}
+		r, err := f.Open()
+		if err != nil {
+			return nil, Statusf(400, "Invalid zip archive")
+		}
+		fData, err := io.ReadAll(io.LimitReader(r, int64(maxSize-size)+1))
+		r.Close()
+		if err != nil {
+			return nil, Statusf(400, "Invalid zip archive")
+		}
+		size += len(fData)
+		if size > maxSize {
+			return nil, Statusf(400, "Code exceeds %d characters", maxSize)
+		}
+		files = append(files, &kilonova.SubmissionFile{Name: path.Clean(f.Name), Data: fData})
+	}
+
+	// Archives usually hold a single top-level directory with the project, which is not part of the file names
+	for len(files) > 0 {
+		prefix, _, ok := strings.Cut(files[0].Name, "/")
+		if !ok || slices.ContainsFunc(files, func(f *kilonova.SubmissionFile) bool { return !strings.HasPrefix(f.Name, prefix+"/") }) {
+			break
+		}
+		for _, file := range files {
+			file.Name = strings.TrimPrefix(file.Name, prefix+"/")
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,130 @@
+package sudoapi
+
+import (
+	"archive/zip"
+	"bytes"
+	"slices"
+	"testing"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/eval"
+)
+
+type splitFilesTest struct {
+	Files      []string
+	Entrypoint string
+	// Expected is the name of the file chosen as the entrypoint
+	Expected string
+	Error    bool
+}
+
+var splitFilesExamples = map[string]splitFilesTest{
+	"default_name":   {Files: []string{"util.cpp", "main.cpp", "util.h"}, Expected: "main.cpp"},
+	"single_source":  {Files: []string{"util.h", "sol.cpp"}, Expected: "sol.cpp"},
+	"explicit":       {Files: []string{"main.cpp", "sol.cpp"}, Entrypoint: "sol.cpp", Expected: "sol.cpp"},
+	"explicit_dir":   {Files: []string{"src/sol.cpp", "main.cpp"}, Entrypoint: "src/sol.cpp", Expected: "src/sol.cpp"},
+	"fail_ambiguous": {Files: []string{"a.cpp", "b.cpp"}, Error: true},
+	"fail_no_source": {Files: []string{"util.h", "notes.txt"}, Error: true},
+	"fail_missing":   {Files: []string{"main.cpp"}, Entrypoint: "sol.cpp", Error: true},
+	"fail_empty":     {Error: true},
+}
+
+func TestSplitSubmissionFiles(t *testing.T) {
+	lang := eval.Language{SourceName: "/box/main.cpp", Extensions: []string{".cpp"}}
+	for k, v := range splitFilesExamples {
+		v := v
+		t.Run(k, func(t *testing.T) {
+			t.Parallel()
+			var files []*kilonova.SubmissionFile
+			for _, name := range v.Files {
+				files = append(files, &kilonova.SubmissionFile{Name: name, Data: []byte(name)})
+			}
+			code, rest, err := SplitSubmissionFiles(lang, files, v.Entrypoint)
+			if err != nil && !v.Error {
+				t.Fatalf("Error splitting files: %#v", err)
+			}
+			if err == nil && v.Error {
+				t.Fatalf("Test should not succeed")
+			}
+			if v.Error {
+				return
+			}
+			if string(code) != v.Expected {
+				t.Fatalf("Invalid entrypoint, expected %q, got %q", v.Expected, code)
+			}
+			if len(rest) != len(v.Files)-1 || slices.ContainsFunc(rest, func(f *kilonova.SubmissionFile) bool { return f.Name == v.Expected }) {
+				t.Fatalf("Invalid remaining files: %v", rest)
+			}
+		})
+	}
+}
+
+type submissionArchiveTest struct {
+	Files   map[string]string
+	MaxSize int
+	// Expected maps the extracted file names to their contents
+	Expected map[string]string
+	Error    bool
+}
+
+var submissionArchiveExamples = map[string]submissionArchiveTest{
+	"flat":            {Files: map[string]string{"main.cpp": "a", "util.h": "b"}, MaxSize: 10, Expected: map[string]string{"main.cpp": "a", "util.h": "b"}},
+	"top_dir":         {Files: map[string]string{"proj/main.cpp": "a", "proj/lib/util.h": "b"}, MaxSize: 10, Expected: map[string]string{"main.cpp": "a", "lib/util.h": "b"}},
+	"nested_top_dirs": {Files: map[string]string{"a/b/main.cpp": "a", "a/b/util.h": "b"}, MaxSize: 10, Expected: map[string]string{"main.cpp": "a", "util.h": "b"}},
+	"mixed_dirs":      {Files: map[string]string{"src/main.cpp": "a", "include/util.h": "b"}, MaxSize: 10, Expected: map[string]string{"src/main.cpp": "a", "include/util.h": "b"}},
+	"cleaned_names":   {Files: map[string]string{"./main.cpp": "a", "lib//util.h": "b"}, MaxSize: 10, Expected: map[string]string{"main.cpp": "a", "lib/util.h": "b"}},
+	"exact_size":      {Files: map[string]string{"main.cpp": "aaaa", "util.h": "bbbb"}, MaxSize: 8, Expected: map[string]string{"main.cpp": "aaaa", "util.h": "bbbb"}},
+	"fail_too_big":    {Files: map[string]string{"main.cpp": "aaaa", "util.h": "bbbbb"}, MaxSize: 8, Error: true},
+}
+
+func TestExtractSubmissionArchive(t *testing.T) {
+	for k, v := range submissionArchiveExamples {
+		v := v
+		t.Run(k, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			w := zip.NewWriter(&buf)
+			for name, data := range v.Files {
+				f, err := w.Create(name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := f.Write([]byte(data)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			files, err := extractSubmissionArchive(buf.Bytes(), v.MaxSize)
+			if err != nil && !v.Error {
+				t.Fatalf("Error extracting archive: %#v", err)
+			}
+			if err == nil && v.Error {
+				t.Fatalf("Test should not succeed")
+			}
+			if v.Error {
+				return
+			}
+			if len(files) != len(v.Expected) {
+				t.Fatalf("Invalid number of files, expected %d, got %d", len(v.Expected), len(files))
+			}
+			for _, file := range files {
+				data, ok := v.Expected[file.Name]
+				if !ok {
+					t.Fatalf("Unexpected file %q", file.Name)
+				}
+				if string(file.Data) != data {
+					t.Fatalf("Invalid contents of %q, expected %q, got %q", file.Name, data, file.Data)
+				}
+			}
+		})
+	}
+}
+
+func TestExtractInvalidSubmissionArchive(t *testing.T) {
+	if _, err := extractSubmissionArchive([]byte("not a zip"), 100); err == nil {
+		t.Fatal("Invalid archives should not be extracted")
+	}
+}
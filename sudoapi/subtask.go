@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/KiloProjects/kilonova"
+	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
 )
 
@@ -41,7 +42,13 @@ func (s *BaseAPI) CreateSubTask(ctx context.Context, subtask *kilonova.SubTask)
 	return nil
 }
 
+// UpdateSubTask updates the subtask's metadata.
+// The threshold only has meaning for threshold aggregation, so switching to another aggregation resets it to the default
 func (s *BaseAPI) UpdateSubTask(ctx context.Context, id int, upd kilonova.SubTaskUpdate) *StatusError {
+	if upd.Aggregation != kilonova.AggregationNone && upd.Aggregation != kilonova.AggregationThreshold {
+		threshold := decimal.NewFromInt(100)
+		upd.Threshold = &threshold
+	}
 	if err := s.db.UpdateSubTask(ctx, id, upd); err != nil {
 		zap.S().Warn(err)
 		return WrapError(err, "Couldn't update subtask metadata")
@@ -27,6 +27,16 @@ func (s *BaseAPI) SubTests(ctx context.Context, submissionID int) ([]*kilonova.S
 	return stests, nil
 }
 
+// RelativeSubTests returns the finished subtests with objective values for the given tests
+func (s *BaseAPI) RelativeSubTests(ctx context.Context, testIDs []int) ([]*kilonova.SubTest, *StatusError) {
+	stests, err := s.db.RelativeSubTests(ctx, testIDs)
+	if err != nil {
+		zap.S().Warn(err)
+		return nil, WrapError(err, "Couldn't get relative subtests")
+	}
+	return stests, nil
+}
+
 func (s *BaseAPI) UpdateSubTest(ctx context.Context, id int, upd kilonova.SubTestUpdate) *StatusError {
 	if err := s.db.UpdateSubTest(ctx, id, upd); err != nil {
 		zap.S().Warn(err)
@@ -3,8 +3,12 @@ package sudoapi
 import (
 	"context"
 	"errors"
+	"io"
+	"regexp"
+	"slices"
 
 	"github.com/KiloProjects/kilonova"
+	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
 )
 
@@ -36,6 +40,67 @@ func (s *BaseAPI) UpdateTest(ctx context.Context, testID int, upd kilonova.TestU
 	return nil
 }
 
+// ImproveTestObjective updates the test's best known objective value, if the given value is better.
+// It returns true if the value was improved.
+func (s *BaseAPI) ImproveTestObjective(ctx context.Context, testID int, value decimal.Decimal, objective kilonova.ObjectiveType) (bool, *StatusError) {
+	improved, err := s.db.ImproveTestObjective(ctx, testID, value, objective == kilonova.ObjectiveMinimize)
+	if err != nil {
+		zap.S().Warn(err)
+		return false, WrapError(err, "Couldn't update best objective")
+	}
+	return improved, nil
+}
+
+func (s *BaseAPI) TestBestObjective(ctx context.Context, testID int) (*decimal.Decimal, *StatusError) {
+	best, err := s.db.TestBestObjective(ctx, testID)
+	if err != nil {
+		zap.S().Warn(err)
+		return nil, WrapError(err, "Couldn't get best objective")
+	}
+	return best, nil
+}
+
+func (s *BaseAPI) ResetTestObjective(ctx context.Context, testID int) *StatusError {
+	if err := s.db.ResetTestObjective(ctx, testID); err != nil {
+		zap.S().Warn(err)
+		return WrapError(err, "Couldn't reset best objective")
+	}
+	return nil
+}
+
+// ResetProblemObjectives clears the best objective values of the problem's tests, used when the objective changes
+func (s *BaseAPI) ResetProblemObjectives(ctx context.Context, problemID int) *StatusError {
+	if err := s.db.ResetProblemObjectives(ctx, problemID); err != nil {
+		zap.S().Warn(err)
+		return WrapError(err, "Couldn't reset best objectives")
+	}
+	return nil
+}
+
+// TestDataChanged must be called after the test's files are changed.
+// It updates the test's data hash, which marks the subtests evaluated on the old data as outdated, and resets the test's best objective value.
+func (s *BaseAPI) TestDataChanged(ctx context.Context, test *kilonova.Test) *StatusError {
+	hash, err := s.TestDataHash(test)
+	if err != nil {
+		zap.S().Warn(err)
+		return WrapError(err, "Couldn't hash test data")
+	}
+	if err := s.UpdateTest(ctx, test.ID, kilonova.TestUpdate{DataHash: &hash}); err != nil {
+		return err
+	}
+	test.DataHash = &hash
+	// Objective values for the old data are meaningless
+	return s.ResetTestObjective(ctx, test.ID)
+}
+
+// GenerateTests runs the test generators and the solution on the grader, for problem packages that don't contain all of their test data
+func (s *BaseAPI) GenerateTests(ctx context.Context, gen *kilonova.TestGeneration) *StatusError {
+	if s.grader == nil {
+		return Statusf(503, "Grader is not running")
+	}
+	return s.grader.GenerateTests(ctx, gen)
+}
+
 func (s *BaseAPI) CreateTest(ctx context.Context, test *kilonova.Test) *StatusError {
 	if err := s.db.CreateTest(ctx, test); err != nil {
 		zap.S().Warn(err)
@@ -45,13 +110,13 @@ func (s *BaseAPI) CreateTest(ctx context.Context, test *kilonova.Test) *StatusEr
 }
 
 func (s *BaseAPI) DeleteTests(ctx context.Context, problemID int) *StatusError {
-	ids, err := s.db.DeleteProblemTests(ctx, problemID)
+	tests, err := s.db.DeleteProblemTests(ctx, problemID)
 	if err != nil {
 		zap.S().Warn(err)
 		return WrapError(err, "Couldn't remove tests")
 	}
-	for _, id := range ids {
-		if err := s.PurgeTestData(id); err != nil {
+	for _, test := range tests {
+		if err := s.PurgeTestData(test.ID, test.InputFiles); err != nil {
 			zap.S().Warn(err)
 		}
 	}
@@ -64,11 +129,15 @@ func (s *BaseAPI) DeleteTests(ctx context.Context, problemID int) *StatusError {
 // Please note that this function does not properly ensure that subtasks would be cleaned up afterwards.
 // This is left as an exercise to the caller
 func (s *BaseAPI) DeleteTest(ctx context.Context, id int) *StatusError {
-	if err := s.db.DeleteTest(ctx, id); err != nil {
+	test, err := s.db.DeleteTest(ctx, id)
+	if err != nil {
 		zap.S().Warn(err)
 		return WrapError(err, "Couldn't remove test")
 	}
-	if err := s.PurgeTestData(id); err != nil {
+	if test == nil {
+		return nil
+	}
+	if err := s.PurgeTestData(test.ID, test.InputFiles); err != nil {
 		zap.S().Warn(err)
 	}
 	return nil
@@ -84,3 +153,59 @@ func (s *BaseAPI) NextVID(ctx context.Context, problemID int) int {
 	}
 	return max + 1
 }
+
+var testInputFileRegex = regexp.MustCompile(`^[a-zA-Z0-9_-][a-zA-Z0-9._-]{0,63}$`)
+
+// ValidateTestInputFile checks that the name can be used for an additional input file of the problem's tests.
+// The file must not overwrite the regular input and output files or the executable in the box.
+func ValidateTestInputFile(pb *kilonova.Problem, name string) *StatusError {
+	if !testInputFileRegex.MatchString(name) {
+		return Statusf(400, "Invalid input file name")
+	}
+	reserved := []string{
+		pb.TestName + ".in", pb.TestName + ".out",
+		"stdin.in", "stdin.out",
+		"output", "output.jar", "main",
+	}
+	if slices.Contains(reserved, name) {
+		return Statusf(400, "Input file name %q is reserved", name)
+	}
+	return nil
+}
+
+// AddTestInputFile saves an additional input file of the test. Existing files with the same name are overwritten
+func (s *BaseAPI) AddTestInputFile(ctx context.Context, pb *kilonova.Problem, test *kilonova.Test, name string, r io.Reader) *StatusError {
+	if err := ValidateTestInputFile(pb, name); err != nil {
+		return err
+	}
+	if err := s.SaveTestInputFile(test.ID, name, r); err != nil {
+		zap.S().Warn(err)
+		return WrapError(err, "Couldn't save input file")
+	}
+	if slices.Contains(test.InputFiles, name) {
+		return nil
+	}
+	files := append(slices.Clone(test.InputFiles), name)
+	slices.Sort(files)
+	if err := s.UpdateTest(ctx, test.ID, kilonova.TestUpdate{InputFiles: files}); err != nil {
+		return err
+	}
+	test.InputFiles = files
+	return nil
+}
+
+// RemoveTestInputFile removes an additional input file of the test
+func (s *BaseAPI) RemoveTestInputFile(ctx context.Context, test *kilonova.Test, name string) *StatusError {
+	if !slices.Contains(test.InputFiles, name) {
+		return Statusf(404, "Input file not found")
+	}
+	files := slices.DeleteFunc(slices.Clone(test.InputFiles), func(f string) bool { return f == name })
+	if err := s.UpdateTest(ctx, test.ID, kilonova.TestUpdate{InputFiles: files}); err != nil {
+		return err
+	}
+	test.InputFiles = files
+	if err := s.testBucket.RemoveFile(kilonova.TestInputFilename(test.ID, name)); err != nil {
+		zap.S().Warn("Couldn't remove test input file: ", err)
+	}
+	return nil
+}
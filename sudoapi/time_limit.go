@@ -0,0 +1,262 @@
+package sudoapi
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	maxRecommendationSolutions = 10
+	maxRecommendationRuns      = 5
+
+	// The recommended time limit is recommendationMargin times the running time of the slowest intended solution.
+	// It may be lowered down to minRecommendationMargin times, so that the slow solutions still time out.
+	recommendationMargin    = 2.5
+	minRecommendationMargin = 2
+	minRecommendedLimit     = 0.1 // seconds
+
+	// Intended solutions are run with a few times the current time limit, to measure them even if the limit is too tight
+	intendedLimitMultiplier = 3
+)
+
+// TimeLimitJob is the progress of a time limit recommendation computed in the background.
+// Jobs are only kept in memory, so they are lost when the server restarts
+type TimeLimitJob struct {
+	ProblemID int `json:"problem_id"`
+
+	Running bool `json:"running"`
+	// Total and Done count the measured solutions
+	Total int    `json:"total"`
+	Done  int    `json:"done"`
+	Error string `json:"error,omitempty"`
+
+	Result *kilonova.TimeLimitRecommendation `json:"result"`
+
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+}
+
+type timeLimitJobs struct {
+	mu   sync.Mutex
+	jobs map[int]*TimeLimitJob
+}
+
+// StartTimeLimitRecommendation suggests in the background a time limit based on the running time of the accepted solutions from the problem editors.
+// If runs is positive, the solutions are run again that many times on the current grader, otherwise their existing results are used.
+// The submissions in slowIDs are the ones that should not pass, they are checked against the recommendation.
+func (s *BaseAPI) StartTimeLimitRecommendation(ctx context.Context, problem *kilonova.Problem, runs int, slowIDs []int) (*TimeLimitJob, *StatusError) {
+	if runs < 0 || runs > maxRecommendationRuns {
+		return nil, Statusf(400, "Number of runs must be between 0 and %d", maxRecommendationRuns)
+	}
+	if runs > 0 && s.grader == nil {
+		return nil, Statusf(503, "Grader is not running")
+	}
+
+	hundred := decimal.NewFromInt(100)
+	intended, err := s.RawSubmissions(ctx, kilonova.SubmissionFilter{
+		ProblemID:   &problem.ID,
+		FromAuthors: true,
+		Status:      kilonova.StatusFinished,
+		Score:       &hundred,
+		Limit:       maxRecommendationSolutions,
+	})
+	if err != nil {
+		return nil, err
+	}
+	intended = slices.DeleteFunc(intended, func(sub *kilonova.Submission) bool { return slices.Contains(slowIDs, sub.ID) })
+	if len(intended) == 0 {
+		return nil, Statusf(400, "Problem has no accepted submissions from its editors")
+	}
+	var slow []*kilonova.Submission
+	if len(slowIDs) > 0 {
+		slow, err = s.RawSubmissions(ctx, kilonova.SubmissionFilter{ProblemID: &problem.ID, IDs: slowIDs})
+		if err != nil {
+			return nil, err
+		}
+		if len(slow) != len(slowIDs) {
+			return nil, Statusf(400, "Slow submissions must be submissions to this problem")
+		}
+	}
+
+	s.timeLimitJobs.mu.Lock()
+	defer s.timeLimitJobs.mu.Unlock()
+	if s.timeLimitJobs.jobs == nil {
+		s.timeLimitJobs.jobs = make(map[int]*TimeLimitJob)
+	}
+	if job, ok := s.timeLimitJobs.jobs[problem.ID]; ok && job.Running {
+		return nil, Statusf(400, "A time limit recommendation is already running for this problem")
+	}
+	job := &TimeLimitJob{
+		ProblemID: problem.ID,
+
+		Running: true,
+		Total:   len(intended) + len(slow),
+
+		StartedAt: time.Now(),
+	}
+	s.timeLimitJobs.jobs[problem.ID] = job
+
+	pb := *problem
+	go func() {
+		rec, err := s.recommendTimeLimit(context.WithoutCancel(ctx), &pb, runs, intended, slow, job)
+
+		s.timeLimitJobs.mu.Lock()
+		defer s.timeLimitJobs.mu.Unlock()
+		if err != nil {
+			job.Error = err.Error()
+		}
+		job.Result = rec
+		job.Running = false
+		finishedAt := time.Now()
+		job.FinishedAt = &finishedAt
+	}()
+
+	ret := *job
+	return &ret, nil
+}
+
+// TimeLimitRecommendation returns the status of the last time limit recommendation of the problem, or nil if none was started
+func (s *BaseAPI) TimeLimitRecommendation(problemID int) *TimeLimitJob {
+	s.timeLimitJobs.mu.Lock()
+	defer s.timeLimitJobs.mu.Unlock()
+	job, ok := s.timeLimitJobs.jobs[problemID]
+	if !ok {
+		return nil
+	}
+	ret := *job
+	return &ret
+}
+
+func (s *BaseAPI) recommendTimeLimit(ctx context.Context, problem *kilonova.Problem, runs int, intended, slow []*kilonova.Submission, job *TimeLimitJob) (*kilonova.TimeLimitRecommendation, *StatusError) {
+	rec := &kilonova.TimeLimitRecommendation{
+		Solutions: []*kilonova.SolutionTiming{},
+		Languages: make(map[string]float64),
+		Warnings:  []string{},
+	}
+
+	var speedFactor float64
+	if factor := s.GraderSpeedFactor(); factor != nil {
+		speedFactor = *factor
+	}
+	intendedLimit := intendedLimitMultiplier * problem.ScaledTimeLimit(speedFactor)
+	for _, sub := range intended {
+		timing, err := s.solutionTiming(ctx, problem, sub, runs, intendedLimit, speedFactor)
+		if err != nil {
+			return nil, err
+		}
+		s.timeLimitJobDone(job)
+		rec.Solutions = append(rec.Solutions, timing)
+		if timing.TimedOut {
+			rec.Warnings = append(rec.Warnings, fmt.Sprintf("Intended submission #%d takes more than %.2fs, %d times the current time limit.", sub.ID, intendedLimit, intendedLimitMultiplier))
+		}
+		if timing.Verdict != "" {
+			rec.Warnings = append(rec.Warnings, fmt.Sprintf("Intended submission #%d failed with verdict %q.", sub.ID, timing.Verdict))
+		}
+		rec.Languages[sub.Language] = max(rec.Languages[sub.Language], timing.MaxTime)
+		rec.SlowestIntended = max(rec.SlowestIntended, timing.MaxTime)
+	}
+	rec.Recommended = roundTimeLimit(max(recommendationMargin*rec.SlowestIntended, minRecommendedLimit))
+
+	// Slow solutions only need to be run up to the highest limit that could be recommended
+	slowLimit := roundTimeLimit(max(intendedLimitMultiplier*rec.SlowestIntended, rec.Recommended))
+	fastestSlow := math.Inf(1)
+	for _, sub := range slow {
+		timing, err := s.solutionTiming(ctx, problem, sub, runs, slowLimit, speedFactor)
+		if err != nil {
+			return nil, err
+		}
+		s.timeLimitJobDone(job)
+		timing.Slow = true
+		rec.Solutions = append(rec.Solutions, timing)
+		if !timing.TimedOut {
+			fastestSlow = min(fastestSlow, timing.MaxTime)
+		}
+	}
+	if fastestSlow <= rec.Recommended {
+		lowered := roundTimeLimit(max(minRecommendationMargin*rec.SlowestIntended, minRecommendedLimit))
+		if lowered < fastestSlow {
+			rec.Recommended = lowered
+			rec.Warnings = append(rec.Warnings, fmt.Sprintf("The recommendation was lowered so that the slow submissions still time out. The margin over the intended submissions is only %dx.", minRecommendationMargin))
+		} else {
+			rec.Warnings = append(rec.Warnings, fmt.Sprintf("A slow submission runs in %.2fs, less than %dx the slowest intended submission. It can't be separated by the time limit.", fastestSlow, minRecommendationMargin))
+		}
+	}
+
+	// The fastest accepted submissions of every user are a sanity check for the recommendation
+	fastest, err1 := s.db.ProblemStatisticsTime(ctx, problem.ID)
+	if err1 != nil {
+		return nil, WrapError(err1, "Couldn't get statistics by time")
+	}
+	var exceeding int
+	for _, sub := range fastest {
+		if normalizeTime(sub.MaxTime, sub.SpeedFactor, speedFactor) > rec.Recommended {
+			exceeding++
+		}
+	}
+	if exceeding > 0 {
+		rec.Warnings = append(rec.Warnings, fmt.Sprintf("%d of the %d fastest accepted submissions would exceed the recommended time limit.", exceeding, len(fastest)))
+	}
+
+	return rec, nil
+}
+
+func (s *BaseAPI) timeLimitJobDone(job *TimeLimitJob) {
+	s.timeLimitJobs.mu.Lock()
+	defer s.timeLimitJobs.mu.Unlock()
+	job.Done++
+}
+
+// solutionTiming measures the submission on the current grader or, if runs is 0, computes the timing from its existing subtests
+func (s *BaseAPI) solutionTiming(ctx context.Context, problem *kilonova.Problem, sub *kilonova.Submission, runs int, timeLimit float64, speedFactor float64) (*kilonova.SolutionTiming, *StatusError) {
+	if runs > 0 {
+		return s.grader.BenchmarkSubmission(ctx, problem, sub, runs, timeLimit)
+	}
+
+	subtests, err := s.SubTests(ctx, sub.ID)
+	if err != nil {
+		return nil, err
+	}
+	timing := &kilonova.SolutionTiming{
+		SubmissionID: sub.ID,
+		Language:     sub.Language,
+	}
+	for _, st := range subtests {
+		timing.MaxTime = max(timing.MaxTime, normalizeTime(st.Time, sub.SpeedFactor, speedFactor))
+		if strings.Contains(st.Verdict, "timeout") {
+			timing.TimedOut = true
+		}
+	}
+	if sub.CompileError != nil && *sub.CompileError {
+		timing.Verdict = "translate:compile_error"
+	}
+	return timing, nil
+}
+
+// normalizeTime converts a running time measured on a grader with speed factor from to one with speed factor to
+func normalizeTime(t float64, from *float64, to float64) float64 {
+	if from == nil || *from <= 0 || to <= 0 {
+		return t
+	}
+	return t * to / *from
+}
+
+// roundTimeLimit rounds the time limit up to a value that is easy to read:
+// multiples of 0.05s below 1s, of 0.1s below 3s and of 0.5s above
+func roundTimeLimit(t float64) float64 {
+	step := 0.5
+	switch {
+	case t < 1:
+		step = 0.05
+	case t < 3:
+		step = 0.1
+	}
+	return math.Round(math.Ceil(t/step-1e-9)*step*100) / 100
+}
@@ -1,6 +1,8 @@
 package kilonova
 
 import (
+	"fmt"
+	"io"
 	"time"
 
 	"github.com/shopspring/decimal"
@@ -12,11 +14,61 @@ type Test struct {
 	Score     decimal.Decimal `json:"score"`
 	ProblemID int             `db:"problem_id" json:"problem_id"`
 	VisibleID int             `db:"visible_id" json:"visible_id"`
+
+	// BestObjective is the best objective value reported for this test, used for relative scoring
+	BestObjective *decimal.Decimal `db:"best_objective" json:"best_objective"`
+
+	// Pretest marks tests that are used for judging while a contest with system testing is running
+	Pretest bool `json:"pretest"`
+
+	// TimeLimit (in seconds) and MemoryLimit (in KB) override the problem's limits for this test, if set
+	TimeLimit   *float64 `db:"time_limit" json:"time_limit"`
+	MemoryLimit *int     `db:"memory_limit" json:"memory_limit"`
+
+	// InputFiles are the names of the additional input files of the test, which are copied into the box next to the regular input
+	InputFiles []string `db:"input_files" json:"input_files"`
+
+	// DataHash identifies the contents of the test's files, such that subtests evaluated on older data can be found
+	DataHash *string `db:"data_hash" json:"data_hash"`
 }
 
 type TestUpdate struct {
 	Score     *decimal.Decimal `json:"score"`
 	VisibleID *int             `json:"visible_id"`
+	Pretest   *bool            `json:"pretest"`
+
+	// A non-positive limit removes the override
+	TimeLimit   *float64 `json:"time_limit"`
+	MemoryLimit *int     `json:"memory_limit"`
+
+	InputFiles []string `json:"input_files"`
+
+	DataHash *string `json:"data_hash"`
+}
+
+// TestInputFilename returns the name of the test's additional input file in the tests bucket
+func TestInputFilename(testID int, name string) string {
+	return fmt.Sprintf("%d.in.%s", testID, name)
+}
+
+// SubtaskAggregation is the way the test percentages of a subtask are combined into the subtask's final percentage
+type SubtaskAggregation string
+
+const (
+	AggregationNone SubtaskAggregation = ""
+	// AggregationMin takes the minimum percentage of all tests
+	AggregationMin SubtaskAggregation = "min"
+	// AggregationSum takes the sum of the tests' scores, scaled to the subtask score
+	AggregationSum SubtaskAggregation = "sum"
+	// AggregationAverage takes the average percentage of all tests
+	AggregationAverage SubtaskAggregation = "average"
+	// AggregationThreshold awards the full score only if all tests are at least Threshold percent correct
+	AggregationThreshold SubtaskAggregation = "threshold"
+)
+
+func ValidAggregation(agg SubtaskAggregation) bool {
+	return agg == AggregationMin || agg == AggregationSum ||
+		agg == AggregationAverage || agg == AggregationThreshold
 }
 
 type SubTask struct {
@@ -26,9 +78,55 @@ type SubTask struct {
 	VisibleID int             `json:"visible_id"`
 	Score     decimal.Decimal `json:"score"`
 	Tests     []int           `json:"tests"`
+
+	Aggregation SubtaskAggregation `json:"aggregation"`
+	// Threshold is the minimum percentage for AggregationThreshold subtasks
+	Threshold decimal.Decimal `json:"threshold"`
+
+	// TimeLimit and MemoryLimit override the problem's limits for the subtask's tests, unless the tests have their own
+	TimeLimit   *float64 `json:"time_limit"`
+	MemoryLimit *int     `json:"memory_limit"`
 }
 
 type SubTaskUpdate struct {
 	VisibleID *int             `json:"visible_id"`
 	Score     *decimal.Decimal `json:"score"`
+
+	Aggregation SubtaskAggregation `json:"aggregation"`
+	Threshold   *decimal.Decimal   `json:"threshold"`
+
+	// A non-positive limit removes the override
+	TimeLimit   *float64 `json:"time_limit"`
+	MemoryLimit *int     `json:"memory_limit"`
+}
+
+// GeneratorProgram is a program compiled on the grader to generate test data. Testlib is available to it
+type GeneratorProgram struct {
+	Lang string
+	Code []byte
+}
+
+// TestGeneration describes how the grader generates the test data missing from a problem package, like Polygon's doall script.
+// Missing inputs are generated by running the generators, then the missing outputs are produced by running the solution on the inputs
+type TestGeneration struct {
+	// Generators are indexed by the name used in the tests' commands
+	Generators map[string]*GeneratorProgram
+	Solution   *GeneratorProgram
+	// SolutionFilename is the name of the solution's input and output files, or "stdin" if it uses the standard streams
+	SolutionFilename string
+
+	Tests []*GeneratedTest
+}
+
+// GeneratedTest is filled in place by the grader
+type GeneratedTest struct {
+	// Command is the generator name followed by its arguments. It's only used if Input is nil
+	Command []string
+	// Input opens the test's input, for tests that only miss their output
+	Input func() (io.ReadCloser, error)
+
+	// InputFile and OutputFile are the names of the files written by the grader in the tests bucket.
+	// They are set as soon as the files are written, so the caller must remove them even if the generation fails
+	InputFile  string
+	OutputFile string
 }
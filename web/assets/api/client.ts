@@ -19,6 +19,8 @@ export type SubmissionQuery = {
 	page: number;
 
 	compile_error?: boolean;
+	language_version?: string;
+	sandbox_version?: string;
 	ordering?: string;
 	ascending?: boolean;
 
@@ -93,6 +95,8 @@ export class KNClient {
 				score: typeof q.score !== "undefined" && q.score >= 0 ? q.score : undefined,
 				lang: typeof q.lang !== "undefined" && q.lang !== "" ? q.lang : undefined,
 				compile_error: q.compile_error,
+				language_version: q.language_version,
+				sandbox_version: q.sandbox_version,
 				offset: (q.page - 1) * 50,
 				limit: typeof q.limit !== "undefined" && q.limit > 0 ? q.limit : 50,
 			},
@@ -22,6 +22,11 @@ declare global {
 		default_points: number;
 		visible: boolean;
 		time_limit: number;
+		time_limit_factor: number | null;
+		stack_limit: number;
+		compile_flags: string;
+		grader_compile_flags: string;
+		checker_compile_flags: string;
 		memory_limit: number;
 		source_credits: string;
 		source_size: number;
@@ -30,6 +35,9 @@ declare global {
 		score_precision: number;
 		published_at?: string;
 
+		objective_type: "" | "maximize" | "minimize";
+		objective_exponent: number;
+
 		score_scale: number;
 	};
 
@@ -75,6 +83,19 @@ declare global {
 
 		submission_type: "classic" | "acm-icpc";
 		icpc_verdict: string | null;
+
+		pretests_only: boolean;
+		num_files: number;
+		speed_factor: number | null;
+		cancelled: boolean;
+
+		language_version?: string | null;
+		sandbox_version?: string | null;
+		expected_verdict?: string | null;
+	};
+	type SubmissionFile = {
+		name: string;
+		data: string; // base64 encoded
 	};
 	type SubTest = {
 		id: number;
@@ -90,6 +111,8 @@ declare global {
 
 		visible_id: number;
 		score: number;
+
+		objective: number | null;
 	};
 
 	type SubmissionSubTask = {
@@ -105,6 +128,9 @@ declare global {
 		score: number;
 		final_percentage?: number;
 
+		aggregation: "min" | "sum" | "average" | "threshold";
+		threshold: number;
+
 		subtests: number[];
 	};
 
@@ -117,11 +143,20 @@ declare global {
 		subtasks: SubmissionSubTask[];
 
 		code: string;
+		files: SubmissionFile[] | null;
 
 		problem_editor: boolean;
+		speed_mismatch: boolean;
+		toolchains?: SubmissionToolchain[];
 		truly_visible: boolean;
 	};
 
+	type SubmissionToolchain = {
+		language_version: string | null;
+		sandbox_version: string | null;
+		evaluated_at: string;
+	};
+
 	// Contest types
 	type Question = {
 		id: number;
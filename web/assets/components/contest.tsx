@@ -64,6 +64,7 @@ type LeaderboardResponse = {
 		user: UserBrief;
 		scores: Record<number, number>;
 		total: number;
+		num_hacks: number;
 
 		num_solved: number;
 		penalty: number;
@@ -266,7 +267,17 @@ export function ContestLeaderboard({ contestID, editor }: { contestID: number; e
 									<td class="kn-table-cell">-</td>
 								)
 							)}
-							{leaderboard?.type == "classic" && <td class="kn-table-cell">{entry.total}</td>}
+							{leaderboard?.type == "classic" && (
+								<td class="kn-table-cell">
+									{entry.total}
+									{entry.num_hacks > 0 && (
+										<span class="text-sm text-muted" title={getText("hacks")}>
+											{" "}
+											(+{entry.num_hacks})
+										</span>
+									)}
+								</td>
+							)}
 						</tr>
 					))}
 					{leaderboard.entries.length == 0 && (
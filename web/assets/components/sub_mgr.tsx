@@ -15,7 +15,7 @@ const slugify = (str) =>
 
 import { BigSpinner, OlderSubmissions, formatScoreStr } from "./common";
 
-import { downloadBlob, parseTime, sizeFormatter, getGradient, fromBase64 } from "../util";
+import { dayjs, downloadBlob, parseTime, sizeFormatter, getGradient, fromBase64 } from "../util";
 import { defaultClient } from "../api/client";
 
 function downloadCode(sub: FullSubmission) {
@@ -141,12 +141,75 @@ function Summary({ sub, pasteAuthor }: { sub: FullSubmission; pasteAuthor?: User
 						<td class="kn-table-cell">{getText("status")}</td>
 						<td class="kn-table-cell">{sub.status}</td>
 					</tr>
+					{sub.pretests_only && (
+						<tr class="kn-table-simple-border">
+							<td class="kn-table-cell" colSpan={2}>
+								<i class="fas fa-fw fa-info-circle"></i> {getText("pretests_only")}
+							</td>
+						</tr>
+					)}
 					{sub.compile_time != null && (
 						<tr class="kn-table-simple-border">
 							<td class="kn-table-cell">{getText("compileTime")}</td>
 							<td class="kn-table-cell">{Math.floor(sub.compile_time * 1000)} ms</td>
 						</tr>
 					)}
+					{typeof sub.toolchains !== "undefined" && sub.toolchains.length > 1 && (
+						<tr class="kn-table-simple-border">
+							<td class="kn-table-cell">{getText("previousToolchains")}</td>
+							<td class="kn-table-cell">
+								<ul>
+									{sub.toolchains.slice(0, -1).map((tc) => (
+										<li key={tc.evaluated_at}>
+											{dayjs(tc.evaluated_at).format("DD/MM/YYYY HH:mm")}: <code>{tc.language_version ?? "-"}</code>, <code>{tc.sandbox_version ?? "-"}</code>
+										</li>
+									))}
+								</ul>
+							</td>
+						</tr>
+					)}
+					{sub.language_version != null && (
+						<tr class="kn-table-simple-border">
+							<td class="kn-table-cell">{getText("languageVersion")}</td>
+							<td class="kn-table-cell">
+								<a href={`/submissions?problem_id=${sub.problem_id}&language_version=${encodeURIComponent(sub.language_version)}`}>
+									<code>{sub.language_version}</code>
+								</a>
+							</td>
+						</tr>
+					)}
+					{sub.sandbox_version != null && (
+						<tr class="kn-table-simple-border">
+							<td class="kn-table-cell">{getText("sandboxVersion")}</td>
+							<td class="kn-table-cell">
+								<a href={`/submissions?problem_id=${sub.problem_id}&sandbox_version=${encodeURIComponent(sub.sandbox_version)}`}>
+									<code>{sub.sandbox_version}</code>
+								</a>
+							</td>
+						</tr>
+					)}
+					{sub.expected_verdict != null && (
+						<tr class="kn-table-simple-border">
+							<td class="kn-table-cell">{getText("expectedVerdict")}</td>
+							<td class="kn-table-cell">
+								<code>{sub.expected_verdict}</code>
+							</td>
+						</tr>
+					)}
+					{sub.cancelled && (
+						<tr class="kn-table-simple-border">
+							<td class="kn-table-cell" colSpan={2}>
+								<i class="fas fa-fw fa-exclamation-triangle"></i> {getText("evaluationCancelled")}
+							</td>
+						</tr>
+					)}
+					{sub.speed_mismatch && (
+						<tr class="kn-table-simple-border">
+							<td class="kn-table-cell" colSpan={2}>
+								<i class="fas fa-fw fa-exclamation-triangle"></i> {getText("speed_mismatch", sub.speed_factor?.toFixed(2), sub.problem.time_limit_factor?.toFixed(2))}
+							</td>
+						</tr>
+					)}
 				</tbody>
 			</table>
 		</div>
@@ -187,6 +250,8 @@ function CompileErrorInfo({ sub }: { sub: FullSubmission }) {
 
 function SubCode({ sub, codeHTML, isPaste }: { sub: FullSubmission; codeHTML: string; isPaste: boolean }) {
 	let [warningDismissed, setWarningDismissed] = useState<boolean>(sub.truly_visible || isPaste);
+	// -1 is the entrypoint, the other values are indices in sub.files
+	let [selectedFile, setSelectedFile] = useState<number>(-1);
 	if (!warningDismissed) {
 		return (
 			<div class="segment-panel">
@@ -202,7 +267,32 @@ function SubCode({ sub, codeHTML, isPaste }: { sub: FullSubmission; codeHTML: st
 	return (
 		<div class="segment-panel">
 			<h2>{getText("sourceCode")}:</h2>
-			{codeHTML.length > 0 ? (
+			{sub.files && sub.files.length > 0 && (
+				<div class="mb-2">
+					<a class={"p-1" + (selectedFile == -1 ? " topbar-selected" : "")} href="#" onClick={(e) => {
+							e.preventDefault();
+							setSelectedFile(-1);
+						}}>
+						{getText("entrypoint")}
+					</a>
+					{sub.files.map((file, idx) => (
+						<span key={file.name}>
+							<div class="topbar-separator"></div>
+							<a class={"p-1" + (selectedFile == idx ? " topbar-selected" : "")} href="#" onClick={(e) => {
+									e.preventDefault();
+									setSelectedFile(idx);
+								}}>
+								{file.name}
+							</a>
+						</span>
+					))}
+				</div>
+			)}
+			{sub.files && selectedFile >= 0 && selectedFile < sub.files.length ? (
+				<pre class="chroma">
+					<code>{fromBase64(sub.files[selectedFile].data)}</code>
+				</pre>
+			) : codeHTML.length > 0 ? (
 				<div dangerouslySetInnerHTML={{ __html: codeHTML }}></div>
 			) : (
 				<>
@@ -3,9 +3,10 @@ import getText from "../translation";
 import register from "preact-custom-element";
 import { useEffect, useMemo, useState } from "preact/hooks";
 import { apiToast, createToast } from "../toast";
+import { confirm } from "./modal";
 import { BigSpinner, Paginator, formatScoreStr } from "./common";
 import { dayjs, getGradient, sizeFormatter } from "../util";
-import { SubmissionQuery, Submissions, defaultClient } from "../api/client";
+import { SubmissionQuery, Submissions, defaultClient, postCall } from "../api/client";
 import { icpcVerdictString } from "./sub_mgr";
 
 export function rezStr(count: number, truncatedCount: boolean = false): string {
@@ -115,6 +116,8 @@ function getInitialData(overwrites: Overwrites): SubmissionQuery {
 		page: !isNaN(page) && page != 0 ? page : 1,
 
 		compile_error: compile_error,
+		language_version: params.get("language_version") ?? undefined,
+		sandbox_version: params.get("sandbox_version") ?? undefined,
 		ordering: ordering ? ordering : "id",
 		ascending: params.get("ascending") === "true",
 	};
@@ -223,6 +226,12 @@ function SubsView(props: SubsViewProps) {
 		if (typeof query.compile_error !== "undefined") {
 			p.append("compile_error", String(query.compile_error));
 		}
+		if (typeof query.language_version !== "undefined") {
+			p.append("language_version", query.language_version);
+		}
+		if (typeof query.sandbox_version !== "undefined") {
+			p.append("sandbox_version", query.sandbox_version);
+		}
 		if (typeof query.ordering !== "undefined" && query.ordering !== "id") {
 			p.append("ordering", query.ordering);
 		}
@@ -246,6 +255,23 @@ function SubsView(props: SubsViewProps) {
 		}
 	}
 
+	// The toolchain filters are only allowed for problem editors, so the results can be reevaluated from here
+	async function reevaluateToolchain() {
+		if (!(await confirm(getText("confirmToolchainReevaluate")))) {
+			return;
+		}
+		const res = await postCall<number>(`/problem/${query.problem_id}/reevaluateToolchain`, {
+			language_version: query.language_version,
+			sandbox_version: query.sandbox_version,
+		});
+		if (res.status !== "success") {
+			apiToast(res);
+			return;
+		}
+		createToast({ status: "success", title: getText("toolchainSubsReevaluated", res.data) });
+		await poll();
+	}
+
 	function doSort(type: string) {
 		let ordering = typeof query.ordering === "undefined" ? "id" : query.ordering;
 		let ascending = typeof query.ascending === "undefined" ? false : query.ascending;
@@ -486,6 +512,12 @@ function SubsView(props: SubsViewProps) {
 					<button class="btn mb-4" onClick={async () => await copyQuery()}>
 						{getText("filterLink")}
 					</button>
+					{typeof query.problem_id !== "undefined" &&
+						(typeof query.language_version !== "undefined" || typeof query.sandbox_version !== "undefined") && (
+							<button class="btn btn-red mb-4" onClick={() => reevaluateToolchain().catch(console.error)}>
+								{getText("reevaluateToolchain")}
+							</button>
+						)}
 				</div>
 			</aside>
 			<div class="page-content">
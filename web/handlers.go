@@ -546,8 +546,9 @@ func (rt *Web) debugPage() http.HandlerFunc {
 		rt.runTempl(w, r, templ, &struct {
 			Metrics []*Metric
 
-			BucketStats []*datastore.BucketStats
-		}{finalMetrics, stats})
+			BucketStats    []*datastore.BucketStats
+			Recompressions map[string]*sudoapi.RecompressionStatus
+		}{finalMetrics, stats, rt.base.Recompressions()})
 	}
 }
 
@@ -1305,6 +1306,40 @@ func (rt *Web) contestCommunication() http.HandlerFunc {
 	}
 }
 
+func (rt *Web) contestHacks() http.HandlerFunc {
+	templ := rt.parse(nil, "contest/hacks.html", "problem/topbar.html")
+	return func(w http.ResponseWriter, r *http.Request) {
+		contest, user := util.Contest(r), util.UserBrief(r)
+		if !contest.AllowHacking {
+			rt.statusPage(w, r, 400, "Hacking is not enabled for this contest")
+			return
+		}
+
+		filter := kilonova.HackFilter{ContestID: &contest.ID, Limit: 100}
+		if !rt.base.IsContestEditor(user, contest) {
+			if !user.IsAuthed() {
+				rt.statusPage(w, r, 401, "You must be logged in to view hacks")
+				return
+			}
+			filter.InvolvedUserID = &user.ID
+		}
+		hacks, err := rt.base.Hacks(r.Context(), filter)
+		if err != nil {
+			zap.S().Warn(err)
+			hacks = []*kilonova.Hack{}
+		}
+
+		rt.runTempl(w, r, templ, &ContestParams{
+			Topbar: rt.problemTopbar(r, "contest_hacks", -1),
+
+			Contest: contest,
+
+			Hacks:          hacks,
+			LockedProblems: rt.base.ContestLockedProblems(r.Context(), contest, user),
+		})
+	}
+}
+
 func (rt *Web) contestRegistrations() http.HandlerFunc {
 	templ := rt.parse(nil, "contest/registrations.html", "problem/topbar.html")
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -1373,7 +1408,17 @@ func (rt *Web) graderInfo() http.HandlerFunc {
 			})
 		}
 		slices.SortFunc(langs, func(a, b *GraderInfoLanguage) int { return cmp.Compare(a.Name, b.Name) })
-		rt.runTempl(w, r, templ, &GraderInfoParams{langs})
+		hosts, err := rt.base.GraderHosts(r.Context())
+		if err != nil {
+			zap.S().Warn(err)
+			hosts = []*kilonova.GraderHost{}
+		}
+		rt.runTempl(w, r, templ, &GraderInfoParams{
+			Languages: langs,
+
+			SpeedFactor: rt.base.GraderSpeedFactor(),
+			Hosts:       hosts,
+		})
 	}
 }
 
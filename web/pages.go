@@ -112,6 +112,9 @@ type GraderInfoLanguage struct {
 
 type GraderInfoParams struct {
 	Languages []*GraderInfoLanguage
+
+	SpeedFactor *float64
+	Hosts       []*kilonova.GraderHost
 }
 
 type DonateParams struct {
@@ -129,6 +132,9 @@ type ContestParams struct {
 
 	ContestInvitations []*kilonova.ContestInvitation
 	MOSSResults        []*kilonova.MOSSSubmission
+
+	Hacks          []*kilonova.Hack
+	LockedProblems []int
 }
 
 type ContestInviteParams struct {
@@ -18,6 +18,7 @@ import (
 	"os"
 	"path"
 	"reflect"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -202,6 +203,7 @@ func (rt *Web) Handler() http.Handler {
 				r.Get("/communication", rt.contestCommunication())
 
 				r.Get("/leaderboard", rt.contestLeaderboard())
+				r.Get("/hacks", rt.contestHacks())
 
 				r.Route("/manage", func(r chi.Router) {
 					r.Use(rt.mustBeContestEditor)
@@ -689,6 +691,9 @@ func NewWeb(base *sudoapi.BaseAPI) *Web {
 			}
 			return b.String()
 		},
+		"intListContains": func(ids []int, id int) bool {
+			return slices.Contains(ids, id)
+		},
 		"stringList": func(vals []string) string {
 			return strings.Join(vals, ", ")
 		},